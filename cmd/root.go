@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -47,6 +48,9 @@ var rootCmd = &cobra.Command{
 			GRPCBindAddr:        viper.GetString("grpc-bind-addr"),
 			GRPCLogging:         viper.GetBool("grpc-logging-insecure"),
 			HTTPBindAddr:        viper.GetString("http-bind-addr"),
+			GRPCWebBindAddr:     viper.GetString("grpc-web-bind-addr"),
+			GRPCWebOrigins:      viper.GetString("grpc-web-origins"),
+			RESTEnable:          viper.GetBool("rest-enable"),
 			TLSCertPath:         viper.GetString("tls-cert"),
 			TLSKeyPath:          viper.GetString("tls-key"),
 			LogLevel:            viper.GetUint64("log-level"),
@@ -64,6 +68,13 @@ var rootCmd = &cobra.Command{
 			PingEnable:          viper.GetBool("ping-very-insecure"),
 			Darkside:            viper.GetBool("darkside-very-insecure"),
 			DarksideTimeout:     viper.GetUint64("darkside-timeout"),
+			TaddrTxidsWorkers:   viper.GetInt("taddr-txids-workers"),
+			TaddrTxidsMax:       viper.GetInt("taddr-txids-max"),
+			BlockRangeMax:       viper.GetInt("block-range-max"),
+			BlockBundles:        viper.GetBool("block-bundles"),
+			DonationAddress:     viper.GetString("donation-address"),
+			OperatorContact:     viper.GetString("operator-contact"),
+			DisableMethods:      viper.GetString("disable-methods"),
 		}
 
 		common.Log.Debugf("Options: %#v\n", opts)
@@ -89,6 +100,13 @@ var rootCmd = &cobra.Command{
 			}
 		}
 
+		// Browsers send an Origin header with every gRPC-Web request, so
+		// there's no use serving it without any allowed origins.
+		if opts.GRPCWebBindAddr != "" && strings.Trim(opts.GRPCWebOrigins, ", ") == "" {
+			os.Stderr.WriteString("\n  ** grpc-web-origins must list the origins allowed to use gRPC-Web\n\n")
+			common.Log.Fatal("grpc-web-bind-addr is given without grpc-web-origins")
+		}
+
 		// Start server and block, or exit
 		if err := startServer(opts); err != nil {
 			common.Log.WithFields(logrus.Fields{
@@ -131,6 +149,16 @@ func startServer(opts *common.Options) error {
 	promRegistry.MustRegister(common.Metrics.ArrrPriceGauge)
 	promRegistry.MustRegister(common.Metrics.ArrrPriceHistoryWebAPICounter)
 	promRegistry.MustRegister(common.Metrics.ArrrPriceHistoryErrors)
+	promRegistry.MustRegister(common.Metrics.RebroadcastQueueGauge)
+	promRegistry.MustRegister(common.Metrics.RebroadcastsCounter)
+	promRegistry.MustRegister(common.Metrics.RebroadcastDroppedCounter)
+	promRegistry.MustRegister(common.Metrics.MempoolSizeGauge)
+	promRegistry.MustRegister(common.Metrics.MempoolBytesGauge)
+	promRegistry.MustRegister(common.Metrics.MempoolShieldedTxsGauge)
+	promRegistry.MustRegister(common.Metrics.MempoolOldestAgeGauge)
+	promRegistry.MustRegister(common.Metrics.MempoolAddedCounter)
+	promRegistry.MustRegister(common.Metrics.MempoolRemovedCounter)
+	promRegistry.MustRegister(common.Metrics.BlockRangesInFlightGauge)
 
 	logger.SetLevel(logrus.Level(opts.LogLevel))
 
@@ -144,25 +172,29 @@ func startServer(opts *common.Options) error {
 
 	// gRPC initialization
 	var server *grpc.Server
+	var tlsCert *tls.Certificate // nil unless self-signed
+
+	// The REST gateway uses these too.
+	streamInterceptor := grpc_middleware.ChainStreamServer(
+		grpc_prometheus.StreamServerInterceptor,
+		frontend.MethodFilterStreamInterceptor)
+	unaryInterceptor := grpc_middleware.ChainUnaryServer(
+		logging.LogInterceptor,
+		grpc_prometheus.UnaryServerInterceptor,
+		frontend.MethodFilterUnaryInterceptor)
 
 	if opts.NoTLSVeryInsecure {
 		common.Log.Warningln("Starting insecure no-TLS (plaintext) server")
 		fmt.Println("Starting insecure server")
 		server = grpc.NewServer(
-			grpc.StreamInterceptor(
-				grpc_middleware.ChainStreamServer(
-					grpc_prometheus.StreamServerInterceptor),
-			),
-			grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-				logging.LogInterceptor,
-				grpc_prometheus.UnaryServerInterceptor),
-			))
+			grpc.StreamInterceptor(streamInterceptor),
+			grpc.UnaryInterceptor(unaryInterceptor))
 	} else {
 		var transportCreds credentials.TransportCredentials
 		if opts.GenCertVeryInsecure {
 			common.Log.Warning("Certificate and key not provided, generating self signed values")
 			fmt.Println("Starting insecure self-certificate server")
-			tlsCert := common.GenerateCerts()
+			tlsCert = common.GenerateCerts()
 			transportCreds = credentials.NewServerTLSFromCert(tlsCert)
 		} else {
 			var err error
@@ -177,13 +209,8 @@ func startServer(opts *common.Options) error {
 		}
 		server = grpc.NewServer(
 			grpc.Creds(transportCreds),
-			grpc.StreamInterceptor(grpc_middleware.ChainStreamServer(
-				grpc_prometheus.StreamServerInterceptor),
-			),
-			grpc.UnaryInterceptor(grpc_middleware.ChainUnaryServer(
-				logging.LogInterceptor,
-				grpc_prometheus.UnaryServerInterceptor),
-			))
+			grpc.StreamInterceptor(streamInterceptor),
+			grpc.UnaryInterceptor(unaryInterceptor))
 	}
 	grpc_prometheus.EnableHandlingTimeHistogram()
 	grpc_prometheus.Register(server)
@@ -270,13 +297,36 @@ func startServer(opts *common.Options) error {
 
 	// Compact transaction service initialization
 	{
-		service, err := frontend.NewLwdStreamer(cache, chainName, opts.PingEnable)
+		frontend.BlockRangeMaxBlocks = opts.BlockRangeMax
+		frontend.DonationAddress = opts.DonationAddress
+		frontend.OperatorContact = opts.OperatorContact
+		if err := frontend.ConfigureMethods(methodSettings(opts)); err != nil {
+			common.Log.WithFields(logrus.Fields{
+				"error": err,
+			}).Fatal("invalid methods configuration")
+		}
+		frontend.Features = nil
+		if opts.RESTEnable {
+			frontend.Features = append(frontend.Features, "rest")
+		}
+		if opts.GRPCWebBindAddr != "" {
+			frontend.Features = append(frontend.Features, "grpc-web")
+		}
+		if opts.BlockBundles && !opts.Darkside {
+			frontend.Features = append(frontend.Features, "block-bundles")
+		}
+		service, err := frontend.NewLwdStreamer(cache, chainName, opts.PingEnable, opts.TaddrTxidsWorkers, opts.TaddrTxidsMax)
 		if err != nil {
 			common.Log.WithFields(logrus.Fields{
 				"error": err,
 			}).Fatal("couldn't create backend")
 		}
 		walletrpc.RegisterCompactTxStreamerServer(server, service)
+
+		if opts.RESTEnable {
+			// Served by startHTTPServer, alongside /metrics and /blocks/.
+			http.Handle("/api/v1/", frontend.NewRESTHandler(service, unaryInterceptor, streamInterceptor))
+		}
 	}
 	if opts.Darkside {
 		service, err := frontend.NewDarksideStreamer(cache)
@@ -291,6 +341,16 @@ func startServer(opts *common.Options) error {
 	// Initialize price fetcher
 	common.StartPriceFetcher(dbPath, chainName)
 
+	common.StartNoteTreeBuilder(cache)
+	if !opts.Darkside {
+		common.StartRebroadcaster(cache, dbPath, chainName)
+		common.StartOrchardTreeVerifier(cache)
+		if opts.BlockBundles {
+			common.StartBlockBundler(cache, dbPath, chainName)
+		}
+	}
+	common.StartMempool()
+
 	// Start listening
 	listener, err := net.Listen("tcp", opts.GRPCBindAddr)
 	if err != nil {
@@ -300,12 +360,17 @@ func startServer(opts *common.Options) error {
 		}).Fatal("couldn't create listener")
 	}
 
+	if opts.GRPCWebBindAddr != "" {
+		go startGRPCWebServer(opts, server, tlsCert)
+	}
+
 	// Signal handler for graceful stops
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		s := <-signals
 		cache.Sync()
+		common.SaveRebroadcastQueue()
 		common.Log.WithFields(logrus.Fields{
 			"signal": s.String(),
 		}).Info("caught signal, stopping gRPC server")
@@ -336,6 +401,9 @@ func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is current directory, lightwalletd.yaml)")
 	rootCmd.Flags().String("http-bind-addr", "127.0.0.1:9068", "the address to listen for http on")
+	rootCmd.Flags().String("grpc-web-bind-addr", "", "the address to listen for gRPC-Web (browser) requests on (disabled if empty)")
+	rootCmd.Flags().Bool("rest-enable", false, "serve the REST (JSON) gateway under /api/v1/ on the http-bind-addr server")
+	rootCmd.Flags().String("grpc-web-origins", "", "comma-separated origins allowed to make gRPC-Web requests, required with grpc-web-bind-addr (* allows any)")
 	rootCmd.Flags().String("grpc-bind-addr", "127.0.0.1:9067", "the address to listen for grpc on")
 	rootCmd.Flags().Bool("grpc-logging-insecure", false, "enable grpc logging to stderr")
 	rootCmd.Flags().String("tls-cert", "./cert.pem", "the path to a TLS certificate")
@@ -355,6 +423,13 @@ func init() {
 	rootCmd.Flags().Bool("ping-very-insecure", false, "allow Ping GRPC for testing")
 	rootCmd.Flags().Bool("darkside-very-insecure", false, "run with GRPC-controllable mock pirated for integration testing (shuts down after 30 minutes)")
 	rootCmd.Flags().Int("darkside-timeout", 30, "override 30 minute default darkside timeout")
+	rootCmd.Flags().Int("taddr-txids-workers", 8, "number of transactions GetTaddressTxids fetches from pirated concurrently")
+	rootCmd.Flags().Int("taddr-txids-max", 0, "about the most transactions GetTaddressTxids returns per request, with a continuation for the rest (0 means no limit)")
+	rootCmd.Flags().Int("block-range-max", 0, "most blocks GetBlockRange and SyncBlocks return per request, longer ranges are continued in later requests (0 means no limit)")
+	rootCmd.Flags().Bool("block-bundles", false, "pack finalized compact blocks into bundle files, served over HTTP under /blocks/")
+	rootCmd.Flags().String("donation-address", "", "the operator's donation address, reported by GetLightdInfo")
+	rootCmd.Flags().String("operator-contact", "", "how to contact the operator (such as an email address), reported by GetLightdInfo")
+	rootCmd.Flags().String("disable-methods", "", "comma-separated CompactTxStreamer methods, or groups (taddr, prices), to disable (see also the config file's methods section)")
 
 	viper.BindPFlag("grpc-bind-addr", rootCmd.Flags().Lookup("grpc-bind-addr"))
 	viper.SetDefault("grpc-bind-addr", "127.0.0.1:9067")
@@ -362,6 +437,12 @@ func init() {
 	viper.SetDefault("grpc-logging-insecure", false)
 	viper.BindPFlag("http-bind-addr", rootCmd.Flags().Lookup("http-bind-addr"))
 	viper.SetDefault("http-bind-addr", "127.0.0.1:9068")
+	viper.BindPFlag("grpc-web-bind-addr", rootCmd.Flags().Lookup("grpc-web-bind-addr"))
+	viper.SetDefault("grpc-web-bind-addr", "")
+	viper.BindPFlag("rest-enable", rootCmd.Flags().Lookup("rest-enable"))
+	viper.SetDefault("rest-enable", false)
+	viper.BindPFlag("grpc-web-origins", rootCmd.Flags().Lookup("grpc-web-origins"))
+	viper.SetDefault("grpc-web-origins", "")
 	viper.BindPFlag("tls-cert", rootCmd.Flags().Lookup("tls-cert"))
 	viper.SetDefault("tls-cert", "./cert.pem")
 	viper.BindPFlag("tls-key", rootCmd.Flags().Lookup("tls-key"))
@@ -392,6 +473,20 @@ func init() {
 	viper.SetDefault("darkside-very-insecure", false)
 	viper.BindPFlag("darkside-timeout", rootCmd.Flags().Lookup("darkside-timeout"))
 	viper.SetDefault("darkside-timeout", 30)
+	viper.BindPFlag("taddr-txids-workers", rootCmd.Flags().Lookup("taddr-txids-workers"))
+	viper.SetDefault("taddr-txids-workers", 8)
+	viper.BindPFlag("taddr-txids-max", rootCmd.Flags().Lookup("taddr-txids-max"))
+	viper.SetDefault("taddr-txids-max", 0)
+	viper.BindPFlag("block-range-max", rootCmd.Flags().Lookup("block-range-max"))
+	viper.SetDefault("block-range-max", 0)
+	viper.BindPFlag("block-bundles", rootCmd.Flags().Lookup("block-bundles"))
+	viper.SetDefault("block-bundles", false)
+	viper.BindPFlag("donation-address", rootCmd.Flags().Lookup("donation-address"))
+	viper.SetDefault("donation-address", "")
+	viper.BindPFlag("operator-contact", rootCmd.Flags().Lookup("operator-contact"))
+	viper.SetDefault("operator-contact", "")
+	viper.BindPFlag("disable-methods", rootCmd.Flags().Lookup("disable-methods"))
+	viper.SetDefault("disable-methods", "")
 
 	logger.SetFormatter(&logrus.TextFormatter{
 		//DisableColors:          true,
@@ -451,5 +546,70 @@ func startHTTPServer(opts *common.Options) {
 	// Add the params download handler
 	http.HandleFunc("/params/", common.ParamsHandler)
 
+	// Add the compact block bundles handler
+	http.HandleFunc("/blocks/", common.BlockBundleHandler)
+
 	http.ListenAndServe(opts.HTTPBindAddr, nil)
 }
+
+// methodSettings returns which CompactTxStreamer methods (or groups) are
+// enabled or disabled, from the config file's methods section, such as
+//
+//	methods:
+//	  taddr: false
+//	  GetAddressUtxos: true
+//
+// and the disable-methods option (see frontend.ConfigureMethods).
+func methodSettings(opts *common.Options) map[string]bool {
+	settings := make(map[string]bool)
+	for name, value := range viper.GetStringMap("methods") {
+		enabled, ok := value.(bool)
+		if !ok {
+			common.Log.Fatal("methods setting for ", name, " must be true or false")
+		}
+		settings[name] = enabled
+	}
+	// Names are case-insensitive (viper lowercases the config file's), so
+	// disable-methods overrides the config file regardless of case.
+	for _, name := range strings.Split(opts.DisableMethods, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			settings[strings.ToLower(name)] = false
+		}
+	}
+	return settings
+}
+
+// startGRPCWebServer serves the gRPC services to browsers, using gRPC-Web,
+// with the same TLS settings as the gRPC server.
+func startGRPCWebServer(opts *common.Options, server *grpc.Server, tlsCert *tls.Certificate) {
+	var origins []string
+	for _, origin := range strings.Split(opts.GRPCWebOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	webServer := &http.Server{
+		Addr:    opts.GRPCWebBindAddr,
+		Handler: frontend.NewGRPCWebHandler(server, origins),
+	}
+	common.Log.Info("Starting gRPC-Web server on ", opts.GRPCWebBindAddr)
+	err := listenAndServe(opts, webServer, tlsCert)
+	common.Log.WithFields(logrus.Fields{
+		"bind_addr": opts.GRPCWebBindAddr,
+		"error":     err,
+	}).Fatal("gRPC-Web server exited")
+}
+
+// listenAndServe runs the given server with the same TLS settings as the
+// gRPC server, returning when it fails.
+func listenAndServe(opts *common.Options, server *http.Server, tlsCert *tls.Certificate) error {
+	switch {
+	case opts.NoTLSVeryInsecure:
+		return server.ListenAndServe()
+	case tlsCert != nil:
+		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*tlsCert}}
+		return server.ListenAndServeTLS("", "")
+	default:
+		return server.ListenAndServeTLS(opts.TLSCertPath, opts.TLSKeyPath)
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package commitmenttree
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// Arithmetic on the Jubjub curve, a twisted Edwards curve
+//   -u^2 + v^2 = 1 + d*u^2*v^2
+// over the BLS12-381 scalar field F_r. See section 5.4.9.3 of the Zcash
+// protocol specification.
+
+// fe is an element of F_r in Montgomery form (a*2^256 mod r), as
+// little-endian 64-bit limbs.
+type fe [4]uint64
+
+var (
+	frModulus, _ = new(big.Int).SetString("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001", 16)
+
+	frR    = new(big.Int).Lsh(big.NewInt(1), 256) // Montgomery R
+	frRInv = new(big.Int).ModInverse(frR, frModulus)
+
+	feZero fe
+	feOne  = feFromBig(big.NewInt(1))
+
+	// d = -(10240/10241)
+	jubjubD = func() *big.Int {
+		d := new(big.Int).ModInverse(big.NewInt(10241), frModulus)
+		d.Mul(d, big.NewInt(-10240))
+		return d.Mod(d, frModulus)
+	}()
+	jubjubD2 = feFromBig(new(big.Int).Mod(new(big.Int).Lsh(jubjubD, 1), frModulus))
+)
+
+// The limbs of r, and -r^-1 mod 2^64, as constants for speed.
+const (
+	fr0      = 0xffffffff00000001
+	fr1      = 0x53bda402fffe5bfe
+	fr2      = 0x3339d80809a1d805
+	fr3      = 0x73eda753299d7d48
+	frNegInv = 0xfffffffeffffffff
+)
+
+func bigToLimbs(x *big.Int) (z [4]uint64) {
+	b := x.FillBytes(make([]byte, 32))
+	for i := range z {
+		for j := 0; j < 8; j++ {
+			z[i] |= uint64(b[31-8*i-j]) << uint(8*j)
+		}
+	}
+	return z
+}
+
+func limbsToBig(z [4]uint64) *big.Int {
+	b := make([]byte, 32)
+	for i := range z {
+		for j := 0; j < 8; j++ {
+			b[31-8*i-j] = byte(z[i] >> uint(8*j))
+		}
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+func feFromBig(x *big.Int) fe {
+	m := new(big.Int).Mul(x, frR)
+	return fe(bigToLimbs(m.Mod(m, frModulus)))
+}
+
+func (z *fe) toBig() *big.Int {
+	x := new(big.Int).Mul(limbsToBig(*z), frRInv)
+	return x.Mod(x, frModulus)
+}
+
+// subModulusIfNeeded reduces z, which must be less than 2r (given by the
+// limbs and the carry out of them), modulo r.
+func (z *fe) subModulusIfNeeded(carry uint64) {
+	var t fe
+	var borrow uint64
+	t[0], borrow = bits.Sub64(z[0], fr0, 0)
+	t[1], borrow = bits.Sub64(z[1], fr1, borrow)
+	t[2], borrow = bits.Sub64(z[2], fr2, borrow)
+	t[3], borrow = bits.Sub64(z[3], fr3, borrow)
+	if carry != 0 || borrow == 0 {
+		*z = t
+	}
+}
+
+func (z *fe) add(x, y *fe) {
+	var carry uint64
+	z[0], carry = bits.Add64(x[0], y[0], 0)
+	z[1], carry = bits.Add64(x[1], y[1], carry)
+	z[2], carry = bits.Add64(x[2], y[2], carry)
+	z[3], carry = bits.Add64(x[3], y[3], carry)
+	z.subModulusIfNeeded(carry)
+}
+
+func (z *fe) sub(x, y *fe) {
+	var borrow uint64
+	z[0], borrow = bits.Sub64(x[0], y[0], 0)
+	z[1], borrow = bits.Sub64(x[1], y[1], borrow)
+	z[2], borrow = bits.Sub64(x[2], y[2], borrow)
+	z[3], borrow = bits.Sub64(x[3], y[3], borrow)
+	if borrow != 0 {
+		var carry uint64
+		z[0], carry = bits.Add64(z[0], fr0, 0)
+		z[1], carry = bits.Add64(z[1], fr1, carry)
+		z[2], carry = bits.Add64(z[2], fr2, carry)
+		z[3], _ = bits.Add64(z[3], fr3, carry)
+	}
+}
+
+func (z *fe) neg(x *fe) {
+	z.sub(&feZero, x)
+}
+
+// madd returns a*b + c + d as (hi, lo).
+func madd(a, b, c, d uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	var carry uint64
+	lo, carry = bits.Add64(lo, c, 0)
+	hi += carry
+	lo, carry = bits.Add64(lo, d, 0)
+	hi += carry
+	return hi, lo
+}
+
+// mul sets z to x*y (Montgomery multiplication, CIOS method).
+func (z *fe) mul(x, y *fe) {
+	var t0, t1, t2, t3, t4, t5, c, carry uint64
+	for _, yi := range y {
+		c, t0 = madd(x[0], yi, t0, 0)
+		c, t1 = madd(x[1], yi, t1, c)
+		c, t2 = madd(x[2], yi, t2, c)
+		c, t3 = madd(x[3], yi, t3, c)
+		t4, t5 = bits.Add64(t4, c, 0)
+
+		m := t0 * frNegInv
+		c, _ = madd(m, fr0, t0, 0)
+		c, t0 = madd(m, fr1, t1, c)
+		c, t1 = madd(m, fr2, t2, c)
+		c, t2 = madd(m, fr3, t3, c)
+		t3, carry = bits.Add64(t4, c, 0)
+		t4 = t5 + carry
+	}
+	*z = fe{t0, t1, t2, t3}
+	z.subModulusIfNeeded(t4)
+}
+
+// point is a Jubjub point in extended twisted Edwards coordinates
+// (X:Y:Z:T), with u = X/Z, v = Y/Z and u*v = T/Z.
+type point struct {
+	x, y, z, t fe
+}
+
+// niels is a precomputed point, (v-u, v+u, 2*d*u*v), for fast addition.
+type niels struct {
+	vMinusU, vPlusU, t2d fe
+}
+
+func identity() point {
+	return point{x: feZero, y: feOne, z: feOne, t: feZero}
+}
+
+// affinePoint is a Jubjub point in affine coordinates, used only during
+// setup (where speed doesn't matter).
+type affinePoint struct {
+	u, v *big.Int
+}
+
+func (p affinePoint) add(q affinePoint) affinePoint {
+	r := frModulus
+	uu := new(big.Int).Mul(p.u, q.u)
+	vv := new(big.Int).Mul(p.v, q.v)
+	duuvv := new(big.Int).Mul(jubjubD, uu)
+	duuvv.Mul(duuvv, vv).Mod(duuvv, r)
+
+	u := new(big.Int).Mul(p.u, q.v)
+	u.Add(u, new(big.Int).Mul(p.v, q.u))
+	den := new(big.Int).Add(big.NewInt(1), duuvv)
+	u.Mul(u, den.ModInverse(den, r)).Mod(u, r)
+
+	// a = -1, so v = (v1*v2 + u1*u2) / (1 - d*u1*u2*v1*v2)
+	v := new(big.Int).Add(vv, uu)
+	den = new(big.Int).Sub(big.NewInt(1), duuvv)
+	den.Mod(den, r)
+	v.Mul(v, den.ModInverse(den, r)).Mod(v, r)
+	return affinePoint{u, v}
+}
+
+func (p affinePoint) isIdentity() bool {
+	return p.u.Sign() == 0 && p.v.Cmp(big.NewInt(1)) == 0
+}
+
+func (p affinePoint) niels() niels {
+	u, v := feFromBig(p.u), feFromBig(p.v)
+	var n niels
+	n.vMinusU.sub(&v, &u)
+	n.vPlusU.add(&v, &u)
+	n.t2d.mul(&u, &v)
+	n.t2d.mul(&n.t2d, &jubjubD2)
+	return n
+}
+
+// decodePoint implements abst_J: it returns the point whose encoding
+// (the v-coordinate, with the sign of u in the top bit) is b, or false.
+func decodePoint(b [32]byte) (affinePoint, bool) {
+	r := frModulus
+	sign := uint(b[31] >> 7)
+	b[31] &= 0x7f
+	le := make([]byte, 32)
+	for i := range le {
+		le[i] = b[31-i]
+	}
+	v := new(big.Int).SetBytes(le)
+	if v.Cmp(r) >= 0 {
+		return affinePoint{}, false
+	}
+	// u^2 = (v^2 - 1) / (d*v^2 + 1)
+	vv := new(big.Int).Mul(v, v)
+	num := new(big.Int).Sub(vv, big.NewInt(1))
+	den := new(big.Int).Mul(jubjubD, vv)
+	den.Add(den, big.NewInt(1)).Mod(den, r)
+	if den.Sign() == 0 {
+		return affinePoint{}, false
+	}
+	uu := num.Mul(num, den.ModInverse(den, r))
+	uu.Mod(uu, r)
+	u := new(big.Int).ModSqrt(uu, r)
+	if u == nil {
+		return affinePoint{}, false
+	}
+	if u.Sign() == 0 && sign == 1 {
+		return affinePoint{}, false
+	}
+	if u.Bit(0) != sign {
+		u.Sub(r, u)
+	}
+	return affinePoint{u, v}, true
+}
+
+// addNiels sets p to p + q (add-2008-hwcd-3, which is complete for a = -1).
+func (p *point) addNiels(q *niels) {
+	var a, b, c, d, e, f, g, h fe
+	a.sub(&p.y, &p.x)
+	a.mul(&a, &q.vMinusU)
+	b.add(&p.y, &p.x)
+	b.mul(&b, &q.vPlusU)
+	c.mul(&p.t, &q.t2d)
+	d.add(&p.z, &p.z)
+	e.sub(&b, &a)
+	f.sub(&d, &c)
+	g.add(&d, &c)
+	h.add(&b, &a)
+	p.x.mul(&e, &f)
+	p.y.mul(&g, &h)
+	p.t.mul(&e, &h)
+	p.z.mul(&f, &g)
+}
+
+// subNiels sets p to p - q.
+func (p *point) subNiels(q *niels) {
+	neg := niels{vMinusU: q.vPlusU, vPlusU: q.vMinusU}
+	neg.t2d.neg(&q.t2d)
+	p.addNiels(&neg)
+}
+
+// u returns the affine u-coordinate of p, as 32 little-endian bytes
+// (this is Extract_J).
+func (p *point) u() [32]byte {
+	zInv := new(big.Int).ModInverse(p.z.toBig(), frModulus)
+	u := new(big.Int).Mul(p.x.toBig(), zInv)
+	u.Mod(u, frModulus)
+	be := u.FillBytes(make([]byte, 32))
+	var out [32]byte
+	for i := range out {
+		out[i] = be[31-i]
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package commitmenttree
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// The Orchard note commitment tree hash, MerkleCRH^Orchard, is a Sinsemilla
+// hash over Pallas; see sections 5.4.1.3 and 5.4.1.9 of the Zcash protocol
+// specification.
+
+const (
+	sinsemillaK = 10 // bits per chunk
+
+	orchardMerkleDomain = "z.cash:Orchard-MerkleCRH"
+
+	// A Merkle hash input is 10 + 2*255 bits.
+	orchardMerkleHashChunks = (10 + 2*255) / sinsemillaK
+	orchardUncommitted      = 2 // the x-coordinate used for empty leaves
+)
+
+var (
+	sinsemillaQ    pallasAffine
+	sinsemillaS    [1 << sinsemillaK]pallasAffine
+	sinsemillaOnce sync.Once
+)
+
+func initSinsemilla() {
+	sinsemillaQ = groupHashP("z.cash:SinsemillaQ", []byte(orchardMerkleDomain))
+	for j := range sinsemillaS {
+		var index [4]byte
+		binary.LittleEndian.PutUint32(index[:], uint32(j))
+		sinsemillaS[j] = groupHashP("z.cash:SinsemillaS", index[:])
+	}
+}
+
+// orchardMerkleHash returns MerkleCRH^Orchard(layer, left, right), where
+// level is MerkleDepth - 1 - layer (zero for combining two leaves).
+func orchardMerkleHash(level int, left, right Node) Node {
+	sinsemillaOnce.Do(initSinsemilla)
+
+	// The input is I2LEBSP_10(level) || I2LEBSP_255(left) || I2LEBSP_255(right).
+	var input [(orchardMerkleHashChunks*sinsemillaK + 7) / 8]byte
+	bitPos := 0
+	put := func(bit uint) {
+		input[bitPos/8] |= byte(bit << uint(bitPos%8))
+		bitPos++
+	}
+	for i := 0; i < sinsemillaK; i++ {
+		put(uint(level>>uint(i)) & 1)
+	}
+	for _, n := range []Node{left, right} {
+		r := bitReader{b: n[:]}
+		for i := 0; i < 255; i++ {
+			put(r.next())
+		}
+	}
+
+	// acc = (acc + S(m_i)) + acc, for each chunk m_i, starting from Q.
+	acc := pallasPoint{x: sinsemillaQ.x, y: sinsemillaQ.y, z: fpOne}
+	r := bitReader{b: input[:]}
+	for c := 0; c < orchardMerkleHashChunks; c++ {
+		m := 0
+		for i := 0; i < sinsemillaK; i++ {
+			m |= int(r.next()) << uint(i)
+		}
+		t := acc
+		t.addAffine(&sinsemillaS[m])
+		t.add(&acc)
+		acc = t
+	}
+	return acc.extract()
+}
+
+type orchardHasher struct {
+	once  sync.Once
+	empty [Depth + 1]Node
+}
+
+func (h *orchardHasher) Combine(level int, left, right Node) Node {
+	return orchardMerkleHash(level, left, right)
+}
+
+func (h *orchardHasher) Empty(level int) Node {
+	h.once.Do(func() {
+		h.empty[0][0] = orchardUncommitted
+		for i := 1; i <= Depth; i++ {
+			h.empty[i] = orchardMerkleHash(i-1, h.empty[i-1], h.empty[i-1])
+		}
+	})
+	return h.empty[level]
+}
+
+// Orchard is the Hasher for the Orchard note commitment tree.
+var Orchard Hasher = &orchardHasher{}
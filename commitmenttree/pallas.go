@@ -0,0 +1,434 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package commitmenttree
+
+import (
+	"math/big"
+	"math/bits"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// Arithmetic on the Pallas curve
+//   y^2 = x^3 + 5
+// over F_p, and GroupHash^P, which hashes to it; see sections 5.4.9.6 and
+// 5.4.9.8 of the Zcash protocol specification.
+
+// fp is an element of F_p in Montgomery form (a*2^256 mod p), as
+// little-endian 64-bit limbs.
+type fp [4]uint64
+
+var (
+	fpModulus, _ = new(big.Int).SetString("40000000000000000000000000000000224698fc094cf91b992d30ed00000001", 16)
+
+	fpRInv = new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), 256), fpModulus)
+
+	fpZero fp
+	fpOne  = fpFromBig(big.NewInt(1))
+)
+
+// The limbs of p, and -p^-1 mod 2^64, as constants for speed.
+const (
+	fp0      = 0x992d30ed00000001
+	fp1      = 0x224698fc094cf91b
+	fp2      = 0x0000000000000000
+	fp3      = 0x4000000000000000
+	fpNegInv = 0x992d30ecffffffff
+)
+
+func fpFromBig(x *big.Int) fp {
+	m := new(big.Int).Lsh(x, 256)
+	return fp(bigToLimbs(m.Mod(m, fpModulus)))
+}
+
+func (z *fp) toBig() *big.Int {
+	x := new(big.Int).Mul(limbsToBig(*z), fpRInv)
+	return x.Mod(x, fpModulus)
+}
+
+// subModulusIfNeeded reduces z, which must be less than 2p (given by the
+// limbs and the carry out of them), modulo p.
+func (z *fp) subModulusIfNeeded(carry uint64) {
+	var t fp
+	var borrow uint64
+	t[0], borrow = bits.Sub64(z[0], fp0, 0)
+	t[1], borrow = bits.Sub64(z[1], fp1, borrow)
+	t[2], borrow = bits.Sub64(z[2], fp2, borrow)
+	t[3], borrow = bits.Sub64(z[3], fp3, borrow)
+	if carry != 0 || borrow == 0 {
+		*z = t
+	}
+}
+
+func (z *fp) add(x, y *fp) {
+	var carry uint64
+	z[0], carry = bits.Add64(x[0], y[0], 0)
+	z[1], carry = bits.Add64(x[1], y[1], carry)
+	z[2], carry = bits.Add64(x[2], y[2], carry)
+	z[3], carry = bits.Add64(x[3], y[3], carry)
+	z.subModulusIfNeeded(carry)
+}
+
+func (z *fp) sub(x, y *fp) {
+	var borrow uint64
+	z[0], borrow = bits.Sub64(x[0], y[0], 0)
+	z[1], borrow = bits.Sub64(x[1], y[1], borrow)
+	z[2], borrow = bits.Sub64(x[2], y[2], borrow)
+	z[3], borrow = bits.Sub64(x[3], y[3], borrow)
+	if borrow != 0 {
+		var carry uint64
+		z[0], carry = bits.Add64(z[0], fp0, 0)
+		z[1], carry = bits.Add64(z[1], fp1, carry)
+		z[2], carry = bits.Add64(z[2], fp2, carry)
+		z[3], _ = bits.Add64(z[3], fp3, carry)
+	}
+}
+
+// mul sets z to x*y (Montgomery multiplication, CIOS method).
+func (z *fp) mul(x, y *fp) {
+	var t0, t1, t2, t3, t4, t5, c, carry uint64
+	for _, yi := range y {
+		c, t0 = madd(x[0], yi, t0, 0)
+		c, t1 = madd(x[1], yi, t1, c)
+		c, t2 = madd(x[2], yi, t2, c)
+		c, t3 = madd(x[3], yi, t3, c)
+		t4, t5 = bits.Add64(t4, c, 0)
+
+		m := t0 * fpNegInv
+		c, _ = madd(m, fp0, t0, 0)
+		c, t0 = madd(m, fp1, t1, c)
+		c, t1 = madd(m, fp2, t2, c)
+		c, t2 = madd(m, fp3, t3, c)
+		t3, carry = bits.Add64(t4, c, 0)
+		t4 = t5 + carry
+	}
+	*z = fp{t0, t1, t2, t3}
+	z.subModulusIfNeeded(t4)
+}
+
+func (z *fp) square(x *fp) {
+	z.mul(x, x)
+}
+
+func (z *fp) double(x *fp) {
+	z.add(x, x)
+}
+
+// pallasAffine is a Pallas point in affine coordinates (never the identity).
+type pallasAffine struct {
+	x, y fp
+}
+
+// pallasPoint is a Pallas point in Jacobian coordinates (X:Y:Z), with
+// x = X/Z^2 and y = Y/Z^3; Z is zero for the identity.
+type pallasPoint struct {
+	x, y, z fp
+}
+
+func (p *pallasPoint) isIdentity() bool {
+	return p.z == fpZero
+}
+
+// double sets p to 2p (dbl-2009-l, for a = 0).
+func (p *pallasPoint) double() {
+	var a, b, c, d, e, f, t fp
+	a.square(&p.x)
+	b.square(&p.y)
+	c.square(&b)
+	d.add(&p.x, &b)
+	d.square(&d)
+	d.sub(&d, &a)
+	d.sub(&d, &c)
+	d.double(&d)
+	e.double(&a)
+	e.add(&e, &a)
+	f.square(&e)
+	p.z.mul(&p.y, &p.z)
+	p.z.double(&p.z)
+	p.x.sub(&f, &d)
+	p.x.sub(&p.x, &d)
+	t.sub(&d, &p.x)
+	p.y.mul(&e, &t)
+	c.double(&c)
+	c.double(&c)
+	c.double(&c)
+	p.y.sub(&p.y, &c)
+}
+
+// addAffine sets p to p + q (madd-2007-bl).
+func (p *pallasPoint) addAffine(q *pallasAffine) {
+	if p.isIdentity() {
+		*p = pallasPoint{x: q.x, y: q.y, z: fpOne}
+		return
+	}
+	var z1z1, u2, s2, h, hh, i, j, r, v, t fp
+	z1z1.square(&p.z)
+	u2.mul(&q.x, &z1z1)
+	s2.mul(&q.y, &p.z)
+	s2.mul(&s2, &z1z1)
+	h.sub(&u2, &p.x)
+	r.sub(&s2, &p.y)
+	if h == fpZero {
+		if r == fpZero {
+			p.double()
+		} else {
+			*p = pallasPoint{}
+		}
+		return
+	}
+	hh.square(&h)
+	i.double(&hh)
+	i.double(&i)
+	j.mul(&h, &i)
+	r.double(&r)
+	v.mul(&p.x, &i)
+	t.add(&p.z, &h)
+	p.z.square(&t)
+	p.z.sub(&p.z, &z1z1)
+	p.z.sub(&p.z, &hh)
+	p.x.square(&r)
+	p.x.sub(&p.x, &j)
+	p.x.sub(&p.x, &v)
+	p.x.sub(&p.x, &v)
+	t.sub(&v, &p.x)
+	t.mul(&r, &t)
+	j.mul(&p.y, &j)
+	j.double(&j)
+	p.y.sub(&t, &j)
+}
+
+// add sets p to p + q (add-2007-bl).
+func (p *pallasPoint) add(q *pallasPoint) {
+	if q.isIdentity() {
+		return
+	}
+	if p.isIdentity() {
+		*p = *q
+		return
+	}
+	var z1z1, z2z2, u1, u2, s1, s2, h, i, j, r, v, t fp
+	z1z1.square(&p.z)
+	z2z2.square(&q.z)
+	u1.mul(&p.x, &z2z2)
+	u2.mul(&q.x, &z1z1)
+	s1.mul(&p.y, &q.z)
+	s1.mul(&s1, &z2z2)
+	s2.mul(&q.y, &p.z)
+	s2.mul(&s2, &z1z1)
+	h.sub(&u2, &u1)
+	r.sub(&s2, &s1)
+	if h == fpZero {
+		if r == fpZero {
+			p.double()
+		} else {
+			*p = pallasPoint{}
+		}
+		return
+	}
+	i.double(&h)
+	i.square(&i)
+	j.mul(&h, &i)
+	r.double(&r)
+	v.mul(&u1, &i)
+	t.add(&p.z, &q.z)
+	t.square(&t)
+	t.sub(&t, &z1z1)
+	t.sub(&t, &z2z2)
+	p.z.mul(&t, &h)
+	p.x.square(&r)
+	p.x.sub(&p.x, &j)
+	p.x.sub(&p.x, &v)
+	p.x.sub(&p.x, &v)
+	t.sub(&v, &p.x)
+	t.mul(&r, &t)
+	s1.mul(&s1, &j)
+	s1.double(&s1)
+	p.y.sub(&t, &s1)
+}
+
+// extract returns the affine x-coordinate of p (zero for the identity), as
+// 32 little-endian bytes; this is Extract_P.
+func (p *pallasPoint) extract() Node {
+	var out Node
+	if p.isIdentity() {
+		return out
+	}
+	zInv := new(big.Int).ModInverse(p.z.toBig(), fpModulus)
+	x := new(big.Int).Mul(zInv, zInv)
+	x.Mul(x, p.x.toBig()).Mod(x, fpModulus)
+	be := x.FillBytes(make([]byte, 32))
+	for i := range out {
+		out[i] = be[31-i]
+	}
+	return out
+}
+
+// The rest of this file implements GroupHash^P, which is used only during
+// setup (where speed doesn't matter), with big.Int arithmetic. It's
+// hash_to_curve (of draft-irtf-cfrg-hash-to-curve-10) with BLAKE2b-512,
+// using the simplified SWU map onto iso-Pallas, a curve that's 3-isogenous
+// to Pallas:
+//
+//	y^2 = x^3 + A*x + B.
+var (
+	isoPallasA, _ = new(big.Int).SetString("18354a2eb0ea8c9c49be2d7258370742b74134581a27a59f92bb4b0b657a014b", 16)
+	isoPallasB    = big.NewInt(1265)
+	isoPallasZ    = new(big.Int).Sub(fpModulus, big.NewInt(13))
+)
+
+// The isogeny from iso-Pallas to Pallas, given by Velu's formulas for its
+// kernel {O, (x0, +/-y0)}, followed by the isomorphism (x, y) -> (s*x, t*y):
+//
+//	x -> s * (x + v/(x-x0) + w/(x-x0)^2)
+//	y -> t * y * (1 - v/(x-x0)^2 - 2*w/(x-x0)^3)
+//
+// This is the same map as the rational functions of the specification
+// (the empty Orchard tree root test checks it).
+var (
+	isoMapX0, _ = new(big.Int).SetString("115468c111fb318052cfc0198fdb5ac34301a71d1ff0c7cd6a57031b4ba19471", 16)
+	isoMapV, _  = new(big.Int).SetString("1e710ed623621c1f41f2d5e3ab3e34a6ff2947a9a28d1e5e276abc2de11866a9", 16)
+	isoMapW     = big.NewInt(20)
+	isoMapS, _  = new(big.Int).SetString("0e38e38e38e38e38e38e38e38e38e38e4081775473d8375b775f6034aaaaaaab", 16)
+	isoMapT, _  = new(big.Int).SetString("25ed097b425ed097b425ed097b425ed0ac03e8e134eb3e493e53ab371c71c71d", 16)
+)
+
+// bigPoint is an affine point (on either curve) with big.Int coordinates;
+// nil coordinates are the identity.
+type bigPoint struct {
+	x, y *big.Int
+}
+
+func modP(x *big.Int) *big.Int {
+	return x.Mod(x, fpModulus)
+}
+
+// addBig returns p + q on the curve y^2 = x^3 + a*x + b.
+func addBig(a *big.Int, p, q bigPoint) bigPoint {
+	if p.x == nil {
+		return q
+	}
+	if q.x == nil {
+		return p
+	}
+	var l *big.Int
+	if p.x.Cmp(q.x) == 0 {
+		if modP(new(big.Int).Add(p.y, q.y)).Sign() == 0 {
+			return bigPoint{}
+		}
+		// l = (3x^2 + a) / 2y
+		l = new(big.Int).Mul(p.x, p.x)
+		l.Mul(l, big.NewInt(3)).Add(l, a)
+		den := modP(new(big.Int).Lsh(p.y, 1))
+		l.Mul(l, den.ModInverse(den, fpModulus))
+	} else {
+		l = new(big.Int).Sub(q.y, p.y)
+		den := modP(new(big.Int).Sub(q.x, p.x))
+		l.Mul(l, den.ModInverse(den, fpModulus))
+	}
+	modP(l)
+	x := new(big.Int).Mul(l, l)
+	x.Sub(x, p.x).Sub(x, q.x)
+	modP(x)
+	y := new(big.Int).Sub(p.x, x)
+	y.Mul(y, l).Sub(y, p.y)
+	return bigPoint{x, modP(y)}
+}
+
+// hashToField returns two elements of F_p derived from the message, using
+// expand_message_xmd with BLAKE2b-512.
+func hashToField(domain string, msg []byte) [2]*big.Int {
+	dst := []byte(domain + "-pallas_XMD:BLAKE2b_SSWU_RO_")
+	dst = append(dst, byte(len(dst)))
+	hash := func(parts ...[]byte) []byte {
+		h, _ := blake2b.New512(nil)
+		for _, part := range parts {
+			h.Write(part)
+		}
+		return h.Sum(nil)
+	}
+	b0 := hash(make([]byte, blake2b.BlockSize), msg, []byte{0, 2 * blake2b.Size, 0}, dst)
+	b1 := hash(b0, []byte{1}, dst)
+	x := make([]byte, blake2b.Size)
+	for i := range x {
+		x[i] = b0[i] ^ b1[i]
+	}
+	b2 := hash(x, []byte{2}, dst)
+	return [2]*big.Int{
+		modP(new(big.Int).SetBytes(b1)),
+		modP(new(big.Int).SetBytes(b2)),
+	}
+}
+
+// mapToIsoPallas is the simplified SWU map onto iso-Pallas.
+func mapToIsoPallas(u *big.Int) bigPoint {
+	g := func(x *big.Int) *big.Int {
+		gx := new(big.Int).Mul(x, x)
+		gx.Add(gx, isoPallasA).Mul(gx, x).Add(gx, isoPallasB)
+		return modP(gx)
+	}
+	zu2 := modP(new(big.Int).Mul(u, u))
+	zu2.Mul(zu2, isoPallasZ)
+	modP(zu2)
+	tv := new(big.Int).Mul(zu2, zu2)
+	tv.Add(tv, zu2)
+	modP(tv)
+	var x1 *big.Int
+	if tv.Sign() == 0 {
+		// x1 = B / (Z*A)
+		den := modP(new(big.Int).Mul(isoPallasZ, isoPallasA))
+		x1 = new(big.Int).Mul(isoPallasB, den.ModInverse(den, fpModulus))
+	} else {
+		// x1 = (-B/A) * (1 + 1/tv)
+		x1 = new(big.Int).ModInverse(tv, fpModulus)
+		x1.Add(x1, big.NewInt(1))
+		x1.Mul(x1, new(big.Int).Neg(isoPallasB))
+		x1.Mul(x1, new(big.Int).ModInverse(isoPallasA, fpModulus))
+	}
+	modP(x1)
+	x := x1
+	y := new(big.Int).ModSqrt(g(x1), fpModulus)
+	if y == nil {
+		x = modP(new(big.Int).Mul(zu2, x1))
+		y = new(big.Int).ModSqrt(g(x), fpModulus)
+	}
+	if y.Bit(0) != u.Bit(0) {
+		y = modP(y.Neg(y))
+	}
+	return bigPoint{x, y}
+}
+
+// isoMap maps a point on iso-Pallas to Pallas.
+func isoMap(p bigPoint) bigPoint {
+	if p.x == nil {
+		return p
+	}
+	d := modP(new(big.Int).Sub(p.x, isoMapX0))
+	if d.Sign() == 0 {
+		return bigPoint{}
+	}
+	di := new(big.Int).ModInverse(d, fpModulus)
+	di2 := modP(new(big.Int).Mul(di, di))
+	di3 := modP(new(big.Int).Mul(di2, di))
+
+	x := new(big.Int).Mul(isoMapV, di)
+	x.Add(x, new(big.Int).Mul(isoMapW, di2)).Add(x, p.x)
+	x.Mul(x, isoMapS)
+
+	y := new(big.Int).Mul(isoMapV, di2)
+	y.Add(y, new(big.Int).Mul(new(big.Int).Lsh(isoMapW, 1), di3))
+	y.Sub(big.NewInt(1), y)
+	y.Mul(y, p.y).Mul(y, isoMapT)
+	return bigPoint{modP(x), modP(y)}
+}
+
+// groupHashP implements GroupHash^P(domain, msg).
+func groupHashP(domain string, msg []byte) pallasAffine {
+	u := hashToField(domain, msg)
+	p := isoMap(addBig(isoPallasA, mapToIsoPallas(u[0]), mapToIsoPallas(u[1])))
+	if p.x == nil {
+		panic("GroupHash^P returned the identity")
+	}
+	return pallasAffine{x: fpFromBig(p.x), y: fpFromBig(p.y)}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package commitmenttree
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// The Sapling note commitment tree hash, MerkleCRH^Sapling, is a Pedersen
+// hash over Jubjub; see sections 5.4.1.3 and 5.4.1.7 of the Zcash protocol
+// specification.
+
+const (
+	// The uniform random string used by GroupHash^URS.
+	groupHashURS = "096b36a5804bfacef1691e173c366a47ff5ba84a44f26ddd7e8d9f79d5b42df0"
+
+	pedersenPersonalization = "Zcash_PH"
+
+	// Each segment of the Pedersen hash input is at most this many
+	// 3-bit chunks.
+	pedersenChunksPerSegment = 63
+
+	// A Merkle hash input is 6 + 2*255 bits.
+	merkleHashChunks    = (6 + 2*255 + 2) / 3
+	merkleHashSegments  = (merkleHashChunks + pedersenChunksPerSegment - 1) / pedersenChunksPerSegment
+	saplingUncommitted  = 1 // the u-coordinate used for empty leaves
+	jubjubCofactorLog2  = 3
+	findGroupHashTrials = 256
+)
+
+// groupHash implements GroupHash^URS: it returns the Jubjub point derived
+// from the BLAKE2s hash of msg, multiplied by the cofactor, or false.
+func groupHash(personalization string, msg []byte) (affinePoint, bool) {
+	h := blake2s256(personalization, append([]byte(groupHashURS), msg...))
+	p, ok := decodePoint(h)
+	if !ok {
+		return affinePoint{}, false
+	}
+	for i := 0; i < jubjubCofactorLog2; i++ {
+		p = p.add(p)
+	}
+	if p.isIdentity() {
+		return affinePoint{}, false
+	}
+	return p, true
+}
+
+// findGroupHash implements FindGroupHash^J.
+func findGroupHash(personalization string, msg []byte) affinePoint {
+	for i := 0; i < findGroupHashTrials; i++ {
+		if p, ok := groupHash(personalization, append(append([]byte{}, msg...), byte(i))); ok {
+			return p
+		}
+	}
+	panic("findGroupHash failed")
+}
+
+// pedersenTable[s][j][k-1] is [k * 16^j] I_s, where I_s is the Pedersen
+// hash generator for segment s; so the contribution of chunk j of segment
+// s, which encodes to +/-k, can be found by lookup.
+var (
+	pedersenTable     [merkleHashSegments][pedersenChunksPerSegment][4]niels
+	pedersenTableOnce sync.Once
+)
+
+func initPedersenTable() {
+	for s := range pedersenTable {
+		var index [4]byte
+		binary.LittleEndian.PutUint32(index[:], uint32(s))
+		base := findGroupHash(pedersenPersonalization, index[:])
+		for j := range pedersenTable[s] {
+			p := base
+			for k := 0; k < 4; k++ {
+				pedersenTable[s][j][k] = p.niels()
+				p = p.add(base)
+			}
+			// base = [16] base
+			for i := 0; i < 4; i++ {
+				base = base.add(base)
+			}
+		}
+	}
+}
+
+// bitReader returns the bits of a byte slice, least significant first.
+type bitReader struct {
+	b []byte
+	n int
+}
+
+func (r *bitReader) next() uint {
+	if r.n >= 8*len(r.b) {
+		return 0
+	}
+	bit := uint(r.b[r.n/8]>>uint(r.n%8)) & 1
+	r.n++
+	return bit
+}
+
+// saplingMerkleHash returns MerkleCRH^Sapling(layer, left, right), where
+// level is MerkleDepth - 1 - layer (zero for combining two leaves).
+func saplingMerkleHash(level int, left, right Node) Node {
+	pedersenTableOnce.Do(initPedersenTable)
+
+	// The input is I2LEBSP_6(level) || I2LEBSP_255(left) || I2LEBSP_255(right),
+	// padded with zeros to a multiple of 3 bits.
+	var input [(merkleHashChunks*3 + 7) / 8]byte
+	bitPos := 0
+	put := func(bit uint) {
+		input[bitPos/8] |= byte(bit << uint(bitPos%8))
+		bitPos++
+	}
+	for i := 0; i < 6; i++ {
+		put(uint(level>>uint(i)) & 1)
+	}
+	for _, n := range []Node{left, right} {
+		r := bitReader{b: n[:]}
+		for i := 0; i < 255; i++ {
+			put(r.next())
+		}
+	}
+
+	acc := identity()
+	r := bitReader{b: input[:]}
+	for c := 0; c < merkleHashChunks; c++ {
+		s0, s1, s2 := r.next(), r.next(), r.next()
+		q := &pedersenTable[c/pedersenChunksPerSegment][c%pedersenChunksPerSegment][s0+2*s1]
+		if s2 == 0 {
+			acc.addNiels(q)
+		} else {
+			acc.subNiels(q)
+		}
+	}
+	return acc.u()
+}
+
+type saplingHasher struct {
+	once  sync.Once
+	empty [Depth + 1]Node
+}
+
+func (h *saplingHasher) Combine(level int, left, right Node) Node {
+	return saplingMerkleHash(level, left, right)
+}
+
+func (h *saplingHasher) Empty(level int) Node {
+	h.once.Do(func() {
+		h.empty[0][0] = saplingUncommitted
+		for i := 1; i <= Depth; i++ {
+			h.empty[i] = saplingMerkleHash(i-1, h.empty[i-1], h.empty[i-1])
+		}
+	})
+	return h.empty[level]
+}
+
+// Sapling is the Hasher for the Sapling note commitment tree.
+var Sapling Hasher = &saplingHasher{}
@@ -0,0 +1,203 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+// Package commitmenttree implements the incremental (append-only) note
+// commitment trees of the shielded pools, as maintained by pirated.
+package commitmenttree
+
+import (
+	"bytes"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/pkg/errors"
+)
+
+// Depth is the depth of the note commitment trees.
+const Depth = 32
+
+// Node is a leaf (note commitment) or interior node hash, in the pool's
+// canonical (little-endian) encoding.
+type Node [32]byte
+
+// Hasher is the hash function of a note commitment tree. Levels are
+// counted from the bottom: level 0 combines two leaves.
+type Hasher interface {
+	// Combine returns the parent of the given nodes at the given level.
+	Combine(level int, left, right Node) Node
+	// Empty returns the root of an empty subtree of height level; Empty(0)
+	// is the value of an empty leaf.
+	Empty(level int) Node
+}
+
+// Tree is the frontier of an incremental Merkle tree: just enough of the
+// tree to append leaves and compute the root. Its layout and serialization
+// are the same as pirated's CommitmentTree (as returned by z_gettreestate):
+// the two most recent leaves, if they aren't yet combined, then, for each
+// level, the left sibling awaiting its right sibling, if any.
+type Tree struct {
+	hasher  Hasher
+	left    *Node
+	right   *Node
+	parents []*Node
+}
+
+// NewTree returns an empty tree.
+func NewTree(hasher Hasher) *Tree {
+	return &Tree{hasher: hasher}
+}
+
+// Copy returns a copy of t (which shares no mutable state with t).
+func (t *Tree) Copy() *Tree {
+	c := &Tree{hasher: t.hasher, left: t.left, right: t.right}
+	c.parents = append([]*Node{}, t.parents...)
+	return c
+}
+
+// Size returns the number of leaves in the tree.
+func (t *Tree) Size() uint64 {
+	if t.left == nil {
+		return 0
+	}
+	size := uint64(1)
+	if t.right != nil {
+		size++
+	}
+	for i, p := range t.parents {
+		if p != nil {
+			size += 1 << uint(i+1)
+		}
+	}
+	return size
+}
+
+// Append adds a leaf to the tree.
+func (t *Tree) Append(leaf Node) error {
+	if t.left == nil {
+		t.left = &leaf
+		return nil
+	}
+	if t.right == nil {
+		t.right = &leaf
+		return nil
+	}
+	// Both leaves are present; combine them and carry upward.
+	combined := t.hasher.Combine(0, *t.left, *t.right)
+	t.left, t.right = &leaf, nil
+	for i, p := range t.parents {
+		if p == nil {
+			t.parents[i] = &combined
+			return nil
+		}
+		combined = t.hasher.Combine(i+1, *p, combined)
+		t.parents[i] = nil
+	}
+	if len(t.parents) >= Depth-1 {
+		return errors.New("note commitment tree is full")
+	}
+	t.parents = append(t.parents, &combined)
+	return nil
+}
+
+// Root returns the root of the tree.
+func (t *Tree) Root() Node {
+	if t.left == nil {
+		return t.hasher.Empty(Depth)
+	}
+	right := t.hasher.Empty(0)
+	if t.right != nil {
+		right = *t.right
+	}
+	root := t.hasher.Combine(0, *t.left, right)
+	for level := 1; level < Depth; level++ {
+		if level-1 < len(t.parents) && t.parents[level-1] != nil {
+			root = t.hasher.Combine(level, *t.parents[level-1], root)
+		} else {
+			root = t.hasher.Combine(level, root, t.hasher.Empty(level))
+		}
+	}
+	return root
+}
+
+func writeOptionalNode(buf *bytes.Buffer, n *Node) {
+	if n == nil {
+		buf.WriteByte(0)
+		return
+	}
+	buf.WriteByte(1)
+	buf.Write(n[:])
+}
+
+// MarshalBinary returns the serialization of the tree.
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	writeOptionalNode(&buf, t.left)
+	writeOptionalNode(&buf, t.right)
+	parser.WriteCompactLengthPrefixedLen(&buf, len(t.parents))
+	for _, p := range t.parents {
+		writeOptionalNode(&buf, p)
+	}
+	return buf.Bytes(), nil
+}
+
+func readOptionalNode(in []byte) (*Node, []byte, error) {
+	if len(in) < 1 {
+		return nil, nil, errors.New("truncated tree")
+	}
+	switch in[0] {
+	case 0:
+		return nil, in[1:], nil
+	case 1:
+		if len(in) < 33 {
+			return nil, nil, errors.New("truncated tree")
+		}
+		var n Node
+		copy(n[:], in[1:33])
+		return &n, in[33:], nil
+	}
+	return nil, nil, errors.New("bad optional node")
+}
+
+// ParseTree returns the tree with the given serialization.
+func ParseTree(hasher Hasher, in []byte) (*Tree, error) {
+	t := NewTree(hasher)
+	var err error
+	if t.left, in, err = readOptionalNode(in); err != nil {
+		return nil, err
+	}
+	if t.right, in, err = readOptionalNode(in); err != nil {
+		return nil, err
+	}
+	if t.left == nil && t.right != nil {
+		return nil, errors.New("tree has a right leaf but no left leaf")
+	}
+	count, in, err := readCompactSize(in)
+	if err != nil {
+		return nil, err
+	}
+	if count >= Depth {
+		return nil, errors.New("tree has too many parents")
+	}
+	t.parents = make([]*Node, count)
+	for i := range t.parents {
+		if t.parents[i], in, err = readOptionalNode(in); err != nil {
+			return nil, err
+		}
+	}
+	if len(in) != 0 {
+		return nil, errors.New("trailing data after tree")
+	}
+	return t, nil
+}
+
+// readCompactSize reads a small CompactSize value (the parent count, which
+// is always less than 253).
+func readCompactSize(in []byte) (int, []byte, error) {
+	if len(in) < 1 {
+		return 0, nil, errors.New("truncated tree")
+	}
+	if in[0] >= 253 {
+		return 0, nil, errors.New("bad parent count")
+	}
+	return int(in[0]), in[1:], nil
+}
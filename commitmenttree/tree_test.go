@@ -0,0 +1,253 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package commitmenttree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestBlake2s(t *testing.T) {
+	// RFC 7693, Appendix B
+	h := blake2s256("", []byte("abc"))
+	if hex.EncodeToString(h[:]) != "508c5e8c327c14e2e1a72ba34eeb452f37458b209ed63a294d999b4c86675982" {
+		t.Fatal("unexpected BLAKE2s-256(\"abc\")", hex.EncodeToString(h[:]))
+	}
+	// Multiple blocks, including exactly one full final block.
+	if blake2s256("", make([]byte, 128)) == blake2s256("", make([]byte, 129)) {
+		t.Fatal("unexpected BLAKE2s collision")
+	}
+}
+
+func TestFieldArithmetic(t *testing.T) {
+	if bigToLimbs(frModulus) != [4]uint64{fr0, fr1, fr2, fr3} {
+		t.Fatal("wrong modulus limbs")
+	}
+	if r0, inv := uint64(fr0), uint64(frNegInv); r0*inv != ^uint64(0) {
+		t.Fatal("wrong -r^-1 mod 2^64")
+	}
+	a := feFromBig(jubjubD)
+	var b, c fe
+	b.mul(&a, &feOne)
+	if b != a {
+		t.Fatal("multiplication by one failed")
+	}
+	// (d - d) + d == d, and d * d matches big.Int arithmetic.
+	c.sub(&a, &a)
+	c.add(&c, &a)
+	if c != a {
+		t.Fatal("add/sub failed")
+	}
+	c.mul(&a, &a)
+	dd := c.toBig()
+	want := new(big.Int).Mul(jubjubD, jubjubD)
+	want.Mod(want, frModulus)
+	if dd.Cmp(want) != 0 {
+		t.Fatal("multiplication failed")
+	}
+	c.neg(&a)
+	c.add(&c, &a)
+	if c != feZero {
+		t.Fatal("negation failed")
+	}
+}
+
+func TestSaplingEmptyRoots(t *testing.T) {
+	// The empty roots of the Sapling note commitment tree, as used by zcashd.
+	empty1 := Sapling.Empty(1)
+	if hex.EncodeToString(empty1[:]) != "817de36ab2d57feb077634bca77819c8e0bd298c04f6fed0e6a83cc1356ca155" {
+		t.Fatal("unexpected level 1 empty root", hex.EncodeToString(empty1[:]))
+	}
+	root := NewTree(Sapling).Root()
+	if hex.EncodeToString(root[:]) != "fbc2f4300c01f0b7820d00e3347c8da4ee614674376cbc45359daa54f9b5493e" {
+		t.Fatal("unexpected empty tree root", hex.EncodeToString(root[:]))
+	}
+}
+
+func TestPallasArithmetic(t *testing.T) {
+	if bigToLimbs(fpModulus) != [4]uint64{fp0, fp1, fp2, fp3} {
+		t.Fatal("wrong modulus limbs")
+	}
+	if p0, inv := uint64(fp0), uint64(fpNegInv); p0*inv != ^uint64(0) {
+		t.Fatal("wrong -p^-1 mod 2^64")
+	}
+	a := fpFromBig(isoPallasA)
+	var c fp
+	c.sub(&a, &a)
+	c.add(&c, &a)
+	if c != a {
+		t.Fatal("add/sub failed")
+	}
+	c.mul(&a, &a)
+	want := new(big.Int).Mul(isoPallasA, isoPallasA)
+	want.Mod(want, fpModulus)
+	if c.toBig().Cmp(want) != 0 {
+		t.Fatal("multiplication failed")
+	}
+
+	// Point addition, in its various cases, matches the affine formulas.
+	g := groupHashP("test", nil)
+	h := groupHashP("test", []byte{1})
+	toBig := func(p pallasAffine) bigPoint {
+		return bigPoint{p.x.toBig(), p.y.toBig()}
+	}
+	gh := addBig(new(big.Int), toBig(g), toBig(h))
+	g2 := addBig(new(big.Int), toBig(g), toBig(g))
+	want3 := addBig(new(big.Int), g2, toBig(h))
+	p := pallasPoint{x: g.x, y: g.y, z: fpOne}
+	p.addAffine(&h)
+	if p.extract() != (&pallasPoint{x: fpFromBig(gh.x), y: fpFromBig(gh.y), z: fpOne}).extract() {
+		t.Fatal("mixed addition failed")
+	}
+	q := pallasPoint{x: g.x, y: g.y, z: fpOne}
+	q.addAffine(&g)
+	q.add(&pallasPoint{x: h.x, y: h.y, z: fpOne})
+	if q.extract() != (&pallasPoint{x: fpFromBig(want3.x), y: fpFromBig(want3.y), z: fpOne}).extract() {
+		t.Fatal("doubling or addition failed")
+	}
+	var neg pallasAffine
+	neg.x = g.x
+	neg.y.sub(&fpZero, &g.y)
+	r := pallasPoint{x: g.x, y: g.y, z: fpOne}
+	r.addAffine(&neg)
+	if !r.isIdentity() {
+		t.Fatal("P + -P should be the identity")
+	}
+}
+
+func TestOrchardEmptyRoots(t *testing.T) {
+	// The empty root of the Orchard note commitment tree, as used by zcashd.
+	root := NewTree(Orchard).Root()
+	if hex.EncodeToString(root[:]) != "ae2935f1dfd8a24aed7c70df7de3a668eb7a49b1319880dde2bbd9031ae5d82f" {
+		t.Fatal("unexpected empty tree root", hex.EncodeToString(root[:]))
+	}
+}
+
+// testHasher is a fast Hasher whose nodes identify the leaves beneath them.
+type testHasher struct{}
+
+func (testHasher) Combine(level int, left, right Node) Node {
+	var buf bytes.Buffer
+	buf.WriteByte(byte(level))
+	buf.Write(left[:])
+	buf.Write(right[:])
+	return blake2s256("test", buf.Bytes())
+}
+
+func (h testHasher) Empty(level int) Node {
+	if level == 0 {
+		return Node{}
+	}
+	e := h.Empty(level - 1)
+	return h.Combine(level-1, e, e)
+}
+
+func testLeaf(i int) Node {
+	var n Node
+	binary.LittleEndian.PutUint64(n[:], uint64(i)+1)
+	return n
+}
+
+// naiveRoot computes the root of the tree with the given leaves directly.
+func naiveRoot(h Hasher, leaves []Node) Node {
+	nodes := append([]Node{}, leaves...)
+	for level := 0; level < Depth; level++ {
+		if len(nodes)%2 == 1 {
+			nodes = append(nodes, h.Empty(level))
+		}
+		if len(nodes) == 0 {
+			return h.Empty(Depth)
+		}
+		var next []Node
+		for i := 0; i < len(nodes); i += 2 {
+			next = append(next, h.Combine(level, nodes[i], nodes[i+1]))
+		}
+		nodes = next
+	}
+	return nodes[0]
+}
+
+func TestTreeAppend(t *testing.T) {
+	tree := NewTree(testHasher{})
+	var leaves []Node
+	for i := 0; i < 70; i++ {
+		if tree.Root() != naiveRoot(testHasher{}, leaves) {
+			t.Fatal("unexpected root with leaves", i)
+		}
+		if tree.Size() != uint64(i) {
+			t.Fatal("unexpected size", tree.Size(), i)
+		}
+		// Serialization round-trips.
+		b, _ := tree.MarshalBinary()
+		parsed, err := ParseTree(testHasher{}, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Root() != tree.Root() {
+			t.Fatal("parsed tree has a different root")
+		}
+		if err := tree.Append(testLeaf(i)); err != nil {
+			t.Fatal(err)
+		}
+		leaves = append(leaves, testLeaf(i))
+	}
+	// A copy is independent.
+	c := tree.Copy()
+	c.Append(testLeaf(70))
+	if c.Root() == tree.Root() {
+		t.Fatal("copy shares state")
+	}
+
+	b, _ := NewTree(Sapling).MarshalBinary()
+	if hex.EncodeToString(b) != "000000" {
+		t.Fatal("unexpected empty tree serialization", hex.EncodeToString(b))
+	}
+	for _, bad := range []string{"", "00", "0100", "0001" + hex.EncodeToString(make([]byte, 32)) + "00", "000000ff"} {
+		in, _ := hex.DecodeString(bad)
+		if _, err := ParseTree(Sapling, in); err == nil {
+			t.Fatal("ParseTree should fail", bad)
+		}
+	}
+}
+
+func TestSaplingAppend(t *testing.T) {
+	tree := NewTree(Sapling)
+	var leaves []Node
+	for i := 0; i < 5; i++ {
+		leaves = append(leaves, testLeaf(i))
+		tree.Append(testLeaf(i))
+	}
+	if tree.Root() != naiveRoot(Sapling, leaves) {
+		t.Fatal("unexpected Sapling root")
+	}
+}
+
+func TestOrchardAppend(t *testing.T) {
+	tree := NewTree(Orchard)
+	var leaves []Node
+	for i := 0; i < 5; i++ {
+		leaves = append(leaves, testLeaf(i))
+		tree.Append(testLeaf(i))
+	}
+	if tree.Root() != naiveRoot(Orchard, leaves) {
+		t.Fatal("unexpected Orchard root")
+	}
+}
+
+func BenchmarkSaplingMerkleHash(b *testing.B) {
+	left, right := Sapling.Empty(3), Sapling.Empty(4)
+	for i := 0; i < b.N; i++ {
+		saplingMerkleHash(10, left, right)
+	}
+}
+
+func BenchmarkOrchardMerkleHash(b *testing.B) {
+	left, right := Orchard.Empty(3), Orchard.Empty(4)
+	for i := 0; i < b.N; i++ {
+		orchardMerkleHash(10, left, right)
+	}
+}
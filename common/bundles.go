@@ -0,0 +1,313 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
+)
+
+// Finalized blocks (those at least bundleFinalityDepth below the tip) are
+// packed into bundle files, which never change once written, so they can
+// be served by a CDN. The blocks are divided into the same groups that
+// GetBlockGroups returns (from the first block in the cache, with the
+// default group size), and each group becomes one bundle: its compact
+// blocks, in height order, each preceded by its length (as a protobuf
+// varint), gzip-compressed, in a file named for its heights and SHA-256.
+// The manifest lists the bundles.
+var (
+	bundleFinalityDepth = 100
+	bundleInterval      = 60 * time.Second
+	bundleTargetSize    = defaultBlockGroupSize
+)
+
+const bundleManifestName = "manifest.json"
+
+// BlockBundle describes one bundle file.
+type BlockBundle struct {
+	StartHeight int    `json:"startHeight"`
+	EndHeight   int    `json:"endHeight"` // inclusive
+	EndHash     string `json:"endHash"`   // big-endian hex, as in block explorers
+	Size        int    `json:"size"`      // total size of the (uncompressed) compact blocks
+	TxCount     int    `json:"txCount"`
+	File        string `json:"file"`
+	FileSize    int64  `json:"fileSize"`
+	SHA256      string `json:"sha256"` // of the file, hex
+}
+
+type bundleManifest struct {
+	Chain      string        `json:"chain"`
+	TargetSize int           `json:"targetSize"`
+	Bundles    []BlockBundle `json:"bundles"`
+}
+
+var bundles struct {
+	mutex        sync.Mutex
+	dir          string // empty if the bundler isn't running
+	cache        *BlockCache
+	manifest     bundleManifest
+	manifestJSON []byte // as served
+}
+
+// StartBlockBundler loads the bundle manifest and starts a thread that
+// periodically packs newly-finalized blocks into bundles.
+func StartBlockBundler(cache *BlockCache, dbPath string, chainName string) {
+	loadBlockBundles(cache, dbPath, chainName)
+	go func() {
+		for {
+			buildBlockBundles()
+			Time.Sleep(bundleInterval)
+		}
+	}()
+}
+
+func loadBlockBundles(cache *BlockCache, dbPath string, chainName string) {
+	bundles.mutex.Lock()
+	defer bundles.mutex.Unlock()
+	bundles.dir = filepath.Join(dbPath, chainName, "bundles")
+	bundles.cache = cache
+	if err := os.MkdirAll(bundles.dir, 0755); err != nil {
+		Log.Fatal("mkdir ", bundles.dir, " failed: ", err)
+	}
+	manifest := bundleManifest{Chain: chainName, TargetSize: bundleTargetSize}
+	b, err := ioutil.ReadFile(filepath.Join(bundles.dir, bundleManifestName))
+	if err == nil {
+		var saved bundleManifest
+		if err := json.Unmarshal(b, &saved); err != nil {
+			Log.Warning("Can't parse the block bundle manifest, rebuilding bundles: ", err)
+		} else if saved.Chain == manifest.Chain && saved.TargetSize == manifest.TargetSize {
+			manifest.Bundles = saved.Bundles
+		}
+	} else if !os.IsNotExist(err) {
+		Log.Warning("Can't read the block bundle manifest, rebuilding bundles: ", err)
+	}
+	// Keep only the bundles whose files are intact (they should all be),
+	// and named for their contents.
+	for i, bundle := range manifest.Bundles {
+		fi, err := os.Stat(filepath.Join(bundles.dir, bundle.File))
+		if err != nil || fi.Size() != bundle.FileSize || bundle.File != bundleFileName(&bundle) {
+			Log.Warning("Block bundle ", bundle.File, " is missing or out of date, rebuilding from there")
+			manifest.Bundles = manifest.Bundles[:i]
+			break
+		}
+	}
+	bundles.manifest = manifest
+	writeBundleManifest()
+}
+
+// writeBundleManifest saves the manifest (replacing the file only once the
+// new one is complete), and updates the copy that's served.
+// Caller should hold bundles.mutex.
+func writeBundleManifest() {
+	if bundles.manifest.Bundles == nil {
+		bundles.manifest.Bundles = make([]BlockBundle, 0)
+	}
+	b, err := json.MarshalIndent(&bundles.manifest, "", "  ")
+	if err != nil {
+		Log.Errorf("Couldn't encode the block bundle manifest: %v", err)
+		return
+	}
+	bundles.manifestJSON = b
+	fileName := filepath.Join(bundles.dir, bundleManifestName)
+	if err := ioutil.WriteFile(fileName+".tmp", b, 0644); err != nil {
+		Log.Errorf("Couldn't write the block bundle manifest: %v", err)
+		return
+	}
+	if err := os.Rename(fileName+".tmp", fileName); err != nil {
+		Log.Errorf("Couldn't write the block bundle manifest: %v", err)
+	}
+}
+
+// buildBlockBundles drops any bundles that a (deep) reorg has invalidated,
+// then writes bundles for the finalized blocks that aren't in one yet.
+func buildBlockBundles() {
+	bundles.mutex.Lock()
+	cache := bundles.cache
+	for n := len(bundles.manifest.Bundles); n > 0; n-- {
+		last := bundles.manifest.Bundles[n-1]
+		block := cache.Get(last.EndHeight)
+		if block != nil && hex.EncodeToString(parser.Reverse(block.Hash)) == last.EndHash {
+			break
+		}
+		Log.Warning("Block bundle ", last.File, " is no longer in the best chain, removing it")
+		os.Remove(filepath.Join(bundles.dir, last.File))
+		bundles.manifest.Bundles = bundles.manifest.Bundles[:n-1]
+		writeBundleManifest()
+	}
+	next := cache.GetFirstHeight()
+	if n := len(bundles.manifest.Bundles); n > 0 {
+		next = bundles.manifest.Bundles[n-1].EndHeight + 1
+	}
+	targetSize := bundles.manifest.TargetSize
+	bundles.mutex.Unlock()
+
+	// Bundles are written without holding the lock (or the cache's lock
+	// for long), since there may be many of them after the initial sync.
+	finalized := cache.GetLatestHeight() - bundleFinalityDepth
+	for next <= finalized {
+		groups, err := cache.GetBlockGroups(next, finalized, targetSize)
+		if err != nil {
+			Log.Errorf("Couldn't get the block groups at height %d: %v", next, err)
+			return
+		}
+		for _, group := range groups {
+			if int(group.EndHeight) == finalized && int(group.Size) < blockGroupSize(targetSize) {
+				// Not enough finalized blocks to fill the next bundle.
+				return
+			}
+			bundle, err := writeBlockBundle(cache, group)
+			if err != nil {
+				Log.Errorf("Couldn't write block bundle at height %d: %v", next, err)
+				return
+			}
+			bundles.mutex.Lock()
+			bundles.manifest.Bundles = append(bundles.manifest.Bundles, *bundle)
+			writeBundleManifest()
+			bundles.mutex.Unlock()
+			next = bundle.EndHeight + 1
+		}
+	}
+}
+
+// writeBlockBundle writes the bundle of the given group of blocks. Its file
+// name includes the hash of its contents, so a bundle that's rebuilt after
+// a reorg doesn't have the name of the one it replaces.
+func writeBlockBundle(cache *BlockCache, group *walletrpc.BlockGroup) (*BlockBundle, error) {
+	var data bytes.Buffer
+	bundle := &BlockBundle{
+		StartHeight: int(group.StartHeight),
+		EndHeight:   int(group.EndHeight),
+		EndHash:     hex.EncodeToString(parser.Reverse(group.EndHash)),
+		Size:        int(group.Size),
+		TxCount:     int(group.TxCount),
+	}
+	for height := bundle.StartHeight; height <= bundle.EndHeight; height++ {
+		block := cache.Get(height)
+		if block == nil {
+			return nil, fmt.Errorf("block %d is not in the cache", height)
+		}
+		if height == bundle.EndHeight && !bytes.Equal(block.Hash, group.EndHash) {
+			return nil, fmt.Errorf("block %d was replaced by a reorg", height)
+		}
+		b, err := proto.Marshal(block)
+		if err != nil {
+			return nil, err
+		}
+		var length [binary.MaxVarintLen64]byte
+		data.Write(length[:binary.PutUvarint(length[:], uint64(len(b)))])
+		data.Write(b)
+	}
+
+	tmpName := filepath.Join(bundles.dir, fmt.Sprintf("%d-%d.tmp", bundle.StartHeight, bundle.EndHeight))
+	f, err := os.Create(tmpName)
+	if err != nil {
+		return nil, err
+	}
+	hasher := sha256.New()
+	err = compressBundle(io.MultiWriter(f, hasher), &data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		bundle.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+		bundle.File = bundleFileName(bundle)
+		err = os.Rename(tmpName, filepath.Join(bundles.dir, bundle.File))
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return nil, err
+	}
+	fi, err := os.Stat(filepath.Join(bundles.dir, bundle.File))
+	if err != nil {
+		return nil, err
+	}
+	bundle.FileSize = fi.Size()
+	return bundle, nil
+}
+
+// bundleFileName returns the name of the bundle's file.
+func bundleFileName(bundle *BlockBundle) string {
+	return fmt.Sprintf("%d-%d-%s.bin.gz", bundle.StartHeight, bundle.EndHeight, bundle.SHA256)
+}
+
+func compressBundle(w io.Writer, data io.Reader) error {
+	// The header is left empty (no name or time), so that a bundle's
+	// contents (and hash) depend only on its blocks.
+	zw, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(zw, data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// BlockBundleHandler serves the block bundles (and their manifest) under
+// /blocks/, with ETags (the content's SHA-256) and support for Range
+// requests.
+func BlockBundleHandler(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(req.URL.Path, "/blocks/")
+	bundles.mutex.Lock()
+	if bundles.dir == "" {
+		bundles.mutex.Unlock()
+		http.Error(w, "Not Found", 404)
+		return
+	}
+	if name == bundleManifestName {
+		manifestJSON := bundles.manifestJSON
+		bundles.mutex.Unlock()
+		hash := sha256.Sum256(manifestJSON)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(hash[:])+`"`)
+		// The manifest grows, so caches must check for a newer one.
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Content-Type", "application/json")
+		http.ServeContent(w, req, name, time.Time{}, bytes.NewReader(manifestJSON))
+		return
+	}
+	// Only the files in the manifest are served.
+	var bundle *BlockBundle
+	for i := range bundles.manifest.Bundles {
+		if bundles.manifest.Bundles[i].File == name {
+			b := bundles.manifest.Bundles[i]
+			bundle = &b
+			break
+		}
+	}
+	dir := bundles.dir
+	bundles.mutex.Unlock()
+	if bundle == nil {
+		http.Error(w, "Not Found", 404)
+		return
+	}
+	f, err := os.Open(filepath.Join(dir, bundle.File))
+	if err != nil {
+		// It was removed after a reorg.
+		http.Error(w, "Not Found", 404)
+		return
+	}
+	defer f.Close()
+	w.Header().Set("ETag", `"`+bundle.SHA256+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, req, bundle.File, time.Time{}, f)
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/commitmenttree"
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
+)
+
+// readBlockBundle returns the compact blocks in a bundle file, as a client
+// would read them.
+func readBlockBundle(data []byte) ([]*walletrpc.CompactBlock, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(zr)
+	var blocks []*walletrpc.CompactBlock
+	for {
+		length, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, length)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		block := &walletrpc.CompactBlock{}
+		if err := proto.Unmarshal(b, block); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+func bundleGet(path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	BlockBundleHandler(w, req)
+	return w
+}
+
+func TestBlockBundles(t *testing.T) {
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 1000, 0)
+	savedDepth, savedSize, savedMin := bundleFinalityDepth, bundleTargetSize, minBlockGroupSize
+	defer func() {
+		c.Close()
+		os.RemoveAll(unitTestPath)
+		bundles.dir = ""
+		bundleFinalityDepth, bundleTargetSize, minBlockGroupSize = savedDepth, savedSize, savedMin
+	}()
+	tree := commitmenttree.NewTree(commitmenttree.Sapling)
+	for height := 1000; height < 1020; height++ {
+		if err := c.Add(height, saplingTestBlock(height, 1, 0, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Bundles of about three blocks, of the blocks up to 1014.
+	bundleFinalityDepth = 5
+	bundleTargetSize = 3 * c.blockLength(1000)
+	minBlockGroupSize = 1
+
+	if w := bundleGet("/blocks/manifest.json", nil); w.Code != 404 {
+		t.Fatal("manifest served without a bundler", w.Code)
+	}
+	loadBlockBundles(c, unitTestPath, unitTestChain)
+	buildBlockBundles()
+
+	checkBundles := func() {
+		groups, err := c.GetBlockGroups(1000, 1014, bundleTargetSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if int(groups[len(groups)-1].Size) < bundleTargetSize {
+			// The last group isn't complete, so it isn't bundled yet.
+			groups = groups[:len(groups)-1]
+		}
+		if len(bundles.manifest.Bundles) != len(groups) {
+			t.Fatal("unexpected number of bundles", len(bundles.manifest.Bundles), len(groups))
+		}
+		for i, bundle := range bundles.manifest.Bundles {
+			group := groups[i]
+			if bundle.StartHeight != int(group.StartHeight) || bundle.EndHeight != int(group.EndHeight) ||
+				bundle.Size != int(group.Size) || bundle.TxCount != int(group.TxCount) ||
+				bundle.EndHash != hex.EncodeToString(parser.Reverse(group.EndHash)) {
+				t.Fatal("unexpected bundle", bundle, "expected", group)
+			}
+			data, err := ioutil.ReadFile(filepath.Join(bundles.dir, bundle.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash := sha256.Sum256(data)
+			if int64(len(data)) != bundle.FileSize || hex.EncodeToString(hash[:]) != bundle.SHA256 {
+				t.Fatal("unexpected bundle file size or hash", bundle.File)
+			}
+			blocks, err := readBlockBundle(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(blocks) != bundle.EndHeight-bundle.StartHeight+1 {
+				t.Fatal("unexpected number of blocks in bundle", bundle.File, len(blocks))
+			}
+			for j, block := range blocks {
+				if !proto.Equal(block, c.Get(bundle.StartHeight+j)) {
+					t.Fatal("unexpected block in bundle", bundle.File, j)
+				}
+			}
+		}
+	}
+	checkBundles()
+	if len(bundles.manifest.Bundles) < 3 {
+		t.Fatal("expected at least three bundles", len(bundles.manifest.Bundles))
+	}
+
+	// The manifest.
+	w := bundleGet("/blocks/manifest.json", nil)
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("unexpected manifest response", w.Code, w.Header())
+	}
+	var manifest bundleManifest
+	if err := json.Unmarshal(w.Body.Bytes(), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Chain != unitTestChain || manifest.TargetSize != bundleTargetSize ||
+		len(manifest.Bundles) != len(bundles.manifest.Bundles) {
+		t.Fatal("unexpected manifest", manifest)
+	}
+	etag := w.Header().Get("ETag")
+	if w := bundleGet("/blocks/manifest.json", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Fatal("manifest not cached", w.Code)
+	}
+
+	// A bundle, whole and in part.
+	bundle := bundles.manifest.Bundles[0]
+	data, _ := ioutil.ReadFile(filepath.Join(bundles.dir, bundle.File))
+	w = bundleGet("/blocks/"+bundle.File, nil)
+	if w.Code != 200 || w.Body.String() != string(data) ||
+		w.Header().Get("ETag") != `"`+bundle.SHA256+`"` ||
+		w.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Fatal("unexpected bundle response", w.Code, w.Header())
+	}
+	w = bundleGet("/blocks/"+bundle.File, map[string]string{"Range": "bytes=2-9"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != string(data[2:10]) {
+		t.Fatal("unexpected range response", w.Code, w.Body.Len())
+	}
+	w = bundleGet("/blocks/"+bundle.File, map[string]string{"If-None-Match": `"` + bundle.SHA256 + `"`})
+	if w.Code != http.StatusNotModified {
+		t.Fatal("bundle not cached", w.Code)
+	}
+	for _, path := range []string{"/blocks/", "/blocks/nosuchfile", "/blocks/" + bundleManifestName + ".tmp"} {
+		if w := bundleGet(path, nil); w.Code != 404 {
+			t.Fatal("unexpected response for", path, w.Code)
+		}
+	}
+
+	// A deep reorg replaces the blocks from 1010, so the bundles that
+	// include them are rebuilt, with new names.
+	oldFiles := make(map[int]string)
+	for _, bundle := range bundles.manifest.Bundles {
+		oldFiles[bundle.StartHeight] = bundle.File
+	}
+	c.Reorg(1010)
+	for height := 1010; height < 1020; height++ {
+		if err := c.Add(height, saplingTestBlock(height, 1, 1, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buildBlockBundles()
+	checkBundles()
+	for _, bundle := range bundles.manifest.Bundles {
+		if (oldFiles[bundle.StartHeight] == bundle.File) != (bundle.EndHeight < 1010) {
+			t.Fatal("unexpected bundle name after reorg", bundle.File)
+		}
+	}
+	if w := bundleGet("/blocks/"+oldFiles[bundles.manifest.Bundles[len(bundles.manifest.Bundles)-1].StartHeight], nil); w.Code != 404 {
+		t.Fatal("replaced bundle still served", w.Code)
+	}
+	last := bundles.manifest.Bundles[len(bundles.manifest.Bundles)-1]
+
+	// Restarting keeps the bundles, up to the first missing one.
+	saved := bundles.manifest.Bundles
+	loadBlockBundles(c, unitTestPath, unitTestChain)
+	if len(bundles.manifest.Bundles) != len(saved) || bundles.manifest.Bundles[len(saved)-1] != last {
+		t.Fatal("bundles not reloaded", bundles.manifest.Bundles)
+	}
+	os.Remove(filepath.Join(bundles.dir, saved[1].File))
+	loadBlockBundles(c, unitTestPath, unitTestChain)
+	if len(bundles.manifest.Bundles) != 1 {
+		t.Fatal("bundles after a missing file", bundles.manifest.Bundles)
+	}
+	buildBlockBundles()
+	checkBundles()
+
+	// A different bundle size starts over.
+	bundleTargetSize = 1
+	loadBlockBundles(c, unitTestPath, unitTestChain)
+	if len(bundles.manifest.Bundles) != 0 {
+		t.Fatal("bundles kept after changing the target size")
+	}
+}
@@ -8,7 +8,10 @@ package common
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"hash/fnv"
 	"io"
 	"io/ioutil"
@@ -16,6 +19,7 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/PirateNetwork/lightwalletd/parser"
 	"github.com/PirateNetwork/lightwalletd/walletrpc"
 	"github.com/golang/protobuf/proto"
 )
@@ -25,10 +29,99 @@ type BlockCache struct {
 	lengthsName, blocksName string // pathnames
 	lengthsFile, blocksFile *os.File
 	starts                  []int64 // Starting offset of each block within blocksFile
-	firstBlock              int     // height of the first block in the cache (usually Sapling activation)
-	nextBlock               int     // height of the first block not in the cache
-	latestHash              []byte  // hash of the most recent (highest height) block, for detecting reorgs.
+	headersName             string
+	headersFile             *os.File
+	headerStarts            []int64 // Starting offset of each header within headersFile
+	subtrees                map[walletrpc.ShieldedProtocol]*subtreeCache
+	treeStates              *treeStateCache
+	noteTrees               *noteTreeCache
+	firstBlock              int    // height of the first block in the cache (usually Sapling activation)
+	nextBlock               int    // height of the first block not in the cache
+	latestHash              []byte // hash of the most recent (highest height) block, for detecting reorgs.
+	subscribers             map[*BlockSubscription]struct{}
 	mutex                   sync.RWMutex
+
+	// The number of transactions in each block (by height-firstBlock), or
+	// -1 if not yet known; see blockTxCount. It has its own mutex because
+	// it's filled in while c.mutex is only read-locked.
+	txCounts      []int32
+	txCountsMutex sync.Mutex
+}
+
+// The default (approximate) size of the groups of blocks returned by
+// GetLiteWalletBlockGroup and GetBlockGroups.
+const defaultBlockGroupSize = 4000000
+
+// The smallest group size that GetBlockGroups allows, and the most groups
+// it returns at once (which keeps the reply well under gRPC's message size
+// limit, and bounds the work done while holding the cache lock).
+var (
+	minBlockGroupSize = 100000
+	maxBlockGroups    = 1000
+)
+
+// A BlockSubscription is notified whenever blocks are added to or removed
+// from the cache (see Subscribe).
+type BlockSubscription struct {
+	cache    *BlockCache
+	changed  chan struct{}
+	rollback int // lowest height removed by Reorg since the last Rollback(), or -1
+}
+
+// Subscribe returns a subscription to changes in the cache; the caller
+// should Close it when done.
+func (c *BlockCache) Subscribe() *BlockSubscription {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	sub := &BlockSubscription{
+		cache:    c,
+		changed:  make(chan struct{}, 1),
+		rollback: -1,
+	}
+	if c.subscribers == nil {
+		c.subscribers = make(map[*BlockSubscription]struct{})
+	}
+	c.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Changed returns a channel that receives a value when the cache has changed
+// since the last receive. Several changes may result in only one value.
+func (sub *BlockSubscription) Changed() <-chan struct{} {
+	return sub.changed
+}
+
+// Rollback returns the lowest height that Reorg has removed from the cache
+// since the previous call, or -1 if there have been no reorgs.
+func (sub *BlockSubscription) Rollback() int {
+	sub.cache.mutex.Lock()
+	defer sub.cache.mutex.Unlock()
+	height := sub.rollback
+	sub.rollback = -1
+	return height
+}
+
+// Close ends the subscription.
+func (sub *BlockSubscription) Close() {
+	sub.cache.mutex.Lock()
+	defer sub.cache.mutex.Unlock()
+	delete(sub.cache.subscribers, sub)
+}
+
+// notifySubscribers tells the subscribers that the cache has changed;
+// removed is the lowest height that's been removed, or -1.
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) notifySubscribers(removed int) {
+	for sub := range c.subscribers {
+		if removed >= 0 && (sub.rollback < 0 || removed < sub.rollback) {
+			sub.rollback = removed
+		}
+		select {
+		case sub.changed <- struct{}{}:
+		default:
+			// A notification is already pending.
+		}
+	}
 }
 
 // GetNextHeight returns the height of the lowest unobtained block.
@@ -76,10 +169,27 @@ func (c *BlockCache) setDbFiles(height int) {
 		if err := c.blocksFile.Truncate(c.starts[index]); err != nil {
 			Log.Fatal("truncate blocks file failed: ", err)
 		}
+		if index < len(c.headerStarts) {
+			if err := c.headersFile.Truncate(c.headerStarts[index]); err != nil {
+				Log.Fatal("truncate headers file failed: ", err)
+			}
+			c.headerStarts = c.headerStarts[:index+1]
+		}
 		c.Sync()
 		c.starts = c.starts[:index+1]
+		c.truncateTxCounts(index)
 		c.nextBlock = height
+		for _, sc := range c.subtrees {
+			sc.reorg(height)
+		}
+		if c.treeStates != nil {
+			c.treeStates.reorg(height)
+		}
+		if c.noteTrees != nil {
+			c.rebuildNoteTrees()
+		}
 		c.setLatestHash()
+		c.notifySubscribers(height)
 	}
 }
 
@@ -132,6 +242,41 @@ func (c *BlockCache) blockLength(height int) int {
 	return int(c.starts[index+1] - c.starts[index] - 8)
 }
 
+// blockTxCount returns the number of transactions in the block at the given
+// height, or false if the block can't be read.
+// Caller should hold (at least) c.mutex.RLock().
+func (c *BlockCache) blockTxCount(height int) (int, bool) {
+	index := height - c.firstBlock
+	c.txCountsMutex.Lock()
+	if index < len(c.txCounts) && c.txCounts[index] >= 0 {
+		count := c.txCounts[index]
+		c.txCountsMutex.Unlock()
+		return int(count), true
+	}
+	c.txCountsMutex.Unlock()
+	block := c.readBlock(height)
+	if block == nil {
+		return 0, false
+	}
+	c.txCountsMutex.Lock()
+	defer c.txCountsMutex.Unlock()
+	for len(c.txCounts) <= index {
+		c.txCounts = append(c.txCounts, -1)
+	}
+	c.txCounts[index] = int32(len(block.Vtx))
+	return len(block.Vtx), true
+}
+
+// Forget the transaction counts of the blocks from the given index on.
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) truncateTxCounts(index int) {
+	c.txCountsMutex.Lock()
+	defer c.txCountsMutex.Unlock()
+	if index < len(c.txCounts) {
+		c.txCounts = c.txCounts[:index]
+	}
+}
+
 // Calculate the 8-byte checksum that precedes each block in the blocks file.
 func checksum(height int, b []byte) []byte {
 	h := make([]byte, 8)
@@ -173,6 +318,80 @@ func (c *BlockCache) readBlock(height int) *walletrpc.CompactBlock {
 	return block
 }
 
+// Each entry in the headers file is a 4-byte length, followed by an 8-byte
+// checksum, followed by the serialized block header. The length may be zero
+// if the header is not known (for example, blocks cached by an older version).
+const headerPrefixLen = 12
+
+// Caller should hold (at least) c.mutex.RLock().
+func (c *BlockCache) readHeader(height int) []byte {
+	index := height - c.firstBlock
+	if index+1 >= len(c.headerStarts) {
+		return nil
+	}
+	offset := c.headerStarts[index]
+	b := make([]byte, c.headerStarts[index+1]-offset)
+	if len(b) <= headerPrefixLen {
+		return nil
+	}
+	n, err := c.headersFile.ReadAt(b, offset)
+	if err != nil || n != len(b) {
+		Log.Warning("headers read offset: ", offset, " failed: ", n, err)
+		return nil
+	}
+	if !bytes.Equal(checksum(height, b[headerPrefixLen:]), b[4:headerPrefixLen]) {
+		Log.Warning("bad header checksum at height: ", height, " offset: ", offset)
+		return nil
+	}
+	return b[headerPrefixLen:]
+}
+
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) writeHeader(height int, header []byte) {
+	b := make([]byte, headerPrefixLen, headerPrefixLen+len(header))
+	binary.LittleEndian.PutUint32(b, uint32(len(header)))
+	copy(b[4:], checksum(height, header))
+	b = append(b, header...)
+	n, err := c.headersFile.Write(b)
+	if err != nil {
+		Log.Fatal("headers write failed: ", err)
+	}
+	if n != len(b) {
+		Log.Fatal("headers write incorrect length: expected: ", len(b), "written: ", n)
+	}
+	offset := c.headerStarts[len(c.headerStarts)-1]
+	c.headerStarts = append(c.headerStarts, offset+int64(len(b)))
+}
+
+// Read the headers file's index, making it cover exactly the blocks in the
+// cache: entries beyond the last block are discarded, and blocks cached by an
+// older version (that didn't save headers) get empty entries.
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) loadHeaders() {
+	c.headerStarts = []int64{0}
+	var offset int64
+	prefix := make([]byte, headerPrefixLen)
+	for height := c.firstBlock; height < c.nextBlock; height++ {
+		n, err := c.headersFile.ReadAt(prefix, offset)
+		if err != nil || n != len(prefix) {
+			break
+		}
+		length := binary.LittleEndian.Uint32(prefix)
+		if length > 4*1000*1000 {
+			Log.Warning("headers file has impossible value ", length)
+			break
+		}
+		offset += headerPrefixLen + int64(length)
+		c.headerStarts = append(c.headerStarts, offset)
+	}
+	if err := c.headersFile.Truncate(c.headerStarts[len(c.headerStarts)-1]); err != nil {
+		Log.Fatal("truncate headers file failed: ", err)
+	}
+	for height := c.firstBlock + len(c.headerStarts) - 1; height < c.nextBlock; height++ {
+		c.writeHeader(height, nil)
+	}
+}
+
 // Caller should hold c.mutex.Lock().
 func (c *BlockCache) setLatestHash() {
 	c.latestHash = nil
@@ -191,9 +410,19 @@ func (c *BlockCache) setLatestHash() {
 
 // Reset is used only for darkside testing.
 func (c *BlockCache) Reset(startHeight int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	removed := c.firstBlock
 	c.setDbFiles(c.firstBlock) // empty the cache
 	c.firstBlock = startHeight
 	c.nextBlock = startHeight
+	c.rebuildNoteTrees()
+	// Every block has been removed, even if the cache was already empty.
+	if startHeight < removed {
+		removed = startHeight
+	}
+	c.notifySubscribers(removed)
 }
 
 // NewBlockCache returns an instance of a block cache object.
@@ -216,6 +445,11 @@ func NewBlockCache(dbPath string, chainName string, startHeight int, syncFromHei
 	if err != nil {
 		Log.Fatal("open ", c.lengthsName, " failed: ", err)
 	}
+	c.headersName = filepath.Join(dbPath, chainName, "headers")
+	c.headersFile, err = os.OpenFile(c.headersName, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		Log.Fatal("open ", c.headersName, " failed: ", err)
+	}
 	lengths, err := ioutil.ReadFile(c.lengthsName)
 	if err != nil {
 		Log.Fatal("read ", c.lengthsName, " failed: ", err)
@@ -249,16 +483,31 @@ func NewBlockCache(dbPath string, chainName string, startHeight int, syncFromHei
 		}
 		offset += int64(length) + 8
 		c.starts = append(c.starts, offset)
-		// Check for corruption.
-		block := c.readBlock(c.nextBlock)
+		// Check for corruption. The block must be counted (nextBlock
+		// advanced) before it's read, since blockLength() is zero for
+		// heights at or above nextBlock; otherwise every block would fail
+		// its checksum, and the whole cache would be discarded on restart.
+		c.nextBlock++
+		block := c.readBlock(c.nextBlock - 1)
 		if block == nil {
 			Log.Warning("error reading block")
+			c.nextBlock--
 			c.recoverFromCorruption(c.nextBlock)
 			break
 		}
-		c.nextBlock++
 	}
+	c.subtrees = make(map[walletrpc.ShieldedProtocol]*subtreeCache)
+	for protocol, name := range walletrpc.ShieldedProtocol_name {
+		c.subtrees[walletrpc.ShieldedProtocol(protocol)] = newSubtreeCache(
+			filepath.Join(dbPath, chainName, "subtrees-"+name))
+	}
+	c.treeStates = newTreeStateCache(filepath.Join(dbPath, chainName, "treestates"))
 	c.setDbFiles(c.nextBlock)
+	c.loadHeaders()
+	// Replaced by the notetrees file, which also has the Orchard tree.
+	os.Remove(filepath.Join(dbPath, chainName, "saplingtree"))
+	c.noteTrees = newNoteTreeCache(filepath.Join(dbPath, chainName, "notetrees"))
+	c.rebuildNoteTrees()
 	Log.Info("Found ", c.nextBlock-c.firstBlock, " blocks in cache")
 	return c
 }
@@ -269,7 +518,8 @@ func dbFileNames(dbPath string, chainName string) (string, string) {
 }
 
 // Add adds the given block to the cache at the given height, returning true
-// if a reorg was detected.
+// if a reorg was detected. If the block includes its full header, the header
+// is saved separately (see GetHeader) and not as part of the compact block.
 func (c *BlockCache) Add(height int, block *walletrpc.CompactBlock) error {
 	// Invariant: m[firstBlock..nextBlock) are valid.
 	c.mutex.Lock()
@@ -298,6 +548,13 @@ func (c *BlockCache) Add(height int, block *walletrpc.CompactBlock) error {
 		return nil
 	}
 
+	header := block.Header
+	if header != nil {
+		stripped := *block
+		stripped.Header = nil
+		block = &stripped
+	}
+
 	// Add the new block and its length to the db files.
 	data, err := proto.Marshal(block)
 	if err != nil {
@@ -321,6 +578,9 @@ func (c *BlockCache) Add(height int, block *walletrpc.CompactBlock) error {
 		Log.Fatal("lengths write incorrect length: expected: ", len(b), "written: ", n)
 	}
 
+	c.writeHeader(height, header)
+	c.addToNoteTrees(height, block, header)
+
 	// update the in-memory variables
 	offset := c.starts[len(c.starts)-1]
 	c.starts = append(c.starts, offset+int64(len(data)+8))
@@ -331,6 +591,7 @@ func (c *BlockCache) Add(height int, block *walletrpc.CompactBlock) error {
 	copy(c.latestHash, block.Hash)
 	c.nextBlock++
 	// Invariant: m[firstBlock..nextBlock) are valid.
+	c.notifySubscribers(-1)
 	return nil
 }
 
@@ -352,6 +613,7 @@ func (c *BlockCache) Reorg(height int) {
 	c.nextBlock = height
 	newCacheLen := height - c.firstBlock
 	c.starts = c.starts[:newCacheLen+1]
+	c.truncateTxCounts(newCacheLen)
 
 	if err := c.lengthsFile.Truncate(int64(4 * newCacheLen)); err != nil {
 		Log.Fatal("truncate failed: ", err)
@@ -359,7 +621,19 @@ func (c *BlockCache) Reorg(height int) {
 	if err := c.blocksFile.Truncate(c.starts[newCacheLen]); err != nil {
 		Log.Fatal("truncate failed: ", err)
 	}
+	if newCacheLen < len(c.headerStarts) {
+		c.headerStarts = c.headerStarts[:newCacheLen+1]
+		if err := c.headersFile.Truncate(c.headerStarts[newCacheLen]); err != nil {
+			Log.Fatal("truncate failed: ", err)
+		}
+	}
+	for _, sc := range c.subtrees {
+		sc.reorg(height)
+	}
+	c.treeStates.reorg(height)
+	c.rebuildNoteTrees()
 	c.setLatestHash()
+	c.notifySubscribers(height)
 }
 
 // Get returns the compact block at the requested height if it's
@@ -384,13 +658,140 @@ func (c *BlockCache) Get(height int) *walletrpc.CompactBlock {
 	return block
 }
 
-// GetLatestHeight returns the height of the most recent block, or -1
-// if the cache is empty.
+// GetHeader returns the full header of the block at the requested height
+// if it's in the cache, else nil.
+func (c *BlockCache) GetHeader(height int) *walletrpc.BlockHeader {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if height < c.firstBlock || height >= c.nextBlock {
+		return nil
+	}
+	header := c.readHeader(height)
+	if header == nil {
+		return nil
+	}
+	// The block hash is the SHA256d of the serialized header.
+	digest := sha256.Sum256(header)
+	digest = sha256.Sum256(digest[:])
+	return &walletrpc.BlockHeader{
+		Height: uint64(height),
+		Hash:   digest[:],
+		Header: header,
+	}
+}
+
+// FindForkPoint returns the height of the highest of the given blocks
+// that's in the cache (that is, in the best chain), comparing the hashes
+// as they appear in CompactBlock.Hash, or -1 if none is.
+func (c *BlockCache) FindForkPoint(locator []*walletrpc.BlockID) int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	fork := -1
+	for _, id := range locator {
+		height := int(id.Height)
+		if height <= fork || height < c.firstBlock || height >= c.nextBlock {
+			continue
+		}
+		block := c.readBlock(height)
+		if block != nil && bytes.Equal(block.Hash, id.Hash) {
+			fork = height
+		}
+	}
+	return fork
+}
+
+// GetSubtreeRoots returns the cached subtree roots of the given shielded
+// pool, starting at index startIndex, up to maxEntries of them (or all, if
+// maxEntries is zero).
+func (c *BlockCache) GetSubtreeRoots(protocol walletrpc.ShieldedProtocol, startIndex, maxEntries int) []*walletrpc.SubtreeRoot {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	sc, ok := c.subtrees[protocol]
+	if !ok || startIndex < 0 || startIndex >= len(sc.roots) {
+		return nil
+	}
+	roots := sc.roots[startIndex:]
+	if maxEntries > 0 && len(roots) > maxEntries {
+		roots = roots[:maxEntries]
+	}
+	return append([]*walletrpc.SubtreeRoot{}, roots...)
+}
+
+// AddSubtreeRoots saves the given subtree roots, the first of which has index
+// startIndex, if they extend the cached sequence. Only roots whose completing
+// block is in the cache (with a matching hash) are saved, so that a reorg of
+// that block removes them.
+func (c *BlockCache) AddSubtreeRoots(protocol walletrpc.ShieldedProtocol, startIndex int, roots []*walletrpc.SubtreeRoot) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sc, ok := c.subtrees[protocol]
+	if !ok || startIndex != len(sc.roots) {
+		return
+	}
+	for _, root := range roots {
+		height := int(root.CompletingBlockHeight)
+		if height < c.firstBlock || height >= c.nextBlock {
+			return
+		}
+		block := c.readBlock(height)
+		if block == nil || !bytes.Equal(parser.Reverse(block.Hash), root.CompletingBlockHash) {
+			return
+		}
+		sc.add(root)
+	}
+}
+
+// ResetSubtreeRoots removes all cached subtree roots of the given pool
+// (used only for darkside testing).
+func (c *BlockCache) ResetSubtreeRoots(protocol walletrpc.ShieldedProtocol) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if sc, ok := c.subtrees[protocol]; ok {
+		sc.truncate(0)
+	}
+}
+
+// GetTreeState returns the saved tree state of the block with the given
+// height or, if height is zero, hash (big-endian hex), or nil if it's not
+// saved. The returned TreeState must not be modified.
+func (c *BlockCache) GetTreeState(height int, hash string) *walletrpc.TreeState {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.treeStates.get(height, hash)
+}
+
+// AddTreeState saves the given tree state if its block is in the cache (with
+// a matching hash) and is at least treeStateFinalityDepth blocks below the
+// tip, so it's unlikely to ever be removed by a reorg.
+func (c *BlockCache) AddTreeState(ts *walletrpc.TreeState) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	height := int(ts.Height)
+	if height < c.firstBlock || height >= c.nextBlock-treeStateFinalityDepth {
+		return
+	}
+	block := c.readBlock(height)
+	if block == nil || hex.EncodeToString(parser.Reverse(block.Hash)) != ts.Hash {
+		return
+	}
+	c.treeStates.add(ts)
+}
+
+// GetLiteWalletBlockGroup returns the height and hash of the block following
+// a group of blocks, starting at the given height, of about
+// defaultBlockGroupSize bytes, or nil if the height isn't cached.
 func (c *BlockCache) GetLiteWalletBlockGroup(height int) *walletrpc.BlockID {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	targetLength := 4000000
+	targetLength := defaultBlockGroupSize
 	groupLength := 0
 
 	if height < c.firstBlock || height >= c.nextBlock {
@@ -398,17 +799,69 @@ func (c *BlockCache) GetLiteWalletBlockGroup(height int) *walletrpc.BlockID {
 	}
 
 	for groupLength < targetLength {
-		 	groupLength += c.blockLength(height)
-			height++
-			if height >= c.nextBlock {
-					height--
-					break
-			}
+		groupLength += c.blockLength(height)
+		height++
+		if height >= c.nextBlock {
+			height--
+			break
+		}
 	}
 
 	block := c.readBlock(height)
+	if block == nil {
+		return nil
+	}
+	return &walletrpc.BlockID{Height: uint64(height), Hash: block.Hash}
+}
+
+// blockGroupSize returns the group size that GetBlockGroups uses for the
+// requested size.
+func blockGroupSize(targetSize int) int {
+	if targetSize <= 0 {
+		return defaultBlockGroupSize
+	}
+	if targetSize < minBlockGroupSize {
+		return minBlockGroupSize
+	}
+	return targetSize
+}
+
+// GetBlockGroups divides the cached blocks from start to end (inclusive)
+// into consecutive groups, each ending with the first block that brings its
+// total size to at least targetSize bytes (or the default size, if zero;
+// at least minBlockGroupSize). The last group ends at end, so it may be
+// smaller. At most maxBlockGroups groups are returned; if the last one ends
+// before end, the caller can continue from the following height.
+func (c *BlockCache) GetBlockGroups(start, end, targetSize int) ([]*walletrpc.BlockGroup, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
-	return &walletrpc.BlockID{Height: uint64(height), Hash: make([]byte, len(block.Hash))}
+	if start < c.firstBlock || end >= c.nextBlock || start > end {
+		return nil, errors.New("block range is not in the cache")
+	}
+	targetSize = blockGroupSize(targetSize)
+	groups := make([]*walletrpc.BlockGroup, 0)
+	group := &walletrpc.BlockGroup{StartHeight: uint64(start)}
+	for height := start; height <= end && len(groups) < maxBlockGroups; height++ {
+		txCount, ok := c.blockTxCount(height)
+		if !ok {
+			return nil, errors.New("can't read block from the cache")
+		}
+		group.Size += uint64(c.blockLength(height))
+		group.TxCount += uint64(txCount)
+		if group.Size < uint64(targetSize) && height < end {
+			continue
+		}
+		block := c.readBlock(height)
+		if block == nil {
+			return nil, errors.New("can't read block from the cache")
+		}
+		group.EndHeight = uint64(height)
+		group.EndHash = block.Hash
+		groups = append(groups, group)
+		group = &walletrpc.BlockGroup{StartHeight: uint64(height + 1)}
+	}
+	return groups, nil
 }
 
 // GetLatestHeight returns the height of the most recent block, or -1
@@ -426,6 +879,7 @@ func (c *BlockCache) GetLatestHeight() int {
 func (c *BlockCache) Sync() {
 	c.lengthsFile.Sync()
 	c.blocksFile.Sync()
+	c.headersFile.Sync()
 }
 
 // Close is Currently used only for testing.
@@ -439,4 +893,17 @@ func (c *BlockCache) Close() {
 		c.blocksFile.Close()
 		c.blocksFile = nil
 	}
+	if c.headersFile != nil {
+		c.headersFile.Close()
+		c.headersFile = nil
+	}
+	for _, sc := range c.subtrees {
+		sc.close()
+	}
+	if c.treeStates != nil {
+		c.treeStates.close()
+	}
+	if c.noteTrees != nil {
+		c.noteTrees.close()
+	}
 }
@@ -5,10 +5,12 @@
 package common
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/PirateNetwork/lightwalletd/parser"
@@ -183,3 +185,327 @@ func fillCache(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheRestart(t *testing.T) {
+	var compactTests []struct {
+		Full string `json:"full"`
+	}
+	blockJSON, err := ioutil.ReadFile("../testdata/compact_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(blockJSON, &compactTests); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	defer func() {
+		c.Close()
+		os.RemoveAll(unitTestPath)
+	}()
+	for i, test := range compactTests {
+		blockData, _ := hex.DecodeString(test.Full)
+		block := parser.NewBlock()
+		if _, err = block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Add(289460+i, block.ToCompact()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The blocks are all kept, and none is seen as corrupt.
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	if c.nextBlock != 289466 {
+		t.Fatal("blocks not kept after restart", c.nextBlock)
+	}
+	for height := 289460; height < 289466; height++ {
+		if block := c.Get(height); block == nil || int(block.Height) != height {
+			t.Fatal("unexpected block after restart", height)
+		}
+	}
+	if _, err := os.Stat(c.blocksName + "-corrupted"); !os.IsNotExist(err) {
+		t.Fatal("cache seen as corrupt after restart", err)
+	}
+
+	// A corrupted block is dropped, with the blocks that follow it.
+	offset := c.starts[3] + 8
+	c.Close()
+	f, err := os.OpenFile(c.blocksName, os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte{0xff, 0xff}, offset)
+	f.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	if c.nextBlock != 289463 {
+		t.Fatal("unexpected nextBlock after corruption", c.nextBlock)
+	}
+	if _, err := os.Stat(c.blocksName + "-corrupted"); err != nil {
+		t.Fatal("corrupted blocks file not saved", err)
+	}
+}
+
+func TestCacheHeaders(t *testing.T) {
+	var compactTests []struct {
+		Full string `json:"full"`
+	}
+	blockJSON, err := ioutil.ReadFile("../testdata/compact_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(blockJSON, &compactTests); err != nil {
+		t.Fatal(err)
+	}
+	var blocks []*walletrpc.CompactBlock
+	var headers [][]byte
+	for _, test := range compactTests {
+		blockData, _ := hex.DecodeString(test.Full)
+		block := parser.NewBlock()
+		if _, err = block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compact := block.ToCompact()
+		compact.Header = block.GetHeader()
+		blocks = append(blocks, compact)
+		headers = append(headers, compact.Header)
+	}
+
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	for i, compact := range blocks {
+		if err := c.Add(289460+i, compact); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(n int) {
+		for i := 0; i < n; i++ {
+			if b := c.Get(289460 + i); b == nil || b.Header != nil {
+				t.Fatal("header should not be stored in the compact block")
+			}
+			h := c.GetHeader(289460 + i)
+			if h == nil {
+				t.Fatal("GetHeader failed at height ", 289460+i)
+			}
+			if !bytes.Equal(h.Header, headers[i]) {
+				t.Fatal("unexpected header at height ", 289460+i)
+			}
+			if !bytes.Equal(h.Hash, blocks[i].Hash) {
+				t.Fatal("unexpected header hash at height ", 289460+i)
+			}
+		}
+		if c.GetHeader(289460+n) != nil {
+			t.Fatal("GetHeader beyond the cache should fail")
+		}
+	}
+	check(len(blocks))
+
+	// Headers are removed along with their blocks on a reorg.
+	c.Reorg(289462)
+	check(2)
+	for i := 2; i < len(blocks); i++ {
+		if err := c.Add(289460+i, blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Simulate a restart to ensure the headers file is read correctly.
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	check(len(blocks))
+
+	// A cache written without headers serves no headers, but still works.
+	c.Close()
+	os.Remove(filepath.Join(unitTestPath, unitTestChain, "headers"))
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	if c.GetHeader(289460) != nil {
+		t.Fatal("unexpected header from a cache without headers")
+	}
+	c.Reorg(289461)
+	if err := c.Add(289461, blocks[1]); err != nil {
+		t.Fatal(err)
+	}
+	if h := c.GetHeader(289461); h == nil || !bytes.Equal(h.Header, headers[1]) {
+		t.Fatal("GetHeader failed after adding to a cache without headers")
+	}
+
+	c.Close()
+	os.RemoveAll(unitTestPath)
+}
+
+func TestCacheBlockGroups(t *testing.T) {
+	var compactTests []struct {
+		Full string `json:"full"`
+	}
+	blockJSON, err := ioutil.ReadFile("../testdata/compact_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(blockJSON, &compactTests); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	defer os.RemoveAll(unitTestPath)
+	defer c.Close()
+	var blocks []*walletrpc.CompactBlock
+	for i, test := range compactTests {
+		blockData, _ := hex.DecodeString(test.Full)
+		block := parser.NewBlock()
+		if _, err = block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block.ToCompact())
+		if err := c.Add(289460+i, blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last := 289460 + len(blocks) - 1
+	var sizes []uint64
+	var totalSize, totalTxs uint64
+	for i, block := range blocks {
+		sizes = append(sizes, uint64(c.blockLength(289460+i)))
+		totalSize += sizes[i]
+		totalTxs += uint64(len(block.Vtx))
+	}
+	checkGroup := func(group *walletrpc.BlockGroup, start, end int) {
+		var size, txs uint64
+		for i := start; i <= end; i++ {
+			size += sizes[i-289460]
+			txs += uint64(len(blocks[i-289460].Vtx))
+		}
+		if group.StartHeight != uint64(start) || group.EndHeight != uint64(end) ||
+			group.Size != size || group.TxCount != txs ||
+			!bytes.Equal(group.EndHash, blocks[end-289460].Hash) {
+			t.Fatal("unexpected group", group, "expected", start, end, size, txs)
+		}
+	}
+
+	// The default size is larger than all of the blocks.
+	groups, err := c.GetBlockGroups(289460, last, 0)
+	if err != nil || len(groups) != 1 {
+		t.Fatal("GetBlockGroups failed", err, len(groups))
+	}
+	checkGroup(groups[0], 289460, last)
+	if groups[0].Size != totalSize || groups[0].TxCount != totalTxs {
+		t.Fatal("unexpected totals")
+	}
+	// GetLiteWalletBlockGroup returns the end of the group, with its hash.
+	id := c.GetLiteWalletBlockGroup(289460)
+	if id == nil || id.Height != uint64(last) || !bytes.Equal(id.Hash, blocks[last-289460].Hash) {
+		t.Fatal("unexpected GetLiteWalletBlockGroup result", id)
+	}
+	if c.GetLiteWalletBlockGroup(last+1) != nil {
+		t.Fatal("GetLiteWalletBlockGroup should have failed for an uncached height")
+	}
+
+	// Each block is its own group.
+	saveMin, saveMax := minBlockGroupSize, maxBlockGroups
+	defer func() { minBlockGroupSize, maxBlockGroups = saveMin, saveMax }()
+	minBlockGroupSize = 1
+	groups, err = c.GetBlockGroups(289461, last, 1)
+	if err != nil || len(groups) != len(blocks)-1 {
+		t.Fatal("GetBlockGroups failed", err, len(groups))
+	}
+	for i, group := range groups {
+		checkGroup(group, 289461+i, 289461+i)
+	}
+	// A group ends with the block that reaches the target size.
+	groups, err = c.GetBlockGroups(289460, 289463, int(sizes[0]+1))
+	if err != nil || len(groups) != 2 {
+		t.Fatal("GetBlockGroups failed", err, len(groups))
+	}
+	checkGroup(groups[0], 289460, 289461)
+	checkGroup(groups[1], 289462, 289463)
+	// The number of groups is limited.
+	maxBlockGroups = 2
+	groups, err = c.GetBlockGroups(289460, last, 1)
+	if err != nil || len(groups) != 2 {
+		t.Fatal("GetBlockGroups failed", err, len(groups))
+	}
+	checkGroup(groups[1], 289461, 289461)
+	maxBlockGroups = saveMax
+	// Sizes below the minimum are raised to it.
+	minBlockGroupSize = int(sizes[0] + 1)
+	groups, err = c.GetBlockGroups(289460, 289463, 1)
+	if err != nil || len(groups) != 2 {
+		t.Fatal("GetBlockGroups failed", err, len(groups))
+	}
+	checkGroup(groups[0], 289460, 289461)
+	minBlockGroupSize = saveMin
+
+	for _, r := range [][]int{{289459, last}, {289460, last + 1}, {289462, 289461}} {
+		if _, err := c.GetBlockGroups(r[0], r[1], 0); err == nil {
+			t.Fatal("GetBlockGroups should have failed for", r)
+		}
+	}
+
+	// The transaction counts are forgotten along with their blocks.
+	c.Reorg(289462)
+	if len(c.txCounts) != 2 {
+		t.Fatal("unexpected transaction counts after reorg", len(c.txCounts))
+	}
+	for i := 2; i < len(blocks); i++ {
+		if err := c.Add(289460+i, blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	groups, err = c.GetBlockGroups(289460, last, 0)
+	if err != nil || len(groups) != 1 {
+		t.Fatal("GetBlockGroups failed", err, len(groups))
+	}
+	checkGroup(groups[0], 289460, last)
+}
+
+func TestCacheForkPoint(t *testing.T) {
+	var compactTests []struct {
+		Full string `json:"full"`
+	}
+	blockJSON, err := ioutil.ReadFile("../testdata/compact_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(blockJSON, &compactTests); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	defer os.RemoveAll(unitTestPath)
+	defer c.Close()
+	var blocks []*walletrpc.CompactBlock
+	for i, test := range compactTests[:5] {
+		blockData, _ := hex.DecodeString(test.Full)
+		block := parser.NewBlock()
+		if _, err = block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block.ToCompact())
+		if err := c.Add(289460+i, blocks[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id := func(i int) *walletrpc.BlockID {
+		return &walletrpc.BlockID{Height: uint64(289460 + i), Hash: blocks[i].Hash}
+	}
+	stale := func(i int) *walletrpc.BlockID {
+		return &walletrpc.BlockID{Height: uint64(289460 + i), Hash: blocks[i-1].Hash}
+	}
+	for _, test := range []struct {
+		locator []*walletrpc.BlockID
+		fork    int
+	}{
+		{[]*walletrpc.BlockID{id(4), id(3), id(1)}, 289464},
+		{[]*walletrpc.BlockID{stale(4), stale(3), id(2), id(0)}, 289462},
+		// the order doesn't matter
+		{[]*walletrpc.BlockID{id(0), id(3), stale(4)}, 289463},
+		// blocks outside the cache are ignored
+		{[]*walletrpc.BlockID{{Height: 289465}, id(1), {Height: 289459}}, 289461},
+		{[]*walletrpc.BlockID{stale(2), stale(1)}, -1},
+		{nil, -1},
+	} {
+		if fork := c.FindForkPoint(test.locator); fork != test.fork {
+			t.Fatal("unexpected fork point", fork, "expected", test.fork)
+		}
+	}
+}
@@ -6,14 +6,19 @@
 package common
 
 import (
+	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PirateNetwork/lightwalletd/parser"
 	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
@@ -31,6 +36,9 @@ type Options struct {
 	GRPCBindAddr        string `json:"grpc_bind_address,omitempty"`
 	GRPCLogging         bool   `json:"grpc_logging_insecure,omitempty"`
 	HTTPBindAddr        string `json:"http_bind_address,omitempty"`
+	GRPCWebBindAddr     string `json:"grpc_web_bind_address,omitempty"`
+	GRPCWebOrigins      string `json:"grpc_web_origins,omitempty"`
+	RESTEnable          bool   `json:"rest_enable,omitempty"`
 	TLSCertPath         string `json:"tls_cert_path,omitempty"`
 	TLSKeyPath          string `json:"tls_cert_key,omitempty"`
 	LogLevel            uint64 `json:"log_level,omitempty"`
@@ -48,6 +56,13 @@ type Options struct {
 	PingEnable          bool   `json:"ping_enable"`
 	Darkside            bool   `json:"darkside"`
 	DarksideTimeout     uint64 `json:"darkside_timeout"`
+	TaddrTxidsWorkers   int    `json:"taddr_txids_workers"`
+	TaddrTxidsMax       int    `json:"taddr_txids_max"`
+	BlockRangeMax       int    `json:"block_range_max"`
+	BlockBundles        bool   `json:"block_bundles"`
+	DonationAddress     string `json:"donation_address,omitempty"`
+	OperatorContact     string `json:"operator_contact,omitempty"`
+	DisableMethods      string `json:"disable_methods,omitempty"`
 }
 
 // RawRequest points to the function to send a an RPC request to pirated;
@@ -72,11 +87,36 @@ var Log *logrus.Entry
 // Metrics as a global object to simplify things
 var Metrics *PrometheusMetrics
 
+// RPCErrorCode is an error code of pirated's RPC interface; RawRequest's
+// errors begin with the code and a colon.
+type RPCErrorCode int32
+
+// pirated's RPC error codes that lightwalletd acts on.
+const (
+	RPCInvalidAddressOrKey  RPCErrorCode = -5  // no such transaction or block
+	RPCInvalidParameter     RPCErrorCode = -8  // such as a height beyond the tip
+	RPCDeserializationError RPCErrorCode = -22 // a transaction that can't be decoded
+	RPCVerifyError          RPCErrorCode = -25 // a transaction that can't be verified
+	RPCVerifyRejected       RPCErrorCode = -26 // a transaction that isn't valid
+	RPCVerifyAlreadyInChain RPCErrorCode = -27 // a transaction that's already mined
+)
+
+// GetRPCErrorCode returns the pirated RPC error code of an error returned by
+// RawRequest, if it has one.
+func GetRPCErrorCode(err error) (RPCErrorCode, bool) {
+	code, parseErr := strconv.ParseInt(strings.SplitN(err.Error(), ":", 2)[0], 10, 32)
+	if parseErr != nil {
+		return 0, false
+	}
+	return RPCErrorCode(code), true
+}
+
 // The following are JSON pirated rpc requests and replies.
 type (
 	// pirated rpc "getblockchaininfo"
 	Upgradeinfo struct {
 		// unneeded fields can be omitted
+		Name             string
 		ActivationHeight int
 		Status           string // "active"
 	}
@@ -127,8 +167,19 @@ type (
 
 	// pirated rpc "getrawtransaction txid 1" (1 means verbose), there are
 	PiratedRpcReplyGetrawtransaction struct {
-		Hex    string
-		Height int
+		Hex       string
+		Height    int
+		Blockhash string
+	}
+
+	// the transparent outputs of pirated rpc "getrawtransaction txid 1"
+	PiratedRpcReplyGetrawtransactionVout struct {
+		Vout []struct {
+			N            int64
+			ScriptPubKey struct {
+				Hex string
+			}
+		}
 	}
 
 	// pirated rpc "getaddressbalance"
@@ -152,9 +203,52 @@ type (
 		Height      int
 	}
 
+	// pirated rpc "getaddressdeltas"
+	PiratedRpcRequestGetaddressdeltas struct {
+		Addresses []string `json:"addresses"`
+		Start     uint64   `json:"start"`
+		End       uint64   `json:"end"`
+	}
+	PiratedRpcReplyGetaddressdeltas struct {
+		Satoshis   int64
+		Txid       string
+		Index      int64
+		BlockIndex int64
+		Height     int
+		Address    string
+	}
+
+	// pirated rpc "getaddressmempool"
+	PiratedRpcRequestGetaddressmempool struct {
+		Addresses []string `json:"addresses"`
+	}
+	PiratedRpcReplyGetaddressmempool struct {
+		Address   string
+		Txid      string
+		Index     int64
+		Satoshis  int64
+		Timestamp int64
+		Prevtxid  string // if this is a spend, the output it spends
+		Prevout   int64
+	}
+
+	// pirated rpc "z_getsubtreesbyindex"
+	PiratedSubtree struct {
+		Root      string `json:"root"`
+		EndHeight int    `json:"end_height"`
+	}
+	PiratedRpcReplyGetsubtreebyindex struct {
+		Pool       string           `json:"pool"`
+		StartIndex int              `json:"start_index"`
+		Subtrees   []PiratedSubtree `json:"subtrees"`
+	}
+
 	// reply to getblock verbose=1 (json includes txid list)
 	PirateRpcReplyGetblock1 struct {
-		Tx []string
+		Hash          string
+		Height        int
+		Confirmations int
+		Tx            []string
 	}
 )
 
@@ -189,7 +283,39 @@ func FirstRPC() {
 	}
 }
 
+// saplingBranchID is the Sapling consensus branch ID, for when pirated
+// doesn't report the upgrade's name. (Orchard is found by name only, since
+// its branch ID isn't the same as Zcash's NU5.)
+const saplingBranchID = "76b809bb"
+
+// lightdInfoTTL is how long GetLightdInfo reuses the information it gets
+// from pirated.
+var lightdInfoTTL = 5 * time.Second
+
+var lightdInfoCache struct {
+	mutex   sync.Mutex
+	info    *walletrpc.LightdInfo
+	expires time.Time
+}
+
+// GetLightdInfo returns the server's information and pirated's (which is
+// cached for lightdInfoTTL, except in darkside mode, where the test driver
+// changes it). The caller may modify the result.
 func GetLightdInfo() (*walletrpc.LightdInfo, error) {
+	lightdInfoCache.mutex.Lock()
+	defer lightdInfoCache.mutex.Unlock()
+	if DarksideEnabled || lightdInfoCache.info == nil || !Time.Now().Before(lightdInfoCache.expires) {
+		info, err := getLightdInfoFromRPC()
+		if err != nil {
+			return nil, err
+		}
+		lightdInfoCache.info = info
+		lightdInfoCache.expires = Time.Now().Add(lightdInfoTTL)
+	}
+	return proto.Clone(lightdInfoCache.info).(*walletrpc.LightdInfo), nil
+}
+
+func getLightdInfoFromRPC() (*walletrpc.LightdInfo, error) {
 	result, rpcErr := RawRequest("getinfo", []json.RawMessage{})
 	if rpcErr != nil {
 		return nil, rpcErr
@@ -197,7 +323,7 @@ func GetLightdInfo() (*walletrpc.LightdInfo, error) {
 	var getinfoReply PiratedRpcReplyGetinfo
 	err := json.Unmarshal(result, &getinfoReply)
 	if err != nil {
-		return nil, rpcErr
+		return nil, err
 	}
 
 	result, rpcErr = RawRequest("getblockchaininfo", []json.RawMessage{})
@@ -207,13 +333,32 @@ func GetLightdInfo() (*walletrpc.LightdInfo, error) {
 	var getblockchaininfoReply PiratedRpcReplyGetblockchaininfo
 	err = json.Unmarshal(result, &getblockchaininfoReply)
 	if err != nil {
-		return nil, rpcErr
+		return nil, err
 	}
 	// If the sapling consensus branch doesn't exist, it must be regtest
-	var saplingHeight int
-	if saplingJSON, ok := getblockchaininfoReply.Upgrades["76b809bb"]; ok { // Sapling ID
-		saplingHeight = saplingJSON.ActivationHeight
-	}
+	var saplingHeight, orchardHeight int
+	var upgrades []*walletrpc.NetworkUpgrade
+	for branchID, upgrade := range getblockchaininfoReply.Upgrades {
+		name := strings.ToLower(upgrade.Name)
+		switch {
+		case branchID == saplingBranchID || name == "sapling":
+			saplingHeight = upgrade.ActivationHeight
+		case name == "orchard":
+			orchardHeight = upgrade.ActivationHeight
+		}
+		upgrades = append(upgrades, &walletrpc.NetworkUpgrade{
+			Name:             upgrade.Name,
+			BranchId:         branchID,
+			ActivationHeight: uint64(upgrade.ActivationHeight),
+			Status:           upgrade.Status,
+		})
+	}
+	sort.Slice(upgrades, func(i, j int) bool {
+		if upgrades[i].ActivationHeight != upgrades[j].ActivationHeight {
+			return upgrades[i].ActivationHeight < upgrades[j].ActivationHeight
+		}
+		return upgrades[i].BranchId < upgrades[j].BranchId
+	})
 
 	vendor := "Pirate LightWalletD"
 	if DarksideEnabled {
@@ -222,7 +367,6 @@ func GetLightdInfo() (*walletrpc.LightdInfo, error) {
 	return &walletrpc.LightdInfo{
 		Version:                 Version,
 		Vendor:                  vendor,
-		TaddrSupport:            false,
 		ChainName:               getblockchaininfoReply.Chain,
 		SaplingActivationHeight: uint64(saplingHeight),
 		ConsensusBranchId:       getblockchaininfoReply.Consensus.Chaintip,
@@ -234,6 +378,8 @@ func GetLightdInfo() (*walletrpc.LightdInfo, error) {
 		EstimatedHeight:         uint64(getblockchaininfoReply.EstimatedHeight),
 		PiratedBuild:            getinfoReply.Build,
 		PiratedSubversion:       getinfoReply.Subversion,
+		Upgrades:                upgrades,
+		OrchardActivationHeight: uint64(orchardHeight),
 	}, nil
 }
 
@@ -323,7 +469,11 @@ func getBlockFromRPC(height int) (*walletrpc.CompactBlock, error) {
 		}
 	}
 
-	return block.ToCompact(), nil
+	// The full header isn't part of the compact block that's sent to
+	// clients, but it's returned here so that the cache can save it.
+	cBlock := block.ToCompact()
+	cBlock.Header = block.GetHeader()
+	return cBlock, nil
 }
 
 var (
@@ -435,32 +585,144 @@ func GetBlock(cache *BlockCache, height int) (*walletrpc.CompactBlock, error) {
 		// Block height is too large
 		return nil, errors.New("block requested is newer than latest block")
 	}
+	block.Header = nil
 	return block, nil
 }
 
-// GetBlockRange returns a sequence of consecutive blocks in the given range.
-func GetBlockRange(cache *BlockCache, blockOut chan<- *walletrpc.CompactBlock, errOut chan<- error, start, end int) {
-	// Go over [start, end] inclusive
-	low := start
-	high := end
+// GetBlockHeader returns the full header of the block at the given height,
+// from the cache if possible, otherwise from pirated.
+func GetBlockHeader(cache *BlockCache, height int) (*walletrpc.BlockHeader, error) {
+	header := cache.GetHeader(height)
+	if header != nil {
+		return header, nil
+	}
+	block, err := getBlockFromRPC(height)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		// Block height is too large
+		return nil, errors.New("block requested is newer than latest block")
+	}
+	return &walletrpc.BlockHeader{
+		Height: block.Height,
+		Hash:   block.Hash,
+		Header: block.Header,
+	}, nil
+}
+
+// GetBlockTxid returns the txid (in internal, little-endian byte order) of
+// the transaction at position index within a block. The block may be
+// identified by height, by hash (little-endian, as in CompactBlock.Hash), or
+// both; if both are given they must refer to the same block. The txid list
+// comes from pirated's verbose getblock, since compact blocks omit
+// transactions that have no shielded elements. A block that is no longer
+// part of the best chain (it was reorged away) is reported as an error.
+func GetBlockTxid(cache *BlockCache, height int, hash []byte, index int) ([]byte, error) {
+	if index < 0 {
+		return nil, errors.New("transaction index must not be negative")
+	}
+	if hash != nil && len(hash) != 32 {
+		return nil, errors.New("block hash has invalid length")
+	}
+	if hash == nil && height <= 0 {
+		return nil, errors.New("request for unspecified identifier")
+	}
+	if hash != nil && height > 0 {
+		// Fail early if the cache knows this height has a different hash.
+		if block := cache.Get(height); block != nil && !bytes.Equal(block.Hash, hash) {
+			return nil, errors.New("block hash does not match the block at the requested height (reorg?)")
+		}
+	}
+	var idJSON []byte
+	var err error
+	if hash != nil {
+		idJSON, err = json.Marshal(displayHash(hash))
+	} else {
+		idJSON, err = json.Marshal(strconv.Itoa(height))
+	}
+	if err != nil {
+		return nil, err
+	}
+	params := []json.RawMessage{idJSON, json.RawMessage("1")}
+	result, rpcErr := RawRequest("getblock", params)
+	if rpcErr != nil {
+		// A height out of range, or an unknown block hash.
+		if code, _ := GetRPCErrorCode(rpcErr); code == RPCInvalidParameter || code == RPCInvalidAddressOrKey {
+			return nil, errors.New("block not found")
+		}
+		return nil, errors.Wrap(rpcErr, "error requesting verbose block")
+	}
+	var block1 PirateRpcReplyGetblock1
+	err = json.Unmarshal(result, &block1)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading JSON response")
+	}
+	if block1.Confirmations < 0 {
+		// pirated reports -1 confirmations for blocks not on the best chain
+		return nil, errors.New("block is not part of the best chain (reorg?)")
+	}
+	if height > 0 && block1.Height != height {
+		return nil, errors.New("block hash does not match the block at the requested height (reorg?)")
+	}
+	if index >= len(block1.Tx) {
+		return nil, errors.Errorf("transaction index %d out of range, block has %d transactions",
+			index, len(block1.Tx))
+	}
+	txid, err := hex.DecodeString(block1.Tx[index])
+	if err != nil || len(txid) != 32 {
+		return nil, errors.New("error decoding getblock txid")
+	}
+	// convert from big-endian
+	return parser.Reverse(txid), nil
+}
+
+// blockRangeReadAhead is how many blocks GetBlockRange fetches before
+// they're needed (so a slow client doesn't cause it to hold many blocks).
+var blockRangeReadAhead = 16
+
+// GetBlockRange starts fetching the blocks from start to end inclusive (in
+// reverse order if start is greater than end). The blocks are sent to the
+// returned block channel, which is closed after the last one, or early if
+// there's an error or ctx is done; the error (nil if all of the blocks were
+// sent, ctx.Err() if ctx is done) is then sent to the returned error
+// channel. The caller should cancel ctx if it stops reading the blocks, so
+// the fetching stops.
+func GetBlockRange(ctx context.Context, cache *BlockCache, start, end int) (<-chan *walletrpc.CompactBlock, <-chan error) {
+	blockOut := make(chan *walletrpc.CompactBlock, blockRangeReadAhead)
+	errOut := make(chan error, 1)
+	Metrics.BlockRangesInFlightGauge.Inc()
+	go func() {
+		defer Metrics.BlockRangesInFlightGauge.Dec()
+		errOut <- getBlockRange(ctx, cache, blockOut, start, end)
+	}()
+	return blockOut, errOut
+}
+
+func getBlockRange(ctx context.Context, cache *BlockCache, blockOut chan<- *walletrpc.CompactBlock, start, end int) error {
+	defer close(blockOut)
+	step := 1
 	if start > end {
 		// reverse the order
-		low, high = end, start
+		step = -1
 	}
-	for i := low; i <= high; i++ {
-		j := i
-		if start > end {
-			// reverse the order
-			j = high - (i - low)
+	for height := start; ; height += step {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
-		block, err := GetBlock(cache, j)
+		block, err := GetBlock(cache, height)
 		if err != nil {
-			errOut <- err
-			return
+			return err
+		}
+		select {
+		case blockOut <- block:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if height == end {
+			return nil
 		}
-		blockOut <- block
 	}
-	errOut <- nil
 }
 
 func displayHash(hash []byte) string {
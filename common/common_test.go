@@ -7,16 +7,24 @@ package common
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/PirateNetwork/lightwalletd/commitmenttree"
+	"github.com/PirateNetwork/lightwalletd/parser"
 	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sirupsen/logrus"
 )
 
@@ -110,6 +118,10 @@ func getLightdInfoStub(method string, params []json.RawMessage) (json.RawMessage
 			Blocks:    9977,
 			Chain:     "bugsbunny",
 			Consensus: ConsensusInfo{Chaintip: "someid"},
+			Upgrades: map[string]Upgradeinfo{
+				"c2d6d0b4": {Name: "Orchard", ActivationHeight: 2000, Status: "pending"},
+				"5ba81b19": {Name: "Overwinter", ActivationHeight: 1, Status: "active"},
+			},
 		})
 		return r, nil
 	}
@@ -120,6 +132,10 @@ func TestGetLightdInfo(t *testing.T) {
 	testT = t
 	RawRequest = getLightdInfoStub
 	Time.Sleep = sleepStub
+	now := time.Now()
+	Time.Now = func() time.Time { return now }
+	defer func() { Time.Now = time.Now }()
+	lightdInfoCache.info = nil
 	// This calls the getblockchaininfo rpc just to establish connectivity with zcashd
 	FirstRPC()
 
@@ -153,6 +169,25 @@ func TestGetLightdInfo(t *testing.T) {
 	if getLightdInfo.ConsensusBranchId != "someid" {
 		t.Error("unexpected ConsensusBranchId", getLightdInfo.ConsensusBranchId)
 	}
+	if len(getLightdInfo.Upgrades) != 2 || getLightdInfo.Upgrades[0].Name != "Overwinter" ||
+		getLightdInfo.Upgrades[1].BranchId != "c2d6d0b4" || getLightdInfo.Upgrades[1].Status != "pending" {
+		t.Error("unexpected upgrades", getLightdInfo.Upgrades)
+	}
+	if getLightdInfo.OrchardActivationHeight != 2000 {
+		t.Error("unexpected orchardActivationHeight", getLightdInfo.OrchardActivationHeight)
+	}
+
+	// pirated's information is cached (and the caller may modify it)
+	getLightdInfo.ChainName = "modified"
+	savedStep := step
+	getLightdInfo, err = GetLightdInfo()
+	if err != nil || step != savedStep || getLightdInfo.ChainName != "bugsbunny" {
+		t.Error("GetLightdInfo should have used the cached information", err, step)
+	}
+	now = now.Add(lightdInfoTTL)
+	if _, err = GetLightdInfo(); err != nil || step != savedStep+2 {
+		t.Error("GetLightdInfo should have called pirated again", err, step)
+	}
 
 	if sleepCount != 1 || sleepDuration != 15*time.Second {
 		t.Error("unexpected sleeps", sleepCount, sleepDuration)
@@ -176,8 +211,38 @@ func checkSleepMethod(count int, duration time.Duration, expected string, method
 	}
 }
 
+// verboseGetblockStub returns what pirated's verbose getblock (the txid
+// list that getBlockFromRPC requests after the raw block) would return for
+// the test block at the requested height.
+func verboseGetblockStub(params []json.RawMessage) (json.RawMessage, error) {
+	var height string
+	err := json.Unmarshal(params[0], &height)
+	if err != nil {
+		testT.Fatal("could not unmarshal height")
+	}
+	h, _ := strconv.Atoi(height)
+	var blockHex string
+	json.Unmarshal(blocks[h-380640], &blockHex)
+	blockData, _ := hex.DecodeString(blockHex)
+	block := parser.NewBlock()
+	if _, err := block.ParseFromSlice(blockData); err != nil {
+		testT.Fatal("could not parse test block")
+	}
+	reply := PirateRpcReplyGetblock1{
+		Hash:   hex.EncodeToString(block.GetDisplayHash()),
+		Height: h,
+	}
+	for _, tx := range block.Transactions() {
+		reply.Tx = append(reply.Tx, hex.EncodeToString(tx.GetDisplayHash()))
+	}
+	return json.Marshal(reply)
+}
+
 // There are four test blocks, 0..3
 func blockIngestorStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method == "getblock" && string(params[1]) == "1" {
+		return verboseGetblockStub(params)
+	}
 	step++
 	// request the first two blocks very quickly (syncing),
 	// then next block isn't yet available
@@ -350,6 +415,23 @@ func blockIngestorStub(method string, params []json.RawMessage) (json.RawMessage
 	return nil, nil
 }
 
+func TestGetRPCErrorCode(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		code RPCErrorCode
+		ok   bool
+	}{
+		{errors.New("-27: transaction already in block chain"), RPCVerifyAlreadyInChain, true},
+		{errors.New("-5:No information available about transaction"), RPCInvalidAddressOrKey, true},
+		{errors.New("-26"), RPCVerifyRejected, true},
+		{errors.New("connection refused"), 0, false},
+	} {
+		if code, ok := GetRPCErrorCode(tt.err); code != tt.code || ok != tt.ok {
+			t.Fatal("unexpected error code", tt.err, code, ok)
+		}
+	}
+}
+
 func TestBlockIngestor(t *testing.T) {
 	testT = t
 	RawRequest = blockIngestorStub
@@ -372,6 +454,9 @@ func TestBlockIngestor(t *testing.T) {
 // There are four test blocks, 0..3
 // (probably don't need all these cases)
 func getblockStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method == "getblock" && string(params[1]) == "1" {
+		return verboseGetblockStub(params)
+	}
 	if method != "getblock" {
 		testT.Error("unexpected method")
 	}
@@ -487,44 +572,22 @@ func getblockStub(method string, params []json.RawMessage) (json.RawMessage, err
 
 func TestGetBlockRange(t *testing.T) {
 	testT = t
+	Metrics = GetPrometheusMetrics()
 	RawRequest = getblockStub
 	os.RemoveAll(unitTestPath)
 	testcache = NewBlockCache(unitTestPath, unitTestChain, 380640, 0)
-	blockChan := make(chan *walletrpc.CompactBlock)
-	errChan := make(chan error)
-	go GetBlockRange(testcache, blockChan, errChan, 380640, 380642)
-
-	// read in block 380640
-	select {
-	case err := <-errChan:
-		// this will also catch context.DeadlineExceeded from the timeout
-		t.Fatal("unexpected error:", err)
-	case cBlock := <-blockChan:
-		if cBlock.Height != 380640 {
-			t.Fatal("unexpected Height:", cBlock.Height)
-		}
-	}
+	blockChan, errChan := GetBlockRange(context.Background(), testcache, 380640, 380642)
 
-	// read in block 380641
-	select {
-	case err := <-errChan:
-		// this will also catch context.DeadlineExceeded from the timeout
-		t.Fatal("unexpected error:", err)
-	case cBlock := <-blockChan:
-		if cBlock.Height != 380641 {
-			t.Fatal("unexpected Height:", cBlock.Height)
-		}
+	// read in blocks 380640 and 380641; reading 380642 fails (see case 3 above)
+	var heights []uint64
+	for cBlock := range blockChan {
+		heights = append(heights, cBlock.Height)
 	}
-
-	// try to read in block 380642, but this will fail (see case 3 above)
-	select {
-	case err := <-errChan:
-		// this will also catch context.DeadlineExceeded from the timeout
-		if err.Error() != "block requested is newer than latest block" {
-			t.Fatal("unexpected error:", err)
-		}
-	case _ = <-blockChan:
-		t.Fatal("reading height 22 should have failed")
+	if len(heights) != 2 || heights[0] != 380640 || heights[1] != 380641 {
+		t.Fatal("unexpected heights:", heights)
+	}
+	if err := <-errChan; err == nil || err.Error() != "block requested is newer than latest block" {
+		t.Fatal("unexpected error:", err)
 	}
 
 	step = 0
@@ -533,6 +596,9 @@ func TestGetBlockRange(t *testing.T) {
 
 // There are four test blocks, 0..3
 func getblockStubReverse(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method == "getblock" && string(params[1]) == "1" {
+		return verboseGetblockStub(params)
+	}
 	var height string
 	err := json.Unmarshal(params[0], &height)
 	if err != nil {
@@ -566,49 +632,102 @@ func getblockStubReverse(method string, params []json.RawMessage) (json.RawMessa
 
 func TestGetBlockRangeReverse(t *testing.T) {
 	testT = t
+	Metrics = GetPrometheusMetrics()
 	RawRequest = getblockStubReverse
 	os.RemoveAll(unitTestPath)
 	testcache = NewBlockCache(unitTestPath, unitTestChain, 380640, 0)
-	blockChan := make(chan *walletrpc.CompactBlock)
-	errChan := make(chan error)
 
 	// Request the blocks in reverse order by specifying start greater than end
-	go GetBlockRange(testcache, blockChan, errChan, 380642, 380640)
-
-	// read in block 380642
-	select {
-	case err := <-errChan:
-		// this will also catch context.DeadlineExceeded from the timeout
+	blockChan, errChan := GetBlockRange(context.Background(), testcache, 380642, 380640)
+	var heights []uint64
+	for cBlock := range blockChan {
+		heights = append(heights, cBlock.Height)
+	}
+	if len(heights) != 3 || heights[0] != 380642 || heights[1] != 380641 || heights[2] != 380640 {
+		t.Fatal("unexpected heights:", heights)
+	}
+	if err := <-errChan; err != nil {
 		t.Fatal("unexpected error:", err)
-	case cBlock := <-blockChan:
-		if cBlock.Height != 380642 {
-			t.Fatal("unexpected Height:", cBlock.Height)
-		}
 	}
+	step = 0
+	os.RemoveAll(unitTestPath)
+}
 
-	// read in block 380641
-	select {
-	case err := <-errChan:
-		// this will also catch context.DeadlineExceeded from the timeout
-		t.Fatal("unexpected error:", err)
-	case cBlock := <-blockChan:
-		if cBlock.Height != 380641 {
-			t.Fatal("unexpected Height:", cBlock.Height)
-		}
+// waitForGoroutines waits (briefly) for the number of goroutines to drop
+// to the given number, and returns the number.
+func waitForGoroutines(n int) int {
+	for i := 0; i < 100 && runtime.NumGoroutine() > n; i++ {
+		time.Sleep(10 * time.Millisecond)
 	}
+	return runtime.NumGoroutine()
+}
 
-	// read in block 380640
-	select {
-	case err := <-errChan:
-		// this will also catch context.DeadlineExceeded from the timeout
-		t.Fatal("unexpected error:", err)
-	case cBlock := <-blockChan:
-		if cBlock.Height != 380640 {
-			t.Fatal("unexpected Height:", cBlock.Height)
+func TestGetBlockRangeCancel(t *testing.T) {
+	testT = t
+	Metrics = GetPrometheusMetrics()
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 1000, 0)
+	defer func() {
+		c.Close()
+		os.RemoveAll(unitTestPath)
+	}()
+	tree := commitmenttree.NewTree(commitmenttree.Sapling)
+	for height := 1000; height < 1100; height++ {
+		if err := c.Add(height, saplingTestBlock(height, 0, 0, tree, true)); err != nil {
+			t.Fatal(err)
 		}
 	}
-	step = 0
-	os.RemoveAll(unitTestPath)
+	goroutines := runtime.NumGoroutine()
+
+	// The client stops reading (it's gone), and the fetching stops too.
+	ctx, cancel := context.WithCancel(context.Background())
+	blockChan, errChan := GetBlockRange(ctx, c, 1000, 1099)
+	if block := <-blockChan; block.Height != 1000 {
+		t.Fatal("unexpected height", block.Height)
+	}
+	// Only a limited number of blocks are fetched ahead.
+	for i := 0; i < 100 && len(blockChan) < blockRangeReadAhead; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if len(blockChan) != blockRangeReadAhead {
+		t.Fatal("unexpected number of blocks read ahead", len(blockChan))
+	}
+	if n := testutil.ToFloat64(Metrics.BlockRangesInFlightGauge); n != 1 {
+		t.Fatal("unexpected ranges in flight", n)
+	}
+	cancel()
+	if err := <-errChan; err != context.Canceled {
+		t.Fatal("unexpected error", err)
+	}
+	for range blockChan {
+		// the block channel is closed
+	}
+	if n := testutil.ToFloat64(Metrics.BlockRangesInFlightGauge); n != 0 {
+		t.Fatal("unexpected ranges in flight", n)
+	}
+
+	// Likewise when the deadline passes.
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, errChan = GetBlockRange(ctx, c, 1099, 1000)
+	if err := <-errChan; err != context.DeadlineExceeded {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Many abandoned ranges leave nothing behind.
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		blockChan, _ := GetBlockRange(ctx, c, 1000, 1099)
+		<-blockChan
+		cancel()
+	}
+	if n := waitForGoroutines(goroutines); n > goroutines {
+		t.Fatal("goroutines leaked:", n-goroutines)
+	}
+	if n := testutil.ToFloat64(Metrics.BlockRangesInFlightGauge); n != 0 {
+		t.Fatal("unexpected ranges in flight", n)
+	}
 }
 
 func TestGenerateCerts(t *testing.T) {
@@ -617,157 +736,326 @@ func TestGenerateCerts(t *testing.T) {
 	}
 }
 
-// ------------------------------------------ GetMempoolStream
+// ------------------------------------------ Mempool
+
+// What the mempool stub (mock pirated) returns.
+var mempoolTest struct {
+	bestBlockHash string
+	txids         []string          // the mempool
+	rawTxs        map[string]string // hex, by txid
+	mined         map[string]int    // height, by txid
+	mempoolCalls  int
+	fetches       int
+}
 
-// Note that in mocking zcashd's RPC replies here, we don't really need
-// actual txids or transactions, or even strings with the correct format
-// for those, except that a transaction must be a hex string.
 func mempoolStub(method string, params []json.RawMessage) (json.RawMessage, error) {
-	step++
-	switch step {
-	case 1:
-		// This will be a getblockchaininfo request
-		if method != "getblockchaininfo" {
-			testT.Fatal("expecting blockchaininfo")
-		}
-		r, _ := json.Marshal(&PiratedRpcReplyGetblockchaininfo{
-			BestBlockHash: "010203",
-			Blocks:        200,
-		})
-		return r, nil
-	case 2:
-		// No new block has arrived.
-		if method != "getblockchaininfo" {
-			testT.Fatal("expecting blockchaininfo")
-		}
-		r, _ := json.Marshal(&PiratedRpcReplyGetblockchaininfo{
-			BestBlockHash: "010203",
-			Blocks:        200,
-		})
-		return r, nil
-	case 3:
-		// Expect a getrawmempool next.
-		if method != "getrawmempool" {
-			testT.Fatal("expecting getrawmempool")
-		}
-		// In reality, this would be a hex txid
-		r, _ := json.Marshal([]string{
-			"mempooltxid-1",
-		})
-		return r, nil
-	case 4:
-		// Next, it should ask for this transaction (non-verbose).
-		if method != "getrawtransaction" {
-			testT.Fatal("expecting getrawtransaction")
-		}
-		var txid string
-		json.Unmarshal(params[0], &txid)
-		if txid != "mempooltxid-1" {
-			testT.Fatal("unexpected txid")
-		}
-		r, _ := json.Marshal("aabb")
-		return r, nil
-	case 5:
-		// Simulate that still no new block has arrived ...
-		if method != "getblockchaininfo" {
-			testT.Fatal("expecting blockchaininfo")
-		}
-		r, _ := json.Marshal(&PiratedRpcReplyGetblockchaininfo{
-			BestBlockHash: "010203",
+	switch method {
+	case "getblockchaininfo":
+		return json.Marshal(&PiratedRpcReplyGetblockchaininfo{
+			BestBlockHash: mempoolTest.bestBlockHash,
 			Blocks:        200,
 		})
-		return r, nil
-	case 6:
-		// ... but there a second tx has arrived in the mempool
-		if method != "getrawmempool" {
-			testT.Fatal("expecting getrawmempool")
-		}
-		// In reality, this would be a hex txid
-		r, _ := json.Marshal([]string{
-			"mempooltxid-2",
-			"mempooltxid-1"})
-		return r, nil
-	case 7:
-		// The new mempool tx (and only that one) gets fetched
-		if method != "getrawtransaction" {
-			testT.Fatal("expecting getrawtransaction")
-		}
+	case "getrawmempool":
+		mempoolTest.mempoolCalls++
+		return json.Marshal(mempoolTest.txids)
+	case "getrawtransaction":
 		var txid string
 		json.Unmarshal(params[0], &txid)
-		if txid != "mempooltxid-2" {
-			testT.Fatal("unexpected txid")
+		if string(params[1]) == "1" {
+			if height, ok := mempoolTest.mined[txid]; ok {
+				return json.Marshal(&PiratedRpcReplyGetrawtransaction{Height: height})
+			}
+			return nil, errors.New("-5: No such mempool or blockchain transaction")
 		}
-		r, _ := json.Marshal("ccdd")
-		return r, nil
-	case 8:
-		// A new block arrives, this will cause these two tx to be returned
-		if method != "getblockchaininfo" {
-			testT.Fatal("expecting blockchaininfo")
+		rawTx, ok := mempoolTest.rawTxs[txid]
+		if !ok {
+			return nil, errors.New("-5: No such mempool or blockchain transaction")
 		}
-		r, _ := json.Marshal(&PiratedRpcReplyGetblockchaininfo{
-			BestBlockHash: "d1d2d3",
-			Blocks:        201,
-		})
-		return r, nil
+		mempoolTest.fetches++
+		return json.Marshal(rawTx)
 	}
-	testT.Fatal("ran out of cases")
+	testT.Fatal("unexpected call", method)
 	return nil, nil
 }
 
-func TestMempoolStream(t *testing.T) {
+// mempoolTestSetup makes the mock pirated's mempool available: two shielded
+// transactions (from the ZIP 243 test vectors), a transaction with no
+// inputs or outputs at all, and one that can't be parsed. It returns their
+// txids, which don't need to be the real ones, in that order.
+func mempoolTestSetup(t *testing.T) []string {
 	testT = t
 	RawRequest = mempoolStub
-	Time.Sleep = sleepStub
-	Time.Now = nowStub
-	// In real life, wall time is not close to zero, simulate that.
-	sleepDuration = 1000 * time.Second
-
-	var replies []*walletrpc.RawTransaction
-	// The first request after startup immediately returns an empty list.
-	err := GetMempool(func(tx *walletrpc.RawTransaction) error {
-		t.Fatal("send to client function called on initial GetMempool call")
-		return nil
-	})
+	Time.Now = time.Now
+	Metrics = GetPrometheusMetrics()
+	f, err := os.Open("../testdata/zip243_raw_tx")
 	if err != nil {
-		t.Fatal("GetMempool failed")
+		t.Fatal(err)
+	}
+	defer f.Close()
+	txHex := make([]string, 0)
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 1<<20), 1<<22)
+	for scan.Scan() {
+		if scan.Text() != "" && !strings.HasPrefix(scan.Text(), "#") {
+			txHex = append(txHex, scan.Text())
+		}
 	}
+	txHex = append(txHex,
+		"0400008085202f89000000000000000000000000000000000000000000",
+		"0400")
+	mempoolTest.bestBlockHash = "0102"
+	mempoolTest.txids = nil
+	mempoolTest.rawTxs = make(map[string]string)
+	mempoolTest.mined = make(map[string]int)
+	mempoolTest.mempoolCalls = 0
+	mempoolTest.fetches = 0
+	txids := make([]string, 0)
+	for i, rawTx := range txHex {
+		txid := strings.Repeat(fmt.Sprintf("%02x", 0xa0+i), 32)
+		mempoolTest.rawTxs[txid] = rawTx
+		txids = append(txids, txid)
+	}
+	return txids
+}
 
-	// This should return two transactions.
-	err = GetMempool(func(tx *walletrpc.RawTransaction) error {
-		replies = append(replies, tx)
-		return nil
-	})
+// txidHash returns the given big-endian hex txid as a (little-endian) hash.
+func txidHash(txid string) []byte {
+	hash, _ := hex.DecodeString(txid)
+	return parser.Reverse(hash)
+}
+
+func TestMempool(t *testing.T) {
+	txids := mempoolTestSetup(t)
+	a, b, transparent, bad := txids[0], txids[1], txids[2], txids[3]
+	m := NewMempool()
+	mempoolTest.txids = []string{a, transparent, bad, strings.Repeat("ff", 32)}
+	// The poller isn't running, so Txs() fetches the mempool.
+	txs, err := m.Txs()
 	if err != nil {
-		t.Fatal("GetMempool failed")
+		t.Fatal("Txs failed", err)
 	}
-	if len(replies) != 2 {
-		t.Fatal("unexpected number of tx")
+	if len(txs) != 3 || mempoolTest.fetches != 3 {
+		t.Fatal("unexpected mempool size", len(txs), mempoolTest.fetches)
 	}
-	// The interface guarantees that the transactions will be returned
-	// in the order they entered the mempool.
-	if !bytes.Equal([]byte(replies[0].GetData()), []byte{0xaa, 0xbb}) {
-		t.Fatal("unexpected tx contents")
+	for i, txid := range []string{a, transparent, bad} {
+		tx := txs[i]
+		if tx.Txid != txid || tx.Raw.Height != 200 || tx.FirstSeen.IsZero() {
+			t.Fatal("unexpected transaction", i, tx.Txid, tx.Raw.Height)
+		}
+		if tx.Size*2 != len(mempoolTest.rawTxs[txid]) || hex.EncodeToString(tx.Raw.Data) != mempoolTest.rawTxs[txid] {
+			t.Fatal("unexpected transaction data", i)
+		}
 	}
-	if replies[0].GetHeight() != 200 {
-		t.Fatal("unexpected tx height")
+	if txs[0].Compact == nil || !bytes.Equal(txs[0].Compact.Hash, txidHash(a)) {
+		t.Fatal("unexpected compact transaction")
 	}
-	if !bytes.Equal([]byte(replies[1].GetData()), []byte{0xcc, 0xdd}) {
-		t.Fatal("unexpected tx contents")
+	if txs[1].Compact != nil || txs[2].Compact != nil {
+		t.Fatal("unexpected compact transaction for transparent or unparsable transaction")
 	}
-	if replies[1].GetHeight() != 200 {
-		t.Fatal("unexpected tx height")
+	// It's fresh, so it's not fetched again.
+	if _, err := m.Txs(); err != nil || mempoolTest.mempoolCalls != 1 {
+		t.Fatal("Txs fetched the mempool again", err, mempoolTest.mempoolCalls)
 	}
 
-	// Time started at 1000 seconds (since 1970), and just over 4 seconds
-	// should have elapsed. The units here are nanoseconds.
-	if sleepDuration != 1004400000000 {
-		t.Fatal("unexpected end time")
+	// Each subscriber gets each update, starting from the current mempool.
+	sub1, txs, hash := m.Subscribe()
+	sub2, _, _ := m.Subscribe()
+	if len(txs) != 3 || hash != "0102" || len(m.subscribers) != 2 {
+		t.Fatal("unexpected subscription", len(txs), hash)
+	}
+	mempoolTest.txids = []string{b, a}
+	mempoolTest.mined[transparent] = 190
+	if err := m.poll(); err != nil {
+		t.Fatal("poll failed", err)
 	}
-	if step != 8 {
-		t.Fatal("unexpected number of zcashd RPCs")
+	if mempoolTest.fetches != 4 {
+		t.Fatal("poll refetched transactions", mempoolTest.fetches)
 	}
+	for _, sub := range []*MempoolSubscription{sub1, sub2} {
+		updates, err := sub.Next(context.Background())
+		if err != nil || len(updates) != 1 {
+			t.Fatal("unexpected updates", err, len(updates))
+		}
+		update := updates[0]
+		if len(update.Added) != 1 || update.Added[0].Txid != b || len(update.Removed) != 2 {
+			t.Fatal("unexpected update", len(update.Added), len(update.Removed))
+		}
+		for _, removal := range update.Removed {
+			if removal.Tx.Txid == transparent && removal.MinedHeight != 190 ||
+				removal.Tx.Txid == bad && removal.MinedHeight != 0 {
+				t.Fatal("unexpected removal", removal.Tx.Txid, removal.MinedHeight)
+			}
+		}
+	}
+	txs, _ = m.Txs()
+	if len(txs) != 2 || txs[0].Txid != a || txs[1].Txid != b {
+		t.Fatal("unexpected mempool after update")
+	}
+	// A poll that finds nothing new doesn't send an update.
+	if err := m.poll(); err != nil {
+		t.Fatal("poll failed", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sub1.Next(ctx); err != context.Canceled {
+		t.Fatal("Next should have returned the context error", err)
+	}
+	// A subscriber that doesn't keep up is dropped.
+	mempoolMaxPending = 1
+	mempoolTest.bestBlockHash = "0103"
+	m.poll()
+	mempoolTest.bestBlockHash = "0104"
+	m.poll()
+	mempoolMaxPending = 1000
+	if _, err := sub1.Next(context.Background()); err == nil {
+		t.Fatal("Next should have failed for a subscriber that fell behind")
+	}
+	sub1.Close()
+	sub2.Close()
+	sub2.Close()
+	if len(m.subscribers) != 0 {
+		t.Fatal("unexpected subscribers after Close")
+	}
+}
 
-	step = 0
-	sleepCount = 0
-	sleepDuration = 0
+func TestMempoolStream(t *testing.T) {
+	txids := mempoolTestSetup(t)
+	m := NewMempool()
+	mempoolTest.txids = []string{txids[0]}
+	m.poll()
+	sent := make(chan *walletrpc.RawTransaction, 10)
+	done := make(chan error)
+	go func() {
+		done <- m.SendRaw(context.Background(), func(tx *walletrpc.RawTransaction) error {
+			sent <- tx
+			return nil
+		})
+	}()
+	expect := func(txid string) {
+		tx := <-sent
+		if hex.EncodeToString(tx.Data) != mempoolTest.rawTxs[txid] || tx.Height != 200 {
+			t.Fatal("unexpected transaction")
+		}
+	}
+	// The transaction already in the mempool, then a new one.
+	expect(txids[0])
+	mempoolTest.txids = []string{txids[0], txids[1]}
+	m.poll()
+	expect(txids[1])
+	// A new block ends the stream.
+	mempoolTest.bestBlockHash = "0103"
+	mempoolTest.txids = []string{txids[2]}
+	m.poll()
+	if err := <-done; err != nil {
+		t.Fatal("SendRaw failed", err)
+	}
+	if len(sent) != 0 {
+		t.Fatal("SendRaw sent a transaction after the new block")
+	}
+}
+
+func TestMempoolEvents(t *testing.T) {
+	txids := mempoolTestSetup(t)
+	a, b, transparent, bad := txids[0], txids[1], txids[2], txids[3]
+	m := NewMempool()
+	mempoolTest.txids = []string{a, transparent, bad}
+	m.poll()
+	events := make(chan *walletrpc.MempoolEvent, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- m.SendEvents(ctx, func(event *walletrpc.MempoolEvent) error {
+			events <- event
+			return nil
+		})
+	}()
+	expect := func(eventType walletrpc.MempoolEventType, txid string, height uint64) {
+		event := <-events
+		if event.Type != eventType || !bytes.Equal(event.Txid, txidHash(txid)) || event.Height != height {
+			t.Fatal("unexpected event", event)
+		}
+		if (event.Tx != nil) != (eventType == walletrpc.MempoolEventType_mempoolAdded) {
+			t.Fatal("unexpected compact tx in event", event)
+		}
+		if event.Tx != nil && !bytes.Equal(event.Tx.Hash, txidHash(txid)) {
+			t.Fatal("unexpected compact tx hash in event", event)
+		}
+	}
+	// Only the shielded transaction is sent.
+	expect(walletrpc.MempoolEventType_mempoolAdded, a, 0)
+	mempoolTest.txids = []string{a, b}
+	m.poll()
+	expect(walletrpc.MempoolEventType_mempoolAdded, b, 0)
+	// A new block (which doesn't end the stream) includes a.
+	mempoolTest.bestBlockHash = "0103"
+	mempoolTest.txids = []string{b}
+	mempoolTest.mined[a] = 1000
+	m.poll()
+	expect(walletrpc.MempoolEventType_mempoolMined, a, 1000)
+	mempoolTest.txids = []string{}
+	m.poll()
+	expect(walletrpc.MempoolEventType_mempoolEvicted, b, 0)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal("SendEvents should have returned the context error, got", err)
+	}
+	if len(events) != 0 {
+		t.Fatal("unexpected events", len(events))
+	}
+}
+
+func TestMempoolInfo(t *testing.T) {
+	txids := mempoolTestSetup(t)
+	a, b, transparent := txids[0], txids[1], txids[2]
+	now := time.Unix(1600000000, 0)
+	Time.Now = func() time.Time { return now }
+	defer func() { Time.Now = time.Now }()
+	m := NewMempool()
+	mempoolTest.txids = []string{a, transparent}
+	info, err := m.Info()
+	if err != nil {
+		t.Fatal("Info failed", err)
+	}
+	if info.Size != 2 || info.OldestAge != 0 || info.AdditionsPerMinute != 0 {
+		t.Fatal("unexpected info", info)
+	}
+	now = now.Add(time.Minute)
+	mempoolTest.txids = []string{a, transparent, b}
+	m.poll()
+	now = now.Add(time.Minute)
+	mempoolTest.txids = []string{a}
+	mempoolTest.mined[transparent] = 190
+	m.poll()
+
+	txs, _ := m.Txs()
+	compact := txs[0].Compact
+	expected := &walletrpc.MempoolInfo{
+		Size:  1,
+		Bytes: uint64(len(mempoolTest.rawTxs[a]) / 2),
+		// Three additions and one eviction (b; transparent was mined) in two minutes.
+		OldestAge:          120,
+		AdditionsPerMinute: 1.5,
+		EvictionsPerMinute: 0.5,
+	}
+	if len(compact.Spends) > 0 {
+		expected.SaplingSpendTxs = 1
+	}
+	if len(compact.Outputs) > 0 {
+		expected.SaplingOutputTxs = 1
+	}
+	if len(compact.Actions) > 0 {
+		expected.OrchardActionTxs = 1
+	}
+	if info, _ = m.Info(); !proto.Equal(info, expected) {
+		t.Fatal("unexpected info", info, "expected", expected)
+	}
+	// Polls that don't find changes still age the rates.
+	now = now.Add(15 * time.Minute)
+	m.poll()
+	expected.OldestAge = 17 * 60
+	expected.AdditionsPerMinute = 0
+	expected.EvictionsPerMinute = 0
+	if info, _ = m.Info(); !proto.Equal(info, expected) || len(m.history) != 0 {
+		t.Fatal("unexpected info", info, "expected", expected)
+	}
 }
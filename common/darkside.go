@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
 )
 
 type darksideState struct {
@@ -49,6 +50,9 @@ type darksideState struct {
 
 	// Unordered list of replies
 	getAddressUtxos []PiratedRpcReplyGetaddressutxos
+
+	// Subtree roots returned by z_getsubtreesbyindex, by shielded protocol
+	subtrees map[walletrpc.ShieldedProtocol]*walletrpc.DarksideSubtreeRoots
 }
 
 var state darksideState
@@ -92,6 +96,7 @@ func DarksideReset(sa int, bi, cn string) error {
 		stagedTransactions:   make([]stagedTx, 0),
 	}
 	state.cache.Reset(sa)
+	mempool.reset()
 	return nil
 }
 
@@ -370,6 +375,16 @@ func DarksideClearIncomingTransactions() {
 	state.incomingTransactions = make([][]byte, 0)
 }
 
+// darksideTxid returns the txid, in display (big-endian) order, of a raw
+// transaction. Transactions parsed from raw blocks have no txid set (pirated
+// supplies it in real mode), so darksidewalletd computes it. This is the
+// txid of a v4 transaction; v5 txids aren't computed here.
+func darksideTxid(txBytes []byte) []byte {
+	first := sha256.Sum256(txBytes)
+	txid := sha256.Sum256(first[:])
+	return parser.Reverse(txid[:])
+}
+
 func darksideRawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
 	switch method {
 	case "getblockchaininfo":
@@ -393,17 +408,32 @@ func darksideRawRequest(method string, params []json.RawMessage) (json.RawMessag
 		if err != nil {
 			return nil, errors.New("failed to parse getblock request")
 		}
-
-		height, err := strconv.Atoi(heightStr)
-		if err != nil {
-			return nil, errors.New("error parsing height as integer")
-		}
 		state.mutex.RLock()
 		defer state.mutex.RUnlock()
 		const notFoundErr = "-8:"
 		if len(state.activeBlocks) == 0 {
 			return nil, errors.New(notFoundErr)
 		}
+		if len(heightStr) == 64 {
+			// The block is being requested by (display-order) hash
+			hashStr := heightStr
+			heightStr = "-1"
+			for _, b := range state.activeBlocks {
+				block := parser.NewBlock()
+				block.ParseFromSlice(b)
+				if hex.EncodeToString(block.GetDisplayHash()) == hashStr {
+					heightStr = strconv.Itoa(block.GetHeight())
+					break
+				}
+			}
+			if heightStr == "-1" {
+				return nil, errors.New("-5: Block not found")
+			}
+		}
+		height, err := strconv.Atoi(heightStr)
+		if err != nil {
+			return nil, errors.New("error parsing height as integer")
+		}
 		if height > state.latestHeight {
 			return nil, errors.New(notFoundErr)
 		}
@@ -415,6 +445,23 @@ func darksideRawRequest(method string, params []json.RawMessage) (json.RawMessag
 		if index >= len(state.activeBlocks) {
 			return nil, errors.New(notFoundErr)
 		}
+		if len(params) > 1 && string(params[1]) == "1" {
+			// verbose: return the block's hash and list of txids
+			block := parser.NewBlock()
+			if _, err := block.ParseFromSlice(state.activeBlocks[index]); err != nil {
+				return nil, err
+			}
+			reply := PirateRpcReplyGetblock1{
+				Hash:          hex.EncodeToString(block.GetDisplayHash()),
+				Height:        height,
+				Confirmations: state.latestHeight - height + 1,
+				Tx:            make([]string, 0),
+			}
+			for _, tx := range block.Transactions() {
+				reply.Tx = append(reply.Tx, hex.EncodeToString(darksideTxid(tx.Bytes())))
+			}
+			return json.Marshal(reply)
+		}
 		return json.Marshal(hex.EncodeToString(state.activeBlocks[index]))
 
 	case "getbestblockhash":
@@ -457,14 +504,12 @@ func darksideRawRequest(method string, params []json.RawMessage) (json.RawMessag
 		}
 		state.incomingTransactions = append(state.incomingTransactions, txBytes)
 
-		return []byte(hex.EncodeToString(tx.GetDisplayHash())), nil
+		return json.Marshal(hex.EncodeToString(darksideTxid(txBytes)))
 
 	case "getrawmempool":
 		reply := make([]string, 0)
 		addTxToReply := func(txBytes []byte) {
-			ctx := parser.NewTransaction()
-			ctx.ParseFromSlice(txBytes)
-			reply = append(reply, hex.EncodeToString(ctx.GetDisplayHash()))
+			reply = append(reply, hex.EncodeToString(darksideTxid(txBytes)))
 		}
 		for _, blockBytes := range state.stagedBlocks {
 			block := parser.NewBlock()
@@ -495,11 +540,66 @@ func darksideRawRequest(method string, params []json.RawMessage) (json.RawMessag
 		}
 		return json.Marshal(utxosReply)
 
+	case "getaddressmempool":
+		// Staged transactions aren't indexed by address.
+		return json.Marshal(make([]PiratedRpcReplyGetaddressmempool, 0))
+
+	case "z_getsubtreesbyindex":
+		return darksideGetSubtreesByIndex(params)
+
 	default:
 		return nil, errors.New("there was an attempt to call an unsupported RPC")
 	}
 }
 
+func darksideGetSubtreesByIndex(params []json.RawMessage) (json.RawMessage, error) {
+	var pool string
+	err := json.Unmarshal(params[0], &pool)
+	if err != nil {
+		return nil, errors.New("failed to parse z_getsubtreesbyindex pool")
+	}
+	protocol, ok := walletrpc.ShieldedProtocol_value[pool]
+	if !ok {
+		return nil, errors.New("-8: Invalid pool name")
+	}
+	var startIndex, limit int
+	err = json.Unmarshal(params[1], &startIndex)
+	if err != nil {
+		return nil, errors.New("failed to parse z_getsubtreesbyindex start index")
+	}
+	if len(params) > 2 {
+		err = json.Unmarshal(params[2], &limit)
+		if err != nil {
+			return nil, errors.New("failed to parse z_getsubtreesbyindex limit")
+		}
+	}
+	state.mutex.RLock()
+	defer state.mutex.RUnlock()
+	reply := PiratedRpcReplyGetsubtreebyindex{
+		Pool:       pool,
+		StartIndex: startIndex,
+		Subtrees:   make([]PiratedSubtree, 0),
+	}
+	staged := state.subtrees[walletrpc.ShieldedProtocol(protocol)]
+	if staged == nil {
+		return json.Marshal(reply)
+	}
+	for i := startIndex - int(staged.StartIndex); i < len(staged.SubtreeRoots); i++ {
+		if i < 0 {
+			continue
+		}
+		if limit > 0 && len(reply.Subtrees) >= limit {
+			break
+		}
+		root := staged.SubtreeRoots[i]
+		reply.Subtrees = append(reply.Subtrees, PiratedSubtree{
+			Root:      hex.EncodeToString(root.RootHash),
+			EndHeight: int(root.CompletingBlockHeight),
+		})
+	}
+	return json.Marshal(reply)
+}
+
 func darksideGetRawTransaction(params []json.RawMessage) (json.RawMessage, error) {
 	if !state.resetted {
 		return nil, errors.New("please call Reset first")
@@ -539,7 +639,7 @@ func darksideGetRawTransaction(params []json.RawMessage) (json.RawMessage, error
 			block := parser.NewBlock()
 			_, _ = block.ParseFromSlice(b)
 			for _, tx := range block.Transactions() {
-				if bytes.Equal(tx.GetDisplayHash(), txid) {
+				if bytes.Equal(darksideTxid(tx.Bytes()), txid) {
 					return marshalReply(tx, block.GetHeight())
 				}
 			}
@@ -558,7 +658,7 @@ func darksideGetRawTransaction(params []json.RawMessage) (json.RawMessage, error
 	for _, stx := range state.stagedTransactions {
 		tx := parser.NewTransaction()
 		_, _ = tx.ParseFromSlice(stx.bytes)
-		if bytes.Equal(tx.GetDisplayHash(), txid) {
+		if bytes.Equal(darksideTxid(tx.Bytes()), txid) {
 			return marshalReply(tx, 0), nil
 		}
 	}
@@ -631,3 +731,20 @@ func DarksideClearAddressUtxos() error {
 	state.getAddressUtxos = nil
 	return nil
 }
+
+// DarksideSetSubtreeRoots replaces the subtree roots (of one shielded
+// protocol) that z_getsubtreesbyindex returns, and removes any that
+// lightwalletd has cached.
+func DarksideSetSubtreeRoots(arg *walletrpc.DarksideSubtreeRoots) error {
+	if _, ok := walletrpc.ShieldedProtocol_name[int32(arg.ShieldedProtocol)]; !ok {
+		return errors.New("unknown shielded protocol")
+	}
+	state.mutex.Lock()
+	if state.subtrees == nil {
+		state.subtrees = make(map[walletrpc.ShieldedProtocol]*walletrpc.DarksideSubtreeRoots)
+	}
+	state.subtrees[arg.ShieldedProtocol] = arg
+	state.mutex.Unlock()
+	state.cache.ResetSubtreeRoots(arg.ShieldedProtocol)
+	return nil
+}
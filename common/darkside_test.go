@@ -0,0 +1,77 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package common
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+)
+
+func TestDarksideGetBlockTxid(t *testing.T) {
+	savedRawRequest := RawRequest
+	defer func() {
+		state = darksideState{}
+		RawRequest = savedRawRequest
+	}()
+	RawRequest = darksideRawRequest
+	state = darksideState{
+		resetted:     true,
+		startHeight:  380640,
+		latestHeight: 380640 + len(blocks) - 1,
+	}
+	var parsed []*parser.Block
+	for _, blockJSON := range blocks {
+		var blockHex string
+		json.Unmarshal(blockJSON, &blockHex)
+		blockBytes, _ := hex.DecodeString(blockHex)
+		state.activeBlocks = append(state.activeBlocks, blockBytes)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockBytes); err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, block)
+	}
+
+	// Each transaction, looked up by block and index, can then be fetched
+	// by its txid (as GetTransaction does).
+	for _, block := range parsed {
+		seen := make(map[string]bool)
+		for i, tx := range block.Transactions() {
+			for _, hash := range [][]byte{nil, block.GetEncodableHash()} {
+				height := block.GetHeight()
+				if hash != nil {
+					height = 0
+				}
+				txid, err := GetBlockTxid(testcache, height, hash, i)
+				if err != nil {
+					t.Fatal("GetBlockTxid failed", block.GetHeight(), i, err)
+				}
+				txidJSON, _ := json.Marshal(hex.EncodeToString(parser.Reverse(txid)))
+				result, err := RawRequest("getrawtransaction", []json.RawMessage{txidJSON, json.RawMessage("0")})
+				if err != nil {
+					t.Fatal("getrawtransaction failed", block.GetHeight(), i, err)
+				}
+				var txHex string
+				json.Unmarshal(result, &txHex)
+				if txHex != hex.EncodeToString(tx.Bytes()) {
+					t.Fatal("unexpected transaction", block.GetHeight(), i)
+				}
+				seen[hex.EncodeToString(txid)] = true
+			}
+		}
+		if len(seen) != len(block.Transactions()) {
+			t.Fatal("transactions don't have distinct txids", block.GetHeight())
+		}
+	}
+
+	// index beyond the end of the block
+	_, err := GetBlockTxid(testcache, 380640, nil, len(parsed[0].Transactions()))
+	if err == nil || !strings.Contains(err.Error(), "out of range") {
+		t.Fatal("GetBlockTxid unexpected result for index out of range", err)
+	}
+}
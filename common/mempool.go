@@ -1,147 +1,538 @@
 package common
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"sync"
 	"time"
 
+	"github.com/PirateNetwork/lightwalletd/parser"
 	"github.com/PirateNetwork/lightwalletd/walletrpc"
 )
 
-type txid string
-
 var (
-	// Set of mempool txids that have been seen during the current block interval.
-	// The zcashd RPC `getrawmempool` returns the entire mempool each time, so
-	// this allows us to ignore the txids that we've already seen.
-	g_txidSeen map[txid]struct{} = map[txid]struct{}{}
-
-	// List of transactions during current block interval, in order received. Each
-	// client thread can keep an index into this slice to record which transactions
-	// it's sent back to the client (everything before that index). The g_txidSeen
-	// map allows this list to not contain duplicates.
-	g_txList []*walletrpc.RawTransaction
-
-	// The most recent absolute time that we fetched the mempool and the latest
-	// (tip) block hash (so we know when a new block has been mined).
-	g_lastTime time.Time
-
-	// The most recent zcashd getblockchaininfo reply, for height and best block
-	// hash (tip) which is used to detect when a new block arrives.
-	g_lastBlockChainInfo *PiratedRpcReplyGetblockchaininfo = &PiratedRpcReplyGetblockchaininfo{}
-
-	// Mutex to protect the above variables.
-	g_lock sync.Mutex
+	// How often the poller fetches pirated's mempool.
+	mempoolPollInterval = 2 * time.Second
+
+	// A subscriber that has this many updates waiting (it isn't keeping up
+	// with the mempool) is dropped, rather than letting its queue grow.
+	mempoolMaxPending = 1000
+
+	// The period over which GetMempoolInfo averages the addition and
+	// eviction rates.
+	mempoolRateWindow = 10 * time.Minute
 )
 
-func GetMempool(sendToClient func(*walletrpc.RawTransaction) error) error {
-	g_lock.Lock()
-	index := 0
-	// Stay in this function until the tip block hash changes.
-	stayHash := g_lastBlockChainInfo.BestBlockHash
+// MempoolTx is a transaction in pirated's mempool, fetched once and shared
+// by all clients (so it must not be modified).
+type MempoolTx struct {
+	Txid      string // big-endian hex, as from pirated
+	FirstSeen time.Time
+	Size      int
+	// The full transaction; its Height is the chain height when it was seen.
+	Raw *walletrpc.RawTransaction
+	// The compact form (with index zero), or nil if the transaction has
+	// no shielded parts (or couldn't be parsed).
+	Compact *walletrpc.CompactTx
+}
+
+// MempoolRemoval is a transaction that has left the mempool.
+type MempoolRemoval struct {
+	Tx          *MempoolTx
+	MinedHeight int // the height of the block that includes it, or zero if evicted
+}
+
+// A MempoolUpdate is what one poll found had changed.
+type MempoolUpdate struct {
+	BestBlockHash string // the tip (big-endian hex) at the time of the poll
+	Added         []*MempoolTx
+	Removed       []MempoolRemoval
+}
+
+// Mempool tracks pirated's mempool. A single poller fetches each new
+// transaction once, and each poll's changes are sent to any number of
+// subscribers.
+type Mempool struct {
+	pollMutex sync.Mutex // held during a poll (which makes RPCs)
+
+	// mutex protects the fields below; it isn't held during RPCs. Only
+	// poll() changes txs, so it can read txs while holding just pollMutex.
+	mutex         sync.Mutex
+	txs           map[string]*MempoolTx
+	txList        []*MempoolTx // the same transactions, in the order first seen
+	bestBlockHash string
+	lastPoll      time.Time
+	started       bool
+	subscribers   map[*MempoolSubscription]struct{}
+	firstPoll     time.Time
+	history       []mempoolPollCounts // the polls (with changes) within mempoolRateWindow
+}
+
+// mempoolPollCounts is the number of transactions one poll found had been
+// added and evicted.
+type mempoolPollCounts struct {
+	time           time.Time
+	added, evicted int
+}
+
+// A MempoolSubscription receives the mempool's updates; see Subscribe.
+type MempoolSubscription struct {
+	mempool *Mempool
+	changed chan struct{}
+	pending []*MempoolUpdate // protected by mempool.mutex
+	dropped bool             // the subscriber fell behind
+}
+
+// NewMempool returns an empty Mempool; its poller isn't started.
+func NewMempool() *Mempool {
+	return &Mempool{
+		txs:         make(map[string]*MempoolTx),
+		subscribers: make(map[*MempoolSubscription]struct{}),
+	}
+}
+
+// The mempool shared by all clients.
+var mempool = NewMempool()
 
-	// Wait for more transactions to be added to the list
+// StartMempool starts polling pirated's mempool.
+func StartMempool() {
+	mempool.Start()
+}
+
+// GetMempool sends each transaction in the mempool, then each new one as
+// it arrives, until the tip block changes.
+func GetMempool(ctx context.Context, sendToClient func(*walletrpc.RawTransaction) error) error {
+	return mempool.SendRaw(ctx, sendToClient)
+}
+
+// GetMempoolTxs returns the transactions in the mempool, in the order they
+// were first seen.
+func GetMempoolTxs() ([]*MempoolTx, error) {
+	return mempool.Txs()
+}
+
+// GetMempoolInfo returns statistics about the mempool.
+func GetMempoolInfo() (*walletrpc.MempoolInfo, error) {
+	return mempool.Info()
+}
+
+// GetMempoolEvents sends an event for each shielded transaction in the
+// mempool and as each new one arrives, and another when that transaction
+// leaves the mempool (mined or evicted). It runs until the context is done
+// or a send fails; new blocks don't end it.
+func GetMempoolEvents(ctx context.Context, sendToClient func(*walletrpc.MempoolEvent) error) error {
+	return mempool.SendEvents(ctx, sendToClient)
+}
+
+// Start starts the background poller (if it isn't already running).
+func (m *Mempool) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.started {
+		return
+	}
+	m.started = true
+	go func() {
+		for {
+			if err := m.poll(); err != nil {
+				Log.Warning("Mempool: poll failed: ", err)
+			}
+			Time.Sleep(mempoolPollInterval)
+		}
+	}()
+}
+
+// Txs returns the transactions in the mempool, in the order they were first
+// seen. If the poller isn't running, the mempool is fetched if it's stale.
+func (m *Mempool) Txs() ([]*MempoolTx, error) {
+	m.mutex.Lock()
+	stale := !m.started && Time.Now().After(m.lastPoll.Add(mempoolPollInterval))
+	m.mutex.Unlock()
+	if stale {
+		if err := m.poll(); err != nil {
+			return nil, err
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]*MempoolTx{}, m.txList...), nil
+}
+
+// Info returns statistics about the mempool. If the poller isn't running,
+// the mempool is fetched if it's stale.
+func (m *Mempool) Info() (*walletrpc.MempoolInfo, error) {
+	if _, err := m.Txs(); err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.info(), nil
+}
+
+// Caller should hold m.mutex.
+func (m *Mempool) info() *walletrpc.MempoolInfo {
+	now := Time.Now()
+	info := &walletrpc.MempoolInfo{Size: uint64(len(m.txList))}
+	for _, tx := range m.txList {
+		info.Bytes += uint64(tx.Size)
+		if tx.Compact != nil {
+			if len(tx.Compact.Spends) > 0 {
+				info.SaplingSpendTxs++
+			}
+			if len(tx.Compact.Outputs) > 0 {
+				info.SaplingOutputTxs++
+			}
+			if len(tx.Compact.Actions) > 0 {
+				info.OrchardActionTxs++
+			}
+		}
+	}
+	// txList is in the order first seen.
+	if len(m.txList) > 0 && now.After(m.txList[0].FirstSeen) {
+		info.OldestAge = uint64(now.Sub(m.txList[0].FirstSeen).Seconds())
+	}
+	var added, evicted int
+	for _, counts := range m.history {
+		if now.Sub(counts.time) <= mempoolRateWindow {
+			added += counts.added
+			evicted += counts.evicted
+		}
+	}
+	// Average over the window, or over the time since the first poll if
+	// that's shorter.
+	window := mempoolRateWindow
+	if elapsed := now.Sub(m.firstPoll); elapsed < window {
+		window = elapsed
+	}
+	if minutes := window.Minutes(); minutes > 0 {
+		info.AdditionsPerMinute = float64(added) / minutes
+		info.EvictionsPerMinute = float64(evicted) / minutes
+	}
+	return info
+}
+
+// updateStats records the changes found by a poll, and updates the metrics.
+// Caller should hold m.mutex.
+func (m *Mempool) updateStats(update *MempoolUpdate) {
+	counts := mempoolPollCounts{time: m.lastPoll, added: len(update.Added)}
+	Metrics.MempoolAddedCounter.Add(float64(len(update.Added)))
+	for _, removal := range update.Removed {
+		if removal.MinedHeight > 0 {
+			Metrics.MempoolRemovedCounter.WithLabelValues("mined").Inc()
+		} else {
+			Metrics.MempoolRemovedCounter.WithLabelValues("evicted").Inc()
+			counts.evicted++
+		}
+	}
+	for len(m.history) > 0 && m.lastPoll.Sub(m.history[0].time) > mempoolRateWindow {
+		m.history = m.history[1:]
+	}
+	if counts.added > 0 || counts.evicted > 0 {
+		m.history = append(m.history, counts)
+	}
+
+	info := m.info()
+	Metrics.MempoolSizeGauge.Set(float64(info.Size))
+	Metrics.MempoolBytesGauge.Set(float64(info.Bytes))
+	Metrics.MempoolShieldedTxsGauge.WithLabelValues("sapling_spends").Set(float64(info.SaplingSpendTxs))
+	Metrics.MempoolShieldedTxsGauge.WithLabelValues("sapling_outputs").Set(float64(info.SaplingOutputTxs))
+	Metrics.MempoolShieldedTxsGauge.WithLabelValues("orchard_actions").Set(float64(info.OrchardActionTxs))
+	Metrics.MempoolOldestAgeGauge.Set(float64(info.OldestAge))
+}
+
+// Subscribe returns a subscription to the mempool's updates, along with the
+// transactions in it (in the order first seen) and the tip block hash as of
+// the subscription, so that no change is missed. The caller should Close
+// the subscription when done.
+func (m *Mempool) Subscribe() (*MempoolSubscription, []*MempoolTx, string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	sub := &MempoolSubscription{
+		mempool: m,
+		changed: make(chan struct{}, 1),
+	}
+	m.subscribers[sub] = struct{}{}
+	Metrics.MempoolClientsGauge.Inc()
+	return sub, append([]*MempoolTx{}, m.txList...), m.bestBlockHash
+}
+
+// Next waits for and returns the updates since the previous call.
+func (sub *MempoolSubscription) Next(ctx context.Context) ([]*MempoolUpdate, error) {
+	m := sub.mempool
 	for {
-		// Don't fetch the mempool more often than every 2 seconds.
-		now := Time.Now()
-		if now.After(g_lastTime.Add(2 * time.Second)) {
-			blockChainInfo, err := getLatestBlockChainInfo()
-			if err != nil {
-				g_lock.Unlock()
-				return err
+		m.mutex.Lock()
+		updates := sub.pending
+		sub.pending = nil
+		dropped := sub.dropped
+		m.mutex.Unlock()
+		if dropped {
+			return nil, errors.New("mempool client fell behind")
+		}
+		if len(updates) > 0 {
+			return updates, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-sub.changed:
+		}
+	}
+}
+
+// Close ends the subscription.
+func (sub *MempoolSubscription) Close() {
+	m := sub.mempool
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.subscribers[sub]; ok {
+		delete(m.subscribers, sub)
+		Metrics.MempoolClientsGauge.Dec()
+	}
+}
+
+// SendRaw sends each transaction in the mempool, then each new one as it
+// arrives, returning (without error) when the tip block changes.
+func (m *Mempool) SendRaw(ctx context.Context, sendToClient func(*walletrpc.RawTransaction) error) error {
+	sub, txs, stayHash := m.Subscribe()
+	defer sub.Close()
+	for _, tx := range txs {
+		if err := sendToClient(tx.Raw); err != nil {
+			return err
+		}
+	}
+	for {
+		updates, err := sub.Next(ctx)
+		if err != nil {
+			return err
+		}
+		for _, update := range updates {
+			if update.BestBlockHash != stayHash {
+				return nil
 			}
-			if g_lastBlockChainInfo.BestBlockHash != blockChainInfo.BestBlockHash {
-				// A new block has arrived
-				g_lastBlockChainInfo = blockChainInfo
-				Log.Infoln("Latest Block changed, clearing everything")
-				// We're the first thread to notice, clear cached state.
-				g_txidSeen = map[txid]struct{}{}
-				g_txList = []*walletrpc.RawTransaction{}
-				g_lastTime = time.Time{}
-				break
+			for _, tx := range update.Added {
+				if err := sendToClient(tx.Raw); err != nil {
+					return err
+				}
 			}
-			if err = refreshMempoolTxns(); err != nil {
-				g_lock.Unlock()
-				return err
+		}
+	}
+}
+
+// SendEvents sends an event for each shielded transaction in the mempool
+// and as each new one arrives, and another when one of those leaves the
+// mempool, until the context is done or a send fails.
+func (m *Mempool) SendEvents(ctx context.Context, sendToClient func(*walletrpc.MempoolEvent) error) error {
+	sub, txs, _ := m.Subscribe()
+	defer sub.Close()
+	// The transactions (by txid) that have been sent.
+	sent := make(map[string]bool)
+	sendAdded := func(txs []*MempoolTx) error {
+		for _, tx := range txs {
+			if tx.Compact == nil {
+				continue
 			}
-			g_lastTime = now
-		}
-		// Send transactions we haven't sent yet, best to not do so while
-		// holding the mutex, since this call may get flow-controlled.
-		toSend := g_txList[index:]
-		index = len(g_txList)
-		g_lock.Unlock()
-		for _, tx := range toSend {
-			if err := sendToClient(tx); err != nil {
+			sent[tx.Txid] = true
+			err := sendToClient(&walletrpc.MempoolEvent{
+				Type: walletrpc.MempoolEventType_mempoolAdded,
+				Txid: tx.Compact.Hash,
+				Tx:   tx.Compact,
+			})
+			if err != nil {
 				return err
 			}
 		}
-		Time.Sleep(200 * time.Millisecond)
-		g_lock.Lock()
-		if g_lastBlockChainInfo.BestBlockHash != stayHash {
-			break
+		return nil
+	}
+	if err := sendAdded(txs); err != nil {
+		return err
+	}
+	for {
+		updates, err := sub.Next(ctx)
+		if err != nil {
+			return err
+		}
+		for _, update := range updates {
+			if err := sendAdded(update.Added); err != nil {
+				return err
+			}
+			for _, removal := range update.Removed {
+				if !sent[removal.Tx.Txid] {
+					continue
+				}
+				delete(sent, removal.Tx.Txid)
+				event := &walletrpc.MempoolEvent{
+					Type: walletrpc.MempoolEventType_mempoolEvicted,
+					Txid: removal.Tx.Compact.Hash,
+				}
+				if removal.MinedHeight > 0 {
+					event.Type = walletrpc.MempoolEventType_mempoolMined
+					event.Height = uint64(removal.MinedHeight)
+				}
+				if err := sendToClient(event); err != nil {
+					return err
+				}
+			}
 		}
 	}
-	g_lock.Unlock()
-	return nil
 }
 
-// RefreshMempoolTxns gets all new mempool txns and sends any new ones to waiting clients
-func refreshMempoolTxns() error {
-	Log.Infoln("Refreshing mempool")
+// reset forgets the mempool's transactions (without telling subscribers);
+// it's used only for darkside testing.
+func (m *Mempool) reset() {
+	m.pollMutex.Lock()
+	defer m.pollMutex.Unlock()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.txs = make(map[string]*MempoolTx)
+	m.txList = nil
+	m.bestBlockHash = ""
+	m.lastPoll = time.Time{}
+	m.firstPoll = time.Time{}
+	m.history = nil
+}
+
+// poll fetches the mempool (and any new transactions in it), and sends the
+// changes to the subscribers.
+func (m *Mempool) poll() error {
+	m.pollMutex.Lock()
+	defer m.pollMutex.Unlock()
 
-	params := []json.RawMessage{}
-	result, rpcErr := RawRequest("getrawmempool", params)
+	blockChainInfo, err := getLatestBlockChainInfo()
+	if err != nil {
+		return err
+	}
+	result, rpcErr := RawRequest("getrawmempool", []json.RawMessage{})
 	if rpcErr != nil {
 		return rpcErr
 	}
 	var mempoolList []string
-	err := json.Unmarshal(result, &mempoolList)
-	if err != nil {
+	if err := json.Unmarshal(result, &mempoolList); err != nil {
 		return err
 	}
-
-	// Fetch all new mempool txns and add them into `newTxns`
+	update := &MempoolUpdate{BestBlockHash: blockChainInfo.BestBlockHash}
+	inMempool := make(map[string]bool)
 	for _, txidstr := range mempoolList {
-		if _, ok := g_txidSeen[txid(txidstr)]; ok {
-			// We've already fetched this transaction
+		inMempool[txidstr] = true
+		if _, ok := m.txs[txidstr]; ok {
 			continue
 		}
-		g_txidSeen[txid(txidstr)] = struct{}{}
-		// We haven't fetched this transaction already.
-		txidJSON, err := json.Marshal(txidstr)
+		tx, err := getMempoolTx(txidstr, blockChainInfo.Blocks)
 		if err != nil {
-			return err
-		}
-		// The "0" is because we only need the raw hex, which is returned as
-		// just a hex string, and not even a json string (with quotes).
-		params := []json.RawMessage{txidJSON, json.RawMessage("0")}
-		result, rpcErr := RawRequest("getrawtransaction", params)
-		if rpcErr != nil {
 			// Not an error; mempool transactions can disappear
 			continue
 		}
-		// strip the quotes
-		var txStr string
-		err = json.Unmarshal(result, &txStr)
-		if err != nil {
-			return err
+		update.Added = append(update.Added, tx)
+	}
+	for txidstr, tx := range m.txs {
+		if inMempool[txidstr] {
+			continue
 		}
-		txBytes, err := hex.DecodeString(txStr)
+		height, err := getMinedHeight(txidstr)
 		if err != nil {
-			return err
+			// Try again next time.
+			Log.Warning("Mempool: getrawtransaction failed: ", err)
+			continue
 		}
-		Log.Infoln("appending", txidstr)
-		newRtx := &walletrpc.RawTransaction{
-			Data:   txBytes,
-			Height: uint64(g_lastBlockChainInfo.Blocks),
+		if height < 0 {
+			height = 0
+		}
+		update.Removed = append(update.Removed, MempoolRemoval{Tx: tx, MinedHeight: height})
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastPoll = Time.Now()
+	if m.firstPoll.IsZero() {
+		m.firstPoll = m.lastPoll
+	}
+	if len(update.Added) == 0 && len(update.Removed) == 0 && update.BestBlockHash == m.bestBlockHash {
+		// The oldest transaction has aged, and the rates may have changed.
+		m.updateStats(update)
+		return nil
+	}
+	m.bestBlockHash = update.BestBlockHash
+	for _, removal := range update.Removed {
+		delete(m.txs, removal.Tx.Txid)
+	}
+	if len(update.Removed) > 0 {
+		txList := make([]*MempoolTx, 0, len(m.txs)+len(update.Added))
+		for _, tx := range m.txList {
+			if _, ok := m.txs[tx.Txid]; ok {
+				txList = append(txList, tx)
+			}
+		}
+		m.txList = txList
+	}
+	for _, tx := range update.Added {
+		m.txs[tx.Txid] = tx
+		m.txList = append(m.txList, tx)
+	}
+	m.updateStats(update)
+	for sub := range m.subscribers {
+		if len(sub.pending) >= mempoolMaxPending {
+			sub.dropped = true
+		} else {
+			sub.pending = append(sub.pending, update)
+		}
+		select {
+		case sub.changed <- struct{}{}:
+		default:
+			// A notification is already pending.
 		}
-		g_txList = append(g_txList, newRtx)
 	}
 	return nil
 }
 
+// getMempoolTx fetches the given mempool transaction.
+func getMempoolTx(txidstr string, height int) (*MempoolTx, error) {
+	txid, err := hex.DecodeString(txidstr)
+	if err != nil {
+		return nil, err
+	}
+	txidJSON, err := json.Marshal(txidstr)
+	if err != nil {
+		return nil, err
+	}
+	// The "0" is because we only need the raw hex, which is returned as
+	// just a hex string, and not even a json string (with quotes).
+	params := []json.RawMessage{txidJSON, json.RawMessage("0")}
+	result, rpcErr := RawRequest("getrawtransaction", params)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	var txStr string
+	if err := json.Unmarshal(result, &txStr); err != nil {
+		return nil, err
+	}
+	txBytes, err := hex.DecodeString(txStr)
+	if err != nil {
+		return nil, err
+	}
+	mtx := &MempoolTx{
+		Txid:      txidstr,
+		FirstSeen: Time.Now(),
+		Size:      len(txBytes),
+		Raw: &walletrpc.RawTransaction{
+			Data:   txBytes,
+			Height: uint64(height),
+		},
+	}
+	tx := parser.NewTransaction()
+	rest, err := tx.ParseFromSlice(txBytes)
+	if err != nil || len(rest) > 0 {
+		Log.Warning("Mempool: can't parse transaction ", txidstr)
+		return mtx, nil
+	}
+	if tx.HasShieldedElements() {
+		// The parser doesn't compute the txid.
+		tx.SetTxID(parser.Reverse(txid))
+		mtx.Compact = tx.ToCompact(0)
+	}
+	return mtx, nil
+}
+
 func getLatestBlockChainInfo() (*PiratedRpcReplyGetblockchaininfo, error) {
 	result, rpcErr := RawRequest("getblockchaininfo", []json.RawMessage{})
 	if rpcErr != nil {
@@ -0,0 +1,630 @@
+// Copyright (c) 2019-2020 The Zcash developers
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/PirateNetwork/lightwalletd/commitmenttree"
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/pkg/errors"
+)
+
+var (
+	// noteTreeInterval is how often (in blocks) the note commitment trees
+	// are saved, so that the trees as of any cached height can be computed
+	// from nearby saved trees.
+	noteTreeInterval = 1000
+
+	// noteTreeBuildInterval is how often the note commitment trees are
+	// brought up to date with the cache, after they've been reset to saved
+	// trees (at startup or by a reorg).
+	noteTreeBuildInterval = 5 * time.Second
+
+	// noteTreeBuildBlocks is how many blocks are read, with the cache
+	// locked, for each step of bringing the trees up to date.
+	noteTreeBuildBlocks = 100
+
+	// orchardVerifyInterval is how often the Orchard tree as of the latest
+	// cached block is checked against pirated's.
+	orchardVerifyInterval = 60 * time.Second
+)
+
+// Each saved pair of trees in the file is a 4-byte length, an 8-byte
+// checksum, then the height (8 bytes), the length of the serialized Sapling
+// tree (4 bytes), the serialized Sapling tree, and the serialized Orchard
+// tree.
+const noteTreeCheckpointPrefixLen = 4 + 8 + 8 + 4
+
+type noteTreeCheckpoint struct {
+	height  int
+	sapling []byte
+	orchard []byte
+}
+
+// noteTreeCache maintains the Sapling and Orchard note commitment trees,
+// computed locally from the note commitments in the cached compact blocks,
+// along with saved trees (at every noteTreeInterval heights) in a file next
+// to the block cache files. The first saved trees are either the empty trees
+// before the first cached block (the Sapling activation height) or, if the
+// cache already had blocks when the trees were first needed, pirated's trees
+// at a recent height (see seedNoteTrees).
+//
+// The trees are kept up to date by Add once they've caught up with the
+// cache, which is done by a background goroutine (see StartNoteTreeBuilder)
+// after a restart or a reorg. Roots are computed only when trees are saved
+// and when a tree state is requested: the Sapling root is checked against
+// the block header's hashFinalSaplingRoot. The block headers don't commit to
+// the Orchard tree, so it's checked against pirated's (see
+// StartOrchardTreeVerifier); tree states that include Orchard note
+// commitments are served only at or below the highest verified height (an
+// empty Orchard tree needs no check). Locking is done by the BlockCache that
+// owns it.
+type noteTreeCache struct {
+	file            *os.File
+	checkpoints     []noteTreeCheckpoint
+	offsets         []int64              // file offset of each checkpoint, plus the end
+	sapling         *commitmenttree.Tree // as of height, nil if there are no saved trees yet
+	orchard         *commitmenttree.Tree // as of height
+	height          int                  // the height of the trees, less than the cache's if they're behind
+	generation      int                  // incremented whenever the trees are reset
+	orchardVerified int                  // the highest height whose Orchard tree matched pirated's
+	bad             bool                 // the trees couldn't be updated, or a saved root didn't match
+}
+
+func newNoteTreeCache(name string) *noteTreeCache {
+	nt := &noteTreeCache{offsets: []int64{0}, height: -1, orchardVerified: -1}
+	var err error
+	nt.file, err = os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		Log.Fatal("open ", name, " failed: ", err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		Log.Fatal("read ", name, " failed: ", err)
+	}
+	var offset int64
+	for len(b) >= noteTreeCheckpointPrefixLen {
+		length := int(binary.LittleEndian.Uint32(b))
+		if length < noteTreeCheckpointPrefixLen-12 || len(b) < 12+length {
+			break
+		}
+		data := b[12 : 12+length]
+		height := int(binary.LittleEndian.Uint64(data))
+		if !bytes.Equal(checksum(height, data), b[4:12]) {
+			break
+		}
+		saplingLen := int(binary.LittleEndian.Uint32(data[8:]))
+		if saplingLen > len(data)-12 {
+			break
+		}
+		nt.checkpoints = append(nt.checkpoints, noteTreeCheckpoint{
+			height:  height,
+			sapling: data[12 : 12+saplingLen],
+			orchard: data[12+saplingLen:],
+		})
+		offset += int64(12 + length)
+		nt.offsets = append(nt.offsets, offset)
+		b = b[12+length:]
+	}
+	if len(b) > 0 {
+		Log.Warning("note tree file ", name, " is corrupt after offset ", offset)
+		if err := nt.file.Truncate(offset); err != nil {
+			Log.Fatal("truncate note tree file failed: ", err)
+		}
+	}
+	return nt
+}
+
+// writeCheckpoint saves the given trees as the trees at the given height.
+func (nt *noteTreeCache) writeCheckpoint(height int, saplingTree, orchardTree *commitmenttree.Tree) {
+	sapling, err := saplingTree.MarshalBinary()
+	if err != nil {
+		Log.Fatal("sapling tree marshal failed: ", err)
+	}
+	orchard, err := orchardTree.MarshalBinary()
+	if err != nil {
+		Log.Fatal("orchard tree marshal failed: ", err)
+	}
+	data := make([]byte, 12, 12+len(sapling)+len(orchard))
+	binary.LittleEndian.PutUint64(data, uint64(height))
+	binary.LittleEndian.PutUint32(data[8:], uint32(len(sapling)))
+	data = append(append(data, sapling...), orchard...)
+	b := make([]byte, 12, 12+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(len(data)))
+	copy(b[4:], checksum(height, data))
+	b = append(b, data...)
+	n, err := nt.file.Write(b)
+	if err != nil {
+		Log.Fatal("note tree write failed: ", err)
+	}
+	if n != len(b) {
+		Log.Fatal("note tree write incorrect length: expected: ", len(b), "written: ", n)
+	}
+	nt.checkpoints = append(nt.checkpoints, noteTreeCheckpoint{
+		height:  height,
+		sapling: sapling,
+		orchard: orchard,
+	})
+	nt.offsets = append(nt.offsets, nt.offsets[len(nt.offsets)-1]+int64(len(b)))
+}
+
+// truncate removes the saved trees at and above the given height.
+func (nt *noteTreeCache) truncate(height int) {
+	n := len(nt.checkpoints)
+	for n > 0 && nt.checkpoints[n-1].height >= height {
+		n--
+	}
+	if n == len(nt.checkpoints) {
+		return
+	}
+	if err := nt.file.Truncate(nt.offsets[n]); err != nil {
+		Log.Fatal("truncate note tree file failed: ", err)
+	}
+	nt.checkpoints = nt.checkpoints[:n]
+	nt.offsets = nt.offsets[:n+1]
+}
+
+func (nt *noteTreeCache) close() {
+	if nt.file != nil {
+		nt.file.Close()
+		nt.file = nil
+	}
+}
+
+// appendNoteCommitments appends the block's Sapling note commitments and
+// Orchard note commitments to the trees.
+func appendNoteCommitments(sapling, orchard *commitmenttree.Tree, block *walletrpc.CompactBlock) error {
+	for _, tx := range block.Vtx {
+		for _, output := range tx.Outputs {
+			if len(output.Cmu) != 32 {
+				return errors.New("bad note commitment length")
+			}
+			var leaf commitmenttree.Node
+			copy(leaf[:], output.Cmu)
+			if err := sapling.Append(leaf); err != nil {
+				return err
+			}
+		}
+		for _, action := range tx.Actions {
+			if len(action.Cmx) != 32 {
+				return errors.New("bad note commitment length")
+			}
+			var leaf commitmenttree.Node
+			copy(leaf[:], action.Cmx)
+			if err := orchard.Append(leaf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// saplingRootMatches returns whether the Sapling tree's root matches the
+// header's hashFinalSaplingRoot.
+func saplingRootMatches(sapling *commitmenttree.Tree, header []byte) bool {
+	hdr := parser.NewBlockHeader()
+	if _, err := hdr.ParseFromSlice(header); err != nil {
+		return false
+	}
+	root := sapling.Root()
+	return bytes.Equal(root[:], hdr.HashFinalSaplingRoot)
+}
+
+// saveNoteTrees saves the trees as of a multiple of noteTreeInterval, after
+// checking the Sapling root if the block's header is known.
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) saveNoteTrees(height int, sapling, orchard *commitmenttree.Tree, header []byte) bool {
+	nt := c.noteTrees
+	if header != nil && !saplingRootMatches(sapling, header) {
+		Log.Warning("Sapling note commitment tree root mismatch at height ", height,
+			", tree states above height ", height-noteTreeInterval, " won't be computed locally")
+		nt.bad = true
+		return false
+	}
+	nt.writeCheckpoint(height, sapling, orchard)
+	return true
+}
+
+// addToNoteTrees updates the trees with the block at the given height, if
+// they're up to date with the cache (if not, the block is added to them
+// later, by buildNoteTrees).
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) addToNoteTrees(height int, block *walletrpc.CompactBlock, header []byte) {
+	nt := c.noteTrees
+	if nt.bad || nt.sapling == nil || nt.height != height-1 {
+		return
+	}
+	if err := appendNoteCommitments(nt.sapling, nt.orchard, block); err != nil {
+		Log.Warning("note commitment tree update failed at height ", height, ": ", err)
+		nt.bad = true
+		return
+	}
+	nt.height = height
+	if height%noteTreeInterval == 0 {
+		c.saveNoteTrees(height, nt.sapling, nt.orchard, header)
+	}
+}
+
+// rebuildNoteTrees resets the trees to the highest saved trees at or below
+// the latest cached block, after the cache has been loaded or has shrunk;
+// buildNoteTrees then brings them up to date. If there are no saved trees
+// and the cache is empty, the trees start out empty, before the first
+// cached block.
+// Caller should hold c.mutex.Lock().
+func (c *BlockCache) rebuildNoteTrees() {
+	nt := c.noteTrees
+	nt.truncate(c.nextBlock)
+	if len(nt.checkpoints) > 0 && nt.checkpoints[0].height < c.firstBlock-1 {
+		// Saved for a different starting height (darkside).
+		nt.truncate(0)
+	}
+	nt.generation++
+	nt.sapling, nt.orchard = nil, nil
+	nt.height = -1
+	nt.bad = false
+	// Blocks below the new tip haven't changed.
+	if nt.orchardVerified > c.nextBlock-1 {
+		nt.orchardVerified = c.nextBlock - 1
+	}
+	if n := len(nt.checkpoints); n > 0 {
+		cp := nt.checkpoints[n-1]
+		sapling, err := commitmenttree.ParseTree(commitmenttree.Sapling, cp.sapling)
+		var orchard *commitmenttree.Tree
+		if err == nil {
+			orchard, err = commitmenttree.ParseTree(commitmenttree.Orchard, cp.orchard)
+		}
+		if err != nil {
+			Log.Warning("saved note commitment trees at height ", cp.height, " are invalid (", err, "), rebuilding the trees")
+			nt.truncate(0)
+			nt.orchardVerified = -1
+			c.rebuildNoteTrees()
+			return
+		}
+		nt.sapling, nt.orchard, nt.height = sapling, orchard, cp.height
+		return
+	}
+	if c.nextBlock == c.firstBlock {
+		nt.sapling = commitmenttree.NewTree(commitmenttree.Sapling)
+		nt.orchard = commitmenttree.NewTree(commitmenttree.Orchard)
+		nt.height = c.firstBlock - 1
+		nt.writeCheckpoint(nt.height, nt.sapling, nt.orchard)
+	}
+}
+
+// StartNoteTreeBuilder periodically brings the note commitment trees up to
+// date with the cache, without holding the cache's lock while hashing.
+func StartNoteTreeBuilder(cache *BlockCache) {
+	go func() {
+		for {
+			cache.buildNoteTrees()
+			Time.Sleep(noteTreeBuildInterval)
+		}
+	}()
+}
+
+// buildNoteTrees brings the note commitment trees up to date with the cache
+// (after which Add keeps them up to date), starting from pirated's trees if
+// there are no saved trees. Blocks are read noteTreeBuildBlocks at a time
+// with the cache read-locked, and added to copies of the trees without the
+// lock; the trees are then replaced if they haven't been reset meanwhile.
+func (c *BlockCache) buildNoteTrees() {
+	logged := false
+	for {
+		c.mutex.RLock()
+		nt := c.noteTrees
+		if nt == nil || nt.bad || (nt.sapling != nil && nt.height >= c.nextBlock-1) {
+			c.mutex.RUnlock()
+			return
+		}
+		generation := nt.generation
+		if nt.sapling == nil {
+			c.mutex.RUnlock()
+			c.seedNoteTrees(generation)
+			continue
+		}
+		start := nt.height + 1
+		end := start + noteTreeBuildBlocks
+		if end > c.nextBlock {
+			end = c.nextBlock
+		}
+		if !logged && c.nextBlock-start > noteTreeInterval {
+			Log.Info("Computing the note commitment trees from height ", start)
+			logged = true
+		}
+		sapling, orchard := nt.sapling.Copy(), nt.orchard.Copy()
+		var blocks []*walletrpc.CompactBlock
+		headers := make(map[int][]byte)
+		for height := start; height < end; height++ {
+			block := c.readBlock(height)
+			if block == nil {
+				break
+			}
+			blocks = append(blocks, block)
+			if height%noteTreeInterval == 0 {
+				headers[height] = c.readHeader(height)
+			}
+		}
+		c.mutex.RUnlock()
+
+		type saved struct {
+			height           int
+			sapling, orchard *commitmenttree.Tree
+		}
+		var saves []saved
+		var err error
+		if len(blocks) == 0 {
+			err = errors.New("block not cached")
+		}
+		for _, block := range blocks {
+			if err = appendNoteCommitments(sapling, orchard, block); err != nil {
+				break
+			}
+			if height := int(block.Height); height%noteTreeInterval == 0 {
+				saves = append(saves, saved{height, sapling.Copy(), orchard.Copy()})
+			}
+		}
+
+		c.mutex.Lock()
+		if c.noteTrees == nt && nt.generation == generation {
+			if err != nil {
+				Log.Warning("note commitment tree update failed at height ", start+len(blocks), ": ", err)
+				nt.bad = true
+			}
+			for _, s := range saves {
+				if !c.saveNoteTrees(s.height, s.sapling, s.orchard, headers[s.height]) {
+					break
+				}
+			}
+			if !nt.bad {
+				nt.sapling, nt.orchard, nt.height = sapling, orchard, start+len(blocks)-1
+			}
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// seedNoteTrees starts the trees, when there are no saved trees, from
+// pirated's trees as of the highest multiple of noteTreeInterval among the
+// cached blocks, rather than computing them from the first cached block
+// (which takes hours on mainnet). If that's not possible, the trees start
+// out empty, before the first cached block.
+func (c *BlockCache) seedNoteTrees(generation int) {
+	c.mutex.RLock()
+	height := (c.nextBlock - 1) / noteTreeInterval * noteTreeInterval
+	var hash, header []byte
+	if height >= c.firstBlock {
+		if block := c.readBlock(height); block != nil {
+			hash, header = block.Hash, c.readHeader(height)
+		}
+	}
+	c.mutex.RUnlock()
+
+	var sapling, orchard *commitmenttree.Tree
+	if hash != nil {
+		var err error
+		sapling, orchard, err = getNoteTreesFromRPC(height, hash, header)
+		if err != nil {
+			Log.Warning("Can't get the note commitment trees at height ", height, " from pirated (", err,
+				"), computing them from the first cached block")
+		}
+	}
+	if sapling == nil {
+		sapling = commitmenttree.NewTree(commitmenttree.Sapling)
+		orchard = commitmenttree.NewTree(commitmenttree.Orchard)
+		height = -1
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	nt := c.noteTrees
+	if nt == nil || nt.generation != generation || nt.sapling != nil {
+		return
+	}
+	if height < 0 {
+		height = c.firstBlock - 1
+	} else if nt.orchardVerified < height {
+		// The Orchard tree is pirated's.
+		nt.orchardVerified = height
+	}
+	nt.truncate(0)
+	nt.writeCheckpoint(height, sapling, orchard)
+	nt.sapling, nt.orchard, nt.height = sapling, orchard, height
+}
+
+// getNoteTreesFromRPC returns pirated's trees as of the block at the given
+// height, which must have the given hash, and whose header (if known) must
+// commit to the Sapling tree.
+func getNoteTreesFromRPC(height int, hash, header []byte) (*commitmenttree.Tree, *commitmenttree.Tree, error) {
+	ts, err := getTreeStateFromRPC(height, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	if ts.Hash != hex.EncodeToString(parser.Reverse(hash)) {
+		return nil, nil, errors.New("block hash mismatch")
+	}
+	trees := make([]*commitmenttree.Tree, 2)
+	for i, tree := range []struct {
+		hasher commitmenttree.Hasher
+		hex    string
+	}{{commitmenttree.Sapling, ts.SaplingTree}, {commitmenttree.Orchard, ts.OrchardTree}} {
+		if tree.hex == "" {
+			// The pool isn't active yet.
+			trees[i] = commitmenttree.NewTree(tree.hasher)
+			continue
+		}
+		b, err := hex.DecodeString(tree.hex)
+		if err != nil {
+			return nil, nil, err
+		}
+		if trees[i], err = commitmenttree.ParseTree(tree.hasher, b); err != nil {
+			return nil, nil, err
+		}
+	}
+	if header != nil && !saplingRootMatches(trees[0], header) {
+		return nil, nil, errors.New("Sapling root mismatch")
+	}
+	return trees[0], trees[1], nil
+}
+
+// GetLocalTreeState returns the tree state as of the block at the given
+// height computed from the cached blocks, or nil if that's not possible
+// (the height isn't cached, the trees haven't caught up to it, or the tree
+// doesn't match the block header or isn't verified at that height). Its
+// network name is not set.
+func (c *BlockCache) GetLocalTreeState(height int) *walletrpc.TreeState {
+	// Copy what's needed while holding the lock, then do the (slow) hashing
+	// without it; blocks are then read one at a time, with their hashes
+	// checked so that a concurrent reorg can't produce a wrong result.
+	c.mutex.RLock()
+	nt := c.noteTrees
+	if nt == nil || nt.sapling == nil || height < c.firstBlock || height >= c.nextBlock || height > nt.height {
+		c.mutex.RUnlock()
+		return nil
+	}
+	block := c.readBlock(height)
+	header := c.readHeader(height)
+	if block == nil || header == nil {
+		c.mutex.RUnlock()
+		return nil
+	}
+	orchardVerified := nt.orchardVerified
+	var sapling, orchard *commitmenttree.Tree
+	var saplingBytes, orchardBytes, prevHash []byte
+	start := -1
+	if height == nt.height && !nt.bad {
+		sapling, orchard = nt.sapling.Copy(), nt.orchard.Copy()
+		start = height + 1
+	} else {
+		for i := len(nt.checkpoints) - 1; i >= 0; i-- {
+			cp := nt.checkpoints[i]
+			if cp.height <= height {
+				saplingBytes, orchardBytes = cp.sapling, cp.orchard
+				start = cp.height + 1
+				if cp.height >= c.firstBlock {
+					if prevHash = c.readBlock(cp.height).GetHash(); prevHash == nil {
+						start = -1
+					}
+				}
+				break
+			}
+		}
+	}
+	c.mutex.RUnlock()
+
+	if start < 0 {
+		return nil
+	}
+	if sapling == nil {
+		var err error
+		if sapling, err = commitmenttree.ParseTree(commitmenttree.Sapling, saplingBytes); err != nil {
+			return nil
+		}
+		if orchard, err = commitmenttree.ParseTree(commitmenttree.Orchard, orchardBytes); err != nil {
+			return nil
+		}
+	}
+	for h := start; h <= height; h++ {
+		b := c.Get(h)
+		if b == nil || (prevHash != nil && !bytes.Equal(b.PrevHash, prevHash)) {
+			return nil
+		}
+		if err := appendNoteCommitments(sapling, orchard, b); err != nil {
+			return nil
+		}
+		prevHash = b.Hash
+	}
+	if prevHash != nil && !bytes.Equal(prevHash, block.Hash) {
+		return nil
+	}
+	if !saplingRootMatches(sapling, header) {
+		return nil
+	}
+	if orchard.Size() > 0 && height > orchardVerified {
+		return nil
+	}
+	saplingTree, err := sapling.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	orchardTree, err := orchard.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return &walletrpc.TreeState{
+		Height:      uint64(height),
+		Hash:        hex.EncodeToString(parser.Reverse(block.Hash)),
+		Time:        block.Time,
+		SaplingTree: hex.EncodeToString(saplingTree),
+		OrchardTree: hex.EncodeToString(orchardTree),
+	}
+}
+
+// StartOrchardTreeVerifier periodically checks the locally computed Orchard
+// tree as of the latest cached block against pirated's, so that tree states
+// that include Orchard note commitments can be served from the cache.
+func StartOrchardTreeVerifier(cache *BlockCache) {
+	go func() {
+		for {
+			cache.verifyOrchardTree()
+			Time.Sleep(orchardVerifyInterval)
+		}
+	}()
+}
+
+// verifyOrchardTree compares the Orchard tree as of the latest cached block
+// with pirated's, and if they match, records that the tree is verified up to
+// that height.
+func (c *BlockCache) verifyOrchardTree() {
+	c.mutex.RLock()
+	nt := c.noteTrees
+	if nt == nil || nt.bad || nt.sapling == nil || nt.height != c.nextBlock-1 ||
+		c.nextBlock <= c.firstBlock || c.nextBlock-1 <= nt.orchardVerified {
+		c.mutex.RUnlock()
+		return
+	}
+	height := c.nextBlock - 1
+	hash := append([]byte{}, c.latestHash...)
+	orchard := nt.orchard.Copy()
+	verified := nt.orchardVerified
+	c.mutex.RUnlock()
+
+	if orchard.Size() == 0 {
+		// Nothing to check.
+		return
+	}
+	ts, err := getTreeStateFromRPC(height, "")
+	if err != nil {
+		Log.Warning("Can't get the tree state to verify the Orchard tree: ", err)
+		return
+	}
+	if ts.Hash != hex.EncodeToString(parser.Reverse(hash)) {
+		// A reorg; try again later.
+		return
+	}
+	var theirs *commitmenttree.Tree
+	if b, err := hex.DecodeString(ts.OrchardTree); err == nil {
+		theirs, err = commitmenttree.ParseTree(commitmenttree.Orchard, b)
+	}
+	if theirs == nil || theirs.Size() != orchard.Size() || theirs.Root() != orchard.Root() {
+		Log.Warning("Orchard note commitment tree mismatch at height ", height,
+			", Orchard tree states above height ", verified, " won't be computed locally")
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.noteTrees == nt && height < c.nextBlock && bytes.Equal(c.readBlock(height).GetHash(), hash) &&
+		height > nt.orchardVerified {
+		nt.orchardVerified = height
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) 2019-2020 The Zcash developers
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/commitmenttree"
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+)
+
+// saplingTestBlock returns a block at the given height with the given number
+// of Sapling outputs (whose note commitments are derived from the height and
+// seed), whose header commits to the Sapling tree after appending them to
+// tree (if the header's root is right).
+func saplingTestBlock(height, outputs, seed int, tree *commitmenttree.Tree, rightRoot bool) *walletrpc.CompactBlock {
+	hash := sha256.Sum256([]byte{byte(height), byte(height >> 8), byte(seed)})
+	prevHash := sha256.Sum256(hash[:])
+	block := &walletrpc.CompactBlock{Height: uint64(height), Hash: hash[:], PrevHash: prevHash[:], Time: uint32(height)}
+	tx := &walletrpc.CompactTx{}
+	for i := 0; i < outputs; i++ {
+		var cmu commitmenttree.Node
+		binary.LittleEndian.PutUint32(cmu[:], uint32(height))
+		cmu[4], cmu[5] = byte(i), byte(seed)
+		tree.Append(cmu)
+		tx.Outputs = append(tx.Outputs, &walletrpc.CompactSaplingOutput{Cmu: cmu[:]})
+	}
+	block.Vtx = append(block.Vtx, tx)
+	root := tree.Root()
+	if !rightRoot {
+		root[0]++
+	}
+	hdr := &parser.RawBlockHeader{
+		HashPrevBlock:        make([]byte, 32),
+		HashMerkleRoot:       make([]byte, 32),
+		HashFinalSaplingRoot: root[:],
+		NBitsBytes:           make([]byte, 4),
+		Nonce:                make([]byte, 32),
+	}
+	block.Header, _ = hdr.MarshalBinary()
+	return block
+}
+
+// addChainedBlock adds the block to the cache after setting its PrevHash to
+// the hash of the cache's latest block.
+func addChainedBlock(c *BlockCache, height int, block *walletrpc.CompactBlock) error {
+	if height > c.GetFirstHeight() {
+		block.PrevHash = append([]byte{}, c.GetLatestHash()...)
+	}
+	return c.Add(height, block)
+}
+
+func treeHex(tree *commitmenttree.Tree) string {
+	b, _ := tree.MarshalBinary()
+	return hex.EncodeToString(b)
+}
+
+func TestNoteTrees(t *testing.T) {
+	testT = t
+	RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		testT.Fatal("unexpected call to pirated", method, string(params[0]))
+		return nil, nil
+	}
+	saveInterval := noteTreeInterval
+	noteTreeInterval = 4
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 1000, 0)
+
+	tree := commitmenttree.NewTree(commitmenttree.Sapling)
+	expected := make(map[int]string)
+	for height := 1000; height < 1011; height++ {
+		if err := addChainedBlock(c, height, saplingTestBlock(height, height%3, 0, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+		expected[height] = treeHex(tree)
+	}
+	check := func(height int) {
+		ts, err := GetTreeState(c, height, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ts.Height != uint64(height) || ts.SaplingTree != expected[height] || ts.OrchardTree != "000000" {
+			t.Fatal("unexpected tree state at height", height, ts)
+		}
+		if ts.Hash != hex.EncodeToString(parser.Reverse(c.Get(height).Hash)) || ts.Time != uint32(height) {
+			t.Fatal("unexpected block in tree state at height", height)
+		}
+	}
+	for height := 1000; height < 1011; height++ {
+		check(height)
+	}
+	// The empty trees before the first block, and those at 1000, 1004, 1008.
+	if len(c.noteTrees.checkpoints) != 4 {
+		t.Fatal("unexpected number of saved trees", len(c.noteTrees.checkpoints))
+	}
+	if c.GetLocalTreeState(999) != nil || c.GetLocalTreeState(1011) != nil {
+		t.Fatal("unexpected tree state for uncached height")
+	}
+
+	// After a restart, the trees start from the highest saved trees, and
+	// are brought up to date in the background.
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 1000, -1)
+	if c.noteTrees.height != 1008 || c.GetLocalTreeState(1010) != nil {
+		t.Fatal("unexpected trees after restart", c.noteTrees.height)
+	}
+	c.buildNoteTrees()
+	if c.noteTrees.height != 1010 {
+		t.Fatal("trees not brought up to date", c.noteTrees.height)
+	}
+	for height := 1000; height < 1011; height++ {
+		check(height)
+	}
+
+	// After a reorg, the tree follows the new chain.
+	c.Reorg(1006)
+	if c.GetLocalTreeState(1006) != nil || len(c.noteTrees.checkpoints) != 3 {
+		t.Fatal("reorg did not remove tree state")
+	}
+	c.buildNoteTrees()
+	tree, _ = commitmenttree.ParseTree(commitmenttree.Sapling, mustDecodeHex(expected[1005]))
+	for height := 1006; height < 1009; height++ {
+		if err := addChainedBlock(c, height, saplingTestBlock(height, 2, 1, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+		expected[height] = treeHex(tree)
+	}
+	for height := 1000; height < 1009; height++ {
+		check(height)
+	}
+
+	// A tree state whose root doesn't match the block header isn't served.
+	if err := addChainedBlock(c, 1009, saplingTestBlock(1009, 1, 1, tree, false)); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetLocalTreeState(1009) != nil {
+		t.Fatal("unexpected tree state for a block with a bad root")
+	}
+	check(1008)
+
+	// Tree states that include Orchard note commitments are served only once
+	// the Orchard tree has been checked against pirated's.
+	c.Reorg(1009)
+	tree, _ = commitmenttree.ParseTree(commitmenttree.Sapling, mustDecodeHex(expected[1008]))
+	block := saplingTestBlock(1009, 1, 2, tree, true)
+	orchard := commitmenttree.NewTree(commitmenttree.Orchard)
+	for i := 0; i < 3; i++ {
+		var cmx commitmenttree.Node
+		cmx[0] = byte(i + 1)
+		orchard.Append(cmx)
+		block.Vtx[0].Actions = append(block.Vtx[0].Actions, &walletrpc.CompactOrchardAction{Cmx: cmx[:]})
+	}
+	if err := addChainedBlock(c, 1009, block); err != nil {
+		t.Fatal(err)
+	}
+	if c.noteTrees.height != 1009 || c.GetLocalTreeState(1009) != nil {
+		t.Fatal("unexpected tree state before the Orchard tree is verified")
+	}
+	check(1008)
+	piratedOrchardTree := treeHex(orchard)
+	RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		if method != "z_gettreestate" || string(params[0]) != `"1009"` {
+			testT.Fatal("unexpected call to pirated", method, params)
+		}
+		return json.Marshal(map[string]interface{}{
+			"height":  1009,
+			"hash":    hex.EncodeToString(parser.Reverse(block.Hash)),
+			"sapling": map[string]interface{}{"commitments": map[string]string{"finalState": treeHex(tree)}},
+			"orchard": map[string]interface{}{"commitments": map[string]string{"finalState": piratedOrchardTree}},
+		})
+	}
+	piratedOrchardTree = treeHex(commitmenttree.NewTree(commitmenttree.Orchard))
+	c.verifyOrchardTree()
+	if c.noteTrees.orchardVerified != -1 || c.GetLocalTreeState(1009) != nil {
+		t.Fatal("Orchard tree verified against a different tree")
+	}
+	piratedOrchardTree = treeHex(orchard)
+	c.verifyOrchardTree()
+	ts := c.GetLocalTreeState(1009)
+	if c.noteTrees.orchardVerified != 1009 || ts == nil ||
+		ts.SaplingTree != treeHex(tree) || ts.OrchardTree != treeHex(orchard) {
+		t.Fatal("unexpected tree state after the Orchard tree is verified", ts)
+	}
+
+	// A reorg keeps the Orchard tree verified below the fork point.
+	c.Reorg(1009)
+	if c.noteTrees.orchardVerified != 1008 {
+		t.Fatal("unexpected Orchard verified height after a reorg", c.noteTrees.orchardVerified)
+	}
+
+	c.Close()
+	os.RemoveAll(unitTestPath)
+	noteTreeInterval = saveInterval
+}
+
+func TestNoteTreesSeed(t *testing.T) {
+	testT = t
+	saveInterval := noteTreeInterval
+	noteTreeInterval = 4
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 1000, 0)
+	tree := commitmenttree.NewTree(commitmenttree.Sapling)
+	expected := make(map[int]string)
+	for height := 1000; height < 1011; height++ {
+		if err := addChainedBlock(c, height, saplingTestBlock(height, 2, 0, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+		expected[height] = treeHex(tree)
+	}
+	hash1008 := hex.EncodeToString(parser.Reverse(c.Get(1008).Hash))
+	c.Close()
+
+	// Without saved trees (as after an upgrade), the trees start from
+	// pirated's at the highest multiple of noteTreeInterval.
+	os.Remove(filepath.Join(unitTestPath, unitTestChain, "notetrees"))
+	piratedSaplingTree := expected[1008]
+	calls := 0
+	RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		calls++
+		if method != "z_gettreestate" || string(params[0]) != `"1008"` {
+			testT.Fatal("unexpected call to pirated", method, params)
+		}
+		return json.Marshal(map[string]interface{}{
+			"height":  1008,
+			"hash":    hash1008,
+			"sapling": map[string]interface{}{"commitments": map[string]string{"finalState": piratedSaplingTree}},
+			"orchard": map[string]interface{}{"commitments": map[string]string{"finalState": "000000"}},
+		})
+	}
+	c = NewBlockCache(unitTestPath, unitTestChain, 1000, -1)
+	if c.noteTrees.sapling != nil || c.GetLocalTreeState(1010) != nil {
+		t.Fatal("unexpected trees before they're built")
+	}
+	c.buildNoteTrees()
+	if calls != 1 || c.noteTrees.height != 1010 || len(c.noteTrees.checkpoints) != 1 ||
+		c.noteTrees.checkpoints[0].height != 1008 {
+		t.Fatal("trees not seeded from pirated's", calls, c.noteTrees.height)
+	}
+	for height := 1008; height < 1011; height++ {
+		ts := c.GetLocalTreeState(height)
+		if ts == nil || ts.SaplingTree != expected[height] {
+			t.Fatal("unexpected tree state at height", height, ts)
+		}
+	}
+	if c.GetLocalTreeState(1007) != nil {
+		t.Fatal("unexpected tree state below the seeded trees")
+	}
+	c.Close()
+
+	// Trees from pirated that don't match the block header aren't used.
+	os.Remove(filepath.Join(unitTestPath, unitTestChain, "notetrees"))
+	piratedSaplingTree = expected[1007]
+	calls = 0
+	c = NewBlockCache(unitTestPath, unitTestChain, 1000, -1)
+	c.buildNoteTrees()
+	if calls != 1 || c.noteTrees.height != 1010 || c.noteTrees.checkpoints[0].height != 999 {
+		t.Fatal("trees not computed from the first block", calls, c.noteTrees.height)
+	}
+	for height := 1000; height < 1011; height++ {
+		ts := c.GetLocalTreeState(height)
+		if ts == nil || ts.SaplingTree != expected[height] {
+			t.Fatal("unexpected tree state at height", height, ts)
+		}
+	}
+
+	c.Close()
+	os.RemoveAll(unitTestPath)
+	noteTreeInterval = saveInterval
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		testT.Fatal(err)
+	}
+	return b
+}
@@ -14,6 +14,16 @@ type PrometheusMetrics struct {
 	ArrrPriceGauge                prometheus.Gauge
 	ArrrPriceHistoryWebAPICounter prometheus.Counter
 	ArrrPriceHistoryErrors        prometheus.Counter
+	RebroadcastQueueGauge         prometheus.Gauge
+	RebroadcastsCounter           prometheus.Counter
+	RebroadcastDroppedCounter     *prometheus.CounterVec
+	MempoolSizeGauge              prometheus.Gauge
+	MempoolBytesGauge             prometheus.Gauge
+	MempoolShieldedTxsGauge       *prometheus.GaugeVec
+	MempoolOldestAgeGauge         prometheus.Gauge
+	MempoolAddedCounter           prometheus.Counter
+	MempoolRemovedCounter         *prometheus.CounterVec
+	BlockRangesInFlightGauge      prometheus.Gauge
 }
 
 func GetPrometheusMetrics() *PrometheusMetrics {
@@ -73,5 +83,55 @@ func GetPrometheusMetrics() *PrometheusMetrics {
 		Help: "Counter for number of errors seen in the history price API",
 	})
 
+	m.RebroadcastQueueGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lightwalletd_rebroadcast_queue_size",
+		Help: "Number of sent transactions waiting to be mined",
+	})
+
+	m.RebroadcastsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "lightwalletd_rebroadcasts_total",
+		Help: "Total number of times sent transactions were rebroadcast",
+	})
+
+	m.RebroadcastDroppedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lightwalletd_rebroadcast_dropped_total",
+		Help: "Total number of transactions removed from (or not added to) the rebroadcast queue, by reason (mined, expired, rejected, full)",
+	}, []string{"reason"})
+
+	m.MempoolSizeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lightwalletd_mempool_size",
+		Help: "Number of transactions in the mempool",
+	})
+
+	m.MempoolBytesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lightwalletd_mempool_bytes",
+		Help: "Total size of the transactions in the mempool",
+	})
+
+	m.MempoolShieldedTxsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lightwalletd_mempool_shielded_txs",
+		Help: "Number of mempool transactions with each kind of shielded part (sapling_spends, sapling_outputs, orchard_actions)",
+	}, []string{"kind"})
+
+	m.MempoolOldestAgeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lightwalletd_mempool_oldest_age_seconds",
+		Help: "Time since the oldest transaction in the mempool was first seen",
+	})
+
+	m.MempoolAddedCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "lightwalletd_mempool_added_total",
+		Help: "Total number of transactions added to the mempool",
+	})
+
+	m.MempoolRemovedCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lightwalletd_mempool_removed_total",
+		Help: "Total number of transactions removed from the mempool, by reason (mined, evicted)",
+	}, []string{"reason"})
+
+	m.BlockRangesInFlightGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "lightwalletd_block_ranges_in_flight",
+		Help: "Number of block ranges (GetBlockRange calls) being streamed",
+	})
+
 	return m
 }
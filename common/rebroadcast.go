@@ -0,0 +1,307 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package common
+
+import (
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Transactions sent through SendTransaction are kept in a queue (saved in a
+// file next to the block cache) and rebroadcast if they disappear from
+// pirated's mempool before being mined, such as when pirated restarts or
+// evicts them. They're dropped from the queue once they've been mined
+// rebroadcastDepth blocks deep, once they've expired, or if pirated rejects
+// them (for example, because a conflicting transaction was mined).
+// At most rebroadcastMaxQueue transactions are kept; ones sent when the
+// queue is full aren't rebroadcast. The file is rewritten only by the
+// rebroadcast thread (and at shutdown), when the queue has changed.
+var (
+	rebroadcastDepth    = 10
+	rebroadcastInterval = 60 * time.Second
+	rebroadcastMaxQueue = 10000
+)
+
+type rebroadcastTx struct {
+	Txid         string // big-endian hex, as from pirated
+	Data         []byte
+	ExpiryHeight uint32 // zero means it doesn't expire
+	MinedHeight  int    // zero if not mined (as of the last check)
+	Rebroadcasts int
+}
+
+var rebroadcast struct {
+	mutex    sync.Mutex
+	fileName string // empty if the rebroadcaster isn't running
+	cache    *BlockCache
+	txs      map[string]*rebroadcastTx
+	dirty    bool // txs has changed since the file was written
+}
+
+func readRebroadcastFile() (map[string]*rebroadcastTx, error) {
+	f, err := os.Open(rebroadcast.fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var txs map[string]*rebroadcastTx
+	if err := gob.NewDecoder(f).Decode(&txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// writeRebroadcastFile saves the queue, replacing the file only once the
+// new one is complete.
+// Caller should hold rebroadcast.mutex.
+func writeRebroadcastFile() {
+	tmpName := rebroadcast.fileName + ".tmp"
+	f, err := os.Create(tmpName)
+	if err != nil {
+		Log.Errorf("Couldn't create rebroadcast queue file: %v", err)
+		return
+	}
+	err = gob.NewEncoder(f).Encode(rebroadcast.txs)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, rebroadcast.fileName)
+	}
+	if err != nil {
+		Log.Errorf("Couldn't write rebroadcast queue file: %v", err)
+	}
+}
+
+// StartRebroadcaster loads the rebroadcast queue and starts a thread that
+// periodically rebroadcasts the transactions in it as needed.
+func StartRebroadcaster(cache *BlockCache, dbPath string, chainName string) {
+	loadRebroadcastQueue(cache, dbPath, chainName)
+	go func() {
+		for {
+			Time.Sleep(rebroadcastInterval)
+			checkRebroadcastQueue()
+			SaveRebroadcastQueue()
+		}
+	}()
+}
+
+func loadRebroadcastQueue(cache *BlockCache, dbPath string, chainName string) {
+	rebroadcast.mutex.Lock()
+	rebroadcast.fileName = filepath.Join(dbPath, chainName, "rebroadcast")
+	rebroadcast.cache = cache
+	txs, err := readRebroadcastFile()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			Log.Errorf("Couldn't read rebroadcast queue, starting with an empty queue: %v", err)
+		}
+		txs = make(map[string]*rebroadcastTx)
+	}
+	rebroadcast.txs = txs
+	rebroadcast.dirty = false
+	Metrics.RebroadcastQueueGauge.Set(float64(len(txs)))
+	rebroadcast.mutex.Unlock()
+}
+
+// QueueRebroadcast adds a transaction that pirated has accepted to the
+// rebroadcast queue (if the rebroadcaster is running).
+func QueueRebroadcast(txid string, data []byte, expiryHeight uint32) {
+	rebroadcast.mutex.Lock()
+	defer rebroadcast.mutex.Unlock()
+	if rebroadcast.fileName == "" {
+		return
+	}
+	if _, ok := rebroadcast.txs[txid]; ok {
+		return
+	}
+	if len(rebroadcast.txs) >= rebroadcastMaxQueue {
+		Metrics.RebroadcastDroppedCounter.WithLabelValues("full").Inc()
+		Log.WithFields(logrus.Fields{
+			"txid": txid,
+		}).Warning("Rebroadcast: queue is full, not queueing transaction")
+		return
+	}
+	rebroadcast.txs[txid] = &rebroadcastTx{
+		Txid:         txid,
+		Data:         data,
+		ExpiryHeight: expiryHeight,
+	}
+	Metrics.RebroadcastQueueGauge.Set(float64(len(rebroadcast.txs)))
+	rebroadcast.dirty = true
+}
+
+// SaveRebroadcastQueue writes the rebroadcast queue to its file if it has
+// changed since it was last written.
+func SaveRebroadcastQueue() {
+	rebroadcast.mutex.Lock()
+	defer rebroadcast.mutex.Unlock()
+	if rebroadcast.fileName == "" || !rebroadcast.dirty {
+		return
+	}
+	writeRebroadcastFile()
+	rebroadcast.dirty = false
+}
+
+// GetRebroadcastTx returns the given transaction (by big-endian hex txid)
+// if it's in the rebroadcast queue, or nil.
+func GetRebroadcastTx(txid string) []byte {
+	rebroadcast.mutex.Lock()
+	defer rebroadcast.mutex.Unlock()
+	if tx, ok := rebroadcast.txs[txid]; ok {
+		return tx.Data
+	}
+	return nil
+}
+
+// getMinedHeight returns the height of the block that includes the given
+// transaction, zero if it isn't mined, or -1 if pirated doesn't know it.
+func getMinedHeight(txid string) (int, error) {
+	txidJSON, err := json.Marshal(txid)
+	if err != nil {
+		return 0, err
+	}
+	result, rpcErr := RawRequest("getrawtransaction", []json.RawMessage{txidJSON, json.RawMessage("1")})
+	if rpcErr != nil {
+		if code, _ := GetRPCErrorCode(rpcErr); code == RPCInvalidAddressOrKey {
+			return -1, nil
+		}
+		return 0, rpcErr
+	}
+	var txinfo PiratedRpcReplyGetrawtransaction
+	if err := json.Unmarshal(result, &txinfo); err != nil {
+		return 0, err
+	}
+	if txinfo.Height < 0 {
+		return 0, nil
+	}
+	return txinfo.Height, nil
+}
+
+// checkRebroadcastQueue rebroadcasts the queued transactions that are
+// neither in the mempool nor mined, and drops the ones that are no longer
+// needed. The queue isn't locked while pirated is queried, so that
+// SendTransaction isn't delayed.
+func checkRebroadcastQueue() {
+	rebroadcast.mutex.Lock()
+	queued := make([]rebroadcastTx, 0, len(rebroadcast.txs))
+	for _, tx := range rebroadcast.txs {
+		queued = append(queued, *tx)
+	}
+	rebroadcast.mutex.Unlock()
+
+	latest := rebroadcast.cache.GetLatestHeight()
+	if latest < 0 || len(queued) == 0 {
+		return
+	}
+	result, rpcErr := RawRequest("getrawmempool", []json.RawMessage{})
+	if rpcErr != nil {
+		Log.Warning("Rebroadcast: getrawmempool failed: ", rpcErr)
+		return
+	}
+	var mempoolList []string
+	if err := json.Unmarshal(result, &mempoolList); err != nil {
+		Log.Warning("Rebroadcast: can't parse getrawmempool reply: ", err)
+		return
+	}
+	mempool := make(map[string]bool)
+	for _, txid := range mempoolList {
+		mempool[txid] = true
+	}
+
+	// The reason each transaction is dropped, if it is.
+	dropReasons := make([]string, len(queued))
+	for i := range queued {
+		tx := &queued[i]
+		if mempool[tx.Txid] {
+			tx.MinedHeight = 0
+			continue
+		}
+		height, err := getMinedHeight(tx.Txid)
+		if err != nil {
+			Log.Warning("Rebroadcast: getrawtransaction failed: ", err)
+			queued = queued[:i]
+			break
+		}
+		if height > 0 {
+			// Keep it until it's deep enough that a reorg is unlikely.
+			tx.MinedHeight = height
+			if latest-height+1 >= rebroadcastDepth {
+				dropReasons[i] = "mined"
+			}
+			continue
+		}
+		if height == 0 {
+			tx.MinedHeight = 0
+		}
+		// Without -txindex, pirated doesn't know a mined transaction
+		// (height is -1), so its MinedHeight, if any, is from an earlier
+		// rebroadcast (below).
+		if tx.MinedHeight == 0 && tx.ExpiryHeight != 0 && uint32(latest)+1 > tx.ExpiryHeight {
+			dropReasons[i] = "expired"
+			continue
+		}
+		txJSON, err := json.Marshal(hex.EncodeToString(tx.Data))
+		if err != nil {
+			continue
+		}
+		tx.Rebroadcasts++
+		Metrics.RebroadcastsCounter.Inc()
+		_, rpcErr := RawRequest("sendrawtransaction", []json.RawMessage{txJSON})
+		if rpcErr != nil {
+			code, _ := GetRPCErrorCode(rpcErr)
+			if code == RPCVerifyError || code == RPCVerifyRejected {
+				dropReasons[i] = "rejected"
+				continue
+			}
+			if code == RPCVerifyAlreadyInChain {
+				// Mined, but without -txindex, getrawtransaction can't
+				// find it, so its height isn't known; it's at or below
+				// the tip as of when this was first seen.
+				if tx.MinedHeight == 0 {
+					tx.MinedHeight = latest
+				}
+				if latest-tx.MinedHeight+1 >= rebroadcastDepth {
+					dropReasons[i] = "mined"
+				}
+				continue
+			}
+			Log.Warning("Rebroadcast: sendrawtransaction failed: ", rpcErr)
+			continue
+		}
+		// Back in the mempool (after a reorg, if it was mined).
+		tx.MinedHeight = 0
+		Log.WithFields(logrus.Fields{
+			"txid":  tx.Txid,
+			"count": tx.Rebroadcasts,
+		}).Info("Rebroadcast: sent transaction")
+	}
+
+	rebroadcast.mutex.Lock()
+	defer rebroadcast.mutex.Unlock()
+	for i, tx := range queued {
+		if dropReasons[i] != "" {
+			delete(rebroadcast.txs, tx.Txid)
+			Metrics.RebroadcastDroppedCounter.WithLabelValues(dropReasons[i]).Inc()
+			Log.WithFields(logrus.Fields{
+				"txid":   tx.Txid,
+				"reason": dropReasons[i],
+			}).Info("Rebroadcast: dropping transaction")
+			continue
+		}
+		if queuedTx, ok := rebroadcast.txs[tx.Txid]; ok {
+			queuedTx.MinedHeight = tx.MinedHeight
+			queuedTx.Rebroadcasts = tx.Rebroadcasts
+		}
+	}
+	Metrics.RebroadcastQueueGauge.Set(float64(len(rebroadcast.txs)))
+	rebroadcast.dirty = true
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package common
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/commitmenttree"
+)
+
+func TestRebroadcast(t *testing.T) {
+	testT = t
+	Metrics = GetPrometheusMetrics()
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 1000, 0)
+	defer func() {
+		c.Close()
+		os.RemoveAll(unitTestPath)
+		rebroadcast.fileName = ""
+	}()
+	tree := commitmenttree.NewTree(commitmenttree.Sapling)
+	for height := 1000; height < 1020; height++ {
+		if err := c.Add(height, saplingTestBlock(height, 0, 0, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	txid := func(b byte) string {
+		return strings.Repeat(hex.EncodeToString([]byte{b}), 32)
+	}
+	minedHeight := map[string]int{txid(0xb): 1015}
+	var sent []string
+	RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		switch method {
+		case "getrawmempool":
+			return json.Marshal([]string{txid(0xa)})
+		case "getrawtransaction":
+			var id string
+			json.Unmarshal(params[0], &id)
+			if height, ok := minedHeight[id]; ok {
+				return json.Marshal(&PiratedRpcReplyGetrawtransaction{Height: height})
+			}
+			return nil, errors.New("-5: No information available about transaction")
+		case "sendrawtransaction":
+			var data string
+			json.Unmarshal(params[0], &data)
+			sent = append(sent, data)
+			if data == "0e" {
+				return nil, errors.New("-26: 18: bad-txns-inputs-spent")
+			}
+			if data == "0f" {
+				return nil, errors.New("-27: transaction already in block chain")
+			}
+			return json.Marshal(txid(0))
+		}
+		testT.Fatal("unexpected call", method)
+		return nil, nil
+	}
+
+	// Not running, so nothing is queued.
+	QueueRebroadcast(txid(0xa), []byte{0xa}, 0)
+	if rebroadcast.txs != nil {
+		t.Fatal("transaction queued without a rebroadcaster")
+	}
+
+	loadRebroadcastQueue(c, unitTestPath, unitTestChain)
+	QueueRebroadcast(txid(0xa), []byte{0xa}, 0)    // in the mempool
+	QueueRebroadcast(txid(0xb), []byte{0xb}, 0)    // mined
+	QueueRebroadcast(txid(0xc), []byte{0xc}, 1030) // dropped from the mempool
+	QueueRebroadcast(txid(0xd), []byte{0xd}, 1019) // expired
+	QueueRebroadcast(txid(0xe), []byte{0xe}, 0)    // now invalid
+	QueueRebroadcast(txid(0xe), []byte{0xe}, 0)
+	QueueRebroadcast(txid(0xf), []byte{0xf}, 0) // mined, but not known without txindex
+
+	checkRebroadcastQueue()
+	sort.Strings(sent)
+	if strings.Join(sent, ",") != "0c,0e,0f" {
+		t.Fatal("unexpected rebroadcasts", sent)
+	}
+	// The one that's already in the chain is kept until it's deep enough,
+	// taking the tip as its height.
+	if len(rebroadcast.txs) != 4 || rebroadcast.txs[txid(0xb)].MinedHeight != 1015 ||
+		rebroadcast.txs[txid(0xc)].Rebroadcasts != 1 || rebroadcast.txs[txid(0xa)] == nil ||
+		rebroadcast.txs[txid(0xf)].MinedHeight != 1019 {
+		t.Fatal("unexpected queue after check", rebroadcast.txs)
+	}
+
+	// The queue is written by the rebroadcast thread, and survives a restart.
+	if !rebroadcast.dirty {
+		t.Fatal("queue not marked as changed")
+	}
+	SaveRebroadcastQueue()
+	rebroadcast.txs = nil
+	loadRebroadcastQueue(c, unitTestPath, unitTestChain)
+	if len(rebroadcast.txs) != 4 || rebroadcast.dirty || rebroadcast.txs[txid(0xc)].Rebroadcasts != 1 ||
+		rebroadcast.txs[txid(0xc)].ExpiryHeight != 1030 {
+		t.Fatal("unexpected queue after restart", rebroadcast.txs)
+	}
+
+	// Once it's mined deeply enough, it's dropped; after a reorg, the
+	// transaction that's no longer mined is rebroadcast.
+	sent = nil
+	minedHeight = map[string]int{txid(0xb): 1010, txid(0xc): 1019}
+	checkRebroadcastQueue()
+	if strings.Join(sent, ",") != "0f" || len(rebroadcast.txs) != 3 ||
+		rebroadcast.txs[txid(0xc)].MinedHeight != 1019 || rebroadcast.txs[txid(0xf)].MinedHeight != 1019 {
+		t.Fatal("unexpected queue after mining", sent, rebroadcast.txs)
+	}
+	sent = nil
+	minedHeight = nil
+	checkRebroadcastQueue()
+	sort.Strings(sent)
+	if strings.Join(sent, ",") != "0c,0f" || rebroadcast.txs[txid(0xc)].MinedHeight != 0 {
+		t.Fatal("unexpected rebroadcasts after reorg", sent)
+	}
+
+	// The one that's in the chain (as far as pirated says) is dropped once
+	// it's deep enough.
+	for height := 1020; height < 1028; height++ {
+		if err := c.Add(height, saplingTestBlock(height, 0, 0, tree, true)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRebroadcastQueue()
+	if rebroadcast.txs[txid(0xf)] == nil {
+		t.Fatal("transaction dropped before it's deep enough")
+	}
+	if err := c.Add(1028, saplingTestBlock(1028, 0, 0, tree, true)); err != nil {
+		t.Fatal(err)
+	}
+	checkRebroadcastQueue()
+	if rebroadcast.txs[txid(0xf)] != nil || len(rebroadcast.txs) != 2 {
+		t.Fatal("unexpected queue after the transaction is deep enough", rebroadcast.txs)
+	}
+
+	// When the queue is full, new transactions aren't queued.
+	saveMaxQueue := rebroadcastMaxQueue
+	rebroadcastMaxQueue = len(rebroadcast.txs)
+	QueueRebroadcast(txid(0x10), []byte{0x10}, 0)
+	rebroadcastMaxQueue = saveMaxQueue
+	if rebroadcast.txs[txid(0x10)] != nil {
+		t.Fatal("transaction queued when the queue is full")
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2019-2020 The Zcash developers
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package common
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/pkg/errors"
+)
+
+// Each entry in a subtrees file is the subtree root (32 bytes), the hash
+// of the block that completed the subtree (32 bytes), and that block's
+// height (8 bytes).
+const subtreeRecordLen = 32 + 32 + 8
+
+// subtreeCache holds the note commitment subtree roots of one shielded pool,
+// persisted in a file next to the block cache files. Only a consecutive
+// sequence of subtrees, starting at index zero, is kept; subtree i is roots[i].
+// Locking is done by the BlockCache that owns it.
+type subtreeCache struct {
+	file  *os.File
+	roots []*walletrpc.SubtreeRoot
+}
+
+func newSubtreeCache(name string) *subtreeCache {
+	sc := &subtreeCache{}
+	var err error
+	sc.file, err = os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		Log.Fatal("open ", name, " failed: ", err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		Log.Fatal("read ", name, " failed: ", err)
+	}
+	for ; len(b) >= subtreeRecordLen; b = b[subtreeRecordLen:] {
+		sc.roots = append(sc.roots, &walletrpc.SubtreeRoot{
+			RootHash:              b[:32],
+			CompletingBlockHash:   b[32:64],
+			CompletingBlockHeight: binary.LittleEndian.Uint64(b[64:subtreeRecordLen]),
+		})
+	}
+	if len(b) > 0 {
+		Log.Warning("subtrees file ", name, " has a partial entry")
+		sc.truncate(len(sc.roots))
+	}
+	return sc
+}
+
+// truncate keeps only the first n subtree roots.
+func (sc *subtreeCache) truncate(n int) {
+	if n > len(sc.roots) {
+		return
+	}
+	if err := sc.file.Truncate(int64(n * subtreeRecordLen)); err != nil {
+		Log.Fatal("truncate subtrees file failed: ", err)
+	}
+	sc.roots = sc.roots[:n]
+}
+
+func (sc *subtreeCache) add(root *walletrpc.SubtreeRoot) {
+	b := make([]byte, subtreeRecordLen)
+	copy(b[:32], root.RootHash)
+	copy(b[32:64], root.CompletingBlockHash)
+	binary.LittleEndian.PutUint64(b[64:], root.CompletingBlockHeight)
+	n, err := sc.file.Write(b)
+	if err != nil {
+		Log.Fatal("subtrees write failed: ", err)
+	}
+	if n != len(b) {
+		Log.Fatal("subtrees write incorrect length: expected: ", len(b), "written: ", n)
+	}
+	sc.roots = append(sc.roots, root)
+}
+
+// reorg removes the subtrees completed at or above the given height.
+func (sc *subtreeCache) reorg(height int) {
+	for i, root := range sc.roots {
+		if int(root.CompletingBlockHeight) >= height {
+			sc.truncate(i)
+			return
+		}
+	}
+}
+
+func (sc *subtreeCache) close() {
+	if sc.file != nil {
+		sc.file.Close()
+		sc.file = nil
+	}
+}
+
+func subtreePoolName(protocol walletrpc.ShieldedProtocol) (string, error) {
+	switch protocol {
+	case walletrpc.ShieldedProtocol_sapling:
+		return "sapling", nil
+	case walletrpc.ShieldedProtocol_orchard:
+		return "orchard", nil
+	}
+	return "", errors.New("unknown shielded protocol")
+}
+
+// GetSubtreeRoots returns up to maxEntries (or all, if maxEntries is zero)
+// note commitment subtree roots of the given shielded pool, starting at
+// subtree index startIndex. Roots are served from the cache when possible;
+// the rest come from pirated's z_getsubtreesbyindex, and those whose
+// completing block is in the block cache are saved (so that a reorg of that
+// block can invalidate them).
+func GetSubtreeRoots(cache *BlockCache, protocol walletrpc.ShieldedProtocol, startIndex, maxEntries int) ([]*walletrpc.SubtreeRoot, error) {
+	pool, err := subtreePoolName(protocol)
+	if err != nil {
+		return nil, err
+	}
+	roots := cache.GetSubtreeRoots(protocol, startIndex, maxEntries)
+	if maxEntries > 0 && len(roots) >= maxEntries {
+		return roots, nil
+	}
+	next := startIndex + len(roots)
+	poolJSON, err := json.Marshal(pool)
+	if err != nil {
+		return nil, err
+	}
+	params := []json.RawMessage{poolJSON, json.RawMessage(strconv.Itoa(next))}
+	if maxEntries > 0 {
+		params = append(params, json.RawMessage(strconv.Itoa(maxEntries-len(roots))))
+	}
+	result, rpcErr := RawRequest("z_getsubtreesbyindex", params)
+	if rpcErr != nil {
+		return nil, errors.Wrap(rpcErr, "error requesting subtree roots")
+	}
+	var reply PiratedRpcReplyGetsubtreebyindex
+	err = json.Unmarshal(result, &reply)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading JSON response")
+	}
+	var fetched []*walletrpc.SubtreeRoot
+	for _, subtree := range reply.Subtrees {
+		rootHash, err := hex.DecodeString(subtree.Root)
+		if err != nil || len(rootHash) != 32 {
+			return nil, errors.New("error decoding subtree root")
+		}
+		block, err := GetBlock(cache, subtree.EndHeight)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, &walletrpc.SubtreeRoot{
+			RootHash: rootHash,
+			// big-endian (display order), as upstream lightwalletd does
+			CompletingBlockHash:   parser.Reverse(block.Hash),
+			CompletingBlockHeight: block.Height,
+		})
+	}
+	cache.AddSubtreeRoots(protocol, next, fetched)
+	return append(roots, fetched...), nil
+}
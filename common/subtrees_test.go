@@ -0,0 +1,147 @@
+// Copyright (c) 2019-2020 The Zcash developers
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+)
+
+var subtreeRPCs int
+
+func subtreesStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method != "z_getsubtreesbyindex" {
+		testT.Fatal("unexpected method", method)
+	}
+	subtreeRPCs++
+	var pool string
+	var start, limit int
+	json.Unmarshal(params[0], &pool)
+	json.Unmarshal(params[1], &start)
+	if len(params) > 2 {
+		json.Unmarshal(params[2], &limit)
+	}
+	if pool != "sapling" {
+		testT.Fatal("unexpected pool", pool)
+	}
+	// Three complete subtrees, completed by blocks 289461, 289463, 289465
+	reply := PiratedRpcReplyGetsubtreebyindex{Pool: pool, StartIndex: start}
+	for i := start; i < 3; i++ {
+		if limit > 0 && len(reply.Subtrees) == limit {
+			break
+		}
+		reply.Subtrees = append(reply.Subtrees, PiratedSubtree{
+			Root:      strings.Repeat(hex.EncodeToString([]byte{byte(i)}), 32),
+			EndHeight: 289461 + 2*i,
+		})
+	}
+	return json.Marshal(reply)
+}
+
+func TestSubtreeRoots(t *testing.T) {
+	testT = t
+	RawRequest = subtreesStub
+	var compactTests []struct {
+		Full string `json:"full"`
+	}
+	blockJSON, err := ioutil.ReadFile("../testdata/compact_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(blockJSON, &compactTests); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	for i, test := range compactTests {
+		blockData, _ := hex.DecodeString(test.Full)
+		block := parser.NewBlock()
+		if _, err = block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Add(289460+i, block.ToCompact()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(roots []*walletrpc.SubtreeRoot, start int) {
+		for i, root := range roots {
+			index := start + i
+			if !bytes.Equal(root.RootHash, bytes.Repeat([]byte{byte(index)}, 32)) {
+				t.Fatal("unexpected root hash at index", index)
+			}
+			height := 289461 + 2*index
+			if root.CompletingBlockHeight != uint64(height) {
+				t.Fatal("unexpected completing height at index", index)
+			}
+			if !bytes.Equal(root.CompletingBlockHash, parser.Reverse(c.Get(height).Hash)) {
+				t.Fatal("unexpected completing hash at index", index)
+			}
+		}
+	}
+
+	// The first call goes to pirated, and caches the results.
+	roots, err := GetSubtreeRoots(c, walletrpc.ShieldedProtocol_sapling, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 1 {
+		t.Fatal("unexpected number of roots", len(roots))
+	}
+	check(roots, 1)
+	// Roots are cached only from index zero, so this wasn't cached.
+	if len(c.GetSubtreeRoots(walletrpc.ShieldedProtocol_sapling, 0, 0)) != 0 {
+		t.Fatal("unexpected cached roots")
+	}
+	roots, err = GetSubtreeRoots(c, walletrpc.ShieldedProtocol_sapling, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 3 || subtreeRPCs != 2 {
+		t.Fatal("unexpected number of roots", len(roots), subtreeRPCs)
+	}
+	check(roots, 0)
+
+	// Requests that the cache can satisfy don't call pirated.
+	roots, err = GetSubtreeRoots(c, walletrpc.ShieldedProtocol_sapling, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 2 || subtreeRPCs != 2 {
+		t.Fatal("unexpected number of roots", len(roots), subtreeRPCs)
+	}
+	check(roots, 1)
+
+	// The cache survives a restart.
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	check(c.GetSubtreeRoots(walletrpc.ShieldedProtocol_sapling, 0, 0), 0)
+	if len(c.GetSubtreeRoots(walletrpc.ShieldedProtocol_sapling, 0, 0)) != 3 {
+		t.Fatal("subtree roots not reloaded")
+	}
+	if len(c.GetSubtreeRoots(walletrpc.ShieldedProtocol_orchard, 0, 0)) != 0 {
+		t.Fatal("unexpected orchard roots")
+	}
+
+	// A reorg removes the subtrees completed by the removed blocks.
+	c.Reorg(289463)
+	if len(c.GetSubtreeRoots(walletrpc.ShieldedProtocol_sapling, 0, 0)) != 1 {
+		t.Fatal("reorg did not remove subtree roots")
+	}
+
+	if _, err = GetSubtreeRoots(c, walletrpc.ShieldedProtocol(7), 0, 0); err == nil {
+		t.Fatal("GetSubtreeRoots unknown protocol should fail")
+	}
+	c.Close()
+	os.RemoveAll(unitTestPath)
+	subtreeRPCs = 0
+}
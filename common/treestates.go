@@ -0,0 +1,308 @@
+// Copyright (c) 2019-2020 The Zcash developers
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+)
+
+var (
+	// treeStateFinalityDepth is how far below the tip a block must be for
+	// its tree state to be saved; blocks this deep aren't expected to be
+	// reorged.
+	treeStateFinalityDepth = 100
+
+	// treeStateSaveInterval is which tree states (those at multiples of
+	// it) are saved in the file; treeStateRecentMax is how many of the
+	// others, the most recently fetched, are kept in memory.
+	treeStateSaveInterval = 1000
+	treeStateRecentMax    = 1000
+)
+
+// treeStateCache holds the tree states that have been fetched from pirated:
+// those at multiples of treeStateSaveInterval, persisted in a file next to
+// the block cache files, and the most recent treeStateRecentMax others, in
+// memory only. Each entry in the file is a 4-byte length, an 8-byte
+// checksum, and the marshalled TreeState (with an empty network name, which
+// the caller fills in). All entries are also kept in memory. Locking is done
+// by the BlockCache that owns it.
+type treeStateCache struct {
+	file   *os.File
+	states map[int]*walletrpc.TreeState
+	byHash map[string]int // block hash (big-endian hex) to height
+	recent []int          // heights of the unsaved states, oldest first
+}
+
+func newTreeStateCache(name string) *treeStateCache {
+	tc := &treeStateCache{
+		states: make(map[int]*walletrpc.TreeState),
+		byHash: make(map[string]int),
+	}
+	var err error
+	tc.file, err = os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		Log.Fatal("open ", name, " failed: ", err)
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		Log.Fatal("read ", name, " failed: ", err)
+	}
+	var offset int64
+	unsaved := false
+	for len(b) >= 12 {
+		length := int(binary.LittleEndian.Uint32(b))
+		if len(b) < 12+length {
+			break
+		}
+		data := b[12 : 12+length]
+		ts := &walletrpc.TreeState{}
+		if err := proto.Unmarshal(data, ts); err != nil ||
+			!bytes.Equal(checksum(int(ts.Height), data), b[4:12]) {
+			break
+		}
+		if int(ts.Height)%treeStateSaveInterval == 0 {
+			tc.states[int(ts.Height)] = ts
+			tc.byHash[ts.Hash] = int(ts.Height)
+		} else {
+			// Saved by an older version.
+			unsaved = true
+		}
+		offset += int64(12 + length)
+		b = b[12+length:]
+	}
+	if len(b) > 0 {
+		Log.Warning("tree states file ", name, " is corrupt after offset ", offset)
+		if err := tc.file.Truncate(offset); err != nil {
+			Log.Fatal("truncate tree states file failed: ", err)
+		}
+	}
+	if unsaved {
+		tc.rewrite()
+	}
+	return tc
+}
+
+func (tc *treeStateCache) write(ts *walletrpc.TreeState) {
+	data, err := proto.Marshal(ts)
+	if err != nil {
+		Log.Fatal("tree state marshal failed: ", err)
+	}
+	b := make([]byte, 12, 12+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(len(data)))
+	copy(b[4:], checksum(int(ts.Height), data))
+	b = append(b, data...)
+	n, err := tc.file.Write(b)
+	if err != nil {
+		Log.Fatal("tree states write failed: ", err)
+	}
+	if n != len(b) {
+		Log.Fatal("tree states write incorrect length: expected: ", len(b), "written: ", n)
+	}
+}
+
+// rewrite replaces the file's contents with the saved tree states.
+func (tc *treeStateCache) rewrite() {
+	if err := tc.file.Truncate(0); err != nil {
+		Log.Fatal("truncate tree states file failed: ", err)
+	}
+	var heights []int
+	for h := range tc.states {
+		if h%treeStateSaveInterval == 0 {
+			heights = append(heights, h)
+		}
+	}
+	sort.Ints(heights)
+	for _, h := range heights {
+		tc.write(tc.states[h])
+	}
+}
+
+// get returns the tree state of the block with the given height, or, if
+// height is zero, the given hash (big-endian hex), or nil if not present.
+// If both are given, the block at that height must have that hash.
+func (tc *treeStateCache) get(height int, hash string) *walletrpc.TreeState {
+	if height <= 0 {
+		h, ok := tc.byHash[hash]
+		if !ok {
+			return nil
+		}
+		height = h
+	}
+	ts := tc.states[height]
+	if ts == nil || (hash != "" && ts.Hash != hash) {
+		return nil
+	}
+	return ts
+}
+
+func (tc *treeStateCache) add(ts *walletrpc.TreeState) {
+	height := int(ts.Height)
+	if _, ok := tc.states[height]; ok {
+		return
+	}
+	if height%treeStateSaveInterval == 0 {
+		tc.write(ts)
+	} else {
+		tc.recent = append(tc.recent, height)
+		if len(tc.recent) > treeStateRecentMax {
+			tc.remove(tc.recent[0])
+			tc.recent = tc.recent[1:]
+		}
+	}
+	tc.states[height] = ts
+	tc.byHash[ts.Hash] = height
+}
+
+func (tc *treeStateCache) remove(height int) {
+	if ts, ok := tc.states[height]; ok {
+		delete(tc.states, height)
+		delete(tc.byHash, ts.Hash)
+	}
+}
+
+// reorg removes the tree states at and above the given height. This is
+// rare (only stored states are finalized), so the file is simply rewritten.
+func (tc *treeStateCache) reorg(height int) {
+	removed := false
+	for h := range tc.states {
+		if h >= height {
+			tc.remove(h)
+			removed = true
+		}
+	}
+	if !removed {
+		return
+	}
+	var recent []int
+	for _, h := range tc.recent {
+		if h < height {
+			recent = append(recent, h)
+		}
+	}
+	tc.recent = recent
+	tc.rewrite()
+}
+
+func (tc *treeStateCache) close() {
+	if tc.file != nil {
+		tc.file.Close()
+		tc.file = nil
+	}
+}
+
+// GetTreeState returns the note commitment tree state as of the end of the
+// block with the given height or, if height is zero, (big-endian) hash,
+// first from the saved tree states, then computed from the cached blocks,
+// then, if neither is possible, from pirated. The returned TreeState's
+// network name is not set, and it must not be modified.
+func GetTreeState(cache *BlockCache, height int, hash []byte) (*walletrpc.TreeState, error) {
+	if height <= 0 && hash == nil {
+		return nil, errors.New("request for unspecified identifier")
+	}
+	hashHex := ""
+	if hash != nil {
+		hashHex = hex.EncodeToString(hash)
+	}
+	if ts := cache.GetTreeState(height, hashHex); ts != nil {
+		return ts, nil
+	}
+	ts := cache.GetLocalTreeState(height)
+	if ts == nil {
+		var err error
+		if ts, err = getTreeStateFromRPC(height, hashHex); err != nil {
+			return nil, err
+		}
+		cache.AddTreeState(ts)
+	}
+	if height > 0 && hashHex != "" && ts.Hash != hashHex {
+		return nil, errors.New("the block at the requested height doesn't have the requested hash")
+	}
+	return ts, nil
+}
+
+// GetLatestTreeState returns the tree state as of the most recent block in
+// the cache.
+func GetLatestTreeState(cache *BlockCache) (*walletrpc.TreeState, error) {
+	height := cache.GetLatestHeight()
+	if height < 0 {
+		return nil, errors.New("Cache is empty. Server is probably not yet ready")
+	}
+	return GetTreeState(cache, height, nil)
+}
+
+func getTreeStateFromRPC(height int, hashHex string) (*walletrpc.TreeState, error) {
+	// The pirated z_gettreestate rpc accepts either a block height or block hash
+	var id string
+	if height > 0 {
+		id = strconv.Itoa(height)
+	} else {
+		id = hashHex
+	}
+	reply, err := gettreestate(id)
+	if err != nil {
+		return nil, err
+	}
+	ts := &walletrpc.TreeState{
+		Height:      uint64(reply.Height),
+		Hash:        reply.Hash,
+		Time:        reply.Time,
+		SaplingTree: reply.Sapling.Commitments.FinalState,
+		OrchardTree: reply.Orchard.Commitments.FinalState,
+	}
+	// If a pool's tree didn't change in this block, pirated returns
+	// (instead of the tree) the hash of the most recent block in which
+	// it did change; follow it.
+	skipHash := reply.Sapling.SkipHash
+	for ts.SaplingTree == "" && skipHash != "" {
+		skipReply, err := gettreestate(skipHash)
+		if err != nil {
+			return nil, err
+		}
+		ts.SaplingTree = skipReply.Sapling.Commitments.FinalState
+		skipHash = skipReply.Sapling.SkipHash
+	}
+	skipHash = reply.Orchard.SkipHash
+	for ts.OrchardTree == "" && skipHash != "" {
+		skipReply, err := gettreestate(skipHash)
+		if err != nil {
+			return nil, err
+		}
+		ts.OrchardTree = skipReply.Orchard.Commitments.FinalState
+		skipHash = skipReply.Orchard.SkipHash
+	}
+	if ts.SaplingTree == "" {
+		return nil, errors.New("pirated did not return treestate")
+	}
+	return ts, nil
+}
+
+func gettreestate(id string) (*PiratedRpcReplyGettreestate, error) {
+	idJSON, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	result, rpcErr := RawRequest("z_gettreestate", []json.RawMessage{idJSON})
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	var reply PiratedRpcReplyGettreestate
+	err = json.Unmarshal(result, &reply)
+	if err != nil {
+		return nil, err
+	}
+	return &reply, nil
+}
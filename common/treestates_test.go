@@ -0,0 +1,205 @@
+// Copyright (c) 2019-2020 The Zcash developers
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package common
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+)
+
+var treestateRPCs int
+
+// The blocks in the test cache (by height), for the stub to reply with.
+var treestateBlocks map[int]*walletrpc.CompactBlock
+
+func treestateStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if method != "z_gettreestate" {
+		testT.Fatal("unexpected method", method)
+	}
+	treestateRPCs++
+	var id string
+	json.Unmarshal(params[0], &id)
+	height, err := strconv.Atoi(id)
+	if err != nil {
+		// It's a block hash
+		for h, block := range treestateBlocks {
+			if hex.EncodeToString(parser.Reverse(block.Hash)) == id {
+				height = h
+			}
+		}
+	}
+	block := treestateBlocks[height]
+	if block == nil {
+		testT.Fatal("unexpected block", id)
+	}
+	reply := PiratedRpcReplyGettreestate{
+		Height: height,
+		Hash:   hex.EncodeToString(parser.Reverse(block.Hash)),
+		Time:   block.Time,
+	}
+	// The Sapling tree changes only in even blocks (the others skip back
+	// to the previous block); the Orchard tree changes in every block.
+	if height%2 == 0 {
+		reply.Sapling.Commitments.FinalState = "sapling" + strconv.Itoa(height)
+	} else {
+		reply.Sapling.SkipHash = hex.EncodeToString(parser.Reverse(treestateBlocks[height-1].Hash))
+	}
+	reply.Orchard.Commitments.FinalState = "orchard" + strconv.Itoa(height)
+	return json.Marshal(reply)
+}
+
+func TestTreeStates(t *testing.T) {
+	testT = t
+	RawRequest = treestateStub
+	saveDepth := treeStateFinalityDepth
+	treeStateFinalityDepth = 2
+	saveInterval, saveRecentMax := treeStateSaveInterval, treeStateRecentMax
+	treeStateSaveInterval = 1
+	var compactTests []struct {
+		Full string `json:"full"`
+	}
+	blockJSON, err := ioutil.ReadFile("../testdata/compact_blocks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(blockJSON, &compactTests); err != nil {
+		t.Fatal(err)
+	}
+	os.RemoveAll(unitTestPath)
+	c := NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	treestateBlocks = make(map[int]*walletrpc.CompactBlock)
+	for i, test := range compactTests {
+		blockData, _ := hex.DecodeString(test.Full)
+		block := parser.NewBlock()
+		if _, err = block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Add(289460+i, block.ToCompact()); err != nil {
+			t.Fatal(err)
+		}
+		treestateBlocks[289460+i] = block.ToCompact()
+	}
+	// The cache holds 289460..289465; heights up to 289463 are final.
+
+	if _, err := GetTreeState(c, 0, nil); err == nil {
+		t.Fatal("GetTreeState with no identifier should fail")
+	}
+	// The Sapling tree is taken from the skip-hash block, but the rest of
+	// the state is that of the requested block.
+	ts, err := GetTreeState(c, 289461, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Height != 289461 || ts.SaplingTree != "sapling289460" || ts.OrchardTree != "orchard289461" ||
+		ts.Hash != hex.EncodeToString(parser.Reverse(treestateBlocks[289461].Hash)) {
+		t.Fatal("unexpected tree state", ts)
+	}
+	if treestateRPCs != 2 {
+		t.Fatal("unexpected number of rpcs", treestateRPCs)
+	}
+	// Now it's cached, by height and by hash.
+	if _, err = GetTreeState(c, 289461, nil); err != nil {
+		t.Fatal(err)
+	}
+	ts, err = GetTreeState(c, 0, parser.Reverse(treestateBlocks[289461].Hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Height != 289461 || treestateRPCs != 2 {
+		t.Fatal("tree state not cached", treestateRPCs)
+	}
+	// A height and the hash of a different block don't match.
+	hash289462 := parser.Reverse(treestateBlocks[289462].Hash)
+	if c.GetTreeState(289461, hex.EncodeToString(hash289462)) != nil {
+		t.Fatal("cached tree state returned for the wrong hash")
+	}
+	if _, err = GetTreeState(c, 289461, hash289462); err == nil {
+		t.Fatal("GetTreeState with a mismatched height and hash should fail")
+	}
+
+	// Non-final tree states aren't cached.
+	if _, err = GetTreeState(c, 289465, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = GetTreeState(c, 289465, nil); err != nil {
+		t.Fatal(err)
+	}
+	if treestateRPCs != 8 {
+		t.Fatal("unexpected number of rpcs", treestateRPCs)
+	}
+	ts, err = GetLatestTreeState(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Height != 289465 || ts.OrchardTree != "orchard289465" {
+		t.Fatal("unexpected latest tree state", ts)
+	}
+
+	// The cache survives a restart.
+	if _, err = GetTreeState(c, 289462, nil); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	treestateRPCs = 0
+	for _, height := range []int{289461, 289462} {
+		ts = c.GetTreeState(height, "")
+		if ts == nil || ts.Height != uint64(height) {
+			t.Fatal("tree state not reloaded", height)
+		}
+	}
+
+	// A reorg removes the tree states at and above the reorg height.
+	c.Reorg(289462)
+	if c.GetTreeState(289462, "") != nil {
+		t.Fatal("reorg did not remove tree state")
+	}
+	if c.GetTreeState(0, hex.EncodeToString(parser.Reverse(treestateBlocks[289461].Hash))) == nil {
+		t.Fatal("reorg removed tree state below the reorg height")
+	}
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	if c.GetTreeState(289462, "") != nil || c.GetTreeState(289461, "") == nil {
+		t.Fatal("reorg not persisted")
+	}
+	c.Close()
+
+	// Only the tree states at multiples of treeStateSaveInterval are saved;
+	// of the others, only the most recent are kept, in memory.
+	os.RemoveAll(unitTestPath)
+	treeStateSaveInterval, treeStateRecentMax = 2, 1
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, 0)
+	for i := 0; i < len(compactTests); i++ {
+		if err := c.Add(289460+i, treestateBlocks[289460+i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, height := range []int{289461, 289462, 289463} {
+		if _, err = GetTreeState(c, height, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.GetTreeState(289461, "") != nil || c.GetTreeState(289462, "") == nil || c.GetTreeState(289463, "") == nil {
+		t.Fatal("unexpected tree states kept")
+	}
+	c.Close()
+	c = NewBlockCache(unitTestPath, unitTestChain, 289460, -1)
+	if c.GetTreeState(289462, "") == nil || c.GetTreeState(289463, "") != nil {
+		t.Fatal("unexpected tree states saved")
+	}
+	c.Close()
+
+	os.RemoveAll(unitTestPath)
+	treeStateFinalityDepth = saveDepth
+	treeStateSaveInterval, treeStateRecentMax = saveInterval, saveRecentMax
+	treestateRPCs = 0
+}
@@ -8,17 +8,29 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/PirateNetwork/lightwalletd/common"
+	"github.com/PirateNetwork/lightwalletd/parser"
 	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -36,9 +48,10 @@ const (
 )
 
 func testsetup() (walletrpc.CompactTxStreamerServer, *common.BlockCache) {
+	step = 0
 	os.RemoveAll(unitTestPath)
 	cache := common.NewBlockCache(unitTestPath, unitTestChain, 380640, 0)
-	lwd, err := NewLwdStreamer(cache, "main", false /* enablePing */)
+	lwd, err := NewLwdStreamer(cache, "main", false /* enablePing */, 8, 0)
 	if err != nil {
 		os.Stderr.WriteString(fmt.Sprint("NewLwdStreamer failed:", err))
 		os.Exit(1)
@@ -56,6 +69,7 @@ func TestMain(m *testing.M) {
 	common.Log = logger.WithFields(logrus.Fields{
 		"app": "test",
 	})
+	common.Metrics = common.GetPrometheusMetrics()
 
 	// Several tests need test blocks; read all 4 into memory just once
 	// (for efficiency).
@@ -116,7 +130,7 @@ func TestGetTransaction(t *testing.T) {
 	if err == nil {
 		testT.Fatal("GetTransaction unexpectedly succeeded")
 	}
-	if err.Error() != "Please call GetTransaction with txid" {
+	if err.Error() != "Please call GetTransaction with txid or block and index" {
 		testT.Fatal("GetTransaction unexpected error message")
 	}
 	if rawtx != nil {
@@ -128,7 +142,7 @@ func TestGetTransaction(t *testing.T) {
 	if err == nil {
 		testT.Fatal("GetTransaction unexpectedly succeeded")
 	}
-	if err.Error() != "Can't GetTransaction with a blockhash+num. Please call GetTransaction with txid" {
+	if err.Error() != "request for unspecified identifier" {
 		testT.Fatal("GetTransaction unexpected error message")
 	}
 	if rawtx != nil {
@@ -136,7 +150,130 @@ func TestGetTransaction(t *testing.T) {
 	}
 }
 
+const testTxid = "6732cf8d67aac5b82a2a0f0217a7d4aa245b2adb0b97fd2d923dfc674415e221"
+
+func getblockTxidStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	step++
+	switch method {
+	case "getblock":
+		if string(params[1]) != "1" {
+			testT.Fatal("getblock not verbose")
+		}
+		var id string
+		if err := json.Unmarshal(params[0], &id); err != nil {
+			testT.Fatal("could not unmarshal block id")
+		}
+		reply := common.PirateRpcReplyGetblock1{
+			Hash:          strings.Repeat("ab", 32),
+			Height:        380640,
+			Confirmations: 3,
+			Tx:            []string{strings.Repeat("00", 32), testTxid},
+		}
+		switch id {
+		case "380640", strings.Repeat("ab", 32):
+		case strings.Repeat("cd", 32):
+			// a block that was reorged away
+			reply.Confirmations = -1
+		default:
+			testT.Fatal("unexpected getblock id", id)
+		}
+		return json.Marshal(reply)
+	case "getrawtransaction":
+		var txid string
+		if err := json.Unmarshal(params[0], &txid); err != nil {
+			testT.Fatal("could not unmarshal txid")
+		}
+		if txid != testTxid {
+			testT.Fatal("unexpected txid", txid)
+		}
+		tx := &common.PiratedRpcReplyGetrawtransaction{
+			Hex:    hex.EncodeToString(rawTxData[0]),
+			Height: 380640,
+		}
+		return json.Marshal(tx)
+	}
+	testT.Fatal("unexpected call to getblockTxidStub")
+	return nil, nil
+}
+
+func TestGetTransactionByBlockIndex(t *testing.T) {
+	testT = t
+	common.RawRequest = getblockTxidStub
+	lwd, _ := testsetup()
+
+	blockHash, _ := hex.DecodeString(strings.Repeat("ab", 32))
+	reorgedHash, _ := hex.DecodeString(strings.Repeat("cd", 32))
+	for _, block := range []*walletrpc.BlockID{
+		{Height: 380640},
+		{Hash: blockHash},
+		{Height: 380640, Hash: blockHash},
+	} {
+		rawtx, err := lwd.GetTransaction(context.Background(),
+			&walletrpc.TxFilter{Block: block, Index: 1})
+		if err != nil {
+			t.Fatal("GetTransaction failed", err)
+		}
+		if !bytes.Equal(rawtx.Data, rawTxData[0]) {
+			t.Fatal("GetTransaction mismatch transaction data")
+		}
+		if rawtx.Height != 380640 {
+			t.Fatal("GetTransaction unexpected height", rawtx.Height)
+		}
+	}
+
+	// index beyond the end of the block
+	_, err := lwd.GetTransaction(context.Background(),
+		&walletrpc.TxFilter{Block: &walletrpc.BlockID{Height: 380640}, Index: 2})
+	if err == nil {
+		t.Fatal("GetTransaction unexpectedly succeeded")
+	}
+
+	// block no longer on the best chain
+	_, err = lwd.GetTransaction(context.Background(),
+		&walletrpc.TxFilter{Block: &walletrpc.BlockID{Hash: reorgedHash}, Index: 1})
+	if err == nil || !strings.Contains(err.Error(), "best chain") {
+		t.Fatal("GetTransaction unexpected result for reorged block", err)
+	}
+
+	// height and hash that disagree
+	_, err = lwd.GetTransaction(context.Background(),
+		&walletrpc.TxFilter{Block: &walletrpc.BlockID{Height: 380641, Hash: blockHash}, Index: 1})
+	if err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatal("GetTransaction unexpected result for mismatched height", err)
+	}
+	step = 0
+}
+
+// verboseGetblockReply returns what pirated's verbose getblock would
+// return for the given (JSON hex string) block.
+func verboseGetblockReply(blockJSON []byte) (json.RawMessage, error) {
+	var blockHex string
+	json.Unmarshal(blockJSON, &blockHex)
+	blockData, _ := hex.DecodeString(blockHex)
+	block := parser.NewBlock()
+	if _, err := block.ParseFromSlice(blockData); err != nil {
+		testT.Fatal("could not parse test block")
+	}
+	reply := common.PirateRpcReplyGetblock1{
+		Hash:          hex.EncodeToString(block.GetDisplayHash()),
+		Height:        block.GetHeight(),
+		Confirmations: 1,
+	}
+	for _, tx := range block.Transactions() {
+		// Parsed transactions have no txid; it's the SHA256d of the
+		// (v4) transaction, in display order.
+		first := sha256.Sum256(tx.Bytes())
+		txid := sha256.Sum256(first[:])
+		reply.Tx = append(reply.Tx, hex.EncodeToString(parser.Reverse(txid[:])))
+	}
+	return json.Marshal(reply)
+}
+
 func getblockStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	if string(params[1]) == "1" {
+		// The txid list that follows a successful (non-verbose) getblock
+		return verboseGetblockReply(blocks[0])
+	}
 	step++
 	var height string
 	err := json.Unmarshal(params[0], &height)
@@ -198,21 +335,26 @@ func TestGetLatestBlock(t *testing.T) {
 	step = 0
 }
 
-// A valid address starts with "t", followed by 34 alpha characters;
+// A valid address starts with "R", followed by 33 alphanumeric characters;
 // these should all be detected as invalid.
 var addressTests = []string{
-	"",                                      // too short
-	"a",                                     // too short
-	"t123456789012345678901234567890123",    // one byte too short
-	"t12345678901234567890123456789012345",  // one byte too long
-	"t123456789012345678901234567890123*",   // invalid "*"
-	"s1234567890123456789012345678901234",   // doesn't start with "t"
-	" t1234567890123456789012345678901234",  // extra stuff before
-	"t1234567890123456789012345678901234 ",  // extra stuff after
-	"\nt1234567890123456789012345678901234", // newline before
-	"t1234567890123456789012345678901234\n", // newline after
+	"",                                     // too short
+	"a",                                    // too short
+	"R12345678901234567890123456789012",    // one byte too short
+	"R1234567890123456789012345678901234",  // one byte too long
+	"R12345678901234567890123456789012*",   // invalid "*"
+	"t123456789012345678901234567890123",   // doesn't start with "R"
+	" R123456789012345678901234567890123",  // extra stuff before
+	"R123456789012345678901234567890123 ",  // extra stuff after
+	"\nR123456789012345678901234567890123", // newline before
+	"R123456789012345678901234567890123\n", // newline after
 }
 
+const (
+	testTaddr  = "R123456789012345678901234567890123"
+	testTaddr2 = "R123456789012345678901234567890124"
+)
+
 func zcashdrpcStub(method string, params []json.RawMessage) (json.RawMessage, error) {
 	step++
 	switch method {
@@ -225,7 +367,7 @@ func zcashdrpcStub(method string, params []json.RawMessage) (json.RawMessage, er
 		if len(filter.Addresses) != 1 {
 			testT.Fatal("wrong number of addresses")
 		}
-		if filter.Addresses[0] != "t1234567890123456789012345678901234" {
+		if filter.Addresses[0] != testTaddr {
 			testT.Fatal("wrong address")
 		}
 		if filter.Start != 20 {
@@ -295,7 +437,7 @@ func TestGetTaddressTxids(t *testing.T) {
 	}
 
 	// valid address
-	addressBlockFilter.Address = "t1234567890123456789012345678901234"
+	addressBlockFilter.Address = testTaddr
 	err := lwd.GetTaddressTxids(addressBlockFilter, &testgettx{})
 	if err != nil {
 		t.Fatal("GetTaddressTxids failed", err)
@@ -309,6 +451,300 @@ func TestGetTaddressTxids(t *testing.T) {
 	step = 0
 }
 
+// testgettxs records the transactions sent by GetTaddressTxids, and cancels
+// the context after the given number (if nonzero).
+type testgettxs struct {
+	walletrpc.CompactTxStreamer_GetTaddressTxidsServer
+	ctx     context.Context
+	cancel  context.CancelFunc
+	limit   int
+	txs     []*walletrpc.RawTransaction
+	trailer metadata.MD
+}
+
+func (tg *testgettxs) Context() context.Context {
+	return tg.ctx
+}
+
+func (tg *testgettxs) Send(tx *walletrpc.RawTransaction) error {
+	tg.txs = append(tg.txs, tx)
+	if len(tg.txs) == tg.limit {
+		tg.cancel()
+	}
+	return nil
+}
+
+func (tg *testgettxs) SetTrailer(md metadata.MD) {
+	tg.trailer = metadata.Join(tg.trailer, md)
+}
+
+func TestGetTaddressTxidsConcurrent(t *testing.T) {
+	testT = t
+	const ntx = 50
+	var inflight, maxInflight, fetches int32
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		switch method {
+		case "getaddresstxids":
+			var txids []string
+			for i := 0; i < ntx; i++ {
+				txids = append(txids, fmt.Sprintf("%064x", i))
+			}
+			return json.Marshal(txids)
+		case "getrawtransaction":
+			n := atomic.AddInt32(&inflight, 1)
+			defer atomic.AddInt32(&inflight, -1)
+			atomic.AddInt32(&fetches, 1)
+			for {
+				max := atomic.LoadInt32(&maxInflight)
+				if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+					break
+				}
+			}
+			var txid string
+			json.Unmarshal(params[0], &txid)
+			var i int
+			fmt.Sscanf(txid, "%x", &i)
+			// Later transactions are fetched faster, so they complete out of order.
+			time.Sleep(time.Duration(ntx-i%8) * 50 * time.Microsecond)
+			return json.Marshal(&common.PiratedRpcReplyGetrawtransaction{
+				Hex:    hex.EncodeToString(rawTxData[0]),
+				Height: 1000 + i,
+			})
+		}
+		testT.Fatal("unexpected call", method)
+		return nil, nil
+	}
+	lwd, _ := testsetup()
+	lwd.(*lwdStreamer).taddrTxidsWorkers = 4
+	filter := &walletrpc.TransparentAddressBlockFilter{
+		Address: testTaddr,
+		Range: &walletrpc.BlockRange{
+			Start: &walletrpc.BlockID{Height: 1000},
+			End:   &walletrpc.BlockID{Height: 2000},
+		},
+	}
+
+	// All transactions arrive, in order.
+	stream := &testgettxs{ctx: context.Background()}
+	if err := lwd.GetTaddressTxids(filter, stream); err != nil {
+		t.Fatal("GetTaddressTxids failed", err)
+	}
+	if len(stream.txs) != ntx {
+		t.Fatal("unexpected number of transactions", len(stream.txs))
+	}
+	for i, tx := range stream.txs {
+		if tx.Height != uint64(1000+i) {
+			t.Fatal("transaction out of order", i, tx.Height)
+		}
+	}
+	if maxInflight > 4 || maxInflight < 2 {
+		t.Fatal("unexpected number of concurrent fetches", maxInflight)
+	}
+
+	// Cancelling the request stops the fetches.
+	atomic.StoreInt32(&fetches, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	stream = &testgettxs{ctx: ctx, cancel: cancel, limit: 5}
+	if err := lwd.GetTaddressTxids(filter, stream); err != context.Canceled {
+		t.Fatal("GetTaddressTxids should have been cancelled", err)
+	}
+	if len(stream.txs) != 5 || atomic.LoadInt32(&fetches) > 5+4 {
+		t.Fatal("GetTaddressTxids continued after cancel", len(stream.txs), fetches)
+	}
+}
+
+func TestGetTaddressTxidsContinuation(t *testing.T) {
+	testT = t
+	// Transactions 0-29 are in block 380640, and 30-49 in block 380641.
+	const ntx = 50
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		switch method {
+		case "getaddresstxids":
+			var filter common.PiratedRpcRequestGetaddresstxids
+			json.Unmarshal(params[0], &filter)
+			var txids []string
+			for i := 0; i < ntx; i++ {
+				if height := 380640 + i/30; uint64(height) >= filter.Start && uint64(height) <= filter.End {
+					txids = append(txids, fmt.Sprintf("%064x", i))
+				}
+			}
+			return json.Marshal(txids)
+		case "getrawtransaction":
+			var txid string
+			json.Unmarshal(params[0], &txid)
+			var i int
+			fmt.Sscanf(txid, "%x", &i)
+			return json.Marshal(&common.PiratedRpcReplyGetrawtransaction{
+				Hex:    hex.EncodeToString(rawTxData[0]),
+				Height: 380640 + i/30,
+			})
+		}
+		testT.Fatal("unexpected call", method)
+		return nil, nil
+	}
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	var compacts []*walletrpc.CompactBlock
+	for i := 0; i < 2; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compacts = append(compacts, block.ToCompact())
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lwd.(*lwdStreamer).taddrTxidsMax = 20
+	filter := &walletrpc.TransparentAddressBlockFilter{
+		Address: testTaddr,
+		Range: &walletrpc.BlockRange{
+			Start: &walletrpc.BlockID{Height: 380640},
+			End:   &walletrpc.BlockID{Height: 380643},
+		},
+	}
+
+	// The results are cut short after the first block (which isn't split).
+	stream := &testgettxs{ctx: context.Background()}
+	if err := lwd.GetTaddressTxids(filter, stream); err != nil {
+		t.Fatal("GetTaddressTxids failed", err)
+	}
+	if len(stream.txs) != 30 || stream.txs[29].Height != 380640 {
+		t.Fatal("unexpected number of transactions", len(stream.txs))
+	}
+	v := stream.trailer.Get(continuationTrailer)
+	if len(v) != 1 {
+		t.Fatal("no continuation")
+	}
+	c := &walletrpc.BlockRangeContinuation{}
+	if err := proto.Unmarshal([]byte(v[0]), c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Height != 380641 || !bytes.Equal(c.PrevHash, compacts[0].Hash) {
+		t.Fatal("unexpected continuation", c)
+	}
+
+	// The rest, from the continuation.
+	filter.Range.Resume = c
+	stream = &testgettxs{ctx: context.Background()}
+	if err := lwd.GetTaddressTxids(filter, stream); err != nil {
+		t.Fatal("GetTaddressTxids failed", err)
+	}
+	if len(stream.txs) != 20 || stream.txs[0].Height != 380641 || len(stream.trailer) != 0 {
+		t.Fatal("unexpected continued results", len(stream.txs), stream.trailer)
+	}
+
+	// A continuation whose block was reorged away.
+	filter.Range.Resume = &walletrpc.BlockRangeContinuation{Height: 380641, PrevHash: compacts[1].Hash}
+	err := lwd.GetTaddressTxids(filter, &testgettxs{ctx: context.Background()})
+	if status.Code(err) != codes.Aborted {
+		t.Fatal("unexpected result for a stale continuation", err)
+	}
+}
+
+type testgettransactions struct {
+	walletrpc.CompactTxStreamer_GetTransactionsServer
+	requests []*walletrpc.TxFilter
+	mutex    sync.Mutex
+	replies  []*walletrpc.GetTransactionsReply
+}
+
+func (tg *testgettransactions) Context() context.Context {
+	return context.Background()
+}
+
+func (tg *testgettransactions) Recv() (*walletrpc.TxFilter, error) {
+	if len(tg.requests) == 0 {
+		return nil, io.EOF
+	}
+	txf := tg.requests[0]
+	tg.requests = tg.requests[1:]
+	return txf, nil
+}
+
+func (tg *testgettransactions) Send(reply *walletrpc.GetTransactionsReply) error {
+	tg.mutex.Lock()
+	defer tg.mutex.Unlock()
+	tg.replies = append(tg.replies, reply)
+	return nil
+}
+
+func TestGetTransactions(t *testing.T) {
+	testT = t
+	var inflight, maxInflight int32
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		if method != "getrawtransaction" {
+			testT.Fatal("unexpected call", method)
+		}
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInflight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInflight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		var txid string
+		json.Unmarshal(params[0], &txid)
+		var i int
+		fmt.Sscanf(txid, "%x", &i)
+		if i%5 == 3 {
+			return nil, errors.New("-5: No information available about transaction")
+		}
+		return json.Marshal(&common.PiratedRpcReplyGetrawtransaction{
+			Hex:    hex.EncodeToString(rawTxData[0]),
+			Height: 1000 + i,
+		})
+	}
+	lwd, _ := testsetup()
+
+	const ntx = 40
+	stream := &testgettransactions{}
+	for i := 0; i < ntx; i++ {
+		txid, _ := hex.DecodeString(fmt.Sprintf("%064x", i))
+		stream.requests = append(stream.requests, &walletrpc.TxFilter{Hash: parser.Reverse(txid)})
+	}
+	// A bad request gets an error reply too.
+	stream.requests = append(stream.requests, &walletrpc.TxFilter{})
+	if err := lwd.GetTransactions(stream); err != nil {
+		t.Fatal("GetTransactions failed", err)
+	}
+	if len(stream.replies) != ntx+1 {
+		t.Fatal("unexpected number of replies", len(stream.replies))
+	}
+	seen := make(map[uint32]bool)
+	for _, reply := range stream.replies {
+		i := reply.RequestIndex
+		if seen[i] {
+			t.Fatal("duplicate reply", i)
+		}
+		seen[i] = true
+		switch {
+		case i == ntx:
+			if reply.Error == "" || reply.Transaction != nil {
+				t.Fatal("expected an error for an empty filter", reply)
+			}
+		case i%5 == 3:
+			if reply.Error != "-5: No information available about transaction" || reply.Transaction != nil {
+				t.Fatal("expected an error", reply)
+			}
+		default:
+			if reply.Error != "" || reply.Transaction.Height != uint64(1000+i) ||
+				!bytes.Equal(reply.Transaction.Data, rawTxData[0]) {
+				t.Fatal("unexpected reply", reply)
+			}
+		}
+	}
+	if maxInflight < 2 || maxInflight > getTransactionsConcurrency {
+		t.Fatal("unexpected number of concurrent lookups", maxInflight)
+	}
+}
+
 func TestGetTaddressTxidsNilArgs(t *testing.T) {
 	lwd, _ := testsetup()
 
@@ -347,6 +783,349 @@ func TestGetTaddressTxidsNilArgs(t *testing.T) {
 	}
 }
 
+func addressHistoryStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	txid := func(b byte) string {
+		return hex.EncodeToString(bytes.Repeat([]byte{b}, 32))
+	}
+	switch method {
+	case "getaddressdeltas":
+		var request common.PiratedRpcRequestGetaddressdeltas
+		json.Unmarshal(params[0], &request)
+		if len(request.Addresses) != 2 || request.Start == 0 || request.End == 0 {
+			testT.Fatal("unexpected getaddressdeltas request", string(params[0]))
+		}
+		var reply []common.PiratedRpcReplyGetaddressdeltas
+		for _, d := range []common.PiratedRpcReplyGetaddressdeltas{
+			{Satoshis: 500, Txid: txid(0xaa), BlockIndex: 1, Height: 380640, Address: testTaddr},
+			{Satoshis: -200, Txid: txid(0xaa), BlockIndex: 1, Height: 380640, Address: testTaddr2},
+			{Satoshis: 70, Txid: txid(0xbb), BlockIndex: 0, Height: 380641, Address: testTaddr},
+			{Satoshis: 30, Txid: txid(0xcc), BlockIndex: 0, Height: 380640, Address: testTaddr2},
+		} {
+			if uint64(d.Height) >= request.Start && uint64(d.Height) <= request.End {
+				reply = append(reply, d)
+			}
+		}
+		return json.Marshal(reply)
+	case "getaddressmempool":
+		return json.Marshal([]common.PiratedRpcReplyGetaddressmempool{
+			{Address: testTaddr, Txid: txid(0xdd), Satoshis: -10, Timestamp: 1600000000},
+			{Address: testTaddr2, Txid: txid(0xbb), Satoshis: 70, Timestamp: 1500000000},
+		})
+	}
+	testT.Fatal("unexpected call to addressHistoryStub", method)
+	return nil, nil
+}
+
+func TestGetAddressHistory(t *testing.T) {
+	testT = t
+	common.RawRequest = addressHistoryStub
+	lwd, cache := testsetup()
+	var times []uint32
+	for i := 0; i < 2; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compact := block.ToCompact()
+		times = append(times, compact.Time)
+		if err := cache.Add(380640+i, compact); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type expect struct {
+		txid   byte
+		height uint64
+		time   uint32
+		delta  int64
+	}
+	all := []expect{
+		{0xcc, 380640, times[0], 30},
+		{0xaa, 380640, times[0], 300},
+		{0xbb, 380641, times[1], 70},
+		{0xdd, 0, 1600000000, -10},
+	}
+	check := func(entries []*walletrpc.AddressHistoryEntry, want []expect) {
+		if len(entries) != len(want) {
+			t.Fatal("unexpected number of entries", len(entries), len(want))
+		}
+		for i, e := range entries {
+			if !bytes.Equal(e.Txid, bytes.Repeat([]byte{want[i].txid}, 32)) ||
+				e.Height != want[i].height || e.Time != want[i].time || e.ValueDelta != want[i].delta {
+				t.Fatal("unexpected entry", i, e)
+			}
+		}
+	}
+
+	arg := &walletrpc.GetAddressHistoryArg{Addresses: []string{testTaddr, testTaddr2}}
+	reply, err := lwd.GetAddressHistory(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressHistory failed", err)
+	}
+	check(reply.Entries, all)
+	if reply.ContinuationToken != "" {
+		t.Fatal("unexpected continuation token", reply.ContinuationToken)
+	}
+
+	// Page through the same history.
+	arg.MaxEntries = 3
+	reply, err = lwd.GetAddressHistory(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressHistory failed", err)
+	}
+	check(reply.Entries, all[:3])
+	arg.ContinuationToken = reply.ContinuationToken
+	reply, err = lwd.GetAddressHistory(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressHistory failed", err)
+	}
+	check(reply.Entries, all[3:])
+	if reply.ContinuationToken != "" {
+		t.Fatal("unexpected continuation token", reply.ContinuationToken)
+	}
+
+	// A range with an end excludes the mempool.
+	arg = &walletrpc.GetAddressHistoryArg{
+		Addresses: []string{testTaddr, testTaddr2},
+		Range: &walletrpc.BlockRange{
+			Start: &walletrpc.BlockID{Height: 380641},
+			End:   &walletrpc.BlockID{Height: 380641},
+		},
+	}
+	reply, err = lwd.GetAddressHistory(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressHistory failed", err)
+	}
+	check(reply.Entries, all[2:3])
+	arg.Range.End = nil
+	arg.ExcludeMempool = true
+	reply, err = lwd.GetAddressHistory(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressHistory failed", err)
+	}
+	check(reply.Entries, all[2:3])
+
+	for _, bad := range []*walletrpc.GetAddressHistoryArg{
+		{},
+		{Addresses: []string{testTaddr}, ContinuationToken: "garbage"},
+		{Addresses: []string{testTaddr}, Range: &walletrpc.BlockRange{
+			Start: &walletrpc.BlockID{Height: 20},
+			End:   &walletrpc.BlockID{Height: 10},
+		}},
+	} {
+		if _, err := lwd.GetAddressHistory(context.Background(), bad); err == nil {
+			t.Fatal("GetAddressHistory should have failed", bad)
+		}
+	}
+
+	// Each address is checked, and there may not be too many.
+	tooMany := make([]string, maxAddressHistoryAddresses+1)
+	for i := range tooMany {
+		tooMany[i] = testTaddr
+	}
+	for _, addresses := range [][]string{{testTaddr, "tB"}, tooMany} {
+		_, err := lwd.GetAddressHistory(context.Background(), &walletrpc.GetAddressHistoryArg{Addresses: addresses})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatal("GetAddressHistory should have failed with InvalidArgument", err)
+		}
+	}
+}
+
+func addressUtxosStub(method string, params []json.RawMessage) (json.RawMessage, error) {
+	txid := func(b byte) string {
+		return hex.EncodeToString(bytes.Repeat([]byte{b}, 32))
+	}
+	switch method {
+	case "getaddressutxos":
+		return json.Marshal([]common.PiratedRpcReplyGetaddressutxos{
+			{Address: "tA", Txid: txid(0x02), OutputIndex: 1, Script: "aa", Satoshis: 10, Height: 100},
+			{Address: "tB", Txid: txid(0x01), OutputIndex: 0, Script: "bb", Satoshis: 20, Height: 100},
+			{Address: "tA", Txid: txid(0x03), OutputIndex: 2, Script: "cc", Satoshis: 30, Height: 50},
+		})
+	case "getaddressmempool":
+		return json.Marshal([]common.PiratedRpcReplyGetaddressmempool{
+			{Address: "tA", Txid: txid(0x04), Index: 0, Satoshis: -30, Prevtxid: txid(0x03), Prevout: 2},
+			{Address: "tB", Txid: txid(0x04), Index: 1, Satoshis: 25},
+			{Address: "tB", Txid: txid(0x05), Index: 0, Satoshis: -25, Prevtxid: txid(0x04), Prevout: 1},
+			{Address: "tA", Txid: txid(0x05), Index: 1, Satoshis: 5},
+		})
+	case "getrawtransaction":
+		var id string
+		json.Unmarshal(params[0], &id)
+		return json.Marshal(map[string]interface{}{
+			"vout": []map[string]interface{}{
+				{"n": 0, "scriptPubKey": map[string]string{"hex": "d0"}},
+				{"n": 1, "scriptPubKey": map[string]string{"hex": "d1" + id[:2]}},
+			},
+		})
+	}
+	testT.Fatal("unexpected call to addressUtxosStub", method)
+	return nil, nil
+}
+
+func TestGetAddressUtxos(t *testing.T) {
+	testT = t
+	common.RawRequest = addressUtxosStub
+	lwd, _ := testsetup()
+
+	type expect struct {
+		txid   byte
+		index  int32
+		height uint64
+		value  int64
+		script string
+	}
+	check := func(reply *walletrpc.GetAddressUtxosReplyList, want []expect) {
+		if len(reply.AddressUtxos) != len(want) {
+			t.Fatal("unexpected number of utxos", len(reply.AddressUtxos), len(want))
+		}
+		for i, u := range reply.AddressUtxos {
+			w := want[i]
+			if !bytes.Equal(u.Txid, bytes.Repeat([]byte{w.txid}, 32)) || u.Index != w.index ||
+				u.Height != w.height || u.ValueZat != w.value || hex.EncodeToString(u.Script) != w.script {
+				t.Fatal("unexpected utxo", i, u)
+			}
+		}
+	}
+	confirmed := []expect{
+		{0x03, 2, 50, 30, "cc"},
+		{0x01, 0, 100, 20, "bb"},
+		{0x02, 1, 100, 10, "aa"},
+	}
+	arg := &walletrpc.GetAddressUtxosArg{Addresses: []string{"tA", "tB"}}
+	reply, err := lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, confirmed)
+	if reply.NextCursor != nil {
+		t.Fatal("unexpected cursor", reply.NextCursor)
+	}
+
+	// Page through the results.
+	arg.MaxEntries = 2
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, confirmed[:2])
+	if reply.NextCursor == nil || reply.NextCursor.Height != 100 || reply.NextCursor.Index != 0 {
+		t.Fatal("unexpected cursor", reply.NextCursor)
+	}
+	arg.StartAfter = reply.NextCursor
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, confirmed[2:])
+	if reply.NextCursor != nil {
+		t.Fatal("unexpected cursor", reply.NextCursor)
+	}
+
+	// Exclude outputs spent in the mempool.
+	arg = &walletrpc.GetAddressUtxosArg{Addresses: []string{"tA", "tB"}, ExcludeMempoolSpent: true}
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, confirmed[1:])
+
+	// Include mempool outputs (last), even ones spent in the mempool.
+	arg = &walletrpc.GetAddressUtxosArg{Addresses: []string{"tA", "tB"}, IncludeMempool: true, StartHeight: 60}
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, append(append([]expect{}, confirmed[1:]...), expect{0x04, 1, 0, 25, "d104"}, expect{0x05, 1, 0, 5, "d105"}))
+
+	// Both: the spendable balance including pending transactions.
+	arg.ExcludeMempoolSpent = true
+	arg.StartHeight = 0
+	arg.MaxEntries = 3
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, append(append([]expect{}, confirmed[1:]...), expect{0x05, 1, 0, 5, "d105"}))
+	if reply.NextCursor != nil {
+		t.Fatal("unexpected cursor", reply.NextCursor)
+	}
+	arg.MaxEntries = 1
+	arg.StartAfter = &walletrpc.UtxoCursor{Height: 100, Txid: bytes.Repeat([]byte{0x02}, 32), Index: 1}
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, []expect{{0x05, 1, 0, 5, "d105"}})
+
+	// The scripts of mempool outputs before the cursor aren't fetched.
+	var fetched []string
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		if method == "getrawtransaction" {
+			var id string
+			json.Unmarshal(params[0], &id)
+			fetched = append(fetched, id[:2])
+		}
+		return addressUtxosStub(method, params)
+	}
+	arg = &walletrpc.GetAddressUtxosArg{
+		Addresses:      []string{"tA", "tB"},
+		IncludeMempool: true,
+		StartAfter:     &walletrpc.UtxoCursor{Height: 0, Txid: bytes.Repeat([]byte{0x04}, 32), Index: 1},
+	}
+	reply, err = lwd.GetAddressUtxos(context.Background(), arg)
+	if err != nil {
+		t.Fatal("GetAddressUtxos failed", err)
+	}
+	check(reply, []expect{{0x05, 1, 0, 5, "d105"}})
+	if strings.Join(fetched, ",") != "05" {
+		t.Fatal("unexpected mempool transactions fetched", fetched)
+	}
+	common.RawRequest = addressUtxosStub
+
+	// The stream returns the cursor to continue from in a trailer.
+	arg = &walletrpc.GetAddressUtxosArg{Addresses: []string{"tA", "tB"}, MaxEntries: 2}
+	stream := &testgetutxos{}
+	if err := lwd.GetAddressUtxosStream(arg, stream); err != nil {
+		t.Fatal("GetAddressUtxosStream failed", err)
+	}
+	check(&walletrpc.GetAddressUtxosReplyList{AddressUtxos: stream.utxos}, confirmed[:2])
+	var next walletrpc.UtxoCursor
+	if err := proto.Unmarshal([]byte(stream.trailer.Get(utxoCursorTrailer)[0]), &next); err != nil {
+		t.Fatal(err)
+	}
+	if next.Height != 100 || next.Index != 0 {
+		t.Fatal("unexpected cursor", next)
+	}
+	arg.StartAfter = &next
+	stream = &testgetutxos{}
+	if err := lwd.GetAddressUtxosStream(arg, stream); err != nil {
+		t.Fatal("GetAddressUtxosStream failed", err)
+	}
+	check(&walletrpc.GetAddressUtxosReplyList{AddressUtxos: stream.utxos}, confirmed[2:])
+	if stream.trailer != nil {
+		t.Fatal("unexpected trailer", stream.trailer)
+	}
+}
+
+type testgetutxos struct {
+	walletrpc.CompactTxStreamer_GetAddressUtxosStreamServer
+	utxos   []*walletrpc.GetAddressUtxosReply
+	trailer metadata.MD
+}
+
+func (tg *testgetutxos) Send(utxo *walletrpc.GetAddressUtxosReply) error {
+	tg.utxos = append(tg.utxos, utxo)
+	return nil
+}
+
+func (tg *testgetutxos) SetTrailer(md metadata.MD) {
+	tg.trailer = md
+}
+
 func TestGetBlock(t *testing.T) {
 	testT = t
 	common.RawRequest = getblockStub
@@ -422,6 +1201,600 @@ func TestGetBlockRange(t *testing.T) {
 	step = 0
 }
 
+// testgetbrangeclosed is a client that has gone away, or goes away after
+// receiving one block.
+type testgetbrangeclosed struct {
+	walletrpc.CompactTxStreamer_GetBlockRangeServer
+	ctx  context.Context
+	sent int
+}
+
+func (tg *testgetbrangeclosed) Context() context.Context {
+	return tg.ctx
+}
+
+func (tg *testgetbrangeclosed) Send(cb *walletrpc.CompactBlock) error {
+	tg.sent++
+	return errors.New("client went away")
+}
+
+func TestGetBlockRangeDisconnect(t *testing.T) {
+	testT = t
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	for i := 0; i < 3; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Add(380640+i, block.ToCompact()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	blockrange := &walletrpc.BlockRange{
+		Start: &walletrpc.BlockID{Height: 380640},
+		End:   &walletrpc.BlockID{Height: 380642},
+	}
+	goroutines := runtime.NumGoroutine()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 50; i++ {
+		stream := &testgetbrangeclosed{ctx: context.Background()}
+		if err := lwd.GetBlockRange(blockrange, stream); err == nil || stream.sent != 1 {
+			t.Fatal("GetBlockRange should have failed after one block", err, stream.sent)
+		}
+		stream = &testgetbrangeclosed{ctx: canceled}
+		if err := lwd.GetBlockRange(blockrange, stream); err != context.Canceled || stream.sent != 0 {
+			t.Fatal("GetBlockRange should have been canceled", err, stream.sent)
+		}
+	}
+	// Nothing is left fetching blocks for the clients that went away.
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Fatal("goroutines leaked:", n-goroutines)
+	}
+}
+
+type testgetbrangetrailer struct {
+	walletrpc.CompactTxStreamer_GetBlockRangeServer
+	heights []uint64
+	trailer metadata.MD
+}
+
+func (tg *testgetbrangetrailer) Context() context.Context {
+	return context.Background()
+}
+
+func (tg *testgetbrangetrailer) Send(cb *walletrpc.CompactBlock) error {
+	tg.heights = append(tg.heights, cb.Height)
+	return nil
+}
+
+func (tg *testgetbrangetrailer) SetTrailer(md metadata.MD) {
+	tg.trailer = metadata.Join(tg.trailer, md)
+}
+
+func TestGetBlockRangeContinuation(t *testing.T) {
+	testT = t
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New("-8: Block height out of range")
+	}
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	defer func() { BlockRangeMaxBlocks = 0 }()
+	var compacts []*walletrpc.CompactBlock
+	for i := 0; i < 3; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compacts = append(compacts, block.ToCompact())
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getRange := func(start, end uint64, resume *walletrpc.BlockRangeContinuation) (*testgetbrangetrailer, error) {
+		stream := &testgetbrangetrailer{}
+		err := lwd.GetBlockRange(&walletrpc.BlockRange{
+			Start:  &walletrpc.BlockID{Height: start},
+			End:    &walletrpc.BlockID{Height: end},
+			Resume: resume,
+		}, stream)
+		return stream, err
+	}
+	continuation := func(stream *testgetbrangetrailer) *walletrpc.BlockRangeContinuation {
+		v := stream.trailer.Get(continuationTrailer)
+		if len(v) != 1 {
+			t.Fatal("no continuation")
+		}
+		c := &walletrpc.BlockRangeContinuation{}
+		if err := proto.Unmarshal([]byte(v[0]), c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	checkHeights := func(stream *testgetbrangetrailer, heights ...uint64) {
+		if fmt.Sprint(stream.heights) != fmt.Sprint(heights) {
+			t.Fatal("unexpected heights", stream.heights, "expected", heights)
+		}
+	}
+
+	// The range is cut short, and then continued.
+	BlockRangeMaxBlocks = 2
+	stream, err := getRange(380640, 380642, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeights(stream, 380640, 380641)
+	c := continuation(stream)
+	if c.Height != 380642 || !bytes.Equal(c.PrevHash, compacts[1].Hash) {
+		t.Fatal("unexpected continuation", c)
+	}
+	stream, err = getRange(380640, 380642, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeights(stream, 380642)
+	if len(stream.trailer) != 0 {
+		t.Fatal("unexpected continuation at the end of the range")
+	}
+
+	// likewise in reverse
+	stream, err = getRange(380642, 380640, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeights(stream, 380642, 380641)
+	c = continuation(stream)
+	if c.Height != 380640 || !bytes.Equal(c.PrevHash, compacts[1].Hash) {
+		t.Fatal("unexpected continuation", c)
+	}
+	stream, err = getRange(380642, 380640, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeights(stream, 380640)
+
+	// A client can continue from the last block it received (such as
+	// after losing the connection).
+	BlockRangeMaxBlocks = 0
+	stream, err = getRange(380640, 380642, &walletrpc.BlockRangeContinuation{Height: 380641, PrevHash: compacts[0].Hash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeights(stream, 380641, 380642)
+
+	for _, c := range []*walletrpc.BlockRangeContinuation{
+		{Height: 380640, PrevHash: compacts[0].Hash},
+		{Height: 380643, PrevHash: compacts[2].Hash},
+	} {
+		if _, err := getRange(380640, 380642, c); err == nil {
+			t.Fatal("continuation outside the range should fail", c.Height)
+		}
+	}
+
+	// A reorg replaces block 380641, so the continuation after it fails.
+	cache.Reorg(380641)
+	reorged := proto.Clone(compacts[1]).(*walletrpc.CompactBlock)
+	reorged.Hash = compacts[0].Hash
+	if err := cache.Add(380641, reorged); err != nil {
+		t.Fatal(err)
+	}
+	_, err = getRange(380640, 380642, &walletrpc.BlockRangeContinuation{Height: 380642, PrevHash: compacts[1].Hash})
+	if status.Code(err) != codes.Aborted || !strings.Contains(err.Error(), "reorg") {
+		t.Fatal("expected a reorg error", err)
+	}
+}
+
+type testsubscribeblocks struct {
+	walletrpc.CompactTxStreamer_SubscribeBlocksServer
+	ctx     context.Context
+	events  chan *walletrpc.BlockSubscriptionEvent
+	sent    func(*walletrpc.BlockSubscriptionEvent) // if set, called after each Send
+	trailer metadata.MD
+}
+
+func (tg *testsubscribeblocks) Context() context.Context {
+	return tg.ctx
+}
+
+func (tg *testsubscribeblocks) Send(event *walletrpc.BlockSubscriptionEvent) error {
+	tg.events <- event
+	if tg.sent != nil {
+		tg.sent(event)
+	}
+	return nil
+}
+
+func (tg *testsubscribeblocks) SetTrailer(md metadata.MD) {
+	tg.trailer = metadata.Join(tg.trailer, md)
+}
+
+func TestSubscribeBlocks(t *testing.T) {
+	testT = t
+	lwd, cache := testsetup()
+	var compacts []*walletrpc.CompactBlock
+	for i := 0; i < 3; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compacts = append(compacts, block.ToCompact())
+	}
+	for i := 0; i < 2; i++ {
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, height := range []uint64{380639, 380643} {
+		stream := &testsubscribeblocks{ctx: context.Background()}
+		if err := lwd.SubscribeBlocks(&walletrpc.BlockID{Height: height}, stream); err == nil {
+			t.Fatal("SubscribeBlocks should have failed for height", height)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &testsubscribeblocks{
+		ctx:    ctx,
+		events: make(chan *walletrpc.BlockSubscriptionEvent, 10),
+	}
+	done := make(chan error)
+	go func() {
+		done <- lwd.SubscribeBlocks(&walletrpc.BlockID{Height: 380640}, stream)
+	}()
+	expectBlock := func(height uint64) {
+		event := <-stream.events
+		if event.Block == nil || event.Rollback != nil || event.Block.Height != height {
+			t.Fatal("expected block", height, "got", event)
+		}
+	}
+	// Replay of the cached blocks.
+	expectBlock(380640)
+	expectBlock(380641)
+	// A new block.
+	if err := cache.Add(380642, compacts[2]); err != nil {
+		t.Fatal(err)
+	}
+	expectBlock(380642)
+	// A reorg, then the replacement blocks.
+	cache.Reorg(380641)
+	for i := 1; i < 3; i++ {
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	event := <-stream.events
+	if event.Rollback == nil || event.Block != nil || event.Rollback.Height != 380640 {
+		t.Fatal("expected rollback to 380640, got", event)
+	}
+	expectBlock(380641)
+	expectBlock(380642)
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatal("SubscribeBlocks should have returned the context error, got", err)
+	}
+	if len(stream.events) != 0 {
+		t.Fatal("unexpected events", len(stream.events))
+	}
+
+	// A reorg while the cached blocks are being replayed; the rollback
+	// comes before any block from the new branch.
+	ctx, cancel = context.WithCancel(context.Background())
+	stream = &testsubscribeblocks{
+		ctx:    ctx,
+		events: make(chan *walletrpc.BlockSubscriptionEvent, 10),
+	}
+	reorged := false
+	stream.sent = func(event *walletrpc.BlockSubscriptionEvent) {
+		if event.Block != nil && event.Block.Height == 380641 && !reorged {
+			reorged = true
+			cache.Reorg(380641)
+			for i := 1; i < 3; i++ {
+				if err := cache.Add(380640+i, compacts[i]); err != nil {
+					t.Error(err)
+				}
+			}
+		}
+	}
+	go func() {
+		done <- lwd.SubscribeBlocks(&walletrpc.BlockID{Height: 380640}, stream)
+	}()
+	expectBlock(380640)
+	expectBlock(380641)
+	event = <-stream.events
+	if event.Rollback == nil || event.Rollback.Height != 380640 {
+		t.Fatal("expected rollback to 380640, got", event)
+	}
+	expectBlock(380641)
+	expectBlock(380642)
+
+	// Resetting the cache (darkside) ends the stream.
+	cache.Reset(380650)
+	if err := <-done; status.Code(err) != codes.Aborted {
+		t.Fatal("SubscribeBlocks should have been aborted by Reset, got", err)
+	}
+	cancel()
+}
+
+func TestSyncBlocks(t *testing.T) {
+	testT = t
+	lwd, cache := testsetup()
+	var compacts []*walletrpc.CompactBlock
+	for i := 0; i < 3; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compacts = append(compacts, block.ToCompact())
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	id := func(i int) *walletrpc.BlockID {
+		return &walletrpc.BlockID{Height: uint64(380640 + i), Hash: compacts[i].Hash}
+	}
+	stale := &walletrpc.BlockID{Height: 380641, Hash: make([]byte, 32)}
+	var stream *testsubscribeblocks
+	sync := func(locator ...*walletrpc.BlockID) ([]*walletrpc.BlockSubscriptionEvent, error) {
+		stream = &testsubscribeblocks{
+			ctx:    context.Background(),
+			events: make(chan *walletrpc.BlockSubscriptionEvent, 10),
+		}
+		err := lwd.SyncBlocks(&walletrpc.BlockLocator{Blocks: locator}, stream)
+		close(stream.events)
+		var events []*walletrpc.BlockSubscriptionEvent
+		for event := range stream.events {
+			events = append(events, event)
+		}
+		return events, err
+	}
+
+	// Already at the tip.
+	if events, err := sync(id(2), id(1), id(0)); err != nil || len(events) != 0 {
+		t.Fatal("unexpected SyncBlocks result at the tip", events, err)
+	}
+	// Behind the tip.
+	events, err := sync(id(0))
+	if err != nil || len(events) != 2 || events[0].Block == nil || events[0].Block.Height != 380641 ||
+		events[1].Block == nil || events[1].Block.Height != 380642 {
+		t.Fatal("unexpected SyncBlocks result", events, err)
+	}
+	// The wallet's last block was reorged away.
+	events, err = sync(stale, id(0))
+	if err != nil || len(events) != 3 || events[0].Rollback == nil || events[0].Rollback.Height != 380640 ||
+		!proto.Equal(events[1].Block, compacts[1]) || !proto.Equal(events[2].Block, compacts[2]) {
+		t.Fatal("unexpected SyncBlocks result after a reorg", events, err)
+	}
+
+	// The blocks are limited, with a continuation.
+	BlockRangeMaxBlocks = 1
+	defer func() { BlockRangeMaxBlocks = 0 }()
+	events, err = sync(stale, id(0))
+	if err != nil || len(events) != 2 || events[0].Rollback == nil || !proto.Equal(events[1].Block, compacts[1]) {
+		t.Fatal("unexpected limited SyncBlocks result", events, err)
+	}
+	v := stream.trailer.Get(continuationTrailer)
+	c := &walletrpc.BlockRangeContinuation{}
+	if len(v) != 1 || proto.Unmarshal([]byte(v[0]), c) != nil ||
+		c.Height != 380642 || !bytes.Equal(c.PrevHash, compacts[1].Hash) {
+		t.Fatal("unexpected SyncBlocks continuation", v)
+	}
+	events, err = sync(&walletrpc.BlockID{Height: c.Height - 1, Hash: c.PrevHash})
+	if err != nil || len(events) != 1 || !proto.Equal(events[0].Block, compacts[2]) || len(stream.trailer) != 0 {
+		t.Fatal("unexpected continued SyncBlocks result", events, err)
+	}
+
+	// errors
+	for _, test := range []struct {
+		locator []*walletrpc.BlockID
+		code    codes.Code
+	}{
+		{nil, codes.InvalidArgument},
+		{make([]*walletrpc.BlockID, maxLocatorBlocks+1), codes.InvalidArgument},
+		{[]*walletrpc.BlockID{stale}, codes.NotFound},
+		{[]*walletrpc.BlockID{{Height: 380643}, id(2)}, codes.Unavailable},
+	} {
+		if _, err := sync(test.locator...); status.Code(err) != test.code {
+			t.Fatal("unexpected SyncBlocks error", len(test.locator), err)
+		}
+	}
+	cache.Close()
+	os.RemoveAll(unitTestPath)
+}
+
+func TestGetLightdInfo(t *testing.T) {
+	testT = t
+	savedNow := common.Time.Now
+	common.Time.Now = time.Now
+	defer func() { common.Time.Now = savedNow }()
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		switch method {
+		case "getinfo":
+			return json.Marshal(&common.PiratedRpcReplyGetinfo{})
+		case "getblockchaininfo":
+			return json.Marshal(&common.PiratedRpcReplyGetblockchaininfo{
+				Chain:  "main",
+				Blocks: 380641,
+				Upgrades: map[string]common.Upgradeinfo{
+					"76b809bb": {Name: "Sapling", ActivationHeight: 152855, Status: "active"},
+				},
+			})
+		}
+		return nil, errors.New("unexpected call " + method)
+	}
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	for i := 0; i < 2; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		if err := cache.Add(380640+i, block.ToCompact()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	DonationAddress, OperatorContact, Features = "zs1donate", "ops@example.com", []string{"rest"}
+	defer func() { DonationAddress, OperatorContact, Features = "", "", nil }()
+
+	info, err := lwd.GetLightdInfo(context.Background(), &walletrpc.Empty{})
+	if err != nil {
+		t.Fatal("GetLightdInfo failed", err)
+	}
+	if info.SaplingActivationHeight != 152855 || info.BlockHeight != 380641 ||
+		info.FirstCachedHeight != 380640 || info.LatestCachedHeight != 380641 {
+		t.Fatal("unexpected heights", info)
+	}
+	if len(info.Features) != 1 || info.Features[0] != "rest" ||
+		info.DonationAddress != "zs1donate" || info.Contact != "ops@example.com" {
+		t.Fatal("unexpected operator information", info)
+	}
+	if !info.TaddrSupport || len(info.EnabledMethods) != len(compactTxStreamerMethods())-1 {
+		t.Fatal("unexpected methods", info.TaddrSupport, info.EnabledMethods)
+	}
+	ConfigureMethods(map[string]bool{"GetAddressUtxos": false})
+	defer ConfigureMethods(nil)
+	info, err = lwd.GetLightdInfo(context.Background(), &walletrpc.Empty{})
+	if err != nil || info.TaddrSupport {
+		t.Fatal("taddrSupport should be false when a t-address method is disabled", err)
+	}
+}
+
+type testgetheaders struct {
+	walletrpc.CompactTxStreamer_GetBlockHeadersServer
+	headers []*walletrpc.BlockHeader
+	trailer metadata.MD
+}
+
+func (tg *testgetheaders) SetTrailer(md metadata.MD) {
+	tg.trailer = md
+}
+
+func (tg *testgetheaders) Context() context.Context {
+	return context.Background()
+}
+
+func (tg *testgetheaders) Send(h *walletrpc.BlockHeader) error {
+	tg.headers = append(tg.headers, h)
+	return nil
+}
+
+func TestGetBlockHeaders(t *testing.T) {
+	testT = t
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New("getblock test error")
+	}
+	lwd, cache := testsetup()
+
+	var headers [][]byte
+	for i := 0; i < 2; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compact := block.ToCompact()
+		compact.Header = block.GetHeader()
+		headers = append(headers, compact.Header)
+		if err := cache.Add(380640+i, compact); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// descending order
+	stream := &testgetheaders{}
+	err := lwd.GetBlockHeaders(&walletrpc.BlockRange{
+		Start: &walletrpc.BlockID{Height: 380641},
+		End:   &walletrpc.BlockID{Height: 380640},
+	}, stream)
+	if err != nil {
+		t.Fatal("GetBlockHeaders failed", err)
+	}
+	if len(stream.headers) != 2 {
+		t.Fatal("GetBlockHeaders unexpected number of headers", len(stream.headers))
+	}
+	for i, h := range stream.headers {
+		if h.Height != uint64(380641-i) {
+			t.Fatal("GetBlockHeaders unexpected height", h.Height)
+		}
+		if !bytes.Equal(h.Header, headers[1-i]) {
+			t.Fatal("GetBlockHeaders unexpected header at height", h.Height)
+		}
+		if !bytes.Equal(h.Hash, cache.Get(int(h.Height)).Hash) {
+			t.Fatal("GetBlockHeaders unexpected hash at height", h.Height)
+		}
+	}
+
+	// Heights beyond the latest cached block aren't requested from pirated.
+	stream = &testgetheaders{}
+	err = lwd.GetBlockHeaders(&walletrpc.BlockRange{
+		Start: &walletrpc.BlockID{Height: 380641},
+		End:   &walletrpc.BlockID{Height: 380642},
+	}, stream)
+	if status.Code(err) != codes.OutOfRange {
+		t.Fatal("GetBlockHeaders beyond the latest block should fail", err)
+	}
+	if len(stream.headers) != 0 {
+		t.Fatal("GetBlockHeaders unexpected number of headers", len(stream.headers))
+	}
+
+	// A range longer than the limit is cut short, with a continuation.
+	BlockRangeMaxBlocks = 1
+	defer func() { BlockRangeMaxBlocks = 0 }()
+	stream = &testgetheaders{}
+	span := &walletrpc.BlockRange{
+		Start: &walletrpc.BlockID{Height: 380640},
+		End:   &walletrpc.BlockID{Height: 380641},
+	}
+	if err := lwd.GetBlockHeaders(span, stream); err != nil {
+		t.Fatal("GetBlockHeaders failed", err)
+	}
+	if len(stream.headers) != 1 || stream.headers[0].Height != 380640 {
+		t.Fatal("GetBlockHeaders unexpected headers with a limit", stream.headers)
+	}
+	var resume walletrpc.BlockRangeContinuation
+	if err := proto.Unmarshal([]byte(stream.trailer.Get(continuationTrailer)[0]), &resume); err != nil {
+		t.Fatal(err)
+	}
+	if resume.Height != 380641 || !bytes.Equal(resume.PrevHash, stream.headers[0].Hash) {
+		t.Fatal("GetBlockHeaders unexpected continuation", resume.Height)
+	}
+	span.Resume = &resume
+	stream = &testgetheaders{}
+	if err := lwd.GetBlockHeaders(span, stream); err != nil {
+		t.Fatal("GetBlockHeaders resume failed", err)
+	}
+	if len(stream.headers) != 1 || stream.headers[0].Height != 380641 || stream.trailer != nil {
+		t.Fatal("GetBlockHeaders unexpected headers after resuming", stream.headers)
+	}
+
+	err = lwd.GetBlockHeaders(&walletrpc.BlockRange{Start: &walletrpc.BlockID{Height: 380640}}, stream)
+	if err == nil {
+		t.Fatal("GetBlockHeaders nil argument should fail")
+	}
+}
+
 func TestGetBlockRangeNilArgs(t *testing.T) {
 	lwd, _ := testsetup()
 
@@ -452,12 +1825,12 @@ func sendrawtransactionStub(method string, params []json.RawMessage) (json.RawMe
 	if method != "sendrawtransaction" {
 		testT.Fatal("unexpected method")
 	}
-	if string(params[0]) != "\"07\"" {
+	if string(params[0]) != "\""+hex.EncodeToString(rawTxData[0])+"\"" {
 		testT.Fatal("unexpected tx data")
 	}
 	switch step {
 	case 1:
-		return []byte("sendtxresult"), nil
+		return []byte("\"" + strings.Repeat("01", 31) + "ff\""), nil
 	case 2:
 		return nil, errors.New("-17: some error")
 	}
@@ -465,20 +1838,33 @@ func sendrawtransactionStub(method string, params []json.RawMessage) (json.RawMe
 	return nil, nil
 }
 
+// withExpiryHeight returns a copy of the ZIP 243 test transaction with the
+// given nExpiryHeight.
+func withExpiryHeight(height uint32) []byte {
+	tx := append([]byte{}, rawTxData[0]...)
+	// 407096499, little-endian
+	i := bytes.Index(tx, []byte{0xb3, 0xcc, 0x43, 0x18})
+	if i < 0 {
+		testT.Fatal("can't find nExpiryHeight")
+	}
+	binary.LittleEndian.PutUint32(tx[i:], height)
+	return tx
+}
+
 func TestSendTransaction(t *testing.T) {
 	testT = t
-	lwd, _ := testsetup()
+	lwd, cache := testsetup()
 	common.RawRequest = sendrawtransactionStub
-	rawtx := walletrpc.RawTransaction{Data: []byte{7}}
+	rawtx := walletrpc.RawTransaction{Data: rawTxData[0]}
 	sendresult, err := lwd.SendTransaction(context.Background(), &rawtx)
 	if err != nil {
 		t.Fatal("SendTransaction failed", err)
 	}
-	if sendresult.ErrorCode != 0 {
-		t.Fatal("SendTransaction unexpected ErrorCode return")
+	if sendresult.ErrorCode != 0 || sendresult.ErrorMessage != "" || sendresult.Rejection != walletrpc.SendRejection_notRejected {
+		t.Fatal("SendTransaction unexpected error return", sendresult)
 	}
-	if sendresult.ErrorMessage != "sendtxresult" {
-		t.Fatal("SendTransaction unexpected ErrorMessage return")
+	if !bytes.Equal(sendresult.Txid, append([]byte{0xff}, bytes.Repeat([]byte{1}, 31)...)) {
+		t.Fatal("SendTransaction unexpected txid", sendresult.Txid)
 	}
 
 	// sendrawtransactionStub case 2 (error)
@@ -487,13 +1873,158 @@ func TestSendTransaction(t *testing.T) {
 	if err != nil {
 		t.Fatal("SendTransaction failed:", err)
 	}
-	if sendresult.ErrorCode != -17 {
+	if sendresult.ErrorCode != -17 || sendresult.Rejection != walletrpc.SendRejection_rejectedByNode {
 		t.Fatal("SendTransaction unexpected ErrorCode return")
 	}
-	if sendresult.ErrorMessage != "some error" {
+	if sendresult.ErrorMessage != "some error" || sendresult.Txid != nil {
 		t.Fatal("SendTransaction unexpected ErrorMessage return")
 	}
 	step = 0
+
+	// The rest are rejected without calling pirated, except to get (once,
+	// since it's cached) the next block's consensus branch ID.
+	savedNow := common.Time.Now
+	now := time.Now().Add(time.Hour) // after any earlier test's cached info expires
+	common.Time.Now = func() time.Time { return now }
+	defer func() { common.Time.Now = savedNow }()
+	infoCalls := 0
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		switch method {
+		case "getinfo":
+			infoCalls++
+			return json.Marshal(&common.PiratedRpcReplyGetinfo{})
+		case "getblockchaininfo":
+			return json.Marshal(&common.PiratedRpcReplyGetblockchaininfo{
+				Blocks: 380640,
+				Upgrades: map[string]common.Upgradeinfo{
+					"76b809bb": {Name: "Sapling", ActivationHeight: 152855},
+					"c2d6d0b4": {Name: "Orchard", ActivationHeight: 380641},
+				},
+			})
+		}
+		testT.Fatal("unexpected call", method)
+		return nil, nil
+	}
+	reject := func(data []byte, rejection walletrpc.SendRejection, errCode int32) {
+		sendresult, err := lwd.SendTransaction(context.Background(), &walletrpc.RawTransaction{Data: data})
+		if err != nil {
+			t.Fatal("SendTransaction failed:", err)
+		}
+		if sendresult.Rejection != rejection || sendresult.ErrorCode != errCode || sendresult.ErrorMessage == "" {
+			t.Fatal("SendTransaction unexpected rejection", rejection, sendresult)
+		}
+	}
+	reject([]byte{7}, walletrpc.SendRejection_malformed, -22)
+	reject(append(append([]byte{}, rawTxData[0]...), 0), walletrpc.SendRejection_malformed, -22)
+	reject(make([]byte, 2000001), walletrpc.SendRejection_tooLarge, -26)
+
+	// A version 5 transaction (from the parser tests) for another branch.
+	s, err := os.ReadFile("../testdata/tx_v5.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testdata [][]interface{}
+	if err := json.Unmarshal(s, &testdata); err != nil {
+		t.Fatal(err)
+	}
+	v5tx, _ := hex.DecodeString(testdata[2][0].(string))
+	reject(v5tx, walletrpc.SendRejection_wrongConsensusBranch, -26)
+	reject(v5tx, walletrpc.SendRejection_wrongConsensusBranch, -26)
+	if infoCalls != 1 {
+		t.Fatal("consensus branch ID not cached", infoCalls)
+	}
+
+	// Expiry is checked against the next block's height, 380641.
+	var blockHex string
+	json.Unmarshal(blocks[0], &blockHex)
+	blockData, _ := hex.DecodeString(blockHex)
+	block := parser.NewBlock()
+	if _, err := block.ParseFromSlice(blockData); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Add(380640, block.ToCompact()); err != nil {
+		t.Fatal(err)
+	}
+	reject(withExpiryHeight(380640), walletrpc.SendRejection_expired, -26)
+	reject(withExpiryHeight(380643), walletrpc.SendRejection_expiringSoon, -26)
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		return json.Marshal(strings.Repeat("00", 32))
+	}
+	for _, height := range []uint32{0, 380644} {
+		sendresult, err = lwd.SendTransaction(context.Background(), &walletrpc.RawTransaction{Data: withExpiryHeight(height)})
+		if err != nil || sendresult.Rejection != walletrpc.SendRejection_notRejected {
+			t.Fatal("SendTransaction unexpectedly rejected", height, err, sendresult)
+		}
+	}
+}
+
+func TestGetTransactionStatus(t *testing.T) {
+	testT = t
+	lwd, cache := testsetup()
+	var hashes [][]byte
+	for i := 0; i < 2; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compact := block.ToCompact()
+		hashes = append(hashes, compact.Hash)
+		if err := cache.Add(380640+i, compact); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replies := map[byte]*common.PiratedRpcReplyGetrawtransaction{
+		1: {Hex: hex.EncodeToString(rawTxData[0]), Height: 380640, Blockhash: hex.EncodeToString(parser.Reverse(hashes[0]))},
+		2: {Hex: hex.EncodeToString(rawTxData[0]), Height: 380640, Blockhash: strings.Repeat("ab", 32)},
+		3: {Hex: hex.EncodeToString(withExpiryHeight(380700))},
+		4: {Hex: hex.EncodeToString(withExpiryHeight(380641))},
+	}
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		if method != "getrawtransaction" {
+			testT.Fatal("unexpected call", method)
+		}
+		var txid string
+		json.Unmarshal(params[0], &txid)
+		b, _ := hex.DecodeString(txid[:2])
+		if reply, ok := replies[b[0]]; ok {
+			return json.Marshal(reply)
+		}
+		if b[0] == 6 {
+			return nil, errors.New("-28: Loading block index...")
+		}
+		return nil, errors.New("-5: No information available about transaction")
+	}
+	status := func(b byte) *walletrpc.TransactionStatus {
+		s, err := lwd.GetTransactionStatus(context.Background(), &walletrpc.TxFilter{Hash: bytes.Repeat([]byte{b}, 32)})
+		if err != nil {
+			t.Fatal("GetTransactionStatus failed", err)
+		}
+		return s
+	}
+	if s := status(1); s.State != walletrpc.TransactionState_mined || s.Height != 380640 ||
+		!bytes.Equal(s.BlockHash, hashes[0]) || s.Confirmations != 2 || s.ExpiryHeight != 407096499 {
+		t.Fatal("unexpected mined status", s)
+	}
+	if s := status(2); s.State != walletrpc.TransactionState_mined || s.Confirmations != 0 {
+		t.Fatal("unexpected status for a block not in the cache", s)
+	}
+	if s := status(3); s.State != walletrpc.TransactionState_inMempool || s.ExpiryHeight != 380700 {
+		t.Fatal("unexpected mempool status", s)
+	}
+	if s := status(4); s.State != walletrpc.TransactionState_expiredUnmined || s.ExpiryHeight != 380641 {
+		t.Fatal("unexpected expired status", s)
+	}
+	if s := status(5); s.State != walletrpc.TransactionState_notFound || s.ExpiryHeight != 0 {
+		t.Fatal("unexpected unknown status", s)
+	}
+	for _, txf := range []*walletrpc.TxFilter{{Hash: bytes.Repeat([]byte{6}, 32)}, {Hash: []byte{1}}} {
+		if _, err := lwd.GetTransactionStatus(context.Background(), txf); err == nil {
+			t.Fatal("GetTransactionStatus should have failed")
+		}
+	}
 }
 
 var sampleconf = `
@@ -0,0 +1,198 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package frontend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// gRPC-Web (https://github.com/grpc/grpc/blob/master/doc/PROTOCOL-WEB.md)
+// lets browsers, which can't use HTTP/2 trailers, call the gRPC services.
+// It's the same as gRPC, except that it works over HTTP/1.1, and the
+// trailers (including the status) are sent as a final frame in the body.
+// The "text" variant base64-encodes the body, for clients that can't
+// stream binary data.
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// The flag in the frame header that marks the trailers frame.
+	grpcWebTrailersFlag = 0x80
+)
+
+// The request headers browsers may send, and the response headers they
+// may read, across origins.
+const (
+	grpcWebAllowHeaders  = "content-type, x-grpc-web, x-user-agent, grpc-timeout"
+	grpcWebExposeHeaders = "grpc-status, grpc-message, grpc-status-details-bin"
+)
+
+type grpcWebHandler struct {
+	server         http.Handler
+	allowedOrigins map[string]bool
+	allowAll       bool
+}
+
+// NewGRPCWebHandler returns a handler that serves gRPC-Web requests by
+// translating them into gRPC requests for the given server (a *grpc.Server),
+// so server streaming calls work as they do over gRPC. Cross-origin
+// requests are allowed from the given origins ("*" allows any origin).
+func NewGRPCWebHandler(server http.Handler, allowedOrigins []string) http.Handler {
+	h := &grpcWebHandler{
+		server:         server,
+		allowedOrigins: make(map[string]bool),
+	}
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			h.allowAll = true
+		}
+		h.allowedOrigins[strings.ToLower(origin)] = true
+	}
+	return h
+}
+
+func (h *grpcWebHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !h.allowAll && !h.allowedOrigins[strings.ToLower(origin)] {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+		w.Header().Set("Access-Control-Expose-Headers", grpcWebExposeHeaders)
+	}
+	if r.Method == "OPTIONS" {
+		// CORS preflight
+		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", grpcWebAllowHeaders)
+		w.Header().Set("Access-Control-Max-Age", "86400")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	var text bool
+	switch contentType {
+	case grpcWebContentType, grpcWebContentType + "+proto":
+	case grpcWebTextContentType, grpcWebTextContentType + "+proto":
+		text = true
+	default:
+		http.Error(w, "not a gRPC-Web request", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// The gRPC server only accepts HTTP/2 requests, but it doesn't need
+	// anything from HTTP/2 other than trailers, which are handled here.
+	req := r.WithContext(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2"
+	req.Header = r.Header.Clone()
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	if text {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{base64.NewDecoder(base64.StdEncoding, r.Body), r.Body}
+	}
+	resp := &grpcWebResponse{
+		w:           w,
+		header:      make(http.Header),
+		contentType: contentType,
+		text:        text,
+	}
+	h.server.ServeHTTP(resp, req)
+	resp.finish()
+}
+
+// grpcWebResponse is the http.ResponseWriter given to the gRPC server. It
+// passes the headers and messages through, and collects the trailers,
+// which are written as the final frame once the call is done.
+type grpcWebResponse struct {
+	w           http.ResponseWriter
+	header      http.Header
+	contentType string
+	text        bool
+	wroteHeader bool
+	pending     bytes.Buffer // not yet base64-encoded (text only)
+}
+
+func (resp *grpcWebResponse) Header() http.Header {
+	return resp.header
+}
+
+func (resp *grpcWebResponse) WriteHeader(code int) {
+	if resp.wroteHeader {
+		return
+	}
+	resp.wroteHeader = true
+	h := resp.w.Header()
+	for k, vv := range resp.header {
+		if k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		h[k] = vv
+	}
+	h.Set("Content-Type", resp.contentType)
+	h.Del("Content-Length")
+	resp.w.WriteHeader(code)
+}
+
+func (resp *grpcWebResponse) Write(b []byte) (int, error) {
+	resp.WriteHeader(http.StatusOK)
+	if resp.text {
+		// Encoded when flushed, so that each message isn't padded
+		// separately.
+		return resp.pending.Write(b)
+	}
+	return resp.w.Write(b)
+}
+
+func (resp *grpcWebResponse) Flush() {
+	resp.WriteHeader(http.StatusOK)
+	if resp.text && resp.pending.Len() > 0 {
+		resp.w.Write([]byte(base64.StdEncoding.EncodeToString(resp.pending.Bytes())))
+		resp.pending.Reset()
+	}
+	if f, ok := resp.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish writes the trailers (those the gRPC server declared, and those
+// it added after writing the headers) as the final frame.
+func (resp *grpcWebResponse) finish() {
+	declared := make(map[string]bool)
+	for _, k := range resp.header["Trailer"] {
+		declared[http.CanonicalHeaderKey(k)] = true
+	}
+	var lines []string
+	for k, vv := range resp.header {
+		name := strings.TrimPrefix(k, http.TrailerPrefix)
+		if name == k && !declared[k] {
+			continue
+		}
+		for _, v := range vv {
+			lines = append(lines, strings.ToLower(name)+": "+v+"\r\n")
+		}
+	}
+	sort.Strings(lines)
+	trailers := strings.Join(lines, "")
+	var frame [5]byte
+	frame[0] = grpcWebTrailersFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(trailers)))
+	resp.Write(frame[:])
+	resp.Write([]byte(trailers))
+	resp.Flush()
+}
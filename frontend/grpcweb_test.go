@@ -0,0 +1,210 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package frontend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+const grpcWebTestOrigin = "https://wallet.example.com"
+
+// grpcWebCall makes a gRPC-Web call, returning the response messages and
+// the trailers.
+func grpcWebCall(t *testing.T, url, method string, text bool, req proto.Message) ([][]byte, map[string]string) {
+	b, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(b)))
+	body = append(body, b...)
+	contentType := grpcWebContentType + "+proto"
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+		contentType = grpcWebTextContentType + "+proto"
+	}
+	httpReq, _ := http.NewRequest("POST", url+"/pirate.wallet.sdk.rpc.CompactTxStreamer/"+method, bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", contentType)
+	httpReq.Header.Set("Origin", grpcWebTestOrigin)
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != contentType ||
+		resp.Header.Get("Access-Control-Allow-Origin") != grpcWebTestOrigin {
+		t.Fatal("unexpected gRPC-Web response", resp.StatusCode, resp.Header)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text {
+		// Each flush is encoded (and padded) separately, so decode
+		// each group of four characters on its own.
+		var decoded []byte
+		for ; len(data) >= 4; data = data[4:] {
+			d, err := base64.StdEncoding.DecodeString(string(data[:4]))
+			if err != nil {
+				t.Fatal("bad base64 response", err)
+			}
+			decoded = append(decoded, d...)
+		}
+		data = decoded
+	}
+	var messages [][]byte
+	for len(data) >= 5 {
+		flags := data[0]
+		length := binary.BigEndian.Uint32(data[1:5])
+		if int(length) > len(data)-5 {
+			t.Fatal("truncated gRPC-Web frame")
+		}
+		frame := data[5 : 5+length]
+		data = data[5+length:]
+		if flags&grpcWebTrailersFlag == 0 {
+			messages = append(messages, frame)
+			continue
+		}
+		if len(data) > 0 {
+			t.Fatal("data after the trailers")
+		}
+		trailers := make(map[string]string)
+		for _, line := range strings.Split(string(frame), "\r\n") {
+			if line == "" {
+				continue
+			}
+			kv := strings.SplitN(line, ": ", 2)
+			trailers[kv[0]] = kv[1]
+		}
+		return messages, trailers
+	}
+	t.Fatal("no gRPC-Web trailers")
+	return nil, nil
+}
+
+func TestGRPCWeb(t *testing.T) {
+	testT = t
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	var compacts []*walletrpc.CompactBlock
+	for i := 0; i < 3; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compacts = append(compacts, block.ToCompact())
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := grpc.NewServer()
+	walletrpc.RegisterCompactTxStreamerServer(server, lwd)
+	ts := httptest.NewServer(NewGRPCWebHandler(server, []string{grpcWebTestOrigin}))
+	defer ts.Close()
+
+	// unary
+	for _, text := range []bool{false, true} {
+		messages, trailers := grpcWebCall(t, ts.URL, "GetLatestBlock", text, &walletrpc.ChainSpec{})
+		if trailers["grpc-status"] != "0" || len(messages) != 1 {
+			t.Fatal("GetLatestBlock failed", trailers, len(messages))
+		}
+		var id walletrpc.BlockID
+		if err := proto.Unmarshal(messages[0], &id); err != nil {
+			t.Fatal(err)
+		}
+		if id.Height != 380642 || !bytes.Equal(id.Hash, compacts[2].Hash) {
+			t.Fatal("unexpected latest block", id.Height)
+		}
+	}
+
+	// server streaming
+	for _, text := range []bool{false, true} {
+		messages, trailers := grpcWebCall(t, ts.URL, "GetBlockRange", text, &walletrpc.BlockRange{
+			Start: &walletrpc.BlockID{Height: 380640},
+			End:   &walletrpc.BlockID{Height: 380642},
+		})
+		if trailers["grpc-status"] != "0" || len(messages) != 3 {
+			t.Fatal("GetBlockRange failed", trailers, len(messages))
+		}
+		for i, m := range messages {
+			var block walletrpc.CompactBlock
+			if err := proto.Unmarshal(m, &block); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(&block, compacts[i]) {
+				t.Fatal("unexpected block", i)
+			}
+		}
+	}
+
+	// errors are returned in the trailers
+	messages, trailers := grpcWebCall(t, ts.URL, "GetBlock", false, &walletrpc.BlockID{})
+	if len(messages) != 0 || trailers["grpc-status"] != "2" ||
+		trailers["grpc-message"] != "request for unspecified identifier" {
+		t.Fatal("unexpected GetBlock result", trailers, len(messages))
+	}
+	messages, trailers = grpcWebCall(t, ts.URL, "NoSuchMethod", true, &walletrpc.Empty{})
+	if len(messages) != 0 || trailers["grpc-status"] != "12" {
+		t.Fatal("unexpected result for an unknown method", trailers, len(messages))
+	}
+
+	// CORS
+	preflight := func(origin string) *http.Response {
+		req, _ := http.NewRequest("OPTIONS", ts.URL+"/pirate.wallet.sdk.rpc.CompactTxStreamer/GetBlockRange", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	resp := preflight(grpcWebTestOrigin)
+	if resp.StatusCode != http.StatusNoContent ||
+		resp.Header.Get("Access-Control-Allow-Origin") != grpcWebTestOrigin ||
+		!strings.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "x-grpc-web") ||
+		!strings.Contains(resp.Header.Get("Access-Control-Allow-Methods"), "POST") {
+		t.Fatal("unexpected preflight response", resp.StatusCode, resp.Header)
+	}
+	if resp := preflight("https://evil.example.com"); resp.StatusCode != http.StatusForbidden {
+		t.Fatal("origin should not be allowed", resp.StatusCode)
+	}
+	tsAll := httptest.NewServer(NewGRPCWebHandler(server, []string{"*"}))
+	defer tsAll.Close()
+	req, _ := http.NewRequest("OPTIONS", tsAll.URL+"/", nil)
+	req.Header.Set("Origin", "https://other.example.com")
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatal("any origin should be allowed", err)
+	}
+
+	// not gRPC-Web
+	if resp, err := http.Post(ts.URL+"/pirate.wallet.sdk.rpc.CompactTxStreamer/GetLatestBlock",
+		"application/grpc", bytes.NewReader(nil)); err != nil || resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatal("plain gRPC request should fail", err)
+	}
+	if resp, err := http.Get(ts.URL + "/"); err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal("GET should fail", err)
+	}
+}
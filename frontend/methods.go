@@ -0,0 +1,149 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package frontend
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// methodGroups are names for sets of CompactTxStreamer methods that
+// operators commonly disable together.
+var methodGroups = map[string][]string{
+	// The t-address RPCs need pirated's address index, and link the
+	// addresses to the client's IP address.
+	"taddr": {
+		"GetTaddressTxids",
+		"GetTaddressBalance",
+		"GetTaddressBalanceStream",
+		"GetAddressHistory",
+		"GetAddressUtxos",
+		"GetAddressUtxosStream",
+	},
+	"prices": {
+		"GetARRRPrice",
+		"GetCurrentARRRPrice",
+	},
+}
+
+// disabledMethods are the CompactTxStreamer methods that the operator has
+// disabled (see ConfigureMethods).
+var disabledMethods = make(map[string]bool)
+
+// compactTxStreamerMethods returns the names of all of the
+// CompactTxStreamer methods, unary and streaming.
+func compactTxStreamerMethods() []string {
+	desc := &walletrpc.CompactTxStreamer_ServiceDesc
+	var methods []string
+	for _, m := range desc.Methods {
+		methods = append(methods, m.MethodName)
+	}
+	for _, s := range desc.Streams {
+		methods = append(methods, s.StreamName)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// ConfigureMethods enables (true) or disables (false) CompactTxStreamer
+// methods, given by name or group name (such as "taddr"), ignoring case;
+// names that differ only in case must have the same setting. Methods that
+// aren't mentioned are enabled, and a setting for a method overrides the
+// setting for its group. It must be called before the server starts.
+func ConfigureMethods(settings map[string]bool) error {
+	methods := make(map[string]string)
+	for _, method := range compactTxStreamerMethods() {
+		methods[strings.ToLower(method)] = method
+	}
+	groups := make(map[string][]string)
+	for group, members := range methodGroups {
+		groups[strings.ToLower(group)] = members
+	}
+	normalized := make(map[string]bool)
+	for name, enabled := range settings {
+		key := strings.ToLower(name)
+		if _, ok := groups[key]; !ok {
+			if _, ok := methods[key]; !ok {
+				return errors.New("unknown method " + name)
+			}
+		}
+		if other, ok := normalized[key]; ok && other != enabled {
+			return errors.New("conflicting settings for method " + name)
+		}
+		normalized[key] = enabled
+	}
+	disabled := make(map[string]bool)
+	for key, enabled := range normalized {
+		for _, method := range groups[key] {
+			disabled[method] = !enabled
+		}
+	}
+	for key, enabled := range normalized {
+		if method, ok := methods[key]; ok {
+			disabled[method] = !enabled
+		}
+	}
+	disabledMethods = make(map[string]bool)
+	for method, off := range disabled {
+		if off {
+			disabledMethods[method] = true
+		}
+	}
+	return nil
+}
+
+// methodEnabled returns whether the given CompactTxStreamer method (by
+// name) is enabled.
+func methodEnabled(method string) bool {
+	return !disabledMethods[method]
+}
+
+// enabledMethods returns the names of the enabled CompactTxStreamer
+// methods, as reported by GetLightdInfo.
+func enabledMethods(pingEnable bool) []string {
+	var methods []string
+	for _, method := range compactTxStreamerMethods() {
+		if methodEnabled(method) && (pingEnable || method != "Ping") {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// disabledMethodError returns the error for a call to the given gRPC
+// method (such as "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetBlock") if
+// it's a disabled CompactTxStreamer method, else nil.
+func disabledMethodError(fullMethod string) error {
+	prefix := "/" + walletrpc.CompactTxStreamer_ServiceDesc.ServiceName + "/"
+	if !strings.HasPrefix(fullMethod, prefix) || methodEnabled(strings.TrimPrefix(fullMethod, prefix)) {
+		return nil
+	}
+	return status.Error(codes.Unimplemented, strings.TrimPrefix(fullMethod, prefix)+" is disabled on this server")
+}
+
+// MethodFilterUnaryInterceptor fails calls to disabled unary methods with
+// codes.Unimplemented.
+func MethodFilterUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := disabledMethodError(info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// MethodFilterStreamInterceptor fails calls to disabled streaming methods
+// with codes.Unimplemented.
+func MethodFilterStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := disabledMethodError(info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package frontend
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConfigureMethods(t *testing.T) {
+	defer ConfigureMethods(nil)
+	if err := ConfigureMethods(map[string]bool{"NoSuchMethod": false}); err == nil {
+		t.Fatal("ConfigureMethods should have failed for an unknown method")
+	}
+	if err := ConfigureMethods(map[string]bool{"getblock": true, "GetBlock": false}); err == nil {
+		t.Fatal("ConfigureMethods should have failed for conflicting settings")
+	}
+	// Viper lowercases the names in the config file.
+	if err := ConfigureMethods(map[string]bool{
+		"taddr":           false,
+		"getaddressutxos": true,
+		"GetARRRPrice":    false,
+		"GetBlock":        true,
+		"getblock":        true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for method, enabled := range map[string]bool{
+		"GetTaddressTxids":    false,
+		"GetTaddressBalance":  false,
+		"GetAddressUtxos":     true,
+		"GetARRRPrice":        false,
+		"GetCurrentARRRPrice": true,
+		"GetBlock":            true,
+	} {
+		if methodEnabled(method) != enabled {
+			t.Fatal("unexpected setting for", method)
+		}
+	}
+
+	// the interceptors
+	prefix := "/" + walletrpc.CompactTxStreamer_ServiceDesc.ServiceName + "/"
+	called := false
+	unaryHandler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	_, err := MethodFilterUnaryInterceptor(context.Background(), nil,
+		&grpc.UnaryServerInfo{FullMethod: prefix + "GetTaddressBalance"}, unaryHandler)
+	if status.Code(err) != codes.Unimplemented || called {
+		t.Fatal("disabled unary method should have failed", err)
+	}
+	for _, method := range []string{prefix + "GetBlock", "/pirate.wallet.sdk.rpc.DarksideStreamer/GetARRRPrice"} {
+		called = false
+		if _, err := MethodFilterUnaryInterceptor(context.Background(), nil,
+			&grpc.UnaryServerInfo{FullMethod: method}, unaryHandler); err != nil || !called {
+			t.Fatal("enabled unary method failed", method, err)
+		}
+	}
+	streamHandler := func(srv interface{}, stream grpc.ServerStream) error {
+		called = true
+		return nil
+	}
+	called = false
+	err = MethodFilterStreamInterceptor(nil, nil, &grpc.StreamServerInfo{FullMethod: prefix + "GetTaddressTxids"}, streamHandler)
+	if status.Code(err) != codes.Unimplemented || called {
+		t.Fatal("disabled streaming method should have failed", err)
+	}
+	if err := MethodFilterStreamInterceptor(nil, nil,
+		&grpc.StreamServerInfo{FullMethod: prefix + "GetBlockRange"}, streamHandler); err != nil || !called {
+		t.Fatal("enabled streaming method failed", err)
+	}
+
+	// the REST gateway, even without the interceptors
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	h := NewRESTHandler(lwd, nil, nil)
+	if w := restCall(h, "GET", "/api/v1/price?timestamp=1", ""); w.Code != http.StatusNotImplemented {
+		t.Fatal("disabled method should have failed over REST", w.Code, w.Body.String())
+	}
+	if w := restCall(h, "POST", "/api/v1/rpc/GetTaddressBalance", ""); w.Code != http.StatusNotImplemented {
+		t.Fatal("disabled method should have failed over REST", w.Code, w.Body.String())
+	}
+
+	// advertised methods
+	methods := make(map[string]bool)
+	for _, method := range enabledMethods(false) {
+		methods[method] = true
+	}
+	if methods["GetTaddressTxids"] || methods["GetARRRPrice"] || methods["Ping"] ||
+		!methods["GetAddressUtxos"] || !methods["GetBlock"] || !methods["GetLightdInfo"] {
+		t.Fatal("unexpected enabled methods", methods)
+	}
+	if len(enabledMethods(true)) != len(methods)+1 {
+		t.Fatal("Ping should be enabled")
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+
+package frontend
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// The REST gateway serves the CompactTxStreamer service as JSON over HTTP,
+// using the protobuf JSON mapping (so field names are lowerCamelCase,
+// 64-bit integers are strings, and bytes are base64). Every RPC can be
+// called at /api/v1/rpc/<method> by POSTing its request (an empty body is
+// an empty request); for client-streaming RPCs, the body is the requests,
+// one per line. The results of streaming RPCs are returned as
+// newline-delimited JSON, one {"result": ...} per line, ending with
+// {"error": ...} if the call fails partway, or {"trailers": ...} if it
+// succeeds and returns trailers (such as GetBlockRange's continuation, the
+// values of "-bin" trailers being base64). The most useful RPCs also have
+// GET paths (see restRoute). It's served on the HTTP server only if
+// --rest-enable is given.
+const restPrefix = "/api/v1/"
+
+const ndjsonContentType = "application/x-ndjson"
+
+var restMarshaler = jsonpb.Marshaler{}
+
+type restHandler struct {
+	service walletrpc.CompactTxStreamerServer
+	unary   grpc.UnaryServerInterceptor
+	stream  grpc.StreamServerInterceptor
+}
+
+// NewRESTHandler returns the REST gateway to the given service. The calls
+// go through the given interceptors (which may be nil), as gRPC calls do.
+func NewRESTHandler(service walletrpc.CompactTxStreamerServer, unary grpc.UnaryServerInterceptor, stream grpc.StreamServerInterceptor) http.Handler {
+	return &restHandler{service: service, unary: unary, stream: stream}
+}
+
+// restError is the body of an error response (or the last line of a
+// streaming response).
+type restError struct {
+	Code    codes.Code `json:"code"`
+	Message string     `json:"message"`
+}
+
+// restHTTPStatus returns the HTTP status for a gRPC status code.
+func restHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange, codes.FailedPrecondition:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func writeRESTError(w http.ResponseWriter, st *status.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(restHTTPStatus(st.Code()))
+	json.NewEncoder(w).Encode(&restError{Code: st.Code(), Message: st.Message()})
+}
+
+func (h *restHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, restPrefix)
+	var method string
+	var req proto.Message
+	if strings.HasPrefix(path, "rpc/") {
+		if r.Method != "POST" {
+			writeRESTError(w, status.New(codes.Unimplemented, "RPCs must be called with POST"))
+			return
+		}
+		method = strings.TrimPrefix(path, "rpc/")
+	} else {
+		if r.Method != "GET" {
+			writeRESTError(w, status.New(codes.Unimplemented, "method not allowed"))
+			return
+		}
+		var err error
+		method, req, err = restRoute(strings.Split(path, "/"), r.URL.Query())
+		if err != nil {
+			writeRESTError(w, status.Convert(err))
+			return
+		}
+	}
+
+	// (The interceptors may not include the method filter.)
+	if !methodEnabled(method) {
+		writeRESTError(w, status.New(codes.Unimplemented, method+" is disabled on this server"))
+		return
+	}
+
+	// Let the service see the client's address, as it would over gRPC.
+	ctx := r.Context()
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("x-real-ip", realIP))
+	}
+	stream := &restStream{
+		ctx: ctx,
+		w:   w,
+		req: req,
+	}
+	if req == nil {
+		stream.dec = json.NewDecoder(r.Body)
+	}
+
+	desc := &walletrpc.CompactTxStreamer_ServiceDesc
+	for i := range desc.Methods {
+		if desc.Methods[i].MethodName != method {
+			continue
+		}
+		reply, err := desc.Methods[i].Handler(h.service, ctx, stream.RecvMsg, h.unary)
+		if err != nil {
+			writeRESTError(w, status.Convert(err))
+			return
+		}
+		s, err := restMarshaler.MarshalToString(reply.(proto.Message))
+		if err != nil {
+			writeRESTError(w, status.Convert(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, s+"\n")
+		return
+	}
+	for i := range desc.Streams {
+		if desc.Streams[i].StreamName != method {
+			continue
+		}
+		stream.clientStreams = desc.Streams[i].ClientStreams
+		var err error
+		if h.stream != nil {
+			err = h.stream(h.service, stream, &grpc.StreamServerInfo{
+				FullMethod:     "/" + desc.ServiceName + "/" + method,
+				IsClientStream: desc.Streams[i].ClientStreams,
+				IsServerStream: desc.Streams[i].ServerStreams,
+			}, desc.Streams[i].Handler)
+		} else {
+			err = desc.Streams[i].Handler(h.service, stream)
+		}
+		if err != nil {
+			st := status.Convert(err)
+			if !stream.sent {
+				writeRESTError(w, st)
+				return
+			}
+			b, _ := json.Marshal(map[string]*restError{"error": {Code: st.Code(), Message: st.Message()}})
+			w.Write(append(b, '\n'))
+			return
+		}
+		if !stream.sent {
+			// No results; the response is still newline-delimited JSON.
+			w.Header().Set("Content-Type", ndjsonContentType)
+		}
+		if len(stream.trailer) > 0 {
+			trailers := make(map[string]string)
+			for k, vv := range stream.trailer {
+				for i, v := range vv {
+					if strings.HasSuffix(k, "-bin") {
+						vv[i] = base64.StdEncoding.EncodeToString([]byte(v))
+					}
+				}
+				trailers[k] = strings.Join(vv, ",")
+			}
+			b, _ := json.Marshal(map[string]map[string]string{"trailers": trailers})
+			w.Write(append(b, '\n'))
+		}
+		return
+	}
+	writeRESTError(w, status.New(codes.NotFound, "unknown method "+method))
+}
+
+// restRoute returns the RPC (and its request) for a GET path (below
+// /api/v1/, split at the slashes):
+//
+//	latest-block                   GetLatestBlock
+//	blocks?start=&end=             GetBlockRange (streaming)
+//	blocks/<height>                GetBlock
+//	transactions/<txid>            GetTransaction (txid in big-endian hex)
+//	tree-states/<height>|latest    GetTreeState, GetLatestTreeState
+//	lightd-info                    GetLightdInfo
+//	addresses/<taddr>/utxos        GetAddressUtxos (startHeight=, maxEntries=)
+//	price                          GetCurrentARRRPrice
+//	price?timestamp=&currency=     GetARRRPrice (currency defaults to USD)
+//	mempool                        GetMempoolTx (streaming)
+//	mempool/info                   GetMempoolInfo
+func restRoute(path []string, query map[string][]string) (string, proto.Message, error) {
+	param := func(name string) string {
+		if v := query[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	var err error
+	parseHeight := func(s, name string) uint64 {
+		height, parseErr := strconv.ParseUint(s, 10, 64)
+		if parseErr != nil && err == nil {
+			err = status.Error(codes.InvalidArgument, "invalid "+name+": "+s)
+		}
+		return height
+	}
+	var method string
+	var req proto.Message
+	switch {
+	case len(path) == 1 && path[0] == "latest-block":
+		method, req = "GetLatestBlock", &walletrpc.ChainSpec{}
+	case len(path) == 1 && path[0] == "blocks":
+		method, req = "GetBlockRange", &walletrpc.BlockRange{
+			Start: &walletrpc.BlockID{Height: parseHeight(param("start"), "start")},
+			End:   &walletrpc.BlockID{Height: parseHeight(param("end"), "end")},
+		}
+	case len(path) == 2 && path[0] == "blocks":
+		method, req = "GetBlock", &walletrpc.BlockID{Height: parseHeight(path[1], "height")}
+	case len(path) == 2 && path[0] == "transactions":
+		txid, hexErr := hex.DecodeString(path[1])
+		if hexErr != nil || len(txid) != 32 {
+			return "", nil, status.Error(codes.InvalidArgument, "invalid txid: "+path[1])
+		}
+		method, req = "GetTransaction", &walletrpc.TxFilter{Hash: parser.Reverse(txid)}
+	case len(path) == 2 && path[0] == "tree-states" && path[1] == "latest":
+		method, req = "GetLatestTreeState", &walletrpc.Empty{}
+	case len(path) == 2 && path[0] == "tree-states":
+		method, req = "GetTreeState", &walletrpc.BlockID{Height: parseHeight(path[1], "height")}
+	case len(path) == 1 && path[0] == "lightd-info":
+		method, req = "GetLightdInfo", &walletrpc.Empty{}
+	case len(path) == 3 && path[0] == "addresses" && path[2] == "utxos":
+		arg := &walletrpc.GetAddressUtxosArg{Addresses: []string{path[1]}}
+		if s := param("startHeight"); s != "" {
+			arg.StartHeight = parseHeight(s, "startHeight")
+		}
+		if s := param("maxEntries"); s != "" {
+			arg.MaxEntries = uint32(parseHeight(s, "maxEntries"))
+		}
+		method, req = "GetAddressUtxos", arg
+	case len(path) == 1 && path[0] == "price" && param("timestamp") == "":
+		method, req = "GetCurrentARRRPrice", &walletrpc.Empty{}
+	case len(path) == 1 && path[0] == "price":
+		currency := param("currency")
+		if currency == "" {
+			currency = "USD"
+		}
+		method, req = "GetARRRPrice", &walletrpc.PriceRequest{
+			Timestamp: parseHeight(param("timestamp"), "timestamp"),
+			Currency:  currency,
+		}
+	case len(path) == 1 && path[0] == "mempool":
+		method, req = "GetMempoolTx", &walletrpc.Exclude{}
+	case len(path) == 2 && path[0] == "mempool" && path[1] == "info":
+		method, req = "GetMempoolInfo", &walletrpc.Empty{}
+	default:
+		return "", nil, status.Error(codes.NotFound, "not found")
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return method, req, nil
+}
+
+// restStream is the grpc.ServerStream for a REST call. It reads the
+// requests from the body (or, for a GET path, returns the request made
+// from the path), and writes each result as a line of JSON.
+type restStream struct {
+	ctx           context.Context
+	w             http.ResponseWriter
+	req           proto.Message // the request, for GET paths
+	dec           *json.Decoder // the requests, for POSTs
+	clientStreams bool
+	received      bool
+	sent          bool
+	trailer       metadata.MD
+}
+
+func (s *restStream) RecvMsg(m interface{}) error {
+	if s.req != nil {
+		if s.received {
+			return io.EOF
+		}
+		s.received = true
+		proto.Merge(m.(proto.Message), s.req)
+		return nil
+	}
+	if !s.dec.More() {
+		if !s.clientStreams && !s.received {
+			// An empty body is an empty request.
+			s.received = true
+			return nil
+		}
+		return io.EOF
+	}
+	s.received = true
+	if err := jsonpb.UnmarshalNext(s.dec, m.(proto.Message)); err != nil {
+		return status.Error(codes.InvalidArgument, "invalid request: "+err.Error())
+	}
+	return nil
+}
+
+func (s *restStream) SendMsg(m interface{}) error {
+	result, err := restMarshaler.MarshalToString(m.(proto.Message))
+	if err != nil {
+		return err
+	}
+	if !s.sent {
+		s.sent = true
+		s.w.Header().Set("Content-Type", ndjsonContentType)
+	}
+	if _, err := io.WriteString(s.w, `{"result":`+result+"}\n"); err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+func (s *restStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *restStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *restStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *restStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+var _ grpc.ServerStream = (*restStream)(nil)
@@ -0,0 +1,203 @@
+// Copyright (c) 2019-2021 Pirate Chain developers
+// Distributed under the MIT software license, see the accompanying
+// file COPYING or https://www.opensource.org/licenses/mit-license.php .
+package frontend
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/PirateNetwork/lightwalletd/common"
+	"github.com/PirateNetwork/lightwalletd/parser"
+	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+)
+
+func restCall(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// restLines returns the results of a streaming call, and the error (if any)
+// on the last line.
+func restLines(t *testing.T, w *httptest.ResponseRecorder) ([]json.RawMessage, *restError) {
+	if w.Code != 200 || w.Header().Get("Content-Type") != ndjsonContentType {
+		t.Fatal("unexpected streaming response", w.Code, w.Header(), w.Body.String())
+	}
+	var results []json.RawMessage
+	for _, line := range strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		var v struct {
+			Result json.RawMessage `json:"result"`
+			Error  *restError      `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatal("bad line", line, err)
+		}
+		if v.Error != nil {
+			return results, v.Error
+		}
+		results = append(results, v.Result)
+	}
+	return results, nil
+}
+
+func TestREST(t *testing.T) {
+	testT = t
+	lwd, cache := testsetup()
+	defer os.RemoveAll(unitTestPath)
+	defer cache.Close()
+	var compacts []*walletrpc.CompactBlock
+	for i := 0; i < 3; i++ {
+		var blockHex string
+		json.Unmarshal(blocks[i], &blockHex)
+		blockData, _ := hex.DecodeString(blockHex)
+		block := parser.NewBlock()
+		if _, err := block.ParseFromSlice(blockData); err != nil {
+			t.Fatal(err)
+		}
+		compacts = append(compacts, block.ToCompact())
+		if err := cache.Add(380640+i, compacts[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	common.RawRequest = func(method string, params []json.RawMessage) (json.RawMessage, error) {
+		if method != "getblock" {
+			testT.Fatal("unexpected call", method)
+		}
+		return nil, errors.New("-8: Block height out of range")
+	}
+	// The calls go through the interceptors.
+	var called []string
+	h := NewRESTHandler(lwd,
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			called = append(called, info.FullMethod)
+			return handler(ctx, req)
+		},
+		func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			called = append(called, info.FullMethod)
+			return handler(srv, ss)
+		})
+	const prefix = "/pirate.wallet.sdk.rpc.CompactTxStreamer/"
+
+	// unary, by GET path and by RPC name
+	w := restCall(h, "GET", "/api/v1/latest-block", "")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatal("unexpected latest-block response", w.Code, w.Body.String())
+	}
+	var id walletrpc.BlockID
+	if err := jsonpb.UnmarshalString(w.Body.String(), &id); err != nil {
+		t.Fatal(err)
+	}
+	if id.Height != 380642 || string(id.Hash) != string(compacts[2].Hash) {
+		t.Fatal("unexpected latest block", w.Body.String())
+	}
+	if len(called) != 1 || called[0] != prefix+"GetLatestBlock" {
+		t.Fatal("unary call not intercepted", called)
+	}
+	// protobuf JSON mapping: 64-bit integers are strings
+	if !strings.Contains(w.Body.String(), `"height":"380642"`) {
+		t.Fatal("unexpected JSON", w.Body.String())
+	}
+	for _, w := range []*httptest.ResponseRecorder{
+		restCall(h, "GET", "/api/v1/blocks/380641", ""),
+		restCall(h, "POST", "/api/v1/rpc/GetBlock", `{"height": 380641}`),
+	} {
+		var block walletrpc.CompactBlock
+		if w.Code != 200 {
+			t.Fatal("GetBlock failed", w.Code, w.Body.String())
+		}
+		if err := jsonpb.UnmarshalString(w.Body.String(), &block); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(&block, compacts[1]) {
+			t.Fatal("unexpected block")
+		}
+	}
+
+	// streaming
+	for _, w := range []*httptest.ResponseRecorder{
+		restCall(h, "GET", "/api/v1/blocks?start=380640&end=380642", ""),
+		restCall(h, "POST", "/api/v1/rpc/GetBlockRange", `{"start": {"height": 380640}, "end": {"height": "380642"}}`),
+	} {
+		results, restErr := restLines(t, w)
+		if restErr != nil || len(results) != 3 {
+			t.Fatal("GetBlockRange failed", restErr, len(results))
+		}
+		for i, result := range results {
+			var block walletrpc.CompactBlock
+			if err := jsonpb.UnmarshalString(string(result), &block); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(&block, compacts[i]) {
+				t.Fatal("unexpected block", i)
+			}
+		}
+	}
+	if called[len(called)-1] != prefix+"GetBlockRange" {
+		t.Fatal("streaming call not intercepted", called)
+	}
+	// trailers (here, the continuation of a range that's cut short) are the
+	// last line
+	BlockRangeMaxBlocks = 2
+	w = restCall(h, "GET", "/api/v1/blocks?start=380640&end=380642", "")
+	BlockRangeMaxBlocks = 0
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	var trailers struct {
+		Trailers map[string]string `json:"trailers"`
+	}
+	if len(lines) != 3 || json.Unmarshal([]byte(lines[2]), &trailers) != nil {
+		t.Fatal("unexpected GetBlockRange response", w.Body.String())
+	}
+	b, _ := base64.StdEncoding.DecodeString(trailers.Trailers[continuationTrailer])
+	var c walletrpc.BlockRangeContinuation
+	if err := proto.Unmarshal(b, &c); err != nil || c.Height != 380642 {
+		t.Fatal("unexpected continuation", trailers, err)
+	}
+
+	// an error partway through a stream is the last line
+	results, restErr := restLines(t, restCall(h, "GET", "/api/v1/blocks?start=380641&end=380645", ""))
+	if len(results) != 2 || restErr == nil || !strings.Contains(restErr.Message, "newer than latest") {
+		t.Fatal("unexpected GetBlockRange result", len(results), restErr)
+	}
+
+	// errors
+	for _, test := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{"GET", "/api/v1/blocks/abc", "", http.StatusBadRequest},
+		{"GET", "/api/v1/blocks?start=380640", "", http.StatusBadRequest},
+		{"GET", "/api/v1/transactions/1234", "", http.StatusBadRequest},
+		{"GET", "/api/v1/no-such-path", "", http.StatusNotFound},
+		{"POST", "/api/v1/blocks/380640", "", http.StatusNotImplemented},
+		{"GET", "/api/v1/rpc/GetBlock", "", http.StatusNotImplemented},
+		{"POST", "/api/v1/rpc/NoSuchMethod", "", http.StatusNotFound},
+		{"POST", "/api/v1/rpc/GetBlock", `{"height": "x"}`, http.StatusBadRequest},
+		{"POST", "/api/v1/rpc/GetBlock", `{"noSuchField": 1}`, http.StatusBadRequest},
+		// the service's own errors
+		{"POST", "/api/v1/rpc/GetBlock", "", http.StatusInternalServerError},
+		{"POST", "/api/v1/rpc/GetBlockRange", `{"start": {"height": 380643}}`, http.StatusInternalServerError},
+	} {
+		w := restCall(h, test.method, test.path, test.body)
+		var restErr restError
+		if w.Code != test.code || w.Header().Get("Content-Type") != "application/json" ||
+			json.Unmarshal(w.Body.Bytes(), &restErr) != nil || restErr.Message == "" {
+			t.Fatal("unexpected response for", test.method, test.path, test.body, w.Code, w.Body.String())
+		}
+	}
+}
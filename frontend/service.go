@@ -7,6 +7,7 @@
 package frontend
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"encoding/json"
@@ -25,9 +26,13 @@ import (
 	"github.com/PirateNetwork/lightwalletd/common"
 	"github.com/PirateNetwork/lightwalletd/parser"
 	"github.com/PirateNetwork/lightwalletd/walletrpc"
+	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 type latencyCacheEntry struct {
@@ -36,18 +41,55 @@ type latencyCacheEntry struct {
 	totalBlocks uint64
 }
 
+// BlockRangeMaxBlocks is the most blocks GetBlockRange, GetBlockHeaders and
+// SyncBlocks return per call (zero means no limit); longer ranges are cut short, with
+// a continuation. It's set from the command line.
+var BlockRangeMaxBlocks = 0
+
+// DonationAddress and OperatorContact are the operator's details, and
+// Features are the optional features (other than the ping RPC) that are
+// enabled, as reported by GetLightdInfo; they're set from the command line.
+var (
+	DonationAddress string
+	OperatorContact string
+	Features        []string
+)
+
+// continuationTrailer is the trailer in which GetBlockRange,
+// GetBlockHeaders, GetTaddressTxids and SyncBlocks return the
+// BlockRangeContinuation when they cut a range short.
+const continuationTrailer = "continuation-bin"
+
+// setContinuation sets the continuation trailer, to resume at the given
+// height, after the block with the given hash.
+func setContinuation(stream grpc.ServerStream, height uint64, prevHash []byte) error {
+	b, err := proto.Marshal(&walletrpc.BlockRangeContinuation{Height: height, PrevHash: prevHash})
+	if err != nil {
+		return err
+	}
+	stream.SetTrailer(metadata.Pairs(continuationTrailer, string(b)))
+	return nil
+}
+
 type lwdStreamer struct {
 	cache      *common.BlockCache
 	chainName  string
 	pingEnable bool
+	// taddrTxidsWorkers is the number of transactions GetTaddressTxids
+	// fetches from pirated at once, and taddrTxidsMax is about the most
+	// transactions it returns per call (zero means no limit).
+	taddrTxidsWorkers int
+	taddrTxidsMax     int
 	walletrpc.UnimplementedCompactTxStreamerServer
 	latencyCache map[string]*latencyCacheEntry
 	latencyMutex sync.RWMutex
 }
 
 // NewLwdStreamer constructs a gRPC context.
-func NewLwdStreamer(cache *common.BlockCache, chainName string, enablePing bool) (walletrpc.CompactTxStreamerServer, error) {
-	return &lwdStreamer{cache: cache, chainName: chainName, pingEnable: enablePing, latencyCache: make(map[string]*latencyCacheEntry), latencyMutex: sync.RWMutex{}}, nil
+func NewLwdStreamer(cache *common.BlockCache, chainName string, enablePing bool, taddrTxidsWorkers, taddrTxidsMax int) (walletrpc.CompactTxStreamerServer, error) {
+	return &lwdStreamer{cache: cache, chainName: chainName, pingEnable: enablePing,
+		taddrTxidsWorkers: taddrTxidsWorkers, taddrTxidsMax: taddrTxidsMax,
+		latencyCache: make(map[string]*latencyCacheEntry), latencyMutex: sync.RWMutex{}}, nil
 }
 
 // DarksideStreamer holds the gRPC state for darksidewalletd.
@@ -142,10 +184,35 @@ func (s *lwdStreamer) GetLiteWalletBlockGroup(ctx context.Context, id *walletrpc
 			return nil, errors.New("Invalid block, must use height greater than 0")
 	}
 
-  blockId := s.cache.GetLiteWalletBlockGroup(int(id.Height))
+	blockId := s.cache.GetLiteWalletBlockGroup(int(id.Height))
+	if blockId == nil {
+		return nil, errors.New("Block group not available")
+	}
 	return blockId, nil
 }
 
+// GetBlockGroups returns the groups (of about the requested size) that the
+// given range of cached blocks divides into, so that a wallet can plan
+// parallel GetBlockRange calls.
+func (s *lwdStreamer) GetBlockGroups(ctx context.Context, arg *walletrpc.GetBlockGroupsArg) (*walletrpc.BlockGroupList, error) {
+	latestBlock := s.cache.GetLatestHeight()
+	if latestBlock == -1 {
+		return nil, errors.New("Cache is empty. Server is probably not yet ready")
+	}
+	if arg.Range == nil || arg.Range.Start == nil {
+		return nil, errors.New("Must specify a start height")
+	}
+	start, end := int(arg.Range.Start.Height), latestBlock
+	if arg.Range.End != nil && arg.Range.End.Height != 0 {
+		end = int(arg.Range.End.Height)
+	}
+	groups, err := s.cache.GetBlockGroups(start, end, int(arg.TargetSize))
+	if err != nil {
+		return nil, err
+	}
+	return &walletrpc.BlockGroupList{Groups: groups}, nil
+}
+
 // GetLatestBlock returns the height of the best chain, according to zcashd.
 func (s *lwdStreamer) GetLatestBlock(ctx context.Context, placeholder *walletrpc.ChainSpec) (*walletrpc.BlockID, error) {
 	latestBlock := s.cache.GetLatestHeight()
@@ -158,10 +225,24 @@ func (s *lwdStreamer) GetLatestBlock(ctx context.Context, placeholder *walletrpc
 	return &walletrpc.BlockID{Height: uint64(latestBlock), Hash: latestHash}, nil
 }
 
+// checkTaddress returns an error if the given t-address is not valid
+// (34 alphanumeric characters, starting with "R").
+func checkTaddress(taddr string) error {
+	match, err := regexp.Match("\\AR[a-zA-Z0-9]{33}\\z", []byte(taddr))
+	if err != nil || !match {
+		return errors.New("Invalid address")
+	}
+	return nil
+}
+
 // GetTaddressTxids is a streaming RPC that returns transaction IDs that have
-// the given transparent address (taddr) as either an input or output.
+// the given transparent address (taddr) as either an input or output. If
+// there are more than the limit, it returns the transactions of the first
+// blocks (it never splits a block's transactions), and a continuation.
 func (s *lwdStreamer) GetTaddressTxids(addressBlockFilter *walletrpc.TransparentAddressBlockFilter, resp walletrpc.CompactTxStreamer_GetTaddressTxidsServer) error {
-
+	if err := checkTaddress(addressBlockFilter.Address); err != nil {
+		return err
+	}
 	if addressBlockFilter.Range == nil {
 		return errors.New("Must specify block range")
 	}
@@ -171,11 +252,18 @@ func (s *lwdStreamer) GetTaddressTxids(addressBlockFilter *walletrpc.Transparent
 	if addressBlockFilter.Range.End == nil {
 		return errors.New("Must specify an end block height")
 	}
+	start, end := addressBlockFilter.Range.Start.Height, addressBlockFilter.Range.End.Height
+	if resume := addressBlockFilter.Range.Resume; resume != nil {
+		if err := s.checkContinuation(resume, start, end, resume.Height-1); err != nil {
+			return err
+		}
+		start = resume.Height
+	}
 	params := make([]json.RawMessage, 1)
 	request := &common.PiratedRpcRequestGetaddresstxids{
 		Addresses: []string{addressBlockFilter.Address},
-		Start:     addressBlockFilter.Range.Start.Height,
-		End:       addressBlockFilter.Range.End.Height,
+		Start:     start,
+		End:       end,
 	}
 	param, err := json.Marshal(request)
 	if err != nil {
@@ -194,43 +282,110 @@ func (s *lwdStreamer) GetTaddressTxids(addressBlockFilter *walletrpc.Transparent
 	if err != nil {
 		return err
 	}
+	limit := 0
+	if s.taddrTxidsMax > 0 && len(txids) > s.taddrTxidsMax {
+		limit = s.taddrTxidsMax
+	}
+	last, err := s.sendTransactions(resp.Context(), txids, limit, resp.Send)
+	if err != nil || last == nil {
+		return err
+	}
+	// The transactions were cut short after the block at last.Height.
+	block, err := common.GetBlock(s.cache, int(last.Height))
+	if err != nil {
+		return err
+	}
+	return setContinuation(resp, last.Height+1, block.Hash)
+}
 
-	timeout, cancel := context.WithTimeout(resp.Context(), 30*time.Second)
+// sendTransactions fetches the given transactions (big-endian hex txids)
+// from pirated, up to taddrTxidsWorkers at a time, and sends them in the
+// given order (pirated returns address txids in height order). Fetches run
+// at most taddrTxidsWorkers transactions ahead of the last one sent, so
+// a slow client doesn't cause unbounded buffering. It stops early if the
+// client cancels. If limit is nonzero, it stops at the first block boundary
+// after that many transactions, and returns the last transaction it sent.
+func (s *lwdStreamer) sendTransactions(ctx context.Context, txids []string, limit int, send func(*walletrpc.RawTransaction) error) (*walletrpc.RawTransaction, error) {
+	type fetched struct {
+		tx  *walletrpc.RawTransaction
+		err error
+	}
+	concurrency := s.taddrTxidsWorkers
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	var prevHeight = 0
-	txCache := make([]*walletrpc.RawTransaction, 0)
-	for _, txidstr := range txids {
-		txid, _ := hex.DecodeString(txidstr)
-		// Txid is read as a string, which is in big-endian order. But when converting
-		// to bytes, it should be little-endian
-		tx, err := s.GetTransaction(timeout, &walletrpc.TxFilter{Hash: parser.Reverse(txid)})
-		if err != nil {
-			return err
-		}
-
-		if tx.Height > uint64(prevHeight) {
-			for _, txi := range txCache {
-				if err = resp.Send(txi); err != nil {
-					return err
+	// Each transaction's result has its own slot, so the workers never block.
+	results := make([]chan fetched, len(txids))
+	for i := range results {
+		results[i] = make(chan fetched, 1)
+	}
+	jobs := make(chan int)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				txid, err := hex.DecodeString(txids[i])
+				if err != nil {
+					results[i] <- fetched{err: err}
+					continue
 				}
+				// Txid is read as a string, which is in big-endian order. But when converting
+				// to bytes, it should be little-endian
+				tx, err := s.GetTransaction(ctx, &walletrpc.TxFilter{Hash: parser.Reverse(txid)})
+				results[i] <- fetched{tx: tx, err: err}
+			}
+		}()
+	}
+	// window limits how far the fetches get ahead of the sends.
+	window := make(chan struct{}, concurrency)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range txids {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
 			}
-			txCache = make([]*walletrpc.RawTransaction, 0)
-			prevHeight = int(tx.Height)
 		}
+	}()
 
-		if tx.Height == uint64(prevHeight) {
-			txCache = append(txCache, tx)
+	var last *walletrpc.RawTransaction
+	for i := range txids {
+		var r fetched
+		select {
+		case r = <-results[i]:
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-
-	}
-
-	for _, txi := range txCache {
-		if err = resp.Send(txi); err != nil {
-			return err
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
+		if limit > 0 && i >= limit && r.tx.Height != last.Height {
+			return last, nil
+		}
+		if err := send(r.tx); err != nil {
+			return nil, err
+		}
+		last = r.tx
+		<-window
 	}
-	return nil
+	return nil, nil
 }
 
 // GetBlock returns the compact block at the requested height. Requesting a
@@ -259,11 +414,13 @@ func (s *lwdStreamer) GetBlock(ctx context.Context, id *walletrpc.BlockID) (*wal
 // (as also returned by GetBlock) from the block height 'start' to height
 // 'end' inclusively.
 func (s *lwdStreamer) GetBlockRange(span *walletrpc.BlockRange, resp walletrpc.CompactTxStreamer_GetBlockRangeServer) error {
-	blockChan := make(chan *walletrpc.CompactBlock)
-	errChan := make(chan error)
 	if span.Start == nil || span.End == nil {
 		return errors.New("Must specify start and end heights")
 	}
+	start, end, cut, err := s.blockRangeBounds(span)
+	if err != nil {
+		return err
+	}
 
 	peerip := s.peerIPFromContext(resp.Context())
 
@@ -275,7 +432,7 @@ func (s *lwdStreamer) GetBlockRange(span *walletrpc.BlockRange, resp walletrpc.C
 		}
 
 		// Log only if bulk requesting blocks
-		if span.End.Height-span.Start.Height < 100 {
+		if end-start < 100 {
 			return
 		}
 
@@ -293,7 +450,7 @@ func (s *lwdStreamer) GetBlockRange(span *walletrpc.BlockRange, resp walletrpc.C
 		// Look up if this ip address has a previous getblock range
 		if entry, ok := s.latencyCache[peerip]; ok {
 			// Log only continous blocks
-			if entry.lastBlock+1 == span.Start.Height {
+			if entry.lastBlock+1 == start {
 				common.Log.WithFields(logrus.Fields{
 					"method":         "GetBlockRangeLatency",
 					"peer_addr":      peerip,
@@ -306,8 +463,8 @@ func (s *lwdStreamer) GetBlockRange(span *walletrpc.BlockRange, resp walletrpc.C
 
 		// Add or update the ip entry
 		s.latencyCache[peerip] = &latencyCacheEntry{
-			lastBlock:   span.End.Height,
-			totalBlocks: span.End.Height - span.Start.Height + 1,
+			lastBlock:   end,
+			totalBlocks: end - start + 1,
 			timeNanos:   now,
 		}
 	}()
@@ -315,32 +472,105 @@ func (s *lwdStreamer) GetBlockRange(span *walletrpc.BlockRange, resp walletrpc.C
 	// Logging and metrics
 	go func() {
 		// Log a daily active user if the user requests the day's "key block"
-		for height := span.Start.Height; height <= span.End.Height; height++ {
+		for height := start; height <= end; height++ {
 			s.dailyActiveBlock(height, peerip)
 		}
 
 		common.Log.WithFields(logrus.Fields{
 			"method":    "GetBlockRange",
-			"start":     span.Start.Height,
-			"end":       span.End.Height,
+			"start":     start,
+			"end":       end,
 			"peer_addr": peerip,
 		}).Info("Service")
-		common.Metrics.TotalBlocksServedConter.Add(math.Abs(float64(span.Start.Height) - float64(span.End.Height)))
+		common.Metrics.TotalBlocksServedConter.Add(math.Abs(float64(start) - float64(end)))
 	}()
 
-	go common.GetBlockRange(s.cache, blockChan, errChan, int(span.Start.Height), int(span.End.Height))
+	// Stop fetching blocks if the client goes away (or if Send fails).
+	ctx, cancel := context.WithCancel(resp.Context())
+	defer cancel()
+	blockChan, errChan := common.GetBlockRange(ctx, s.cache, int(start), int(end))
+	var last *walletrpc.CompactBlock
+	for cBlock := range blockChan {
+		if err := resp.Send(cBlock); err != nil {
+			return err
+		}
+		last = cBlock
+	}
+	if err := <-errChan; err != nil {
+		return err
+	}
+	if cut {
+		next := end + 1
+		if start > end {
+			next = end - 1
+		}
+		return setContinuation(resp, next, last.Hash)
+	}
+	return nil
+}
+
+// blockRangeBounds returns the part of the requested range to return in this
+// call (which may have been resumed, and may be cut short by the limit).
+func (s *lwdStreamer) blockRangeBounds(span *walletrpc.BlockRange) (start, end uint64, cut bool, err error) {
+	start, end = span.Start.Height, span.End.Height
+	reverse := start > end
+	if resume := span.Resume; resume != nil {
+		low, high := start, end
+		if reverse {
+			low, high = end, start
+		}
+		prev := resume.Height - 1
+		if reverse {
+			prev = resume.Height + 1
+		}
+		if err := s.checkContinuation(resume, low, high, prev); err != nil {
+			return 0, 0, false, err
+		}
+		start = resume.Height
+	}
+	if max := uint64(BlockRangeMaxBlocks); max > 0 {
+		if !reverse && end-start >= max {
+			end, cut = start+max-1, true
+		}
+		if reverse && start-end >= max {
+			end, cut = start-max+1, true
+		}
+	}
+	return start, end, cut, nil
+}
 
-	for {
-		select {
-		case err := <-errChan:
+// checkContinuation returns an error if the continuation isn't within the
+// range from low to high, or if its previous block, at height prev, is no
+// longer in the best chain.
+func (s *lwdStreamer) checkContinuation(resume *walletrpc.BlockRangeContinuation, low, high, prev uint64) error {
+	if resume.Height < low || resume.Height > high || prev < low || prev > high {
+		return errors.New("continuation is not within the block range")
+	}
+	block, err := common.GetBlock(s.cache, int(prev))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(block.Hash, resume.PrevHash) {
+		return status.Errorf(codes.Aborted,
+			"reorg: block %d is no longer in the best chain, restart the range from an earlier height", prev)
+	}
+	return nil
+}
+
+// GetSubtreeRoots is a streaming RPC that returns the roots of the complete
+// note commitment subtrees (each of 2^16 leaves) of the given shielded pool,
+// starting at the given subtree index.
+func (s *lwdStreamer) GetSubtreeRoots(arg *walletrpc.GetSubtreeRootsArg, resp walletrpc.CompactTxStreamer_GetSubtreeRootsServer) error {
+	roots, err := common.GetSubtreeRoots(s.cache, arg.ShieldedProtocol, int(arg.StartIndex), int(arg.MaxEntries))
+	if err != nil {
+		return err
+	}
+	for _, root := range roots {
+		if err := resp.Send(root); err != nil {
 			return err
-		case cBlock := <-blockChan:
-			err := resp.Send(cBlock)
-			if err != nil {
-				return err
-			}
 		}
 	}
+	return nil
 }
 
 // GetTreeState returns the note commitment tree state corresponding to the given block.
@@ -348,64 +578,209 @@ func (s *lwdStreamer) GetBlockRange(span *walletrpc.BlockRange, resp walletrpc.C
 // values also (even though they can be obtained using GetBlock).
 // The block can be specified by either height or hash.
 func (s *lwdStreamer) GetTreeState(ctx context.Context, id *walletrpc.BlockID) (*walletrpc.TreeState, error) {
-	if id.Height == 0 && id.Hash == nil {
-		return nil, errors.New("request for unspecified identifier")
+	treeState, err := common.GetTreeState(s.cache, int(id.Height), id.Hash)
+	if err != nil {
+		return nil, err
 	}
-	// The Zcash z_gettreestate rpc accepts either a block height or block hash
-	params := make([]json.RawMessage, 1)
-	var hashJSON []byte
-	if id.Height > 0 {
-		heightJSON, err := json.Marshal(strconv.Itoa(int(id.Height)))
-		if err != nil {
-			return nil, err
+	// The cached tree state is shared, so return a copy.
+	reply := *treeState
+	reply.Network = s.chainName
+	return &reply, nil
+}
+
+// GetLatestTreeState returns the note commitment tree state of the latest
+// block in the cache.
+func (s *lwdStreamer) GetLatestTreeState(ctx context.Context, in *walletrpc.Empty) (*walletrpc.TreeState, error) {
+	treeState, err := common.GetLatestTreeState(s.cache)
+	if err != nil {
+		return nil, err
+	}
+	reply := *treeState
+	reply.Network = s.chainName
+	return &reply, nil
+}
+
+// GetBlockHeaders is a streaming RPC that returns the full serialized
+// headers of the blocks from height 'start' to height 'end' inclusively
+// (in descending order if start is greater than end), which must not be
+// beyond the latest cached block. Like GetBlockRange, it returns at most
+// BlockRangeMaxBlocks headers, then a continuation.
+func (s *lwdStreamer) GetBlockHeaders(span *walletrpc.BlockRange, resp walletrpc.CompactTxStreamer_GetBlockHeadersServer) error {
+	if span.Start == nil || span.End == nil {
+		return errors.New("Must specify start and end heights")
+	}
+	first, last, cut, err := s.blockRangeBounds(span)
+	if err != nil {
+		return err
+	}
+	start, end := int(first), int(last)
+	high := start
+	if end > high {
+		high = end
+	}
+	// Headers above the cache's tip would each cost pirated RPCs, and may
+	// not be in the best chain.
+	if latest := s.cache.GetLatestHeight(); high > latest {
+		return status.Errorf(codes.OutOfRange, "block %d is beyond the latest block %d", high, latest)
+	}
+	step := 1
+	if start > end {
+		step = -1
+	}
+	for height := start; ; height += step {
+		if err := resp.Context().Err(); err != nil {
+			return err
 		}
-		params[0] = heightJSON
-	} else {
-		// id.Hash is big-endian, keep in big-endian for the rpc
-		hashJSON, err := json.Marshal(hex.EncodeToString(id.Hash))
+		header, err := common.GetBlockHeader(s.cache, height)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := resp.Send(header); err != nil {
+			return err
+		}
+		if height == end {
+			if cut {
+				return setContinuation(resp, uint64(end+step), header.Hash)
+			}
+			return nil
 		}
-		params[0] = hashJSON
 	}
-	var gettreestateReply common.PiratedRpcReplyGettreestate
+}
+
+// SubscribeBlocks streams the cached blocks starting at the given height,
+// then each new block as BlockIngestor adds it to the cache. If a reorg
+// removes blocks that have already been sent, a rollback to the fork height
+// is sent, followed by the replacement blocks.
+func (s *lwdStreamer) SubscribeBlocks(id *walletrpc.BlockID, resp walletrpc.CompactTxStreamer_SubscribeBlocksServer) error {
+	next := int(id.Height)
+	if next < s.cache.GetFirstHeight() {
+		return errors.New("start height is below the first cached block")
+	}
+	if next > s.cache.GetNextHeight() {
+		return errors.New("start height is beyond the next block")
+	}
+	// Subscribe before reading the cache so that no change is missed.
+	sub := s.cache.Subscribe()
+	defer sub.Close()
+	prevHash := s.cache.Get(next - 1).GetHash() // hash of the block before next, if known
 	for {
-		result, rpcErr := common.RawRequest("z_gettreestate", params)
-		if rpcErr != nil {
-			return nil, rpcErr
+		// Check for a rollback before sending each block, so that no block
+		// from a new branch is sent before the rollback to it.
+		if removed := sub.Rollback(); removed >= 0 && removed < next {
+			if removed < s.cache.GetFirstHeight() {
+				return status.Error(codes.Aborted, "the block cache was reset")
+			}
+			next = removed
+			prevHash = s.cache.Get(next - 1).GetHash()
+			err := resp.Send(&walletrpc.BlockSubscriptionEvent{
+				Rollback: &walletrpc.BlockRollback{Height: uint64(next - 1)},
+			})
+			if err != nil {
+				return err
+			}
 		}
-		err := json.Unmarshal(result, &gettreestateReply)
-		if err != nil {
-			return nil, err
+		// A block that doesn't follow the previous one is from a reorg that
+		// happened after the check above; its rollback is pending.
+		block := s.cache.Get(next)
+		if block != nil && (prevHash == nil || bytes.Equal(block.PrevHash, prevHash)) {
+			if err := resp.Send(&walletrpc.BlockSubscriptionEvent{Block: block}); err != nil {
+				return err
+			}
+			prevHash = block.Hash
+			next++
+			continue
 		}
-		if gettreestateReply.Sapling.Commitments.FinalState != "" {
-			break
+		select {
+		case <-resp.Context().Done():
+			return resp.Context().Err()
+		case <-sub.Changed():
 		}
-		if gettreestateReply.Sapling.SkipHash == "" {
-			break
+	}
+}
+
+// maxLocatorBlocks is the most blocks a SyncBlocks locator may list.
+const maxLocatorBlocks = 100
+
+// SyncBlocks returns, for a wallet that has the locator's blocks, a
+// rollback to the fork point (if any of its blocks are no longer in the
+// best chain), then the blocks from there to the tip. It returns at most
+// BlockRangeMaxBlocks blocks, then a continuation (whose previous block is
+// the locator for the next call).
+func (s *lwdStreamer) SyncBlocks(locator *walletrpc.BlockLocator, resp walletrpc.CompactTxStreamer_SyncBlocksServer) error {
+	if len(locator.Blocks) == 0 {
+		return status.Error(codes.InvalidArgument, "the locator has no blocks")
+	}
+	if len(locator.Blocks) > maxLocatorBlocks {
+		return status.Errorf(codes.InvalidArgument, "the locator has more than %d blocks", maxLocatorBlocks)
+	}
+	tip := 0
+	for _, id := range locator.Blocks {
+		if int(id.Height) > tip {
+			tip = int(id.Height)
 		}
-		hashJSON, err = json.Marshal(gettreestateReply.Sapling.SkipHash)
+	}
+	// Subscribe before reading the cache so that no reorg is missed.
+	sub := s.cache.Subscribe()
+	defer sub.Close()
+	if latest := s.cache.GetLatestHeight(); tip > latest {
+		// The wallet's blocks above our tip may or may not be valid.
+		return status.Errorf(codes.Unavailable, "the locator's highest block %d is beyond the latest block %d", tip, latest)
+	}
+	fork := s.cache.FindForkPoint(locator.Blocks)
+	if fork < 0 {
+		return status.Error(codes.NotFound, "none of the locator's blocks is in the best chain")
+	}
+	if fork < tip {
+		err := resp.Send(&walletrpc.BlockSubscriptionEvent{
+			Rollback: &walletrpc.BlockRollback{Height: uint64(fork)},
+		})
 		if err != nil {
-			return nil, err
+			return err
 		}
-		params[0] = hashJSON
 	}
-	if gettreestateReply.Sapling.Commitments.FinalState == "" {
-		return nil, errors.New("pirated did not return treestate")
+	next := fork + 1
+	for sent := 0; ; sent++ {
+		if removed := sub.Rollback(); removed >= 0 && removed < next {
+			next = removed
+			err := resp.Send(&walletrpc.BlockSubscriptionEvent{
+				Rollback: &walletrpc.BlockRollback{Height: uint64(next - 1)},
+			})
+			if err != nil {
+				return err
+			}
+		}
+		block := s.cache.Get(next)
+		if block == nil {
+			return nil
+		}
+		if BlockRangeMaxBlocks > 0 && sent == BlockRangeMaxBlocks {
+			// (After a rollback, the block before next isn't the last
+			// block sent.)
+			return setContinuation(resp, uint64(next), block.PrevHash)
+		}
+		if err := resp.Send(&walletrpc.BlockSubscriptionEvent{Block: block}); err != nil {
+			return err
+		}
+		next++
 	}
-	return &walletrpc.TreeState{
-		Network:     s.chainName,
-		Height:      uint64(gettreestateReply.Height),
-		Hash:        gettreestateReply.Hash,
-		Time:        gettreestateReply.Time,
-		SaplingTree: gettreestateReply.Sapling.Commitments.FinalState,
-		OrchardTree: gettreestateReply.Orchard.Commitments.FinalState,
-	}, nil
 }
 
 // GetTransaction returns the raw transaction bytes that are returned
-// by the pirated 'getrawtransaction' RPC.
+// by the pirated 'getrawtransaction' RPC. The transaction may be specified
+// either by txid (hash) or by block (height and/or hash) and index within
+// the full block, which is the same index as CompactTx.Index.
 func (s *lwdStreamer) GetTransaction(ctx context.Context, txf *walletrpc.TxFilter) (*walletrpc.RawTransaction, error) {
+	if txf.Hash == nil && txf.Block != nil {
+		var blockHash []byte
+		if len(txf.Block.Hash) > 0 {
+			blockHash = txf.Block.Hash
+		}
+		txid, err := common.GetBlockTxid(s.cache, int(txf.Block.Height), blockHash, int(txf.Index))
+		if err != nil {
+			return nil, err
+		}
+		txf = &walletrpc.TxFilter{Hash: txid}
+	}
 	if txf.Hash != nil {
 		if len(txf.Hash) != 32 {
 			return nil, errors.New("Transaction ID has invalid length")
@@ -424,7 +799,7 @@ func (s *lwdStreamer) GetTransaction(ctx context.Context, txf *walletrpc.TxFilte
 		if rpcErr != nil {
 			return nil, rpcErr
 		}
-		// Many other fields are returned, but we need only these two.
+		// Many other fields are returned, but we need only the data and height.
 		var txinfo common.PiratedRpcReplyGetrawtransaction
 		err = json.Unmarshal(result, &txinfo)
 		if err != nil {
@@ -440,19 +815,240 @@ func (s *lwdStreamer) GetTransaction(ctx context.Context, txf *walletrpc.TxFilte
 		}, nil
 	}
 
-	if txf.Block != nil && txf.Block.Hash != nil {
-		return nil, errors.New("Can't GetTransaction with a blockhash+num. Please call GetTransaction with txid")
+	return nil, errors.New("Please call GetTransaction with txid or block and index")
+}
+
+// getTransactionsConcurrency is the number of lookups each GetTransactions
+// call runs at once.
+const getTransactionsConcurrency = 8
+
+// GetTransactions is a bidirectional streaming RPC: the client sends
+// TxFilters (as for GetTransaction), and for each one the server sends back
+// the transaction or an error, tagged with the filter's position in the
+// request stream. Lookups run concurrently, so replies may be out of order.
+func (s *lwdStreamer) GetTransactions(stream walletrpc.CompactTxStreamer_GetTransactionsServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	replies := make(chan *walletrpc.GetTransactionsReply)
+	recvErr := make(chan error, 1)
+	go func() {
+		var wg sync.WaitGroup
+		defer func() {
+			wg.Wait()
+			close(replies)
+		}()
+		sem := make(chan struct{}, getTransactionsConcurrency)
+		for i := uint32(0); ; i++ {
+			txf, err := stream.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func(i uint32, txf *walletrpc.TxFilter) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				reply := &walletrpc.GetTransactionsReply{RequestIndex: i}
+				tx, err := s.GetTransaction(ctx, txf)
+				if err != nil {
+					reply.Error = err.Error()
+				} else {
+					reply.Transaction = tx
+				}
+				select {
+				case replies <- reply:
+				case <-ctx.Done():
+				}
+			}(i, txf)
+		}
+	}()
+	for reply := range replies {
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
+	select {
+	case err := <-recvErr:
+		return err
+	default:
+		return nil
 	}
-	return nil, errors.New("Please call GetTransaction with txid")
+}
+
+// GetTransactionStatus returns whether the given transaction (txf.Hash) is
+// mined, and if so, how deeply (according to the cache), or is still in the
+// mempool, or has expired without being mined.
+func (s *lwdStreamer) GetTransactionStatus(ctx context.Context, txf *walletrpc.TxFilter) (*walletrpc.TransactionStatus, error) {
+	if len(txf.Hash) != 32 {
+		return nil, errors.New("Transaction ID has invalid length")
+	}
+	txidHex := hex.EncodeToString(parser.Reverse(txf.Hash))
+	txidJSON, err := json.Marshal(txidHex)
+	if err != nil {
+		return nil, err
+	}
+	txStatus := &walletrpc.TransactionStatus{}
+	var txBytes []byte
+	result, rpcErr := common.RawRequest("getrawtransaction", []json.RawMessage{txidJSON, json.RawMessage("1")})
+	if rpcErr != nil {
+		if code, _ := common.GetRPCErrorCode(rpcErr); code != common.RPCInvalidAddressOrKey {
+			return nil, rpcErr
+		}
+		// If it was sent through this server, its expiry can still be checked.
+		txBytes = common.GetRebroadcastTx(txidHex)
+	} else {
+		var txinfo common.PiratedRpcReplyGetrawtransaction
+		if err := json.Unmarshal(result, &txinfo); err != nil {
+			return nil, err
+		}
+		if txBytes, err = hex.DecodeString(txinfo.Hex); err != nil {
+			return nil, err
+		}
+		if txinfo.Height > 0 {
+			blockHash, err := hex.DecodeString(txinfo.Blockhash)
+			if err != nil {
+				return nil, err
+			}
+			txStatus.State = walletrpc.TransactionState_mined
+			txStatus.Height = uint64(txinfo.Height)
+			txStatus.BlockHash = parser.Reverse(blockHash)
+			// pirated may be ahead of the cache, or on a different branch
+			// if there's been a reorg that the cache hasn't followed yet.
+			if block := s.cache.Get(txinfo.Height); block != nil && bytes.Equal(block.Hash, txStatus.BlockHash) {
+				txStatus.Confirmations = uint64(s.cache.GetLatestHeight()-txinfo.Height) + 1
+			}
+		} else {
+			txStatus.State = walletrpc.TransactionState_inMempool
+		}
+	}
+	if txBytes == nil {
+		return txStatus, nil
+	}
+	tx := parser.NewTransaction()
+	if _, err := tx.ParseFromSlice(txBytes); err != nil {
+		return nil, err
+	}
+	txStatus.ExpiryHeight = tx.ExpiryHeight()
+	latest := s.cache.GetLatestHeight()
+	if txStatus.State != walletrpc.TransactionState_mined && txStatus.ExpiryHeight != 0 &&
+		latest >= 0 && uint32(latest)+1 > txStatus.ExpiryHeight {
+		txStatus.State = walletrpc.TransactionState_expiredUnmined
+	}
+	return txStatus, nil
 }
 
 // GetLightdInfo gets the LightWalletD (this server) info, and includes information
 // it gets from its backend pirated.
 func (s *lwdStreamer) GetLightdInfo(ctx context.Context, in *walletrpc.Empty) (*walletrpc.LightdInfo, error) {
-	return common.GetLightdInfo()
+	info, err := common.GetLightdInfo()
+	if err != nil {
+		return nil, err
+	}
+	if latest := s.cache.GetLatestHeight(); latest >= 0 {
+		info.FirstCachedHeight = uint64(s.cache.GetFirstHeight())
+		info.LatestCachedHeight = uint64(latest)
+	}
+	if s.pingEnable {
+		info.Features = append(info.Features, "ping")
+	}
+	info.Features = append(info.Features, Features...)
+	info.DonationAddress = DonationAddress
+	info.Contact = OperatorContact
+	info.EnabledMethods = enabledMethods(s.pingEnable)
+	info.TaddrSupport = true
+	for _, method := range methodGroups["taddr"] {
+		if !methodEnabled(method) {
+			info.TaddrSupport = false
+		}
+	}
+	return info, nil
 }
 
-// SendTransaction forwards raw transaction bytes to a pirated instance over JSON-RPC
+// maxTransactionSize is the largest transaction pirated accepts
+// (MAX_TX_SIZE_AFTER_SAPLING).
+const maxTransactionSize = 2000000
+
+// txExpiringSoonThreshold is how many blocks before its expiry height
+// pirated stops accepting a transaction.
+const txExpiringSoonThreshold = 3
+
+func sendRejection(rejection walletrpc.SendRejection, errCode common.RPCErrorCode, errMsg string) *walletrpc.SendResponse {
+	return &walletrpc.SendResponse{
+		ErrorCode:    int32(errCode),
+		ErrorMessage: errMsg,
+		Rejection:    rejection,
+	}
+}
+
+// checkTransaction returns the response rejecting the given transaction if
+// it can be rejected without sending it to pirated, or else the parsed
+// transaction.
+func (s *lwdStreamer) checkTransaction(data []byte) (*parser.Transaction, *walletrpc.SendResponse, error) {
+	if len(data) > maxTransactionSize {
+		return nil, sendRejection(walletrpc.SendRejection_tooLarge, common.RPCVerifyRejected, "bad-txns-oversize"), nil
+	}
+	tx := parser.NewTransaction()
+	rest, err := tx.ParseFromSlice(data)
+	if err != nil || len(rest) != 0 {
+		return nil, sendRejection(walletrpc.SendRejection_malformed, common.RPCDeserializationError, "TX decode failed"), nil
+	}
+
+	// If the cache is behind pirated, these checks are more lenient than
+	// pirated's, which is safe.
+	if latest := s.cache.GetLatestHeight(); latest >= 0 && tx.ExpiryHeight() != 0 {
+		nextHeight := uint32(latest) + 1
+		if nextHeight > tx.ExpiryHeight() {
+			return nil, sendRejection(walletrpc.SendRejection_expired, common.RPCVerifyRejected,
+				"tx-overwinter-expired: expiry height "+strconv.Itoa(int(tx.ExpiryHeight()))+
+					" is below the next block height "+strconv.Itoa(int(nextHeight))), nil
+		}
+		if nextHeight+txExpiringSoonThreshold > tx.ExpiryHeight() {
+			return nil, sendRejection(walletrpc.SendRejection_expiringSoon, common.RPCVerifyRejected,
+				"tx-expiring-soon: expiry height "+strconv.Itoa(int(tx.ExpiryHeight()))+
+					" should be at least "+strconv.Itoa(int(nextHeight+txExpiringSoonThreshold))), nil
+		}
+	}
+
+	// Only version 5 transactions include their consensus branch ID.
+	if tx.ConsensusBranchID() != 0 {
+		info, err := common.GetLightdInfo()
+		if err != nil {
+			return nil, nil, err
+		}
+		nextBranchID := nextBlockBranchID(info)
+		branchID, err := strconv.ParseUint(nextBranchID, 16, 32)
+		if err == nil && uint32(branchID) != tx.ConsensusBranchID() {
+			return nil, sendRejection(walletrpc.SendRejection_wrongConsensusBranch, common.RPCVerifyRejected,
+				"bad-tx-consensus-branch-id: the next block's consensus branch ID is "+nextBranchID), nil
+		}
+	}
+	return tx, nil, nil
+}
+
+// nextBlockBranchID returns the consensus branch ID (hex) of the block after
+// pirated's tip, that of the latest upgrade active at that height, or an
+// empty string if there's none.
+func nextBlockBranchID(info *walletrpc.LightdInfo) string {
+	var branchID string
+	var activation uint64
+	for _, upgrade := range info.Upgrades {
+		if upgrade.ActivationHeight <= info.BlockHeight+1 && upgrade.ActivationHeight >= activation {
+			branchID, activation = upgrade.BranchId, upgrade.ActivationHeight
+		}
+	}
+	return branchID
+}
+
+// SendTransaction checks the given transaction and, if it looks valid,
+// submits it to pirated to be added to the mempool and relayed.
 func (s *lwdStreamer) SendTransaction(ctx context.Context, rawtx *walletrpc.RawTransaction) (*walletrpc.SendResponse, error) {
 	// sendrawtransaction "hexstring" ( allowhighfees )
 	//
@@ -465,6 +1061,11 @@ func (s *lwdStreamer) SendTransaction(ctx context.Context, rawtx *walletrpc.RawT
 	if rawtx == nil || rawtx.Data == nil {
 		return nil, errors.New("Bad transaction data")
 	}
+	common.Metrics.SendTransactionsCounter.Inc()
+	tx, resp, err := s.checkTransaction(rawtx.Data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
 
 	// Construct raw JSON-RPC params
 	params := make([]json.RawMessage, 1)
@@ -475,36 +1076,28 @@ func (s *lwdStreamer) SendTransaction(ctx context.Context, rawtx *walletrpc.RawT
 	params[0] = txJSON
 	result, rpcErr := common.RawRequest("sendrawtransaction", params)
 
-	var errCode int64
-	var errMsg string
-
 	// For some reason, the error responses are not JSON
 	if rpcErr != nil {
 		errParts := strings.SplitN(rpcErr.Error(), ":", 2)
-		if len(errParts) < 2 {
-			return nil, errors.New("SendTransaction couldn't parse error code")
-		}
-		errMsg = strings.TrimSpace(errParts[1])
-		errCode, err = strconv.ParseInt(errParts[0], 10, 32)
-		if err != nil {
+		errCode, ok := common.GetRPCErrorCode(rpcErr)
+		if len(errParts) < 2 || !ok {
 			// This should never happen. We can't panic here, but it's that class of error.
 			// This is why we need integration testing to work better than regtest currently does. TODO.
 			return nil, errors.New("SendTransaction couldn't parse error code")
 		}
-	} else {
-		errMsg = string(result)
+		return sendRejection(walletrpc.SendRejection_rejectedByNode, errCode, strings.TrimSpace(errParts[1])), nil
 	}
 
-	// TODO these are called Error but they aren't at the moment.
-	// A success will return code 0 and message txhash.
-	resp := &walletrpc.SendResponse{
-		ErrorCode:    int32(errCode),
-		ErrorMessage: errMsg,
+	var txidHex string
+	if err := json.Unmarshal(result, &txidHex); err != nil {
+		return nil, errors.New("SendTransaction couldn't parse txid")
 	}
-
-	common.Metrics.SendTransactionsCounter.Inc()
-
-	return resp, nil
+	txid, err := hex.DecodeString(txidHex)
+	if err != nil || len(txid) != 32 {
+		return nil, errors.New("SendTransaction couldn't parse txid")
+	}
+	common.QueueRebroadcast(txidHex, rawtx.Data, tx.ExpiryHeight())
+	return &walletrpc.SendResponse{Txid: parser.Reverse(txid)}, nil
 }
 
 func getTaddressBalancePiratedRpc(addressList []string) (*walletrpc.Balance, error) {
@@ -557,89 +1150,46 @@ func (s *lwdStreamer) GetTaddressBalanceStream(addresses walletrpc.CompactTxStre
 }
 
 func (s *lwdStreamer) GetMempoolStream(_empty *walletrpc.Empty, resp walletrpc.CompactTxStreamer_GetMempoolStreamServer) error {
-	err := common.GetMempool(func(tx *walletrpc.RawTransaction) error {
+	err := common.GetMempool(resp.Context(), func(tx *walletrpc.RawTransaction) error {
 		return resp.Send(tx)
 	})
 	return err
 }
 
-// Key is 32-byte txid (as a 64-character string), data is pointer to compact tx.
-var mempoolMap *map[string]*walletrpc.CompactTx
-var mempoolList []string
+// GetMempoolEvents streams the shielded mempool transactions as they arrive,
+// and then whether each is mined or evicted, until the client disconnects.
+func (s *lwdStreamer) GetMempoolEvents(_empty *walletrpc.Empty, resp walletrpc.CompactTxStreamer_GetMempoolEventsServer) error {
+	return common.GetMempoolEvents(resp.Context(), func(event *walletrpc.MempoolEvent) error {
+		return resp.Send(event)
+	})
+}
 
-// Last time we pulled a copy of the mempool from zcashd.
-var lastMempool time.Time
+// GetMempoolInfo returns statistics about the mempool.
+func (s *lwdStreamer) GetMempoolInfo(ctx context.Context, in *walletrpc.Empty) (*walletrpc.MempoolInfo, error) {
+	return common.GetMempoolInfo()
+}
 
 func (s *lwdStreamer) GetMempoolTx(exclude *walletrpc.Exclude, resp walletrpc.CompactTxStreamer_GetMempoolTxServer) error {
-	if time.Now().Sub(lastMempool).Seconds() >= 2 {
-		lastMempool = time.Now()
-		// Refresh our copy of the mempool.
-		params := make([]json.RawMessage, 0)
-		result, rpcErr := common.RawRequest("getrawmempool", params)
-		if rpcErr != nil {
-			return rpcErr
-		}
-		err := json.Unmarshal(result, &mempoolList)
-		if err != nil {
-			return err
-		}
-		newmempoolMap := make(map[string]*walletrpc.CompactTx)
-		if mempoolMap == nil {
-			mempoolMap = &newmempoolMap
-		}
-		for _, txidstr := range mempoolList {
-			if ctx, ok := (*mempoolMap)[txidstr]; ok {
-				// This ctx has already been fetched, copy pointer to it.
-				newmempoolMap[txidstr] = ctx
-				continue
-			}
-			txidJSON, err := json.Marshal(txidstr)
-			if err != nil {
-				return err
-			}
-			// The "0" is because we only need the raw hex, which is returned as
-			// just a hex string, and not even a json string (with quotes).
-			params := []json.RawMessage{txidJSON, json.RawMessage("0")}
-			result, rpcErr := common.RawRequest("getrawtransaction", params)
-			if rpcErr != nil {
-				// Not an error; mempool transactions can disappear
-				continue
-			}
-			// strip the quotes
-			var txStr string
-			err = json.Unmarshal(result, &txStr)
-			if err != nil {
-				return err
-			}
-
-			// conver to binary
-			txBytes, err := hex.DecodeString(txStr)
-			if err != nil {
-				return err
-			}
-			tx := parser.NewTransaction()
-			txdata, err := tx.ParseFromSlice(txBytes)
-			if len(txdata) > 0 {
-				return errors.New("extra data deserializing transaction")
-			}
-			newmempoolMap[txidstr] = &walletrpc.CompactTx{}
-			if tx.HasShieldedElements() {
-				newmempoolMap[txidstr] = tx.ToCompact( /* height */ 0)
-			}
+	txs, err := common.GetMempoolTxs()
+	if err != nil {
+		return err
+	}
+	// Key is 32-byte txid (as a 64-character string), data is pointer to compact tx.
+	mempoolMap := make(map[string]*walletrpc.CompactTx)
+	mempoolList := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		if tx.Compact != nil {
+			mempoolMap[tx.Txid] = tx.Compact
+			mempoolList = append(mempoolList, tx.Txid)
 		}
-		mempoolMap = &newmempoolMap
 	}
 	excludeHex := make([]string, len(exclude.Txid))
 	for i := 0; i < len(exclude.Txid); i++ {
 		excludeHex[i] = hex.EncodeToString(parser.Reverse(exclude.Txid[i]))
 	}
 	for _, txid := range MempoolFilter(mempoolList, excludeHex) {
-		tx := (*mempoolMap)[txid]
-		if len(tx.Hash) > 0 {
-			err := resp.Send(tx)
-			if err != nil {
-				return err
-			}
+		if err := resp.Send(mempoolMap[txid]); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -691,43 +1241,131 @@ func MempoolFilter(items, exclude []string) []string {
 	return tosend
 }
 
-func getAddressUtxos(arg *walletrpc.GetAddressUtxosArg, f func(*walletrpc.GetAddressUtxosReply) error) error {
+// utxoCursor returns the position of the given UTXO in the result order.
+func utxoCursor(utxo *walletrpc.GetAddressUtxosReply) *walletrpc.UtxoCursor {
+	return &walletrpc.UtxoCursor{Height: utxo.Height, Txid: utxo.Txid, Index: utxo.Index}
+}
+
+// utxoCursorLess orders UTXOs by height (mempool outputs, whose height is
+// zero, last), then txid, then output index.
+func utxoCursorLess(a, b *walletrpc.UtxoCursor) bool {
+	ah, bh := a.Height, b.Height
+	if ah == 0 {
+		ah = math.MaxUint64
+	}
+	if bh == 0 {
+		bh = math.MaxUint64
+	}
+	if ah != bh {
+		return ah < bh
+	}
+	if c := bytes.Compare(a.Txid, b.Txid); c != 0 {
+		return c < 0
+	}
+	return a.Index < b.Index
+}
+
+// outpoint identifies a transaction output as pirated does (big-endian hex txid).
+func outpoint(txid string, index int64) string {
+	return txid + ":" + strconv.FormatInt(index, 10)
+}
+
+// getMempoolOutputScripts returns the scripts of the transparent outputs of
+// the given (mempool) transaction, by output index.
+func getMempoolOutputScripts(txid string) (map[int64]string, error) {
+	txidJSON, err := json.Marshal(txid)
+	if err != nil {
+		return nil, err
+	}
+	result, rpcErr := common.RawRequest("getrawtransaction", []json.RawMessage{txidJSON, json.RawMessage("1")})
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	var txinfo common.PiratedRpcReplyGetrawtransactionVout
+	if err := json.Unmarshal(result, &txinfo); err != nil {
+		return nil, err
+	}
+	scripts := make(map[int64]string)
+	for _, vout := range txinfo.Vout {
+		scripts[vout.N] = vout.ScriptPubKey.Hex
+	}
+	return scripts, nil
+}
+
+// getAddressUtxos calls f with each of the requested UTXOs, in order. If
+// there are more than arg.MaxEntries, it returns the cursor of the last one
+// returned, from which the next request can continue.
+func getAddressUtxos(arg *walletrpc.GetAddressUtxosArg, f func(*walletrpc.GetAddressUtxosReply) error) (*walletrpc.UtxoCursor, error) {
 	params := make([]json.RawMessage, 1)
 	addrList := &common.PiratedRpcRequestGetaddressutxos{
 		Addresses: arg.Addresses,
 	}
 	param, err := json.Marshal(addrList)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	params[0] = param
 	result, rpcErr := common.RawRequest("getaddressutxos", params)
 	if rpcErr != nil {
-		return rpcErr
+		return nil, rpcErr
 	}
 	var utxosReply []common.PiratedRpcReplyGetaddressutxos
 	err = json.Unmarshal(result, &utxosReply)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	n := 0
+
+	var mempoolDeltas []common.PiratedRpcReplyGetaddressmempool
+	if arg.ExcludeMempoolSpent || arg.IncludeMempool {
+		param, err := json.Marshal(&common.PiratedRpcRequestGetaddressmempool{
+			Addresses: arg.Addresses,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result, rpcErr := common.RawRequest("getaddressmempool", []json.RawMessage{param})
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if err := json.Unmarshal(result, &mempoolDeltas); err != nil {
+			return nil, err
+		}
+	}
+	spent := make(map[string]bool)
+	if arg.ExcludeMempoolSpent {
+		for _, delta := range mempoolDeltas {
+			if delta.Prevtxid != "" {
+				spent[outpoint(delta.Prevtxid, delta.Prevout)] = true
+			}
+		}
+	}
+
+	// getaddressutxos has no start height, so the UTXOs below it (and
+	// those not after arg.StartAfter) are skipped here, before they're
+	// sorted and paged (and before mempool outputs' scripts are fetched).
+	wanted := func(height uint64, txid []byte, index int64) bool {
+		return arg.StartAfter == nil ||
+			utxoCursorLess(arg.StartAfter, &walletrpc.UtxoCursor{Height: height, Txid: txid, Index: int32(index)})
+	}
+	utxos := make([]*walletrpc.GetAddressUtxosReply, 0)
+	confirmed := make(map[string]bool)
 	for _, utxo := range utxosReply {
-		if uint64(utxo.Height) < arg.StartHeight {
+		confirmed[outpoint(utxo.Txid, utxo.OutputIndex)] = true
+		if uint64(utxo.Height) < arg.StartHeight || spent[outpoint(utxo.Txid, utxo.OutputIndex)] {
 			continue
 		}
-		n++
-		if arg.MaxEntries > 0 && uint32(n) > arg.MaxEntries {
-			break
-		}
 		txidBytes, err := hex.DecodeString(utxo.Txid)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if !wanted(uint64(utxo.Height), parser.Reverse(txidBytes), utxo.OutputIndex) {
+			continue
 		}
 		scriptBytes, err := hex.DecodeString(utxo.Script)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		err = f(&walletrpc.GetAddressUtxosReply{
+		utxos = append(utxos, &walletrpc.GetAddressUtxosReply{
 			Address:  utxo.Address,
 			Txid:     parser.Reverse(txidBytes),
 			Index:    int32(utxo.OutputIndex),
@@ -735,35 +1373,326 @@ func getAddressUtxos(arg *walletrpc.GetAddressUtxosArg, f func(*walletrpc.GetAdd
 			ValueZat: int64(utxo.Satoshis),
 			Height:   uint64(utxo.Height),
 		})
-		if err != nil {
-			return err
+	}
+	if arg.IncludeMempool {
+		// The address index doesn't include scripts, so get them from
+		// each transaction (once).
+		scripts := make(map[string]map[int64]string)
+		for _, delta := range mempoolDeltas {
+			op := outpoint(delta.Txid, delta.Index)
+			// A spend, or mined since the UTXOs were queried.
+			if delta.Prevtxid != "" || confirmed[op] || spent[op] {
+				continue
+			}
+			txidBytes, err := hex.DecodeString(delta.Txid)
+			if err != nil {
+				return nil, err
+			}
+			if !wanted(0, parser.Reverse(txidBytes), delta.Index) {
+				continue
+			}
+			if scripts[delta.Txid] == nil {
+				if scripts[delta.Txid], err = getMempoolOutputScripts(delta.Txid); err != nil {
+					return nil, err
+				}
+			}
+			script, ok := scripts[delta.Txid][delta.Index]
+			if !ok {
+				return nil, errors.New("mempool transaction " + delta.Txid + " has no output " + strconv.FormatInt(delta.Index, 10))
+			}
+			scriptBytes, err := hex.DecodeString(script)
+			if err != nil {
+				return nil, err
+			}
+			utxos = append(utxos, &walletrpc.GetAddressUtxosReply{
+				Address:  delta.Address,
+				Txid:     parser.Reverse(txidBytes),
+				Index:    int32(delta.Index),
+				Script:   scriptBytes,
+				ValueZat: delta.Satoshis,
+			})
 		}
 	}
-	return nil
+
+	sort.Slice(utxos, func(i, j int) bool {
+		return utxoCursorLess(utxoCursor(utxos[i]), utxoCursor(utxos[j]))
+	})
+	var next *walletrpc.UtxoCursor
+	if arg.MaxEntries > 0 && len(utxos) > int(arg.MaxEntries) {
+		utxos = utxos[:arg.MaxEntries]
+		next = utxoCursor(utxos[len(utxos)-1])
+	}
+	for _, utxo := range utxos {
+		if err := f(utxo); err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
 }
 
 func (s *lwdStreamer) GetAddressUtxos(ctx context.Context, arg *walletrpc.GetAddressUtxosArg) (*walletrpc.GetAddressUtxosReplyList, error) {
 	addressUtxos := make([]*walletrpc.GetAddressUtxosReply, 0)
-	err := getAddressUtxos(arg, func(utxo *walletrpc.GetAddressUtxosReply) error {
+	next, err := getAddressUtxos(arg, func(utxo *walletrpc.GetAddressUtxosReply) error {
 		addressUtxos = append(addressUtxos, utxo)
 		return nil
 	})
 	if err != nil {
 		return &walletrpc.GetAddressUtxosReplyList{}, err
 	}
-	return &walletrpc.GetAddressUtxosReplyList{AddressUtxos: addressUtxos}, nil
+	return &walletrpc.GetAddressUtxosReplyList{AddressUtxos: addressUtxos, NextCursor: next}, nil
 }
 
+// utxoCursorTrailer is the trailer in which GetAddressUtxosStream returns
+// the UtxoCursor to continue from when arg.MaxEntries cuts the list short.
+const utxoCursorTrailer = "utxo-cursor-bin"
+
 func (s *lwdStreamer) GetAddressUtxosStream(arg *walletrpc.GetAddressUtxosArg, resp walletrpc.CompactTxStreamer_GetAddressUtxosStreamServer) error {
-	err := getAddressUtxos(arg, func(utxo *walletrpc.GetAddressUtxosReply) error {
+	next, err := getAddressUtxos(arg, func(utxo *walletrpc.GetAddressUtxosReply) error {
 		return resp.Send(utxo)
 	})
 	if err != nil {
 		return err
 	}
+	if next != nil {
+		b, err := proto.Marshal(next)
+		if err != nil {
+			return err
+		}
+		resp.SetTrailer(metadata.Pairs(utxoCursorTrailer, string(b)))
+	}
 	return nil
 }
 
+// addressHistoryPageSize is the default (and maximum) number of entries in
+// a GetAddressHistory reply.
+const addressHistoryPageSize = 1000
+
+// maxAddressHistoryAddresses is the most addresses a GetAddressHistory
+// request may list.
+const maxAddressHistoryAddresses = 100
+
+// mempoolHistoryHeight places mempool transactions after all mined ones in
+// the address history order.
+const mempoolHistoryHeight = math.MaxUint64
+
+// addressHistoryKey orders the address history: by height and position
+// within the block (or, for mempool transactions, arrival time), then txid.
+type addressHistoryKey struct {
+	height uint64
+	index  int64
+	txid   string // big-endian hex, as from pirated
+}
+
+func (k addressHistoryKey) less(o addressHistoryKey) bool {
+	if k.height != o.height {
+		return k.height < o.height
+	}
+	if k.index != o.index {
+		return k.index < o.index
+	}
+	return k.txid < o.txid
+}
+
+// The continuation token is the key of the last entry returned; it's
+// opaque to clients.
+func (k addressHistoryKey) token() string {
+	return strconv.FormatUint(k.height, 10) + "." + strconv.FormatInt(k.index, 10) + "." + k.txid
+}
+
+func parseAddressHistoryToken(token string) (addressHistoryKey, error) {
+	var k addressHistoryKey
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return k, errors.New("invalid continuation token")
+	}
+	var err error
+	if k.height, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return k, errors.New("invalid continuation token")
+	}
+	if k.index, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return k, errors.New("invalid continuation token")
+	}
+	if txid, err := hex.DecodeString(parts[2]); err != nil || len(txid) != 32 {
+		return k, errors.New("invalid continuation token")
+	}
+	k.txid = parts[2]
+	return k, nil
+}
+
+type addressHistoryEntry struct {
+	key   addressHistoryKey
+	time  int64 // mempool arrival time; block times are looked up later
+	delta int64
+}
+
+// getAddressHistory returns the history entries of the given addresses,
+// one per transaction, from pirated's address index and (if requested)
+// mempool, unsorted.
+func getAddressHistory(addresses []string, start, end uint64, after *addressHistoryKey, includeMempool bool) ([]*addressHistoryEntry, error) {
+	byTxid := make(map[string]*addressHistoryEntry)
+	if after == nil || after.height != mempoolHistoryHeight {
+		if after != nil && after.height > start {
+			start = after.height
+		}
+		// pirated ignores the range unless both ends are nonzero.
+		if start == 0 {
+			start = 1
+		}
+		if end == 0 {
+			end = math.MaxInt32
+		}
+		param, err := json.Marshal(&common.PiratedRpcRequestGetaddressdeltas{
+			Addresses: addresses,
+			Start:     start,
+			End:       end,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result, rpcErr := common.RawRequest("getaddressdeltas", []json.RawMessage{param})
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		var deltas []common.PiratedRpcReplyGetaddressdeltas
+		if err := json.Unmarshal(result, &deltas); err != nil {
+			return nil, err
+		}
+		for _, d := range deltas {
+			e := byTxid[d.Txid]
+			if e == nil {
+				e = &addressHistoryEntry{key: addressHistoryKey{
+					height: uint64(d.Height),
+					index:  d.BlockIndex,
+					txid:   d.Txid,
+				}}
+				byTxid[d.Txid] = e
+			}
+			e.delta += d.Satoshis
+		}
+	}
+	if includeMempool {
+		param, err := json.Marshal(&common.PiratedRpcRequestGetaddressmempool{
+			Addresses: addresses,
+		})
+		if err != nil {
+			return nil, err
+		}
+		result, rpcErr := common.RawRequest("getaddressmempool", []json.RawMessage{param})
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		var deltas []common.PiratedRpcReplyGetaddressmempool
+		if err := json.Unmarshal(result, &deltas); err != nil {
+			return nil, err
+		}
+		for _, d := range deltas {
+			e := byTxid[d.Txid]
+			if e == nil {
+				e = &addressHistoryEntry{
+					key:  addressHistoryKey{height: mempoolHistoryHeight, index: d.Timestamp, txid: d.Txid},
+					time: d.Timestamp,
+				}
+				byTxid[d.Txid] = e
+			} else if e.key.height != mempoolHistoryHeight {
+				// Mined since the index was queried.
+				continue
+			}
+			e.delta += d.Satoshis
+		}
+	}
+	entries := make([]*addressHistoryEntry, 0, len(byTxid))
+	for _, e := range byTxid {
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// GetAddressHistory returns a page of the transactions that involve any of
+// the given t-addresses (at most maxAddressHistoryAddresses), oldest first,
+// with the net value change of each; unless the request has a range end or
+// excludes them, mempool transactions follow the mined ones.
+func (s *lwdStreamer) GetAddressHistory(ctx context.Context, arg *walletrpc.GetAddressHistoryArg) (*walletrpc.GetAddressHistoryReply, error) {
+	if len(arg.Addresses) == 0 {
+		return nil, errors.New("Must specify at least one address")
+	}
+	if len(arg.Addresses) > maxAddressHistoryAddresses {
+		return nil, status.Errorf(codes.InvalidArgument, "the request has more than %d addresses", maxAddressHistoryAddresses)
+	}
+	for _, addr := range arg.Addresses {
+		if err := checkTaddress(addr); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v: %q", err, addr)
+		}
+	}
+	var start, end uint64
+	if arg.Range != nil {
+		if arg.Range.Start != nil {
+			start = arg.Range.Start.Height
+		}
+		if arg.Range.End != nil {
+			end = arg.Range.End.Height
+		}
+	}
+	if end > 0 && start > end {
+		return nil, errors.New("Start height must not be greater than end height")
+	}
+	var after *addressHistoryKey
+	if arg.ContinuationToken != "" {
+		key, err := parseAddressHistoryToken(arg.ContinuationToken)
+		if err != nil {
+			return nil, err
+		}
+		after = &key
+	}
+	maxEntries := addressHistoryPageSize
+	if arg.MaxEntries > 0 && arg.MaxEntries < addressHistoryPageSize {
+		maxEntries = int(arg.MaxEntries)
+	}
+	entries, err := getAddressHistory(arg.Addresses, start, end, after, end == 0 && !arg.ExcludeMempool)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key.less(entries[j].key)
+	})
+	if after != nil {
+		entries = entries[sort.Search(len(entries), func(i int) bool {
+			return after.less(entries[i].key)
+		}):]
+	}
+	reply := &walletrpc.GetAddressHistoryReply{}
+	blockTimes := make(map[uint64]uint32)
+	if len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+		reply.ContinuationToken = entries[maxEntries-1].key.token()
+	}
+	for _, e := range entries {
+		txid, err := hex.DecodeString(e.key.txid)
+		if err != nil {
+			return nil, err
+		}
+		entry := &walletrpc.AddressHistoryEntry{
+			Txid:       parser.Reverse(txid),
+			Time:       uint32(e.time),
+			ValueDelta: e.delta,
+		}
+		if e.key.height != mempoolHistoryHeight {
+			entry.Height = e.key.height
+			blockTime, ok := blockTimes[e.key.height]
+			if !ok {
+				block, err := common.GetBlock(s.cache, int(e.key.height))
+				if err != nil {
+					return nil, err
+				}
+				blockTime = block.Time
+				blockTimes[e.key.height] = blockTime
+			}
+			entry.Time = blockTime
+		}
+		reply.Entries = append(reply.Entries, entry)
+	}
+	return reply, nil
+}
+
 // This rpc is used only for testing.
 var concurrent int64
 
@@ -796,8 +1725,6 @@ func (s *DarksideStreamer) Reset(ctx context.Context, ms *walletrpc.DarksideMeta
 	if err != nil {
 		return nil, err
 	}
-	mempoolMap = nil
-	mempoolList = nil
 	return &walletrpc.Empty{}, nil
 }
 
@@ -904,3 +1831,9 @@ func (s *DarksideStreamer) ClearAddressUtxo(ctx context.Context, arg *walletrpc.
 	err := common.DarksideClearAddressUtxos()
 	return &walletrpc.Empty{}, err
 }
+
+// SetSubtreeRoots replaces the subtree roots that GetSubtreeRoots returns.
+func (s *DarksideStreamer) SetSubtreeRoots(ctx context.Context, arg *walletrpc.DarksideSubtreeRoots) (*walletrpc.Empty, error) {
+	err := common.DarksideSetSubtreeRoots(arg)
+	return &walletrpc.Empty{}, err
+}
@@ -101,6 +101,16 @@ func (b *Block) GetHeight() int {
 	return int(blockHeight)
 }
 
+// GetHeader returns the block's full serialized header (including nBits
+// and the Equihash solution).
+func (b *Block) GetHeader() []byte {
+	header, err := b.hdr.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return header
+}
+
 // GetPrevHash returns the hash of the block's previous block (little-endian).
 func (b *Block) GetPrevHash() []byte {
 	return b.hdr.HashPrevBlock
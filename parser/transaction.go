@@ -22,7 +22,7 @@ type rawTransaction struct {
 	transparentInputs  []txIn
 	transparentOutputs []txOut
 	//nLockTime           uint32
-	//nExpiryHeight       uint32
+	nExpiryHeight uint32
 	//valueBalanceSapling int64
 	shieldedSpends  []spend
 	shieldedOutputs []output
@@ -370,6 +370,19 @@ func (tx *Transaction) HasShieldedElements() bool {
 	return tx.version >= 4 && nshielded > 0
 }
 
+// ExpiryHeight returns the transaction's nExpiryHeight, the height after
+// which it can't be mined (zero means it doesn't expire).
+func (tx *Transaction) ExpiryHeight() uint32 {
+	return tx.nExpiryHeight
+}
+
+// ConsensusBranchID returns the consensus branch ID of a version 5 (or
+// later) transaction, which is the only version that includes it; it
+// returns zero for earlier versions.
+func (tx *Transaction) ConsensusBranchID() uint32 {
+	return tx.consensusBranchID
+}
+
 // ToCompact converts the given (full) transaction to compact format.
 func (tx *Transaction) ToCompact(index int) *walletrpc.CompactTx {
 	ctx := &walletrpc.CompactTx{
@@ -407,8 +420,8 @@ func (tx *Transaction) parseV4(data []byte) ([]byte, error) {
 		return nil, errors.New("could not skip nLockTime")
 	}
 
-	if !s.Skip(4) {
-		return nil, errors.New("could not skip nExpiryHeight")
+	if !s.ReadUint32(&tx.nExpiryHeight) {
+		return nil, errors.New("could not read nExpiryHeight")
 	}
 
 	var spendCount, outputCount int
@@ -480,8 +493,8 @@ func (tx *Transaction) parseV5(data []byte) ([]byte, error) {
 	if !s.Skip(4) {
 		return nil, errors.New("could not skip nLockTime")
 	}
-	if !s.Skip(4) {
-		return nil, errors.New("could not skip nExpiryHeight")
+	if !s.ReadUint32(&tx.nExpiryHeight) {
+		return nil, errors.New("could not read nExpiryHeight")
 	}
 	s, err = tx.ParseTransparent([]byte(s))
 	if err != nil {
@@ -21,6 +21,7 @@ type TxTestData struct {
 	Version            int
 	NVersionGroupId    int
 	NConsensusBranchId int
+	NExpiryHeight      int
 	Tx_in_count        int
 	Tx_out_count       int
 	NSpendsSapling     int
@@ -39,6 +40,7 @@ func (r *TxTestData) UnmarshalJSON(p []byte) error {
 	r.Version = int(t[2].(float64))
 	r.NVersionGroupId = int(t[3].(float64))
 	r.NConsensusBranchId = int(t[4].(float64))
+	r.NExpiryHeight = int(t[6].(float64))
 	r.Tx_in_count = int(t[7].(float64))
 	r.Tx_out_count = int(t[8].(float64))
 	r.NSpendsSapling = int(t[9].(float64))
@@ -97,6 +99,9 @@ func TestV5TransactionParser(t *testing.T) {
 		if tx.consensusBranchID != uint32(txtestdata.NConsensusBranchId) {
 			t.Fatal("consensusBranchID miscompare")
 		}
+		if tx.ExpiryHeight() != uint32(txtestdata.NExpiryHeight) {
+			t.Fatal("nExpiryHeight miscompare")
+		}
 		if len(tx.transparentInputs) != int(txtestdata.Tx_in_count) {
 			t.Fatal("tx_in_count miscompare")
 		}
@@ -21,6 +21,7 @@ It has these top-level messages:
 	DarksideTransactionsURL
 	DarksideHeight
 	DarksideEmptyBlocks
+	DarksideSubtreeRoots
 */
 package walletrpc
 
@@ -181,6 +182,40 @@ func (m *DarksideEmptyBlocks) GetCount() int32 {
 	return 0
 }
 
+// DarksideSubtreeRoots are the subtree roots that the mock pirated's
+// z_getsubtreesbyindex returns, starting at the given index.
+type DarksideSubtreeRoots struct {
+	ShieldedProtocol ShieldedProtocol `protobuf:"varint,1,opt,name=shieldedProtocol,enum=pirate.wallet.sdk.rpc.ShieldedProtocol" json:"shieldedProtocol,omitempty"`
+	StartIndex       uint32           `protobuf:"varint,2,opt,name=startIndex" json:"startIndex,omitempty"`
+	SubtreeRoots     []*SubtreeRoot   `protobuf:"bytes,3,rep,name=subtreeRoots" json:"subtreeRoots,omitempty"`
+}
+
+func (m *DarksideSubtreeRoots) Reset()                    { *m = DarksideSubtreeRoots{} }
+func (m *DarksideSubtreeRoots) String() string            { return proto.CompactTextString(m) }
+func (*DarksideSubtreeRoots) ProtoMessage()               {}
+func (*DarksideSubtreeRoots) Descriptor() ([]byte, []int) { return file_darkside_proto_rawDesc, []int{6} }
+
+func (m *DarksideSubtreeRoots) GetShieldedProtocol() ShieldedProtocol {
+	if m != nil {
+		return m.ShieldedProtocol
+	}
+	return ShieldedProtocol_sapling
+}
+
+func (m *DarksideSubtreeRoots) GetStartIndex() uint32 {
+	if m != nil {
+		return m.StartIndex
+	}
+	return 0
+}
+
+func (m *DarksideSubtreeRoots) GetSubtreeRoots() []*SubtreeRoot {
+	if m != nil {
+		return m.SubtreeRoots
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*DarksideMetaState)(nil), "pirate.wallet.sdk.rpc.DarksideMetaState")
 	proto.RegisterType((*DarksideBlock)(nil), "pirate.wallet.sdk.rpc.DarksideBlock")
@@ -188,43 +223,49 @@ func init() {
 	proto.RegisterType((*DarksideTransactionsURL)(nil), "pirate.wallet.sdk.rpc.DarksideTransactionsURL")
 	proto.RegisterType((*DarksideHeight)(nil), "pirate.wallet.sdk.rpc.DarksideHeight")
 	proto.RegisterType((*DarksideEmptyBlocks)(nil), "pirate.wallet.sdk.rpc.DarksideEmptyBlocks")
+	proto.RegisterType((*DarksideSubtreeRoots)(nil), "pirate.wallet.sdk.rpc.DarksideSubtreeRoots")
 }
 
 func init() { proto.RegisterFile("darkside.proto", file_darkside_proto_rawDesc) }
 
 var file_darkside_proto_rawDesc = []byte{
-	// 513 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x54, 0xed, 0x6e, 0xd3, 0x30,
-	0x14, 0x6d, 0x3a, 0x65, 0x5a, 0xef, 0xb4, 0xaa, 0x35, 0x63, 0x2b, 0x65, 0x3f, 0xa6, 0x88, 0x4a,
-	0x11, 0xa0, 0x80, 0xe0, 0x09, 0xba, 0x0e, 0x8d, 0x49, 0x80, 0x20, 0xdd, 0x84, 0x06, 0x42, 0xc8,
-	0x75, 0xae, 0xda, 0xa8, 0xa9, 0x63, 0xd9, 0xde, 0xc6, 0xc4, 0x23, 0xf0, 0x46, 0x3c, 0x1d, 0x8a,
-	0x93, 0xac, 0x29, 0x23, 0xb8, 0x82, 0x5f, 0xf1, 0xb5, 0xcf, 0x3d, 0xe7, 0xdc, 0x0f, 0x05, 0xda,
-	0x11, 0x95, 0x73, 0x15, 0x47, 0x18, 0x08, 0x99, 0xea, 0x94, 0xdc, 0x17, 0xb1, 0xa4, 0x1a, 0x83,
-	0x6b, 0x9a, 0x24, 0xa8, 0x03, 0x15, 0xcd, 0x03, 0x29, 0x58, 0x7f, 0x47, 0xa1, 0xbc, 0x8a, 0x59,
-	0x81, 0xf2, 0xbe, 0x43, 0xf7, 0xb8, 0xc8, 0x7b, 0x8b, 0x9a, 0x8e, 0x35, 0xd5, 0x48, 0x9e, 0x42,
-	0x57, 0x51, 0x91, 0xc4, 0x7c, 0x3a, 0x64, 0x3a, 0xbe, 0xa2, 0x3a, 0x4e, 0x79, 0xcf, 0x39, 0x74,
-	0x7c, 0x37, 0xbc, 0xfb, 0x40, 0xfa, 0xb0, 0x35, 0x91, 0x94, 0xb3, 0xd9, 0xe9, 0x71, 0xaf, 0x79,
-	0xe8, 0xf8, 0xad, 0xf0, 0x36, 0x26, 0x07, 0xd0, 0x62, 0x33, 0x1a, 0xf3, 0x77, 0x74, 0x81, 0xbd,
-	0x0d, 0xf3, 0xb8, 0xbc, 0xf0, 0x06, 0xb0, 0x53, 0x8a, 0x1f, 0x25, 0x29, 0x9b, 0x93, 0x5d, 0x70,
-	0x27, 0xd9, 0xc1, 0x88, 0xb5, 0xc2, 0x3c, 0xf0, 0x06, 0x4b, 0x8f, 0x06, 0xa6, 0xce, 0xc3, 0x37,
-	0xa4, 0x03, 0x1b, 0x97, 0x32, 0x29, 0x80, 0xd9, 0xd1, 0x1b, 0xc1, 0x7e, 0x09, 0x3b, 0x93, 0x94,
-	0x2b, 0xca, 0x32, 0x7b, 0x06, 0xbc, 0x07, 0x9b, 0x33, 0x8c, 0xa7, 0x33, 0x5d, 0x54, 0x51, 0x44,
-	0x25, 0x49, 0x73, 0x49, 0xe2, 0x43, 0xbb, 0x24, 0x79, 0x9d, 0x63, 0x6a, 0x72, 0xbd, 0x0b, 0xb8,
-	0x57, 0x22, 0x5f, 0x2d, 0x84, 0xbe, 0xc9, 0xad, 0xd5, 0x4a, 0xed, 0x82, 0xcb, 0x53, 0xce, 0xd0,
-	0x88, 0xb9, 0x61, 0x1e, 0x64, 0xb7, 0x2c, 0xbd, 0xe4, 0xda, 0xf4, 0xc6, 0x0d, 0xf3, 0xe0, 0xc5,
-	0x8f, 0x2d, 0xe8, 0x94, 0xdc, 0x63, 0x2d, 0x91, 0x2e, 0x50, 0x92, 0x0f, 0xe0, 0x86, 0xa8, 0x50,
-	0x13, 0x3f, 0xf8, 0xe3, 0x64, 0x83, 0x3b, 0x73, 0xec, 0x1f, 0xd4, 0x20, 0x8d, 0x5f, 0xaf, 0x41,
-	0x3e, 0x43, 0x77, 0xac, 0xe9, 0xb4, 0xe8, 0x6a, 0xae, 0x44, 0x1e, 0x59, 0xe8, 0x0d, 0xd8, 0x46,
-	0xed, 0x3b, 0xe4, 0x23, 0x6c, 0x57, 0xc8, 0xad, 0xae, 0x6f, 0x27, 0x6b, 0x75, 0xfd, 0x75, 0xc5,
-	0xf5, 0x48, 0x62, 0xb6, 0xb2, 0x8f, 0x2d, 0xf4, 0x95, 0x11, 0x59, 0x05, 0x26, 0xb0, 0x6f, 0x04,
-	0xaa, 0x5b, 0x54, 0x34, 0x67, 0x50, 0x93, 0x1a, 0xd2, 0xeb, 0x0a, 0x7a, 0x8d, 0xee, 0xb0, 0xa2,
-	0x88, 0xaa, 0x06, 0x09, 0x2c, 0x45, 0xfc, 0xb6, 0xd6, 0xd6, 0x42, 0xce, 0x60, 0x7b, 0x28, 0x44,
-	0x72, 0x63, 0x94, 0xa2, 0x5a, 0xf3, 0xab, 0x0b, 0xbf, 0x4e, 0x7b, 0x4e, 0x50, 0x9f, 0x72, 0x96,
-	0x2e, 0x62, 0x3e, 0x5d, 0x29, 0xe0, 0xaf, 0xa9, 0xfd, 0xf5, 0x9a, 0xe7, 0x35, 0x9e, 0x3b, 0xe4,
-	0x02, 0x1e, 0x8c, 0x12, 0xa4, 0xf2, 0x1f, 0x54, 0x6c, 0xf6, 0xbf, 0x40, 0x7b, 0x18, 0x45, 0xc3,
-	0x28, 0x92, 0xa8, 0xd4, 0xb9, 0xfe, 0x96, 0x92, 0x27, 0x35, 0x19, 0x27, 0xa8, 0x2b, 0x30, 0x15,
-	0xa2, 0x48, 0xec, 0xf4, 0xef, 0xa1, 0x63, 0x9c, 0x57, 0x05, 0xfe, 0xcb, 0xf0, 0xd1, 0xc3, 0x4f,
-	0x7b, 0x49, 0x36, 0x98, 0xfc, 0x39, 0x7a, 0x96, 0x7f, 0xa5, 0x60, 0x3f, 0x9b, 0x8d, 0xc9, 0xa6,
-	0xf9, 0x8d, 0xbf, 0xfc, 0x15, 0x00, 0x00, 0xff, 0xff, 0x8d, 0x65, 0x96, 0x68, 0xfe, 0x05, 0x00,
-	0x00,
+	// 605 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0xff, 0x6e, 0xd3, 0x30,
+	0x10, 0x5e, 0x57, 0x65, 0xda, 0x6e, 0xac, 0xb4, 0x66, 0x6c, 0xa5, 0x4c, 0x68, 0x8a, 0xa8, 0x88,
+	0x18, 0x0a, 0x68, 0x3c, 0x41, 0xb7, 0xc1, 0x98, 0x04, 0x68, 0x38, 0x9b, 0xd0, 0x40, 0x80, 0x5c,
+	0xfb, 0xd4, 0x46, 0x4b, 0x93, 0xc8, 0xf6, 0x7e, 0x89, 0x37, 0xe3, 0x35, 0x78, 0x0c, 0x5e, 0x02,
+	0xc5, 0x49, 0xb6, 0x74, 0x23, 0x78, 0x82, 0xbf, 0xea, 0xb3, 0xbf, 0xfb, 0xbe, 0xef, 0xee, 0xec,
+	0x06, 0x5a, 0x82, 0xc9, 0x63, 0x15, 0x0a, 0xf4, 0x53, 0x99, 0xe8, 0x84, 0xdc, 0x4f, 0x43, 0xc9,
+	0x34, 0xfa, 0x67, 0x2c, 0x8a, 0x50, 0xfb, 0x4a, 0x1c, 0xfb, 0x32, 0xe5, 0xbd, 0x25, 0x85, 0xf2,
+	0x34, 0xe4, 0x05, 0xca, 0xfd, 0x0e, 0x9d, 0x9d, 0x22, 0xef, 0x1d, 0x6a, 0x16, 0x68, 0xa6, 0x91,
+	0x3c, 0x83, 0x8e, 0x62, 0x69, 0x14, 0xc6, 0xa3, 0x01, 0xd7, 0xe1, 0x29, 0xd3, 0x61, 0x12, 0x77,
+	0x1b, 0xeb, 0x0d, 0xcf, 0xa1, 0x37, 0x0f, 0x48, 0x0f, 0xe6, 0x87, 0x92, 0xc5, 0x7c, 0xbc, 0xb7,
+	0xd3, 0x9d, 0x5d, 0x6f, 0x78, 0x0b, 0xf4, 0x32, 0x26, 0x6b, 0xb0, 0xc0, 0xc7, 0x2c, 0x8c, 0xdf,
+	0xb3, 0x09, 0x76, 0x9b, 0xe6, 0xf0, 0x6a, 0xc3, 0xed, 0xc3, 0x52, 0x29, 0xbe, 0x15, 0x25, 0xfc,
+	0x98, 0x2c, 0x83, 0x33, 0xcc, 0x16, 0x46, 0x6c, 0x81, 0xe6, 0x81, 0xdb, 0x87, 0xce, 0x14, 0x4c,
+	0x1d, 0xd2, 0xb7, 0xa4, 0x0d, 0xcd, 0x13, 0x19, 0x15, 0xc0, 0x6c, 0xe9, 0x6e, 0xc3, 0x6a, 0x09,
+	0x3b, 0x90, 0x2c, 0x56, 0x8c, 0x67, 0xf6, 0x0c, 0x78, 0x05, 0xe6, 0xc6, 0x18, 0x8e, 0xc6, 0xba,
+	0xa8, 0xa2, 0x88, 0x4a, 0x92, 0xd9, 0x2b, 0x12, 0x0f, 0x5a, 0x25, 0xc9, 0x9b, 0x1c, 0x53, 0x93,
+	0xeb, 0x1e, 0xc1, 0xbd, 0x12, 0xf9, 0x6a, 0x92, 0xea, 0x8b, 0xdc, 0x5a, 0xad, 0xd4, 0x32, 0x38,
+	0x71, 0x12, 0x73, 0x34, 0x62, 0x0e, 0xcd, 0x83, 0x6c, 0x97, 0x27, 0x27, 0xb1, 0x36, 0xbd, 0x71,
+	0x68, 0x1e, 0xb8, 0x3f, 0x1b, 0xb0, 0x5c, 0x72, 0x07, 0x27, 0x43, 0x2d, 0x11, 0x69, 0x92, 0x68,
+	0x45, 0x02, 0x68, 0xab, 0x71, 0x88, 0x91, 0x40, 0xb1, 0x9f, 0x8d, 0x8f, 0x27, 0x79, 0x07, 0x5a,
+	0x9b, 0x4f, 0xfc, 0x3f, 0x8e, 0xdb, 0x0f, 0xae, 0xc1, 0xe9, 0x0d, 0x02, 0xf2, 0x08, 0x40, 0x69,
+	0x26, 0xf5, 0x5e, 0x2c, 0xf0, 0xdc, 0xd8, 0x5b, 0xa2, 0x95, 0x1d, 0xf2, 0x1a, 0xee, 0xa8, 0x8a,
+	0x89, 0x6e, 0x73, 0xbd, 0xe9, 0x2d, 0x6e, 0xba, 0x75, 0x82, 0x57, 0x50, 0x3a, 0x95, 0xb7, 0xf9,
+	0x6b, 0x1e, 0xda, 0x97, 0x55, 0x69, 0x89, 0x6c, 0x82, 0x92, 0x7c, 0x00, 0x87, 0xa2, 0x42, 0x4d,
+	0xbc, 0x1a, 0xbe, 0x1b, 0xb7, 0xb3, 0xb7, 0x56, 0x83, 0x34, 0x53, 0x70, 0x67, 0xc8, 0x67, 0xe8,
+	0x04, 0x9a, 0x8d, 0x8a, 0xbb, 0x92, 0x2b, 0x91, 0xc7, 0x16, 0x7a, 0x03, 0xb6, 0x51, 0x7b, 0x0d,
+	0xf2, 0x11, 0x16, 0x2b, 0xe4, 0x56, 0xd7, 0x97, 0xf7, 0xd5, 0xea, 0xfa, 0xdb, 0x94, 0xeb, 0x6d,
+	0x89, 0xd9, 0x43, 0x7c, 0x6a, 0xa1, 0xaf, 0x5c, 0x3c, 0xab, 0xc0, 0x10, 0x56, 0x8d, 0x40, 0xf5,
+	0x6d, 0x14, 0xcd, 0xe9, 0xd7, 0xa4, 0x52, 0x76, 0x56, 0x41, 0xdf, 0xa2, 0x3b, 0xbc, 0x28, 0xa2,
+	0xaa, 0x41, 0x7c, 0x4b, 0x11, 0xd7, 0x1e, 0xab, 0xb5, 0x90, 0x03, 0x58, 0x1c, 0xa4, 0x69, 0x74,
+	0x61, 0x94, 0x04, 0xe9, 0x5b, 0xe8, 0xf3, 0x67, 0x7c, 0x9b, 0xf6, 0xec, 0xa2, 0xde, 0x8b, 0x79,
+	0x32, 0x09, 0xe3, 0xd1, 0x54, 0x01, 0x7f, 0x4d, 0xed, 0xdd, 0xae, 0x79, 0xee, 0xcc, 0x8b, 0x06,
+	0x39, 0x82, 0x07, 0xdb, 0x11, 0x32, 0xf9, 0x0f, 0x2a, 0x36, 0xfb, 0x5f, 0xa0, 0x35, 0x10, 0x62,
+	0x20, 0x84, 0x44, 0xa5, 0x0e, 0xf5, 0x79, 0x42, 0x36, 0x6a, 0x32, 0x76, 0x51, 0x57, 0x60, 0x8a,
+	0x62, 0x1a, 0xd9, 0xe9, 0xf7, 0xa1, 0x6d, 0x9c, 0x57, 0x05, 0xfe, 0xcf, 0xf0, 0x57, 0xb8, 0x1b,
+	0xa0, 0x9e, 0xfa, 0x77, 0xdb, 0xb0, 0x4c, 0xb2, 0x0a, 0xb6, 0xf1, 0x6f, 0x3d, 0xfc, 0xb4, 0x12,
+	0x65, 0x83, 0xcf, 0x8f, 0xc5, 0xf3, 0xfc, 0x57, 0xa6, 0xfc, 0xc7, 0xec, 0xcc, 0x70, 0xce, 0x7c,
+	0xfc, 0x5e, 0xfe, 0x1e, 0x00, 0x84, 0x8c, 0x33, 0x56, 0x34, 0x07, 0x00, 0x00,
 }
@@ -85,6 +85,9 @@ type DarksideStreamerClient interface {
 	AddAddressUtxo(ctx context.Context, in *GetAddressUtxosReply, opts ...grpc.CallOption) (*Empty, error)
 	// Clear the list of GetAddressUtxos entries (can't fail)
 	ClearAddressUtxo(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Empty, error)
+	// Sets the subtree roots cache (for GetSubtreeRoots),
+	// replacing any existing entries
+	SetSubtreeRoots(ctx context.Context, in *DarksideSubtreeRoots, opts ...grpc.CallOption) (*Empty, error)
 }
 
 type darksideStreamerClient struct {
@@ -267,6 +270,15 @@ func (c *darksideStreamerClient) ClearAddressUtxo(ctx context.Context, in *Empty
 	return out, nil
 }
 
+func (c *darksideStreamerClient) SetSubtreeRoots(ctx context.Context, in *DarksideSubtreeRoots, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.DarksideStreamer/SetSubtreeRoots", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DarksideStreamerServer is the server API for DarksideStreamer service.
 // All implementations must embed UnimplementedDarksideStreamerServer
 // for forward compatibility
@@ -334,6 +346,9 @@ type DarksideStreamerServer interface {
 	AddAddressUtxo(context.Context, *GetAddressUtxosReply) (*Empty, error)
 	// Clear the list of GetAddressUtxos entries (can't fail)
 	ClearAddressUtxo(context.Context, *Empty) (*Empty, error)
+	// Sets the subtree roots cache (for GetSubtreeRoots),
+	// replacing any existing entries
+	SetSubtreeRoots(context.Context, *DarksideSubtreeRoots) (*Empty, error)
 	mustEmbedUnimplementedDarksideStreamerServer()
 }
 
@@ -374,6 +389,9 @@ func (UnimplementedDarksideStreamerServer) AddAddressUtxo(context.Context, *GetA
 func (UnimplementedDarksideStreamerServer) ClearAddressUtxo(context.Context, *Empty) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ClearAddressUtxo not implemented")
 }
+func (UnimplementedDarksideStreamerServer) SetSubtreeRoots(context.Context, *DarksideSubtreeRoots) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetSubtreeRoots not implemented")
+}
 func (UnimplementedDarksideStreamerServer) mustEmbedUnimplementedDarksideStreamerServer() {}
 
 // UnsafeDarksideStreamerServer may be embedded to opt out of forward compatibility for this service.
@@ -604,6 +622,24 @@ func _DarksideStreamer_ClearAddressUtxo_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _DarksideStreamer_SetSubtreeRoots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DarksideSubtreeRoots)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DarksideStreamerServer).SetSubtreeRoots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pirate.wallet.sdk.rpc.DarksideStreamer/SetSubtreeRoots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DarksideStreamerServer).SetSubtreeRoots(ctx, req.(*DarksideSubtreeRoots))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // DarksideStreamer_ServiceDesc is the grpc.ServiceDesc for DarksideStreamer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -643,6 +679,10 @@ var DarksideStreamer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "ClearAddressUtxo",
 			Handler:    _DarksideStreamer_ClearAddressUtxo_Handler,
 		},
+		{
+			MethodName: "SetSubtreeRoots",
+			Handler:    _DarksideStreamer_SetSubtreeRoots_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -17,12 +17,14 @@ It is generated from these files:
 It has these top-level messages:
 	BlockID
 	BlockRange
+	BlockRangeContinuation
 	TxFilter
 	RawTransaction
 	SendResponse
 	ChainSpec
 	Empty
 	LightdInfo
+	NetworkUpgrade
 	TransparentAddressBlockFilter
 	Duration
 	PingResponse
@@ -32,10 +34,27 @@ It has these top-level messages:
 	Exclude
 	TreeState
 	GetAddressUtxosArg
+	UtxoCursor
 	GetAddressUtxosReply
 	GetAddressUtxosReplyList
 	PriceRequest
 	PriceResponse
+	BlockHeader
+	GetSubtreeRootsArg
+	SubtreeRoot
+	GetAddressHistoryArg
+	AddressHistoryEntry
+	GetAddressHistoryReply
+	GetTransactionsReply
+	TransactionStatus
+	BlockRollback
+	BlockSubscriptionEvent
+	BlockLocator
+	MempoolEvent
+	MempoolInfo
+	GetBlockGroupsArg
+	BlockGroup
+	BlockGroupList
 */
 package walletrpc
 
@@ -54,6 +73,118 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+// SendRejection is why SendTransaction didn't relay a transaction.
+type SendRejection int32
+
+const (
+	SendRejection_notRejected          SendRejection = 0
+	SendRejection_malformed            SendRejection = 1
+	SendRejection_tooLarge             SendRejection = 2
+	SendRejection_expired              SendRejection = 3
+	SendRejection_expiringSoon         SendRejection = 4
+	SendRejection_wrongConsensusBranch SendRejection = 5
+	SendRejection_rejectedByNode       SendRejection = 6
+)
+
+var SendRejection_name = map[int32]string{
+	0: "notRejected",
+	1: "malformed",
+	2: "tooLarge",
+	3: "expired",
+	4: "expiringSoon",
+	5: "wrongConsensusBranch",
+	6: "rejectedByNode",
+}
+var SendRejection_value = map[string]int32{
+	"notRejected":          0,
+	"malformed":            1,
+	"tooLarge":             2,
+	"expired":              3,
+	"expiringSoon":         4,
+	"wrongConsensusBranch": 5,
+	"rejectedByNode":       6,
+}
+
+func (x SendRejection) String() string {
+	return proto.EnumName(SendRejection_name, int32(x))
+}
+func (SendRejection) EnumDescriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{0} }
+
+// ShieldedProtocol identifies a shielded pool (note commitment tree).
+type ShieldedProtocol int32
+
+const (
+	ShieldedProtocol_sapling ShieldedProtocol = 0
+	ShieldedProtocol_orchard ShieldedProtocol = 1
+)
+
+var ShieldedProtocol_name = map[int32]string{
+	0: "sapling",
+	1: "orchard",
+}
+var ShieldedProtocol_value = map[string]int32{
+	"sapling": 0,
+	"orchard": 1,
+}
+
+func (x ShieldedProtocol) String() string {
+	return proto.EnumName(ShieldedProtocol_name, int32(x))
+}
+func (ShieldedProtocol) EnumDescriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{1} }
+
+// TransactionState is what's known about a transaction's progress.
+type TransactionState int32
+
+const (
+	TransactionState_notFound       TransactionState = 0
+	TransactionState_inMempool      TransactionState = 1
+	TransactionState_mined          TransactionState = 2
+	TransactionState_expiredUnmined TransactionState = 3
+)
+
+var TransactionState_name = map[int32]string{
+	0: "notFound",
+	1: "inMempool",
+	2: "mined",
+	3: "expiredUnmined",
+}
+var TransactionState_value = map[string]int32{
+	"notFound":       0,
+	"inMempool":      1,
+	"mined":          2,
+	"expiredUnmined": 3,
+}
+
+func (x TransactionState) String() string {
+	return proto.EnumName(TransactionState_name, int32(x))
+}
+func (TransactionState) EnumDescriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{2} }
+
+// MempoolEventType is what happened to a mempool transaction.
+type MempoolEventType int32
+
+const (
+	MempoolEventType_mempoolAdded   MempoolEventType = 0
+	MempoolEventType_mempoolMined   MempoolEventType = 1
+	MempoolEventType_mempoolEvicted MempoolEventType = 2
+)
+
+var MempoolEventType_name = map[int32]string{
+	0: "mempoolAdded",
+	1: "mempoolMined",
+	2: "mempoolEvicted",
+}
+var MempoolEventType_value = map[string]int32{
+	"mempoolAdded":   0,
+	"mempoolMined":   1,
+	"mempoolEvicted": 2,
+}
+
+func (x MempoolEventType) String() string {
+	return proto.EnumName(MempoolEventType_name, int32(x))
+}
+func (MempoolEventType) EnumDescriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{3} }
+
 // A BlockID message contains identifiers to select a block: a height or a
 // hash. Specification by hash is not implemented, but may be in the future.
 type BlockID struct {
@@ -82,9 +213,14 @@ func (m *BlockID) GetHash() []byte {
 
 // BlockRange specifies a series of blocks from start to end inclusive.
 // Both BlockIDs must be heights; specification by hash is not yet supported.
+// The server may limit the number of blocks per call; if it cuts a range
+// short, it returns a BlockRangeContinuation (serialized) in the
+// "continuation-bin" trailer. To continue the range (after the limit, or
+// after losing the connection), repeat the call with resume set.
 type BlockRange struct {
-	Start *BlockID `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
-	End   *BlockID `protobuf:"bytes,2,opt,name=end" json:"end,omitempty"`
+	Start  *BlockID                `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
+	End    *BlockID                `protobuf:"bytes,2,opt,name=end" json:"end,omitempty"`
+	Resume *BlockRangeContinuation `protobuf:"bytes,3,opt,name=resume" json:"resume,omitempty"`
 }
 
 func (m *BlockRange) Reset()                    { *m = BlockRange{} }
@@ -106,9 +242,45 @@ func (m *BlockRange) GetEnd() *BlockID {
 	return nil
 }
 
+func (m *BlockRange) GetResume() *BlockRangeContinuation {
+	if m != nil {
+		return m.Resume
+	}
+	return nil
+}
+
+// A BlockRangeContinuation is where to resume a range: the next height to
+// return, and the hash of the last block received (the previous one in
+// the range's order). If that block is no longer in the best chain (there
+// has been a reorg), the call fails (with ABORTED).
+type BlockRangeContinuation struct {
+	Height   uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+	PrevHash []byte `protobuf:"bytes,2,opt,name=prevHash,proto3" json:"prevHash,omitempty"`
+}
+
+func (m *BlockRangeContinuation) Reset()                    { *m = BlockRangeContinuation{} }
+func (m *BlockRangeContinuation) String() string            { return proto.CompactTextString(m) }
+func (*BlockRangeContinuation) ProtoMessage()               {}
+func (*BlockRangeContinuation) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{2} }
+
+func (m *BlockRangeContinuation) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *BlockRangeContinuation) GetPrevHash() []byte {
+	if m != nil {
+		return m.PrevHash
+	}
+	return nil
+}
+
 // A TxFilter contains the information needed to identify a particular
 // transaction: either a block and an index, or a direct transaction hash.
-// Currently, only specification by hash is supported.
+// The block may be given by height, hash, or both; index is the position
+// within the full block (the same as CompactTx.index).
 type TxFilter struct {
 	Block *BlockID `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
 	Index uint64   `protobuf:"varint,2,opt,name=index" json:"index,omitempty"`
@@ -118,7 +290,7 @@ type TxFilter struct {
 func (m *TxFilter) Reset()                    { *m = TxFilter{} }
 func (m *TxFilter) String() string            { return proto.CompactTextString(m) }
 func (*TxFilter) ProtoMessage()               {}
-func (*TxFilter) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{2} }
+func (*TxFilter) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{3} }
 
 func (m *TxFilter) GetBlock() *BlockID {
 	if m != nil {
@@ -152,7 +324,7 @@ type RawTransaction struct {
 func (m *RawTransaction) Reset()                    { *m = RawTransaction{} }
 func (m *RawTransaction) String() string            { return proto.CompactTextString(m) }
 func (*RawTransaction) ProtoMessage()               {}
-func (*RawTransaction) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{3} }
+func (*RawTransaction) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{4} }
 
 func (m *RawTransaction) GetData() []byte {
 	if m != nil {
@@ -168,18 +340,21 @@ func (m *RawTransaction) GetHeight() uint64 {
 	return 0
 }
 
-// A SendResponse encodes an error code and a string. It is currently used
-// only by SendTransaction(). If error code is zero, the operation was
-// successful; if non-zero, it and the message specify the failure.
+// A SendResponse is the result of SendTransaction(). If the transaction was
+// accepted, errorCode is zero and txid is set; otherwise rejection says why,
+// and errorCode and errorMessage are pirated's error (or, if the transaction
+// was rejected before it was sent to pirated, the error pirated would give).
 type SendResponse struct {
-	ErrorCode    int32  `protobuf:"varint,1,opt,name=errorCode" json:"errorCode,omitempty"`
-	ErrorMessage string `protobuf:"bytes,2,opt,name=errorMessage" json:"errorMessage,omitempty"`
+	ErrorCode    int32         `protobuf:"varint,1,opt,name=errorCode" json:"errorCode,omitempty"`
+	ErrorMessage string        `protobuf:"bytes,2,opt,name=errorMessage" json:"errorMessage,omitempty"`
+	Rejection    SendRejection `protobuf:"varint,3,opt,name=rejection,enum=pirate.wallet.sdk.rpc.SendRejection" json:"rejection,omitempty"`
+	Txid         []byte        `protobuf:"bytes,4,opt,name=txid,proto3" json:"txid,omitempty"`
 }
 
 func (m *SendResponse) Reset()                    { *m = SendResponse{} }
 func (m *SendResponse) String() string            { return proto.CompactTextString(m) }
 func (*SendResponse) ProtoMessage()               {}
-func (*SendResponse) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{4} }
+func (*SendResponse) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{5} }
 
 func (m *SendResponse) GetErrorCode() int32 {
 	if m != nil {
@@ -195,6 +370,20 @@ func (m *SendResponse) GetErrorMessage() string {
 	return ""
 }
 
+func (m *SendResponse) GetRejection() SendRejection {
+	if m != nil {
+		return m.Rejection
+	}
+	return SendRejection_notRejected
+}
+
+func (m *SendResponse) GetTxid() []byte {
+	if m != nil {
+		return m.Txid
+	}
+	return nil
+}
+
 // Chainspec is a placeholder to allow specification of a particular chain fork.
 type ChainSpec struct {
 }
@@ -202,7 +391,7 @@ type ChainSpec struct {
 func (m *ChainSpec) Reset()                    { *m = ChainSpec{} }
 func (m *ChainSpec) String() string            { return proto.CompactTextString(m) }
 func (*ChainSpec) ProtoMessage()               {}
-func (*ChainSpec) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{5} }
+func (*ChainSpec) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{6} }
 
 // Empty is for gRPCs that take no arguments, currently only GetLightdInfo.
 type Empty struct {
@@ -211,31 +400,39 @@ type Empty struct {
 func (m *Empty) Reset()                    { *m = Empty{} }
 func (m *Empty) String() string            { return proto.CompactTextString(m) }
 func (*Empty) ProtoMessage()               {}
-func (*Empty) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{6} }
+func (*Empty) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{7} }
 
 // LightdInfo returns various information about this lightwalletd instance
 // and the state of the blockchain.
 type LightdInfo struct {
-	Version                 string `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
-	Vendor                  string `protobuf:"bytes,2,opt,name=vendor" json:"vendor,omitempty"`
-	TaddrSupport            bool   `protobuf:"varint,3,opt,name=taddrSupport" json:"taddrSupport,omitempty"`
-	ChainName               string `protobuf:"bytes,4,opt,name=chainName" json:"chainName,omitempty"`
-	SaplingActivationHeight uint64 `protobuf:"varint,5,opt,name=saplingActivationHeight" json:"saplingActivationHeight,omitempty"`
-	ConsensusBranchId       string `protobuf:"bytes,6,opt,name=consensusBranchId" json:"consensusBranchId,omitempty"`
-	BlockHeight             uint64 `protobuf:"varint,7,opt,name=blockHeight" json:"blockHeight,omitempty"`
-	GitCommit               string `protobuf:"bytes,8,opt,name=gitCommit" json:"gitCommit,omitempty"`
-	Branch                  string `protobuf:"bytes,9,opt,name=branch" json:"branch,omitempty"`
-	BuildDate               string `protobuf:"bytes,10,opt,name=buildDate" json:"buildDate,omitempty"`
-	BuildUser               string `protobuf:"bytes,11,opt,name=buildUser" json:"buildUser,omitempty"`
-	EstimatedHeight         uint64 `protobuf:"varint,12,opt,name=estimatedHeight" json:"estimatedHeight,omitempty"`
-	PiratedBuild            string `protobuf:"bytes,13,opt,name=piratedBuild" json:"piratedBuild,omitempty"`
-	PiratedSubversion       string `protobuf:"bytes,14,opt,name=piratedSubversion" json:"piratedSubversion,omitempty"`
+	Version                 string            `protobuf:"bytes,1,opt,name=version" json:"version,omitempty"`
+	Vendor                  string            `protobuf:"bytes,2,opt,name=vendor" json:"vendor,omitempty"`
+	TaddrSupport            bool              `protobuf:"varint,3,opt,name=taddrSupport" json:"taddrSupport,omitempty"`
+	ChainName               string            `protobuf:"bytes,4,opt,name=chainName" json:"chainName,omitempty"`
+	SaplingActivationHeight uint64            `protobuf:"varint,5,opt,name=saplingActivationHeight" json:"saplingActivationHeight,omitempty"`
+	ConsensusBranchId       string            `protobuf:"bytes,6,opt,name=consensusBranchId" json:"consensusBranchId,omitempty"`
+	BlockHeight             uint64            `protobuf:"varint,7,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	GitCommit               string            `protobuf:"bytes,8,opt,name=gitCommit" json:"gitCommit,omitempty"`
+	Branch                  string            `protobuf:"bytes,9,opt,name=branch" json:"branch,omitempty"`
+	BuildDate               string            `protobuf:"bytes,10,opt,name=buildDate" json:"buildDate,omitempty"`
+	BuildUser               string            `protobuf:"bytes,11,opt,name=buildUser" json:"buildUser,omitempty"`
+	EstimatedHeight         uint64            `protobuf:"varint,12,opt,name=estimatedHeight" json:"estimatedHeight,omitempty"`
+	PiratedBuild            string            `protobuf:"bytes,13,opt,name=piratedBuild" json:"piratedBuild,omitempty"`
+	PiratedSubversion       string            `protobuf:"bytes,14,opt,name=piratedSubversion" json:"piratedSubversion,omitempty"`
+	Upgrades                []*NetworkUpgrade `protobuf:"bytes,15,rep,name=upgrades" json:"upgrades,omitempty"`
+	OrchardActivationHeight uint64            `protobuf:"varint,16,opt,name=orchardActivationHeight" json:"orchardActivationHeight,omitempty"`
+	FirstCachedHeight       uint64            `protobuf:"varint,17,opt,name=firstCachedHeight" json:"firstCachedHeight,omitempty"`
+	LatestCachedHeight      uint64            `protobuf:"varint,18,opt,name=latestCachedHeight" json:"latestCachedHeight,omitempty"`
+	Features                []string          `protobuf:"bytes,19,rep,name=features" json:"features,omitempty"`
+	DonationAddress         string            `protobuf:"bytes,20,opt,name=donationAddress" json:"donationAddress,omitempty"`
+	Contact                 string            `protobuf:"bytes,21,opt,name=contact" json:"contact,omitempty"`
+	EnabledMethods          []string          `protobuf:"bytes,22,rep,name=enabledMethods" json:"enabledMethods,omitempty"`
 }
 
 func (m *LightdInfo) Reset()                    { *m = LightdInfo{} }
 func (m *LightdInfo) String() string            { return proto.CompactTextString(m) }
 func (*LightdInfo) ProtoMessage()               {}
-func (*LightdInfo) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{7} }
+func (*LightdInfo) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{8} }
 
 func (m *LightdInfo) GetVersion() string {
 	if m != nil {
@@ -335,8 +532,108 @@ func (m *LightdInfo) GetPiratedSubversion() string {
 	return ""
 }
 
+func (m *LightdInfo) GetUpgrades() []*NetworkUpgrade {
+	if m != nil {
+		return m.Upgrades
+	}
+	return nil
+}
+
+func (m *LightdInfo) GetOrchardActivationHeight() uint64 {
+	if m != nil {
+		return m.OrchardActivationHeight
+	}
+	return 0
+}
+
+func (m *LightdInfo) GetFirstCachedHeight() uint64 {
+	if m != nil {
+		return m.FirstCachedHeight
+	}
+	return 0
+}
+
+func (m *LightdInfo) GetLatestCachedHeight() uint64 {
+	if m != nil {
+		return m.LatestCachedHeight
+	}
+	return 0
+}
+
+func (m *LightdInfo) GetFeatures() []string {
+	if m != nil {
+		return m.Features
+	}
+	return nil
+}
+
+func (m *LightdInfo) GetDonationAddress() string {
+	if m != nil {
+		return m.DonationAddress
+	}
+	return ""
+}
+
+func (m *LightdInfo) GetContact() string {
+	if m != nil {
+		return m.Contact
+	}
+	return ""
+}
+
+func (m *LightdInfo) GetEnabledMethods() []string {
+	if m != nil {
+		return m.EnabledMethods
+	}
+	return nil
+}
+
+// A NetworkUpgrade is one of the network upgrades pirated reports.
+type NetworkUpgrade struct {
+	Name             string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	BranchId         string `protobuf:"bytes,2,opt,name=branchId" json:"branchId,omitempty"`
+	ActivationHeight uint64 `protobuf:"varint,3,opt,name=activationHeight" json:"activationHeight,omitempty"`
+	Status           string `protobuf:"bytes,4,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *NetworkUpgrade) Reset()                    { *m = NetworkUpgrade{} }
+func (m *NetworkUpgrade) String() string            { return proto.CompactTextString(m) }
+func (*NetworkUpgrade) ProtoMessage()               {}
+func (*NetworkUpgrade) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{9} }
+
+func (m *NetworkUpgrade) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *NetworkUpgrade) GetBranchId() string {
+	if m != nil {
+		return m.BranchId
+	}
+	return ""
+}
+
+func (m *NetworkUpgrade) GetActivationHeight() uint64 {
+	if m != nil {
+		return m.ActivationHeight
+	}
+	return 0
+}
+
+func (m *NetworkUpgrade) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
 // TransparentAddressBlockFilter restricts the results to the given address
-// or block range.
+// or block range. The server may limit the number of transactions per call;
+// if it cuts the results short (after a whole block), it returns a
+// BlockRangeContinuation in the "continuation-bin" trailer, as GetBlockRange
+// does. To continue, repeat the call with range.resume set.
 type TransparentAddressBlockFilter struct {
 	Address string      `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
 	Range   *BlockRange `protobuf:"bytes,2,opt,name=range" json:"range,omitempty"`
@@ -345,7 +642,7 @@ type TransparentAddressBlockFilter struct {
 func (m *TransparentAddressBlockFilter) Reset()                    { *m = TransparentAddressBlockFilter{} }
 func (m *TransparentAddressBlockFilter) String() string            { return proto.CompactTextString(m) }
 func (*TransparentAddressBlockFilter) ProtoMessage()               {}
-func (*TransparentAddressBlockFilter) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{8} }
+func (*TransparentAddressBlockFilter) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{10} }
 
 func (m *TransparentAddressBlockFilter) GetAddress() string {
 	if m != nil {
@@ -371,7 +668,7 @@ type Duration struct {
 func (m *Duration) Reset()                    { *m = Duration{} }
 func (m *Duration) String() string            { return proto.CompactTextString(m) }
 func (*Duration) ProtoMessage()               {}
-func (*Duration) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{9} }
+func (*Duration) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{11} }
 
 func (m *Duration) GetIntervalUs() int64 {
 	if m != nil {
@@ -391,7 +688,7 @@ type PingResponse struct {
 func (m *PingResponse) Reset()                    { *m = PingResponse{} }
 func (m *PingResponse) String() string            { return proto.CompactTextString(m) }
 func (*PingResponse) ProtoMessage()               {}
-func (*PingResponse) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{10} }
+func (*PingResponse) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{12} }
 
 func (m *PingResponse) GetEntry() int64 {
 	if m != nil {
@@ -414,7 +711,7 @@ type Address struct {
 func (m *Address) Reset()                    { *m = Address{} }
 func (m *Address) String() string            { return proto.CompactTextString(m) }
 func (*Address) ProtoMessage()               {}
-func (*Address) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{11} }
+func (*Address) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{13} }
 
 func (m *Address) GetAddress() string {
 	if m != nil {
@@ -430,7 +727,7 @@ type AddressList struct {
 func (m *AddressList) Reset()                    { *m = AddressList{} }
 func (m *AddressList) String() string            { return proto.CompactTextString(m) }
 func (*AddressList) ProtoMessage()               {}
-func (*AddressList) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{12} }
+func (*AddressList) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{14} }
 
 func (m *AddressList) GetAddresses() []string {
 	if m != nil {
@@ -446,7 +743,7 @@ type Balance struct {
 func (m *Balance) Reset()                    { *m = Balance{} }
 func (m *Balance) String() string            { return proto.CompactTextString(m) }
 func (*Balance) ProtoMessage()               {}
-func (*Balance) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{13} }
+func (*Balance) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{15} }
 
 func (m *Balance) GetValueZat() int64 {
 	if m != nil {
@@ -462,7 +759,7 @@ type Exclude struct {
 func (m *Exclude) Reset()                    { *m = Exclude{} }
 func (m *Exclude) String() string            { return proto.CompactTextString(m) }
 func (*Exclude) ProtoMessage()               {}
-func (*Exclude) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{14} }
+func (*Exclude) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{16} }
 
 func (m *Exclude) GetTxid() [][]byte {
 	if m != nil {
@@ -484,7 +781,7 @@ type TreeState struct {
 func (m *TreeState) Reset()                    { *m = TreeState{} }
 func (m *TreeState) String() string            { return proto.CompactTextString(m) }
 func (*TreeState) ProtoMessage()               {}
-func (*TreeState) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{15} }
+func (*TreeState) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{17} }
 
 func (m *TreeState) GetNetwork() string {
 	if m != nil {
@@ -528,18 +825,24 @@ func (m *TreeState) GetOrchardTree() string {
 	return ""
 }
 
-// Results are sorted by height, which makes it easy to issue another
-// request that picks up from where the previous left off.
+// Results are sorted by (height, txid, index), with unconfirmed outputs
+// last, which makes it easy to issue another request that picks up from
+// where the previous left off: pass the cursor of the last result received
+// (or the reply's nextCursor, or, from GetAddressUtxosStream, the
+// UtxoCursor (serialized) in the "utxo-cursor-bin" trailer) as startAfter.
 type GetAddressUtxosArg struct {
-	Addresses   []string `protobuf:"bytes,1,rep,name=addresses" json:"addresses,omitempty"`
-	StartHeight uint64   `protobuf:"varint,2,opt,name=startHeight" json:"startHeight,omitempty"`
-	MaxEntries  uint32   `protobuf:"varint,3,opt,name=maxEntries" json:"maxEntries,omitempty"`
+	Addresses           []string    `protobuf:"bytes,1,rep,name=addresses" json:"addresses,omitempty"`
+	StartHeight         uint64      `protobuf:"varint,2,opt,name=startHeight" json:"startHeight,omitempty"`
+	MaxEntries          uint32      `protobuf:"varint,3,opt,name=maxEntries" json:"maxEntries,omitempty"`
+	ExcludeMempoolSpent bool        `protobuf:"varint,4,opt,name=excludeMempoolSpent" json:"excludeMempoolSpent,omitempty"`
+	IncludeMempool      bool        `protobuf:"varint,5,opt,name=includeMempool" json:"includeMempool,omitempty"`
+	StartAfter          *UtxoCursor `protobuf:"bytes,6,opt,name=startAfter" json:"startAfter,omitempty"`
 }
 
 func (m *GetAddressUtxosArg) Reset()                    { *m = GetAddressUtxosArg{} }
 func (m *GetAddressUtxosArg) String() string            { return proto.CompactTextString(m) }
 func (*GetAddressUtxosArg) ProtoMessage()               {}
-func (*GetAddressUtxosArg) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{16} }
+func (*GetAddressUtxosArg) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{18} }
 
 func (m *GetAddressUtxosArg) GetAddresses() []string {
 	if m != nil {
@@ -562,6 +865,60 @@ func (m *GetAddressUtxosArg) GetMaxEntries() uint32 {
 	return 0
 }
 
+func (m *GetAddressUtxosArg) GetExcludeMempoolSpent() bool {
+	if m != nil {
+		return m.ExcludeMempoolSpent
+	}
+	return false
+}
+
+func (m *GetAddressUtxosArg) GetIncludeMempool() bool {
+	if m != nil {
+		return m.IncludeMempool
+	}
+	return false
+}
+
+func (m *GetAddressUtxosArg) GetStartAfter() *UtxoCursor {
+	if m != nil {
+		return m.StartAfter
+	}
+	return nil
+}
+
+// A UtxoCursor is a position in the GetAddressUtxos result order.
+type UtxoCursor struct {
+	Height uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+	Txid   []byte `protobuf:"bytes,2,opt,name=txid,proto3" json:"txid,omitempty"`
+	Index  int32  `protobuf:"varint,3,opt,name=index" json:"index,omitempty"`
+}
+
+func (m *UtxoCursor) Reset()                    { *m = UtxoCursor{} }
+func (m *UtxoCursor) String() string            { return proto.CompactTextString(m) }
+func (*UtxoCursor) ProtoMessage()               {}
+func (*UtxoCursor) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{19} }
+
+func (m *UtxoCursor) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *UtxoCursor) GetTxid() []byte {
+	if m != nil {
+		return m.Txid
+	}
+	return nil
+}
+
+func (m *UtxoCursor) GetIndex() int32 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
 type GetAddressUtxosReply struct {
 	Address  string `protobuf:"bytes,6,opt,name=address" json:"address,omitempty"`
 	Txid     []byte `protobuf:"bytes,1,opt,name=txid,proto3" json:"txid,omitempty"`
@@ -574,7 +931,7 @@ type GetAddressUtxosReply struct {
 func (m *GetAddressUtxosReply) Reset()                    { *m = GetAddressUtxosReply{} }
 func (m *GetAddressUtxosReply) String() string            { return proto.CompactTextString(m) }
 func (*GetAddressUtxosReply) ProtoMessage()               {}
-func (*GetAddressUtxosReply) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{17} }
+func (*GetAddressUtxosReply) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{20} }
 
 func (m *GetAddressUtxosReply) GetAddress() string {
 	if m != nil {
@@ -620,12 +977,13 @@ func (m *GetAddressUtxosReply) GetHeight() uint64 {
 
 type GetAddressUtxosReplyList struct {
 	AddressUtxos []*GetAddressUtxosReply `protobuf:"bytes,1,rep,name=addressUtxos" json:"addressUtxos,omitempty"`
+	NextCursor   *UtxoCursor             `protobuf:"bytes,2,opt,name=nextCursor" json:"nextCursor,omitempty"`
 }
 
 func (m *GetAddressUtxosReplyList) Reset()                    { *m = GetAddressUtxosReplyList{} }
 func (m *GetAddressUtxosReplyList) String() string            { return proto.CompactTextString(m) }
 func (*GetAddressUtxosReplyList) ProtoMessage()               {}
-func (*GetAddressUtxosReplyList) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{18} }
+func (*GetAddressUtxosReplyList) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{21} }
 
 func (m *GetAddressUtxosReplyList) GetAddressUtxos() []*GetAddressUtxosReply {
 	if m != nil {
@@ -634,6 +992,13 @@ func (m *GetAddressUtxosReplyList) GetAddressUtxos() []*GetAddressUtxosReply {
 	return nil
 }
 
+func (m *GetAddressUtxosReplyList) GetNextCursor() *UtxoCursor {
+	if m != nil {
+		return m.NextCursor
+	}
+	return nil
+}
+
 type PriceRequest struct {
 	// List of timestamps(in sec) at which the price is being requested
 	Timestamp uint64 `protobuf:"varint,1,opt,name=timestamp" json:"timestamp,omitempty"`
@@ -644,7 +1009,7 @@ type PriceRequest struct {
 func (m *PriceRequest) Reset()                    { *m = PriceRequest{} }
 func (m *PriceRequest) String() string            { return proto.CompactTextString(m) }
 func (*PriceRequest) ProtoMessage()               {}
-func (*PriceRequest) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{19} }
+func (*PriceRequest) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{22} }
 
 func (m *PriceRequest) GetTimestamp() uint64 {
 	if m != nil {
@@ -673,7 +1038,7 @@ type PriceResponse struct {
 func (m *PriceResponse) Reset()                    { *m = PriceResponse{} }
 func (m *PriceResponse) String() string            { return proto.CompactTextString(m) }
 func (*PriceResponse) ProtoMessage()               {}
-func (*PriceResponse) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{20} }
+func (*PriceResponse) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{23} }
 
 func (m *PriceResponse) GetTimestamp() int64 {
 	if m != nil {
@@ -696,15 +1061,603 @@ func (m *PriceResponse) GetPrice() float64 {
 	return 0
 }
 
+// A BlockHeader carries the full serialized header of a block, including
+// nBits and the Equihash solution, so that clients can verify the chain's
+// proof of work and the header's commitments (for example, the final
+// Sapling root) themselves.
+type BlockHeader struct {
+	Height uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+	Hash   []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+	Header []byte `protobuf:"bytes,3,opt,name=header,proto3" json:"header,omitempty"`
+}
+
+func (m *BlockHeader) Reset()                    { *m = BlockHeader{} }
+func (m *BlockHeader) String() string            { return proto.CompactTextString(m) }
+func (*BlockHeader) ProtoMessage()               {}
+func (*BlockHeader) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{24} }
+
+func (m *BlockHeader) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *BlockHeader) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+func (m *BlockHeader) GetHeader() []byte {
+	if m != nil {
+		return m.Header
+	}
+	return nil
+}
+
+type GetSubtreeRootsArg struct {
+	StartIndex       uint32           `protobuf:"varint,1,opt,name=startIndex" json:"startIndex,omitempty"`
+	ShieldedProtocol ShieldedProtocol `protobuf:"varint,2,opt,name=shieldedProtocol,enum=pirate.wallet.sdk.rpc.ShieldedProtocol" json:"shieldedProtocol,omitempty"`
+	MaxEntries       uint32           `protobuf:"varint,3,opt,name=maxEntries" json:"maxEntries,omitempty"`
+}
+
+func (m *GetSubtreeRootsArg) Reset()                    { *m = GetSubtreeRootsArg{} }
+func (m *GetSubtreeRootsArg) String() string            { return proto.CompactTextString(m) }
+func (*GetSubtreeRootsArg) ProtoMessage()               {}
+func (*GetSubtreeRootsArg) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{25} }
+
+func (m *GetSubtreeRootsArg) GetStartIndex() uint32 {
+	if m != nil {
+		return m.StartIndex
+	}
+	return 0
+}
+
+func (m *GetSubtreeRootsArg) GetShieldedProtocol() ShieldedProtocol {
+	if m != nil {
+		return m.ShieldedProtocol
+	}
+	return ShieldedProtocol_sapling
+}
+
+func (m *GetSubtreeRootsArg) GetMaxEntries() uint32 {
+	if m != nil {
+		return m.MaxEntries
+	}
+	return 0
+}
+
+// A SubtreeRoot is the root of a complete note commitment subtree (2^16
+// leaves), along with the block that added its final leaf.
+type SubtreeRoot struct {
+	RootHash              []byte `protobuf:"bytes,2,opt,name=rootHash,proto3" json:"rootHash,omitempty"`
+	CompletingBlockHash   []byte `protobuf:"bytes,3,opt,name=completingBlockHash,proto3" json:"completingBlockHash,omitempty"`
+	CompletingBlockHeight uint64 `protobuf:"varint,4,opt,name=completingBlockHeight" json:"completingBlockHeight,omitempty"`
+}
+
+func (m *SubtreeRoot) Reset()                    { *m = SubtreeRoot{} }
+func (m *SubtreeRoot) String() string            { return proto.CompactTextString(m) }
+func (*SubtreeRoot) ProtoMessage()               {}
+func (*SubtreeRoot) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{26} }
+
+func (m *SubtreeRoot) GetRootHash() []byte {
+	if m != nil {
+		return m.RootHash
+	}
+	return nil
+}
+
+func (m *SubtreeRoot) GetCompletingBlockHash() []byte {
+	if m != nil {
+		return m.CompletingBlockHash
+	}
+	return nil
+}
+
+func (m *SubtreeRoot) GetCompletingBlockHeight() uint64 {
+	if m != nil {
+		return m.CompletingBlockHeight
+	}
+	return 0
+}
+
+// GetAddressHistoryArg requests the transactions that involve any of the
+// given transparent addresses, one page at a time. To get the next page,
+// repeat the request with the continuationToken from the previous reply.
+type GetAddressHistoryArg struct {
+	Addresses         []string    `protobuf:"bytes,1,rep,name=addresses" json:"addresses,omitempty"`
+	Range             *BlockRange `protobuf:"bytes,2,opt,name=range" json:"range,omitempty"`
+	MaxEntries        uint32      `protobuf:"varint,3,opt,name=maxEntries" json:"maxEntries,omitempty"`
+	ContinuationToken string      `protobuf:"bytes,4,opt,name=continuationToken" json:"continuationToken,omitempty"`
+	ExcludeMempool    bool        `protobuf:"varint,5,opt,name=excludeMempool" json:"excludeMempool,omitempty"`
+}
+
+func (m *GetAddressHistoryArg) Reset()                    { *m = GetAddressHistoryArg{} }
+func (m *GetAddressHistoryArg) String() string            { return proto.CompactTextString(m) }
+func (*GetAddressHistoryArg) ProtoMessage()               {}
+func (*GetAddressHistoryArg) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{27} }
+
+func (m *GetAddressHistoryArg) GetAddresses() []string {
+	if m != nil {
+		return m.Addresses
+	}
+	return nil
+}
+
+func (m *GetAddressHistoryArg) GetRange() *BlockRange {
+	if m != nil {
+		return m.Range
+	}
+	return nil
+}
+
+func (m *GetAddressHistoryArg) GetMaxEntries() uint32 {
+	if m != nil {
+		return m.MaxEntries
+	}
+	return 0
+}
+
+func (m *GetAddressHistoryArg) GetContinuationToken() string {
+	if m != nil {
+		return m.ContinuationToken
+	}
+	return ""
+}
+
+func (m *GetAddressHistoryArg) GetExcludeMempool() bool {
+	if m != nil {
+		return m.ExcludeMempool
+	}
+	return false
+}
+
+// An AddressHistoryEntry is a transaction that involves one or more of the
+// requested addresses; a transaction appears once even if it involves
+// several of them.
+type AddressHistoryEntry struct {
+	Txid       []byte `protobuf:"bytes,1,opt,name=txid,proto3" json:"txid,omitempty"`
+	Height     uint64 `protobuf:"varint,2,opt,name=height" json:"height,omitempty"`
+	Time       uint32 `protobuf:"varint,3,opt,name=time" json:"time,omitempty"`
+	ValueDelta int64  `protobuf:"varint,4,opt,name=valueDelta" json:"valueDelta,omitempty"`
+}
+
+func (m *AddressHistoryEntry) Reset()                    { *m = AddressHistoryEntry{} }
+func (m *AddressHistoryEntry) String() string            { return proto.CompactTextString(m) }
+func (*AddressHistoryEntry) ProtoMessage()               {}
+func (*AddressHistoryEntry) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{28} }
+
+func (m *AddressHistoryEntry) GetTxid() []byte {
+	if m != nil {
+		return m.Txid
+	}
+	return nil
+}
+
+func (m *AddressHistoryEntry) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *AddressHistoryEntry) GetTime() uint32 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *AddressHistoryEntry) GetValueDelta() int64 {
+	if m != nil {
+		return m.ValueDelta
+	}
+	return 0
+}
+
+// Entries are sorted by height (then by position within the block), with
+// mempool transactions, in order of arrival, last.
+type GetAddressHistoryReply struct {
+	Entries           []*AddressHistoryEntry `protobuf:"bytes,1,rep,name=entries" json:"entries,omitempty"`
+	ContinuationToken string                 `protobuf:"bytes,2,opt,name=continuationToken" json:"continuationToken,omitempty"`
+}
+
+func (m *GetAddressHistoryReply) Reset()                    { *m = GetAddressHistoryReply{} }
+func (m *GetAddressHistoryReply) String() string            { return proto.CompactTextString(m) }
+func (*GetAddressHistoryReply) ProtoMessage()               {}
+func (*GetAddressHistoryReply) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{29} }
+
+func (m *GetAddressHistoryReply) GetEntries() []*AddressHistoryEntry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *GetAddressHistoryReply) GetContinuationToken() string {
+	if m != nil {
+		return m.ContinuationToken
+	}
+	return ""
+}
+
+// A GetTransactionsReply is the result of one of the TxFilters sent to
+// GetTransactions: the transaction, or why it couldn't be returned. Replies
+// are sent as the lookups complete, which may not be the request order.
+type GetTransactionsReply struct {
+	RequestIndex uint32          `protobuf:"varint,1,opt,name=requestIndex" json:"requestIndex,omitempty"`
+	Transaction  *RawTransaction `protobuf:"bytes,2,opt,name=transaction" json:"transaction,omitempty"`
+	Error        string          `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *GetTransactionsReply) Reset()                    { *m = GetTransactionsReply{} }
+func (m *GetTransactionsReply) String() string            { return proto.CompactTextString(m) }
+func (*GetTransactionsReply) ProtoMessage()               {}
+func (*GetTransactionsReply) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{30} }
+
+func (m *GetTransactionsReply) GetRequestIndex() uint32 {
+	if m != nil {
+		return m.RequestIndex
+	}
+	return 0
+}
+
+func (m *GetTransactionsReply) GetTransaction() *RawTransaction {
+	if m != nil {
+		return m.Transaction
+	}
+	return nil
+}
+
+func (m *GetTransactionsReply) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+// TransactionStatus is the result of GetTransactionStatus.
+type TransactionStatus struct {
+	State         TransactionState `protobuf:"varint,1,opt,name=state,enum=pirate.wallet.sdk.rpc.TransactionState" json:"state,omitempty"`
+	Height        uint64           `protobuf:"varint,2,opt,name=height" json:"height,omitempty"`
+	BlockHash     []byte           `protobuf:"bytes,3,opt,name=blockHash,proto3" json:"blockHash,omitempty"`
+	Confirmations uint64           `protobuf:"varint,4,opt,name=confirmations" json:"confirmations,omitempty"`
+	// zero if the block isn't (yet) in the cache's best chain
+	ExpiryHeight uint32 `protobuf:"varint,5,opt,name=expiryHeight" json:"expiryHeight,omitempty"`
+}
+
+func (m *TransactionStatus) Reset()                    { *m = TransactionStatus{} }
+func (m *TransactionStatus) String() string            { return proto.CompactTextString(m) }
+func (*TransactionStatus) ProtoMessage()               {}
+func (*TransactionStatus) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{31} }
+
+func (m *TransactionStatus) GetState() TransactionState {
+	if m != nil {
+		return m.State
+	}
+	return TransactionState_notFound
+}
+
+func (m *TransactionStatus) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+func (m *TransactionStatus) GetBlockHash() []byte {
+	if m != nil {
+		return m.BlockHash
+	}
+	return nil
+}
+
+func (m *TransactionStatus) GetConfirmations() uint64 {
+	if m != nil {
+		return m.Confirmations
+	}
+	return 0
+}
+
+func (m *TransactionStatus) GetExpiryHeight() uint32 {
+	if m != nil {
+		return m.ExpiryHeight
+	}
+	return 0
+}
+
+// A BlockRollback tells a SubscribeBlocks client that the blocks above the
+// given height have been removed from the best chain (a reorg); the blocks
+// that replace them follow.
+type BlockRollback struct {
+	Height uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+}
+
+func (m *BlockRollback) Reset()                    { *m = BlockRollback{} }
+func (m *BlockRollback) String() string            { return proto.CompactTextString(m) }
+func (*BlockRollback) ProtoMessage()               {}
+func (*BlockRollback) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{32} }
+
+func (m *BlockRollback) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+// A BlockSubscriptionEvent is either the next block in the best chain or
+// a rollback; exactly one of the fields is set.
+type BlockSubscriptionEvent struct {
+	Block    *CompactBlock  `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+	Rollback *BlockRollback `protobuf:"bytes,2,opt,name=rollback" json:"rollback,omitempty"`
+}
+
+func (m *BlockSubscriptionEvent) Reset()                    { *m = BlockSubscriptionEvent{} }
+func (m *BlockSubscriptionEvent) String() string            { return proto.CompactTextString(m) }
+func (*BlockSubscriptionEvent) ProtoMessage()               {}
+func (*BlockSubscriptionEvent) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{33} }
+
+func (m *BlockSubscriptionEvent) GetBlock() *CompactBlock {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+func (m *BlockSubscriptionEvent) GetRollback() *BlockRollback {
+	if m != nil {
+		return m.Rollback
+	}
+	return nil
+}
+
+// A BlockLocator lists blocks (heights and hashes, as in CompactBlock.hash)
+// that a wallet has, newest first: typically its last few blocks, then
+// exponentially sparser ones back to its birthday, so that the server can
+// find the highest one that's still in the best chain.
+type BlockLocator struct {
+	Blocks []*BlockID `protobuf:"bytes,1,rep,name=blocks" json:"blocks,omitempty"`
+}
+
+func (m *BlockLocator) Reset()                    { *m = BlockLocator{} }
+func (m *BlockLocator) String() string            { return proto.CompactTextString(m) }
+func (*BlockLocator) ProtoMessage()               {}
+func (*BlockLocator) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{34} }
+
+func (m *BlockLocator) GetBlocks() []*BlockID {
+	if m != nil {
+		return m.Blocks
+	}
+	return nil
+}
+
+// A MempoolEvent is sent by GetMempoolEvents. Mined and evicted events are
+// sent only for transactions that were sent (as added) on the same stream.
+type MempoolEvent struct {
+	Type   MempoolEventType `protobuf:"varint,1,opt,name=type,enum=pirate.wallet.sdk.rpc.MempoolEventType" json:"type,omitempty"`
+	Txid   []byte           `protobuf:"bytes,2,opt,name=txid,proto3" json:"txid,omitempty"`
+	Tx     *CompactTx       `protobuf:"bytes,3,opt,name=tx" json:"tx,omitempty"`
+	Height uint64           `protobuf:"varint,4,opt,name=height" json:"height,omitempty"`
+}
+
+func (m *MempoolEvent) Reset()                    { *m = MempoolEvent{} }
+func (m *MempoolEvent) String() string            { return proto.CompactTextString(m) }
+func (*MempoolEvent) ProtoMessage()               {}
+func (*MempoolEvent) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{35} }
+
+func (m *MempoolEvent) GetType() MempoolEventType {
+	if m != nil {
+		return m.Type
+	}
+	return MempoolEventType_mempoolAdded
+}
+
+func (m *MempoolEvent) GetTxid() []byte {
+	if m != nil {
+		return m.Txid
+	}
+	return nil
+}
+
+func (m *MempoolEvent) GetTx() *CompactTx {
+	if m != nil {
+		return m.Tx
+	}
+	return nil
+}
+
+func (m *MempoolEvent) GetHeight() uint64 {
+	if m != nil {
+		return m.Height
+	}
+	return 0
+}
+
+// MempoolInfo describes the mempool as lightwalletd sees it (as of its
+// most recent poll).
+type MempoolInfo struct {
+	Size               uint64  `protobuf:"varint,1,opt,name=size" json:"size,omitempty"`
+	Bytes              uint64  `protobuf:"varint,2,opt,name=bytes" json:"bytes,omitempty"`
+	SaplingSpendTxs    uint64  `protobuf:"varint,3,opt,name=saplingSpendTxs" json:"saplingSpendTxs,omitempty"`
+	SaplingOutputTxs   uint64  `protobuf:"varint,4,opt,name=saplingOutputTxs" json:"saplingOutputTxs,omitempty"`
+	OrchardActionTxs   uint64  `protobuf:"varint,5,opt,name=orchardActionTxs" json:"orchardActionTxs,omitempty"`
+	OldestAge          uint64  `protobuf:"varint,6,opt,name=oldestAge" json:"oldestAge,omitempty"`
+	AdditionsPerMinute float64 `protobuf:"fixed64,7,opt,name=additionsPerMinute" json:"additionsPerMinute,omitempty"`
+	EvictionsPerMinute float64 `protobuf:"fixed64,8,opt,name=evictionsPerMinute" json:"evictionsPerMinute,omitempty"`
+}
+
+func (m *MempoolInfo) Reset()                    { *m = MempoolInfo{} }
+func (m *MempoolInfo) String() string            { return proto.CompactTextString(m) }
+func (*MempoolInfo) ProtoMessage()               {}
+func (*MempoolInfo) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{36} }
+
+func (m *MempoolInfo) GetSize() uint64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetBytes() uint64 {
+	if m != nil {
+		return m.Bytes
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetSaplingSpendTxs() uint64 {
+	if m != nil {
+		return m.SaplingSpendTxs
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetSaplingOutputTxs() uint64 {
+	if m != nil {
+		return m.SaplingOutputTxs
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetOrchardActionTxs() uint64 {
+	if m != nil {
+		return m.OrchardActionTxs
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetOldestAge() uint64 {
+	if m != nil {
+		return m.OldestAge
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetAdditionsPerMinute() float64 {
+	if m != nil {
+		return m.AdditionsPerMinute
+	}
+	return 0
+}
+
+func (m *MempoolInfo) GetEvictionsPerMinute() float64 {
+	if m != nil {
+		return m.EvictionsPerMinute
+	}
+	return 0
+}
+
+// GetBlockGroupsArg requests the groups that a range of blocks (in the
+// cache) divides into; the range's end defaults to the latest block.
+type GetBlockGroupsArg struct {
+	Range      *BlockRange `protobuf:"bytes,1,opt,name=range" json:"range,omitempty"`
+	TargetSize uint64      `protobuf:"varint,2,opt,name=targetSize" json:"targetSize,omitempty"`
+}
+
+func (m *GetBlockGroupsArg) Reset()                    { *m = GetBlockGroupsArg{} }
+func (m *GetBlockGroupsArg) String() string            { return proto.CompactTextString(m) }
+func (*GetBlockGroupsArg) ProtoMessage()               {}
+func (*GetBlockGroupsArg) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{37} }
+
+func (m *GetBlockGroupsArg) GetRange() *BlockRange {
+	if m != nil {
+		return m.Range
+	}
+	return nil
+}
+
+func (m *GetBlockGroupsArg) GetTargetSize() uint64 {
+	if m != nil {
+		return m.TargetSize
+	}
+	return 0
+}
+
+// A BlockGroup is a range of consecutive blocks whose compact blocks total
+// about the requested size.
+type BlockGroup struct {
+	StartHeight uint64 `protobuf:"varint,1,opt,name=startHeight" json:"startHeight,omitempty"`
+	EndHeight   uint64 `protobuf:"varint,2,opt,name=endHeight" json:"endHeight,omitempty"`
+	Size        uint64 `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+	TxCount     uint64 `protobuf:"varint,4,opt,name=txCount" json:"txCount,omitempty"`
+	EndHash     []byte `protobuf:"bytes,5,opt,name=endHash,proto3" json:"endHash,omitempty"`
+}
+
+func (m *BlockGroup) Reset()                    { *m = BlockGroup{} }
+func (m *BlockGroup) String() string            { return proto.CompactTextString(m) }
+func (*BlockGroup) ProtoMessage()               {}
+func (*BlockGroup) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{38} }
+
+func (m *BlockGroup) GetStartHeight() uint64 {
+	if m != nil {
+		return m.StartHeight
+	}
+	return 0
+}
+
+func (m *BlockGroup) GetEndHeight() uint64 {
+	if m != nil {
+		return m.EndHeight
+	}
+	return 0
+}
+
+func (m *BlockGroup) GetSize() uint64 {
+	if m != nil {
+		return m.Size
+	}
+	return 0
+}
+
+func (m *BlockGroup) GetTxCount() uint64 {
+	if m != nil {
+		return m.TxCount
+	}
+	return 0
+}
+
+func (m *BlockGroup) GetEndHash() []byte {
+	if m != nil {
+		return m.EndHash
+	}
+	return nil
+}
+
+// At most 1000 groups are returned; if the last one ends before the end of
+// the requested range, request the rest starting at the following height.
+type BlockGroupList struct {
+	Groups []*BlockGroup `protobuf:"bytes,1,rep,name=groups" json:"groups,omitempty"`
+}
+
+func (m *BlockGroupList) Reset()                    { *m = BlockGroupList{} }
+func (m *BlockGroupList) String() string            { return proto.CompactTextString(m) }
+func (*BlockGroupList) ProtoMessage()               {}
+func (*BlockGroupList) Descriptor() ([]byte, []int) { return file_service_proto_rawDesc, []int{39} }
+
+func (m *BlockGroupList) GetGroups() []*BlockGroup {
+	if m != nil {
+		return m.Groups
+	}
+	return nil
+}
+
 func init() {
+	proto.RegisterEnum("pirate.wallet.sdk.rpc.SendRejection", SendRejection_name, SendRejection_value)
+	proto.RegisterEnum("pirate.wallet.sdk.rpc.ShieldedProtocol", ShieldedProtocol_name, ShieldedProtocol_value)
+	proto.RegisterEnum("pirate.wallet.sdk.rpc.TransactionState", TransactionState_name, TransactionState_value)
+	proto.RegisterEnum("pirate.wallet.sdk.rpc.MempoolEventType", MempoolEventType_name, MempoolEventType_value)
 	proto.RegisterType((*BlockID)(nil), "pirate.wallet.sdk.rpc.BlockID")
 	proto.RegisterType((*BlockRange)(nil), "pirate.wallet.sdk.rpc.BlockRange")
+	proto.RegisterType((*BlockRangeContinuation)(nil), "pirate.wallet.sdk.rpc.BlockRangeContinuation")
 	proto.RegisterType((*TxFilter)(nil), "pirate.wallet.sdk.rpc.TxFilter")
 	proto.RegisterType((*RawTransaction)(nil), "pirate.wallet.sdk.rpc.RawTransaction")
 	proto.RegisterType((*SendResponse)(nil), "pirate.wallet.sdk.rpc.SendResponse")
 	proto.RegisterType((*ChainSpec)(nil), "pirate.wallet.sdk.rpc.ChainSpec")
 	proto.RegisterType((*Empty)(nil), "pirate.wallet.sdk.rpc.Empty")
 	proto.RegisterType((*LightdInfo)(nil), "pirate.wallet.sdk.rpc.LightdInfo")
+	proto.RegisterType((*NetworkUpgrade)(nil), "pirate.wallet.sdk.rpc.NetworkUpgrade")
 	proto.RegisterType((*TransparentAddressBlockFilter)(nil), "pirate.wallet.sdk.rpc.TransparentAddressBlockFilter")
 	proto.RegisterType((*Duration)(nil), "pirate.wallet.sdk.rpc.Duration")
 	proto.RegisterType((*PingResponse)(nil), "pirate.wallet.sdk.rpc.PingResponse")
@@ -714,94 +1667,197 @@ func init() {
 	proto.RegisterType((*Exclude)(nil), "pirate.wallet.sdk.rpc.Exclude")
 	proto.RegisterType((*TreeState)(nil), "pirate.wallet.sdk.rpc.TreeState")
 	proto.RegisterType((*GetAddressUtxosArg)(nil), "pirate.wallet.sdk.rpc.GetAddressUtxosArg")
+	proto.RegisterType((*UtxoCursor)(nil), "pirate.wallet.sdk.rpc.UtxoCursor")
 	proto.RegisterType((*GetAddressUtxosReply)(nil), "pirate.wallet.sdk.rpc.GetAddressUtxosReply")
 	proto.RegisterType((*GetAddressUtxosReplyList)(nil), "pirate.wallet.sdk.rpc.GetAddressUtxosReplyList")
 	proto.RegisterType((*PriceRequest)(nil), "pirate.wallet.sdk.rpc.PriceRequest")
 	proto.RegisterType((*PriceResponse)(nil), "pirate.wallet.sdk.rpc.PriceResponse")
+	proto.RegisterType((*BlockHeader)(nil), "pirate.wallet.sdk.rpc.BlockHeader")
+	proto.RegisterType((*GetSubtreeRootsArg)(nil), "pirate.wallet.sdk.rpc.GetSubtreeRootsArg")
+	proto.RegisterType((*SubtreeRoot)(nil), "pirate.wallet.sdk.rpc.SubtreeRoot")
+	proto.RegisterType((*GetAddressHistoryArg)(nil), "pirate.wallet.sdk.rpc.GetAddressHistoryArg")
+	proto.RegisterType((*AddressHistoryEntry)(nil), "pirate.wallet.sdk.rpc.AddressHistoryEntry")
+	proto.RegisterType((*GetAddressHistoryReply)(nil), "pirate.wallet.sdk.rpc.GetAddressHistoryReply")
+	proto.RegisterType((*GetTransactionsReply)(nil), "pirate.wallet.sdk.rpc.GetTransactionsReply")
+	proto.RegisterType((*TransactionStatus)(nil), "pirate.wallet.sdk.rpc.TransactionStatus")
+	proto.RegisterType((*BlockRollback)(nil), "pirate.wallet.sdk.rpc.BlockRollback")
+	proto.RegisterType((*BlockSubscriptionEvent)(nil), "pirate.wallet.sdk.rpc.BlockSubscriptionEvent")
+	proto.RegisterType((*BlockLocator)(nil), "pirate.wallet.sdk.rpc.BlockLocator")
+	proto.RegisterType((*MempoolEvent)(nil), "pirate.wallet.sdk.rpc.MempoolEvent")
+	proto.RegisterType((*MempoolInfo)(nil), "pirate.wallet.sdk.rpc.MempoolInfo")
+	proto.RegisterType((*GetBlockGroupsArg)(nil), "pirate.wallet.sdk.rpc.GetBlockGroupsArg")
+	proto.RegisterType((*BlockGroup)(nil), "pirate.wallet.sdk.rpc.BlockGroup")
+	proto.RegisterType((*BlockGroupList)(nil), "pirate.wallet.sdk.rpc.BlockGroupList")
 }
 
 func init() { proto.RegisterFile("service.proto", file_service_proto_rawDesc) }
 
 var file_service_proto_rawDesc = []byte{
-	// 1269 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x57, 0x6d, 0x73, 0x13, 0xb7,
-	0x13, 0xb7, 0x71, 0x1c, 0xc7, 0x1b, 0x87, 0x07, 0xfd, 0x81, 0xff, 0x8d, 0x0b, 0x34, 0x15, 0x65,
-	0x26, 0x2d, 0x9d, 0xc0, 0x50, 0x3a, 0xe5, 0x45, 0xdf, 0x24, 0x81, 0x06, 0x66, 0x80, 0x52, 0xc5,
-	0xbc, 0x09, 0x33, 0x65, 0x94, 0xbb, 0xc5, 0x56, 0x73, 0xbe, 0xbb, 0x4a, 0x72, 0x70, 0xbe, 0x50,
-	0x67, 0xfa, 0x15, 0xfa, 0x31, 0xfa, 0x69, 0xfa, 0xb2, 0xa3, 0x95, 0x6c, 0x9f, 0x03, 0xe7, 0x38,
-	0xaf, 0x7c, 0xbb, 0x5a, 0xfd, 0x76, 0xf5, 0xdb, 0x07, 0xc9, 0xb0, 0x61, 0x50, 0x9f, 0xa8, 0x18,
-	0xb7, 0x0b, 0x9d, 0xdb, 0x9c, 0xdd, 0x28, 0x94, 0x96, 0x16, 0xb7, 0x3f, 0xca, 0x34, 0x45, 0xbb,
-	0x6d, 0x92, 0xe3, 0x6d, 0x5d, 0xc4, 0xdd, 0x1b, 0x71, 0x3e, 0x2c, 0x64, 0x6c, 0xdf, 0x7f, 0xc8,
-	0xf5, 0x50, 0x5a, 0xe3, 0xad, 0xf9, 0x0f, 0xd0, 0xda, 0x4d, 0xf3, 0xf8, 0xf8, 0xc5, 0x53, 0x76,
-	0x13, 0x56, 0x07, 0xa8, 0xfa, 0x03, 0x1b, 0xd5, 0x37, 0xeb, 0x5b, 0x2b, 0x22, 0x48, 0x8c, 0xc1,
-	0xca, 0x40, 0x9a, 0x41, 0x74, 0x69, 0xb3, 0xbe, 0xd5, 0x11, 0xf4, 0xcd, 0x2d, 0x00, 0x6d, 0x13,
-	0x32, 0xeb, 0x23, 0x7b, 0x0c, 0x4d, 0x63, 0xa5, 0xf6, 0x1b, 0xd7, 0x1f, 0xdd, 0xd9, 0xfe, 0x6c,
-	0x08, 0xdb, 0xc1, 0x91, 0xf0, 0xc6, 0xec, 0x21, 0x34, 0x30, 0x4b, 0x08, 0xf6, 0xfc, 0x3d, 0xce,
-	0x94, 0xff, 0x0e, 0x6b, 0xbd, 0xf1, 0xcf, 0x2a, 0xb5, 0xa8, 0x9d, 0xcf, 0x23, 0xb7, 0xb6, 0xac,
-	0x4f, 0x32, 0x66, 0xd7, 0xa1, 0xa9, 0xb2, 0x04, 0xc7, 0xe4, 0x75, 0x45, 0x78, 0x61, 0x7a, 0xc2,
-	0x46, 0xe9, 0x84, 0x3f, 0xc1, 0x65, 0x21, 0x3f, 0xf6, 0xb4, 0xcc, 0x8c, 0x8c, 0xad, 0xca, 0x33,
-	0x67, 0x95, 0x48, 0x2b, 0xc9, 0x61, 0x47, 0xd0, 0x77, 0x89, 0xb3, 0x4b, 0x65, 0xce, 0xf8, 0x1b,
-	0xe8, 0x1c, 0x60, 0x96, 0x08, 0x34, 0x45, 0x9e, 0x19, 0x64, 0xb7, 0xa0, 0x8d, 0x5a, 0xe7, 0x7a,
-	0x2f, 0x4f, 0x90, 0x00, 0x9a, 0x62, 0xa6, 0x60, 0x1c, 0x3a, 0x24, 0xbc, 0x42, 0x63, 0x64, 0x1f,
-	0x09, 0xab, 0x2d, 0xe6, 0x74, 0x7c, 0x1d, 0xda, 0x7b, 0x03, 0xa9, 0xb2, 0x83, 0x02, 0x63, 0xde,
-	0x82, 0xe6, 0xb3, 0x61, 0x61, 0x4f, 0xf9, 0xbf, 0x0d, 0x80, 0x97, 0xce, 0x63, 0xf2, 0x22, 0xfb,
-	0x90, 0xb3, 0x08, 0x5a, 0x27, 0xa8, 0x8d, 0xca, 0x33, 0x72, 0xd2, 0x16, 0x13, 0xd1, 0x05, 0x7a,
-	0x82, 0x59, 0x92, 0xeb, 0x00, 0x1e, 0x24, 0xe7, 0xda, 0xca, 0x24, 0xd1, 0x07, 0xa3, 0xa2, 0xc8,
-	0xb5, 0x25, 0x0a, 0xd6, 0xc4, 0x9c, 0xce, 0x05, 0x1f, 0x3b, 0xd7, 0xaf, 0xe5, 0x10, 0xa3, 0x15,
-	0xda, 0x3e, 0x53, 0xb0, 0x27, 0xf0, 0x7f, 0x23, 0x8b, 0x54, 0x65, 0xfd, 0x9d, 0xd8, 0xaa, 0x13,
-	0xe9, 0xb8, 0x7a, 0xee, 0x39, 0x69, 0x12, 0x27, 0x55, 0xcb, 0xec, 0x3b, 0xb8, 0x16, 0x3b, 0x76,
-	0x32, 0x33, 0x32, 0xbb, 0x5a, 0x66, 0xf1, 0xe0, 0x45, 0x12, 0xad, 0x12, 0xfe, 0xa7, 0x0b, 0x6c,
-	0x13, 0xd6, 0x29, 0x87, 0x01, 0xbb, 0x45, 0xd8, 0x65, 0x95, 0x8b, 0xb3, 0xaf, 0xec, 0x5e, 0x3e,
-	0x1c, 0x2a, 0x1b, 0xad, 0xf9, 0x38, 0xa7, 0x0a, 0xc7, 0xc0, 0x11, 0x61, 0x45, 0x6d, 0xcf, 0x80,
-	0x97, 0xdc, 0xae, 0xa3, 0x91, 0x4a, 0x93, 0xa7, 0xd2, 0x62, 0x04, 0x7e, 0xd7, 0x54, 0x31, 0x5d,
-	0x7d, 0x6b, 0x50, 0x47, 0xeb, 0xa5, 0x55, 0xa7, 0x60, 0x5b, 0x70, 0x05, 0x8d, 0x55, 0x43, 0x69,
-	0x31, 0x09, 0x71, 0x75, 0x28, 0xae, 0xb3, 0x6a, 0xc7, 0xb3, 0x2f, 0xd0, 0x64, 0xd7, 0xed, 0x8e,
-	0x36, 0x7c, 0x8a, 0xcb, 0x3a, 0xc7, 0x47, 0x90, 0x0f, 0x46, 0x47, 0x93, 0x3c, 0x5e, 0xf6, 0x7c,
-	0x7c, 0xb2, 0xc0, 0x35, 0xdc, 0xa6, 0xea, 0x2c, 0xa4, 0xc6, 0xcc, 0xee, 0x24, 0x89, 0x46, 0x63,
-	0xa8, 0xdc, 0x43, 0x87, 0x44, 0xd0, 0x92, 0x5e, 0x3b, 0x29, 0x86, 0x20, 0xb2, 0x1f, 0xa1, 0xa9,
-	0x5d, 0xe3, 0x86, 0xde, 0xfb, 0x6a, 0x51, 0xef, 0x50, 0x87, 0x0b, 0x6f, 0xcf, 0xbf, 0x85, 0xb5,
-	0xa7, 0x23, 0x4d, 0x39, 0x64, 0x77, 0x00, 0x54, 0x66, 0x51, 0x9f, 0xc8, 0xf4, 0xad, 0xf7, 0xd0,
-	0x10, 0x25, 0x0d, 0x7f, 0x02, 0x9d, 0x37, 0x2a, 0xeb, 0x4f, 0x5b, 0xe0, 0x3a, 0x34, 0x31, 0xb3,
-	0xfa, 0x34, 0x98, 0x7a, 0xc1, 0x35, 0x15, 0x8e, 0x95, 0x6f, 0x9f, 0x86, 0xa0, 0x6f, 0x7e, 0x17,
-	0x5a, 0xe1, 0x38, 0xd5, 0x67, 0xe0, 0xf7, 0x61, 0x3d, 0x18, 0xbd, 0x54, 0x86, 0x72, 0x1f, 0x56,
-	0xd0, 0x99, 0x36, 0x5c, 0x9e, 0xa6, 0x0a, 0x7e, 0x0f, 0x5a, 0xbb, 0x32, 0x95, 0x59, 0x8c, 0xac,
-	0x0b, 0x6b, 0x27, 0x32, 0x1d, 0xe1, 0xa1, 0xb4, 0x21, 0x92, 0xa9, 0xcc, 0x6f, 0x43, 0xeb, 0xd9,
-	0x38, 0x4e, 0x47, 0x09, 0xba, 0xb8, 0xec, 0x58, 0x25, 0x04, 0xd5, 0x11, 0xf4, 0xcd, 0xff, 0xaa,
-	0x43, 0xbb, 0xa7, 0x11, 0x0f, 0xac, 0xab, 0x8c, 0x08, 0x5a, 0x19, 0xda, 0x8f, 0xb9, 0x3e, 0x9e,
-	0x84, 0x16, 0xc4, 0xaa, 0xa1, 0x30, 0x37, 0x66, 0xda, 0x7e, 0xcc, 0x90, 0x1f, 0x15, 0xda, 0x6a,
-	0x43, 0xd0, 0xb7, 0xab, 0xf4, 0xd0, 0x32, 0xce, 0x1b, 0x75, 0x51, 0x5b, 0x94, 0x55, 0xce, 0x22,
-	0xd7, 0xf1, 0x40, 0xea, 0x84, 0x2c, 0x7c, 0xcf, 0x94, 0x55, 0xdc, 0x02, 0xdb, 0xc7, 0x49, 0x55,
-	0xbc, 0xb5, 0xe3, 0xdc, 0xec, 0xe8, 0xfe, 0x62, 0x96, 0xc8, 0xaf, 0x9b, 0xcc, 0xcf, 0xcb, 0xc1,
-	0x97, 0x55, 0x2e, 0xe7, 0x43, 0x39, 0x7e, 0x96, 0x59, 0xad, 0xd0, 0xd0, 0x39, 0x36, 0x44, 0x49,
-	0xc3, 0xff, 0xac, 0xc3, 0xf5, 0x33, 0x6e, 0x05, 0x16, 0xe9, 0x69, 0x39, 0x8f, 0xab, 0xf3, 0xb5,
-	0x38, 0x23, 0xba, 0x3e, 0x21, 0x7a, 0x7e, 0x4a, 0x37, 0x27, 0x53, 0xfa, 0x26, 0xac, 0x9a, 0x58,
-	0xab, 0xc2, 0x86, 0x39, 0x1d, 0xa4, 0xb9, 0x8c, 0xae, 0xcc, 0x67, 0xb4, 0x94, 0x8a, 0xe6, 0xdc,
-	0x7c, 0x3e, 0x86, 0xe8, 0x73, 0x71, 0x52, 0x29, 0xfd, 0x02, 0x1d, 0x59, 0x5a, 0x20, 0x9e, 0xd6,
-	0x1f, 0xdd, 0xaf, 0x68, 0x92, 0xcf, 0xc1, 0x88, 0x39, 0x00, 0xfe, 0x1c, 0x3a, 0x6f, 0xb4, 0x8a,
-	0x51, 0xe0, 0x1f, 0x23, 0xf4, 0xb5, 0xea, 0xf2, 0x6c, 0xac, 0x1c, 0x16, 0xe1, 0xae, 0x9d, 0x29,
-	0xdc, 0x71, 0xe2, 0x91, 0xd6, 0x98, 0xc5, 0xa7, 0x61, 0x56, 0x4f, 0x65, 0xfe, 0x1e, 0x36, 0x02,
-	0xd2, 0xec, 0x5e, 0x99, 0x87, 0x6a, 0x2c, 0x09, 0xe5, 0x38, 0x2e, 0x1c, 0x14, 0x91, 0x59, 0x17,
-	0x5e, 0x78, 0xf4, 0x4f, 0x07, 0xae, 0xed, 0xf9, 0x87, 0x42, 0x6f, 0x7c, 0x60, 0x35, 0xca, 0x21,
-	0x6a, 0xd6, 0x83, 0xcb, 0xfb, 0x68, 0x5f, 0x4a, 0x8b, 0xc6, 0xd2, 0x50, 0x60, 0x9b, 0x15, 0x6c,
-	0x4c, 0xaf, 0xa8, 0xee, 0x39, 0x17, 0x32, 0xaf, 0xb1, 0x5f, 0x61, 0x6d, 0x1f, 0x03, 0xde, 0x39,
-	0xd6, 0xdd, 0xbb, 0x55, 0xfe, 0x7c, 0xac, 0x64, 0xc6, 0x6b, 0xec, 0x1d, 0x6c, 0x4c, 0x20, 0xfd,
-	0xcb, 0xe4, 0xfc, 0xd1, 0xb6, 0x24, 0xf4, 0xc3, 0x3a, 0x7b, 0x07, 0x1d, 0x97, 0x6c, 0x21, 0x04,
-	0xe5, 0x80, 0x55, 0x6d, 0x2c, 0xe7, 0xba, 0xfb, 0xf5, 0x62, 0x23, 0x9f, 0x46, 0x8a, 0xfc, 0x7f,
-	0xfb, 0x68, 0xf7, 0x28, 0x3b, 0x25, 0x1f, 0xb7, 0x2a, 0xb6, 0xd3, 0xed, 0xbf, 0x34, 0xf8, 0x21,
-	0xe5, 0xaf, 0xfc, 0x96, 0xf9, 0xb2, 0x62, 0xe7, 0xe4, 0x79, 0xd5, 0xbd, 0x57, 0x61, 0x30, 0xff,
-	0x26, 0xe2, 0x35, 0xf6, 0x1e, 0xae, 0xb8, 0x97, 0x4e, 0x19, 0x7c, 0xb9, 0xbd, 0x95, 0xc4, 0x97,
-	0x1f, 0x4e, 0xbc, 0xc6, 0x0c, 0x5c, 0x75, 0xc1, 0x87, 0x8e, 0xea, 0x8d, 0x55, 0x62, 0xd8, 0xe3,
-	0xaa, 0xf0, 0x17, 0x5d, 0x88, 0x4b, 0x9f, 0xe9, 0x61, 0x9d, 0x1d, 0xd2, 0xf8, 0x9c, 0x38, 0x9d,
-	0xdc, 0x1d, 0xbc, 0x02, 0xa0, 0x74, 0x11, 0x55, 0xd7, 0xbd, 0xc7, 0xe0, 0x35, 0xf6, 0x1b, 0xcd,
-	0x9e, 0x33, 0xd8, 0xbe, 0xd7, 0x2a, 0xfb, 0x20, 0x78, 0x38, 0x1f, 0x7d, 0xab, 0xce, 0x7a, 0x54,
-	0xa7, 0xaf, 0x70, 0x58, 0xe4, 0x79, 0xda, 0x1b, 0x57, 0x62, 0x86, 0xab, 0xae, 0xbb, 0xb9, 0xb8,
-	0x01, 0x7a, 0xe3, 0x50, 0xfd, 0x57, 0x67, 0xa8, 0x21, 0xda, 0xc5, 0xd5, 0x79, 0x01, 0xba, 0x05,
-	0x85, 0x3c, 0xbb, 0x5b, 0xcf, 0x1b, 0x07, 0x9b, 0x95, 0xf9, 0x0f, 0x08, 0xbc, 0xc6, 0x72, 0xb8,
-	0x72, 0x66, 0x36, 0xb3, 0x6f, 0x96, 0x9b, 0xe1, 0x3b, 0xba, 0xdf, 0x7d, 0x70, 0x81, 0x71, 0xef,
-	0xf2, 0x4e, 0x85, 0x7a, 0xe3, 0xcc, 0x6a, 0xa0, 0xe9, 0x02, 0x6e, 0x2f, 0x72, 0xcb, 0x04, 0xe6,
-	0xdc, 0xc4, 0x2b, 0xfd, 0x05, 0x58, 0x9c, 0x93, 0xaa, 0x79, 0x38, 0x03, 0xe0, 0x35, 0xf6, 0x1a,
-	0x56, 0xdc, 0xcb, 0xad, 0x72, 0x48, 0x4c, 0x9e, 0x80, 0x95, 0x1d, 0x5c, 0x7e, 0xf7, 0xf1, 0xda,
-	0xee, 0x17, 0x87, 0x37, 0x53, 0x87, 0xef, 0xad, 0x92, 0x07, 0xfe, 0x57, 0x17, 0xf1, 0xdf, 0x97,
-	0x6a, 0x47, 0xab, 0xf4, 0x3f, 0xf4, 0xfb, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x29, 0xae, 0x80,
-	0x2c, 0xc6, 0x0e, 0x00, 0x00,
+	// 2648 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x1a, 0x4d, 0x73, 0x1c, 0x47,
+	0x75, 0x67, 0x77, 0xa5, 0x95, 0xde, 0xae, 0xa4, 0x75, 0xfb, 0x23, 0x5b, 0x22, 0x31, 0xa2, 0xed,
+	0x10, 0x45, 0x49, 0x14, 0x97, 0x09, 0x90, 0x14, 0x50, 0x85, 0x24, 0x3b, 0xb6, 0x41, 0x76, 0x9c,
+	0x59, 0x19, 0x0a, 0x87, 0xc2, 0xd5, 0x9a, 0x69, 0xaf, 0x26, 0x9e, 0x9d, 0x9e, 0xf4, 0xf4, 0xc8,
+	0x2b, 0xce, 0x9c, 0x28, 0x2e, 0xa9, 0x82, 0x03, 0x7f, 0x80, 0x82, 0x0b, 0x57, 0xaa, 0xe0, 0x5f,
+	0x70, 0xe5, 0xc8, 0x95, 0x1f, 0x41, 0xf5, 0xeb, 0x9e, 0x9d, 0x99, 0xdd, 0x9d, 0xdd, 0x55, 0x4e,
+	0xda, 0x7e, 0xfd, 0xde, 0xeb, 0xd7, 0xef, 0xbb, 0xdf, 0x08, 0x36, 0x12, 0x2e, 0xcf, 0x03, 0x8f,
+	0xef, 0xc7, 0x52, 0x28, 0x41, 0xae, 0xc7, 0x81, 0x64, 0x8a, 0xef, 0xbf, 0x66, 0x61, 0xc8, 0xd5,
+	0x7e, 0xe2, 0xbf, 0xda, 0x97, 0xb1, 0xb7, 0x7d, 0xdd, 0x13, 0xc3, 0x98, 0x79, 0xea, 0xc5, 0x4b,
+	0x21, 0x87, 0x4c, 0x25, 0x06, 0x9b, 0x7e, 0x1f, 0x5a, 0x87, 0xa1, 0xf0, 0x5e, 0x3d, 0xba, 0x47,
+	0x6e, 0xc0, 0xea, 0x19, 0x0f, 0x06, 0x67, 0xaa, 0xe7, 0xec, 0x38, 0xbb, 0x4d, 0xd7, 0xae, 0x08,
+	0x81, 0xe6, 0x19, 0x4b, 0xce, 0x7a, 0xf5, 0x1d, 0x67, 0xb7, 0xe3, 0xe2, 0x6f, 0xfa, 0x2f, 0x07,
+	0x00, 0xe9, 0x5c, 0x16, 0x0d, 0x38, 0xf9, 0x08, 0x56, 0x12, 0xc5, 0xa4, 0xa1, 0x6c, 0xdf, 0xbd,
+	0xb9, 0x3f, 0x53, 0x86, 0x7d, 0x7b, 0x92, 0x6b, 0x90, 0xc9, 0x1d, 0x68, 0xf0, 0xc8, 0xef, 0xd5,
+	0x97, 0xa2, 0xd1, 0xa8, 0xe4, 0x3e, 0xac, 0x4a, 0x9e, 0xa4, 0x43, 0xde, 0x6b, 0x20, 0xd1, 0x07,
+	0xf3, 0x88, 0x50, 0xb4, 0x23, 0x11, 0xa9, 0x20, 0x4a, 0x99, 0x0a, 0x44, 0xe4, 0x5a, 0x62, 0x7a,
+	0x0c, 0x37, 0x66, 0x63, 0x54, 0xea, 0x60, 0x1b, 0xd6, 0x62, 0xc9, 0xcf, 0x1f, 0xe6, 0x7a, 0x18,
+	0xaf, 0xe9, 0x97, 0xb0, 0x76, 0x32, 0xfa, 0x34, 0x08, 0x15, 0x97, 0x5a, 0x11, 0xa7, 0x9a, 0xf3,
+	0xb2, 0x8a, 0x40, 0x64, 0x72, 0x0d, 0x56, 0x82, 0xc8, 0xe7, 0x23, 0x64, 0xdd, 0x74, 0xcd, 0x62,
+	0xac, 0xf7, 0x46, 0x41, 0xef, 0x3f, 0x86, 0x4d, 0x97, 0xbd, 0x3e, 0x91, 0x2c, 0x4a, 0x98, 0x87,
+	0x12, 0x13, 0x68, 0xfa, 0x4c, 0x31, 0x3c, 0xb0, 0xe3, 0xe2, 0xef, 0xc2, 0x2d, 0xea, 0xc5, 0x5b,
+	0xd0, 0xbf, 0x3a, 0xd0, 0xe9, 0xf3, 0xc8, 0x77, 0x79, 0x12, 0x8b, 0x28, 0xe1, 0xe4, 0x4d, 0x58,
+	0xe7, 0x52, 0x0a, 0x79, 0x24, 0x7c, 0x8e, 0x1c, 0x56, 0xdc, 0x1c, 0x40, 0x28, 0x74, 0x70, 0xf1,
+	0x98, 0x27, 0x09, 0x1b, 0x70, 0x64, 0xb6, 0xee, 0x96, 0x60, 0xe4, 0x10, 0xd6, 0x25, 0xff, 0x92,
+	0xa3, 0x2c, 0x28, 0xe9, 0xe6, 0xdd, 0xdb, 0x15, 0x97, 0x36, 0x27, 0x5b, 0x5c, 0x37, 0x27, 0xd3,
+	0x57, 0x50, 0xa3, 0xc0, 0xef, 0x35, 0xcd, 0x15, 0xf4, 0x6f, 0xda, 0x86, 0xf5, 0xa3, 0x33, 0x16,
+	0x44, 0xfd, 0x98, 0x7b, 0xb4, 0x05, 0x2b, 0xf7, 0x87, 0xb1, 0xba, 0xa0, 0xff, 0x59, 0x05, 0x38,
+	0xd6, 0x57, 0xf1, 0x1f, 0x45, 0x2f, 0x05, 0xe9, 0x41, 0xeb, 0x9c, 0xcb, 0x44, 0x1f, 0xed, 0xa0,
+	0x6c, 0xd9, 0x52, 0x6b, 0xe0, 0x9c, 0x47, 0xbe, 0x90, 0x56, 0x68, 0xbb, 0xd2, 0x57, 0x52, 0xcc,
+	0xf7, 0x65, 0x3f, 0x8d, 0x63, 0x21, 0x15, 0x4a, 0xbc, 0xe6, 0x96, 0x60, 0x5a, 0x29, 0x9e, 0x3e,
+	0xfa, 0x09, 0x1b, 0x72, 0x94, 0x69, 0xdd, 0xcd, 0x01, 0xe4, 0x63, 0x78, 0x23, 0x61, 0x71, 0x18,
+	0x44, 0x83, 0x03, 0x4f, 0x05, 0xe7, 0xe8, 0x36, 0x0f, 0x8d, 0xb2, 0x57, 0x50, 0xd9, 0x55, 0xdb,
+	0xe4, 0x7d, 0xb8, 0xe2, 0x69, 0xad, 0x47, 0x49, 0x9a, 0x1c, 0x4a, 0x16, 0x79, 0x67, 0x8f, 0xfc,
+	0xde, 0x2a, 0xf2, 0x9f, 0xde, 0x20, 0x3b, 0xd0, 0x46, 0xe7, 0xb0, 0xbc, 0x5b, 0xc8, 0xbb, 0x08,
+	0xd2, 0x72, 0x0e, 0x02, 0x75, 0x24, 0x86, 0xc3, 0x40, 0xf5, 0xd6, 0x8c, 0x9c, 0x63, 0x80, 0xd6,
+	0xc0, 0x29, 0xf2, 0xea, 0xad, 0x1b, 0x0d, 0x98, 0x95, 0xa6, 0x3a, 0x4d, 0x83, 0xd0, 0xbf, 0xc7,
+	0x14, 0xef, 0x81, 0xa1, 0x1a, 0x03, 0xc6, 0xbb, 0xcf, 0x12, 0x2e, 0x7b, 0xed, 0xc2, 0xae, 0x06,
+	0x90, 0x5d, 0xd8, 0xe2, 0x89, 0x0a, 0x86, 0x4c, 0x71, 0xdf, 0xca, 0xd5, 0x41, 0xb9, 0x26, 0xc1,
+	0x5a, 0xcf, 0xc6, 0x09, 0xfc, 0x43, 0x4d, 0xdd, 0xdb, 0x30, 0xae, 0x53, 0x84, 0x69, 0x7d, 0xd8,
+	0x75, 0x3f, 0x3d, 0xcd, 0xec, 0xb8, 0x69, 0xf4, 0x31, 0xb5, 0x41, 0x0e, 0x60, 0x2d, 0x8d, 0x07,
+	0x92, 0xf9, 0x3c, 0xe9, 0x6d, 0xed, 0x34, 0x76, 0xdb, 0x77, 0xdf, 0xae, 0xf0, 0xb3, 0x27, 0x5c,
+	0xbd, 0x16, 0xf2, 0xd5, 0x33, 0x83, 0xed, 0x8e, 0xc9, 0xb4, 0xe9, 0x84, 0xf4, 0xce, 0x98, 0xf4,
+	0xa7, 0x4c, 0xd7, 0x35, 0xa6, 0xab, 0xd8, 0xd6, 0xa2, 0xbe, 0x0c, 0x64, 0xa2, 0x8e, 0x98, 0x77,
+	0x36, 0xbe, 0xfa, 0x15, 0xa4, 0x99, 0xde, 0x20, 0xfb, 0x40, 0x42, 0xa6, 0xf8, 0x04, 0x3a, 0x41,
+	0xf4, 0x19, 0x3b, 0x3a, 0xb9, 0xbc, 0xe4, 0x4c, 0xa5, 0x92, 0x27, 0xbd, 0xab, 0x3b, 0x8d, 0xdd,
+	0x75, 0x77, 0xbc, 0xd6, 0x2a, 0xf7, 0x45, 0x84, 0xb2, 0x1c, 0xf8, 0xbe, 0xe4, 0x49, 0xd2, 0xbb,
+	0x86, 0x2a, 0x9a, 0x04, 0xeb, 0x60, 0xf0, 0x44, 0xa4, 0x98, 0xa7, 0x7a, 0xd7, 0x4d, 0x30, 0xd8,
+	0x25, 0xf9, 0x2e, 0x6c, 0xf2, 0x88, 0x9d, 0x86, 0xdc, 0x7f, 0xcc, 0xd5, 0x99, 0xf0, 0x93, 0xde,
+	0x0d, 0x3c, 0x65, 0x02, 0x4a, 0x7f, 0xe7, 0xc0, 0x66, 0x59, 0x79, 0x3a, 0x34, 0x23, 0x1d, 0x06,
+	0x26, 0xbc, 0xf0, 0xb7, 0x16, 0xf7, 0x34, 0x73, 0x5f, 0x13, 0x5d, 0xe3, 0x35, 0xd9, 0x83, 0x2e,
+	0x9b, 0xd4, 0x6d, 0x03, 0x2f, 0x3e, 0x05, 0xd7, 0x1e, 0x9a, 0x28, 0xa6, 0xd2, 0xc4, 0x06, 0x99,
+	0x5d, 0x51, 0x09, 0x6f, 0x61, 0x82, 0x8b, 0x99, 0xe4, 0x91, 0xb2, 0xd7, 0xc3, 0x8c, 0x69, 0x93,
+	0x6c, 0x0f, 0x5a, 0xcc, 0xea, 0xc2, 0x86, 0xbd, 0x5d, 0x92, 0x1f, 0xc2, 0x8a, 0xd4, 0x39, 0xdd,
+	0xd6, 0x94, 0xef, 0x2c, 0x2c, 0x0f, 0xae, 0xc1, 0xa7, 0x7b, 0xb0, 0x76, 0x2f, 0x95, 0xa6, 0x06,
+	0xdc, 0x04, 0x08, 0x22, 0xc5, 0xe5, 0x39, 0x0b, 0x9f, 0x99, 0x13, 0x1a, 0x6e, 0x01, 0x42, 0x3f,
+	0x86, 0xce, 0xd3, 0x20, 0x1a, 0x8c, 0x93, 0xe8, 0x35, 0x58, 0xe1, 0x91, 0x92, 0x17, 0x16, 0xd5,
+	0x2c, 0xb4, 0xe6, 0xf8, 0x28, 0x30, 0x19, 0xb8, 0xe1, 0xe2, 0x6f, 0x7a, 0x0b, 0x5a, 0x05, 0x6b,
+	0xcd, 0xbe, 0x03, 0x7d, 0x0f, 0xda, 0x16, 0xe9, 0x38, 0x48, 0x30, 0xca, 0xed, 0x0e, 0xd7, 0xa8,
+	0xda, 0x6e, 0x39, 0x80, 0xbe, 0x0d, 0xad, 0x43, 0x16, 0xb2, 0xc8, 0x43, 0xb3, 0x9c, 0xb3, 0x30,
+	0xe5, 0xcf, 0x99, 0xb2, 0x92, 0x8c, 0xd7, 0xf4, 0x2d, 0x68, 0xdd, 0x1f, 0x79, 0x61, 0x6a, 0x2c,
+	0x8a, 0xc9, 0x56, 0xb3, 0xca, 0x92, 0xed, 0xdf, 0x1c, 0x58, 0x3f, 0x91, 0x9c, 0xf7, 0x95, 0xce,
+	0x01, 0x3d, 0x68, 0x45, 0xc6, 0x0b, 0x32, 0xd1, 0xec, 0xb2, 0xaa, 0xae, 0x94, 0x2a, 0xd5, 0xba,
+	0xa9, 0x54, 0x78, 0x4e, 0x60, 0x13, 0xe8, 0x86, 0x8b, 0xbf, 0x75, 0x4e, 0xb3, 0xc9, 0x51, 0x9f,
+	0x86, 0xf9, 0x72, 0xdd, 0x2d, 0x82, 0x34, 0x86, 0x8d, 0x41, 0xc4, 0x30, 0xd9, 0xb1, 0x08, 0xa2,
+	0x5f, 0xd7, 0x81, 0x3c, 0xe0, 0x99, 0x5b, 0x3c, 0x53, 0x23, 0x91, 0x1c, 0xc8, 0xc1, 0x7c, 0x35,
+	0xe1, 0xc1, 0x8a, 0x49, 0xf5, 0xb0, 0x28, 0x7d, 0x11, 0xa4, 0x8d, 0x3e, 0x64, 0xa3, 0xfb, 0x91,
+	0x92, 0x01, 0x4f, 0xf0, 0x22, 0x1b, 0x6e, 0x01, 0x42, 0xee, 0xc0, 0x55, 0x6e, 0x34, 0xf8, 0x98,
+	0x0f, 0x63, 0x21, 0xc2, 0x7e, 0xcc, 0x23, 0x85, 0xb7, 0x5b, 0x73, 0x67, 0x6d, 0xe9, 0xa8, 0x0b,
+	0xa2, 0x22, 0x18, 0xef, 0xbb, 0xe6, 0x4e, 0x40, 0xc9, 0x01, 0x00, 0x0a, 0x72, 0xf0, 0x52, 0x71,
+	0xd9, 0x5b, 0x9d, 0xeb, 0xb8, 0xfa, 0xba, 0x47, 0xa9, 0x4c, 0x84, 0x74, 0x0b, 0x44, 0xf4, 0x09,
+	0x40, 0xbe, 0x33, 0xaf, 0x8f, 0x43, 0xcb, 0xd7, 0xf3, 0x32, 0x9b, 0x77, 0x1e, 0x0d, 0x2c, 0xfe,
+	0x66, 0x41, 0xff, 0xe2, 0xc0, 0xb5, 0x09, 0x1d, 0xbb, 0x3c, 0x0e, 0x2f, 0x8a, 0x5e, 0xbb, 0x5a,
+	0x8e, 0xbc, 0xdc, 0xad, 0x66, 0x30, 0xaf, 0x17, 0x98, 0x63, 0xd8, 0x7b, 0x32, 0x88, 0x95, 0x6d,
+	0x6c, 0xec, 0xaa, 0xe4, 0xbf, 0xcd, 0xb2, 0xff, 0x16, 0xae, 0xb4, 0x52, 0x6a, 0x68, 0xfe, 0xee,
+	0x40, 0x6f, 0x96, 0xa0, 0x18, 0x39, 0x9f, 0x41, 0x87, 0x15, 0x36, 0xd0, 0x2b, 0xda, 0x77, 0xdf,
+	0xab, 0x50, 0xed, 0x2c, 0x36, 0x6e, 0x89, 0x81, 0xb6, 0x54, 0xc4, 0x47, 0xca, 0xa8, 0x79, 0x41,
+	0x8a, 0x29, 0x5a, 0x2a, 0x27, 0xa2, 0x0f, 0xa1, 0xf3, 0x54, 0x06, 0x1e, 0x77, 0xf9, 0x57, 0x29,
+	0x37, 0xd1, 0xad, 0x23, 0x23, 0x51, 0x6c, 0x18, 0x5b, 0x73, 0xe5, 0x00, 0xad, 0x12, 0x2f, 0x95,
+	0x92, 0x47, 0xde, 0x45, 0x96, 0x69, 0xb3, 0x35, 0x7d, 0x01, 0x1b, 0x96, 0x53, 0xde, 0xcb, 0x95,
+	0x59, 0x35, 0x96, 0x64, 0xa5, 0xed, 0x14, 0x6b, 0x56, 0x68, 0x10, 0xc7, 0x35, 0x0b, 0xfa, 0x39,
+	0xb4, 0x0f, 0x4d, 0xb7, 0xc1, 0x7c, 0x2e, 0x2f, 0xf3, 0x3a, 0x30, 0xb8, 0x9a, 0x2a, 0x33, 0xb1,
+	0x59, 0xe9, 0x3c, 0xa3, 0x63, 0xb7, 0x9f, 0x9e, 0x2a, 0xc9, 0xb9, 0x2b, 0x84, 0xc2, 0xd8, 0xbd,
+	0x69, 0x23, 0xe0, 0x11, 0x3a, 0x8b, 0x63, 0x62, 0x2f, 0x87, 0x90, 0x3e, 0x74, 0x93, 0xb3, 0x80,
+	0x87, 0x3e, 0xf7, 0x9f, 0xea, 0x47, 0x8b, 0x27, 0x42, 0x3c, 0x6e, 0xf3, 0xee, 0x3b, 0x55, 0xad,
+	0xe6, 0x04, 0xba, 0x3b, 0xc5, 0x60, 0x51, 0xc0, 0xd3, 0xaf, 0x1d, 0x68, 0x17, 0x04, 0xd5, 0x0a,
+	0x94, 0x42, 0xa8, 0xe2, 0x0b, 0x20, 0x5b, 0xeb, 0xe4, 0xa0, 0x5f, 0x57, 0x21, 0x57, 0x41, 0x34,
+	0x30, 0x4a, 0xcb, 0x1b, 0xf7, 0x59, 0x5b, 0xe4, 0x23, 0xb8, 0x3e, 0x09, 0x36, 0xca, 0x6d, 0xa2,
+	0x72, 0x67, 0x6f, 0xd2, 0xff, 0x96, 0xe2, 0xf2, 0x61, 0x90, 0x28, 0x21, 0x2f, 0x16, 0x67, 0xbf,
+	0x6f, 0x5a, 0x15, 0x17, 0x26, 0x45, 0xd3, 0xd1, 0x8e, 0x5f, 0x4f, 0x27, 0xe2, 0x15, 0x8f, 0x6c,
+	0x31, 0x9f, 0xde, 0xc0, 0x36, 0x64, 0x34, 0x2b, 0x21, 0x96, 0xa1, 0x34, 0x85, 0xab, 0xe5, 0x1b,
+	0xde, 0xcf, 0x0a, 0xea, 0x54, 0x86, 0x99, 0x53, 0x90, 0xb0, 0xf8, 0x34, 0x0a, 0xc5, 0xe7, 0x26,
+	0x00, 0xe6, 0x93, 0x7b, 0x3c, 0x54, 0xcc, 0x66, 0x98, 0x02, 0x84, 0xfe, 0xc1, 0x81, 0x1b, 0x53,
+	0xca, 0x35, 0x69, 0xef, 0x1e, 0xb4, 0xb8, 0x55, 0x82, 0x49, 0x22, 0x7b, 0x15, 0x2a, 0x9c, 0x21,
+	0xb7, 0xdb, 0xe2, 0xf3, 0xb4, 0x55, 0xaf, 0xd0, 0x16, 0xfd, 0xb3, 0xb1, 0x75, 0xe1, 0xa9, 0x67,
+	0x73, 0x30, 0x85, 0x8e, 0x34, 0xd9, 0xa3, 0x18, 0x2f, 0x25, 0x18, 0x79, 0x00, 0x6d, 0x95, 0x13,
+	0x5a, 0xbb, 0x57, 0xf5, 0xcb, 0xe5, 0x07, 0xa5, 0x5b, 0xa4, 0xc4, 0xde, 0x46, 0x4a, 0x21, 0x6d,
+	0x69, 0x37, 0x0b, 0xfa, 0x6f, 0x07, 0xae, 0x14, 0x48, 0xfa, 0xd8, 0xb7, 0x91, 0x9f, 0xe0, 0x10,
+	0x40, 0x99, 0x66, 0xb1, 0x3a, 0x36, 0x27, 0x08, 0xb9, 0x6b, 0xa8, 0x2a, 0x6d, 0xa9, 0x9f, 0x24,
+	0x13, 0x21, 0x95, 0x03, 0xc8, 0x6d, 0xd8, 0xf0, 0x44, 0xf4, 0x32, 0xd0, 0x33, 0x0d, 0xad, 0x23,
+	0x1b, 0x40, 0x65, 0x20, 0xbe, 0x64, 0x47, 0x71, 0x20, 0x2f, 0x0a, 0x2f, 0xb5, 0x0d, 0xb7, 0x04,
+	0xa3, 0xef, 0xc0, 0x86, 0x89, 0x00, 0x11, 0x86, 0xa7, 0xcc, 0x7b, 0x55, 0x95, 0xf1, 0xe8, 0x9f,
+	0x1c, 0x3b, 0x3e, 0xe8, 0xa7, 0xa7, 0xa6, 0x76, 0x05, 0x22, 0xba, 0x7f, 0xae, 0x6b, 0xfe, 0x27,
+	0xe5, 0xe7, 0xff, 0xad, 0x0a, 0x15, 0x1c, 0x99, 0x51, 0x8c, 0x39, 0xce, 0x50, 0x90, 0x9f, 0xea,
+	0xfc, 0x62, 0x4e, 0xb6, 0xf6, 0xba, 0x3d, 0x37, 0x4e, 0x2d, 0xae, 0x3b, 0xa6, 0xa2, 0x9f, 0x42,
+	0x07, 0xb7, 0x8e, 0x85, 0xc7, 0x94, 0x90, 0xe4, 0x07, 0xb0, 0x8a, 0xac, 0x33, 0xa7, 0x5d, 0x34,
+	0x8c, 0xb0, 0xd8, 0x3a, 0x4b, 0x77, 0x6c, 0x2c, 0x9a, 0x5b, 0xfd, 0x08, 0x9a, 0xea, 0x22, 0x5e,
+	0x64, 0xd7, 0x22, 0xc9, 0xc9, 0x45, 0xcc, 0x5d, 0x24, 0x9a, 0xd9, 0x75, 0xdc, 0x81, 0xba, 0x1a,
+	0xd9, 0x11, 0xce, 0xce, 0x7c, 0x1d, 0x9d, 0x8c, 0xdc, 0xba, 0x1a, 0x15, 0x6c, 0xd1, 0x2c, 0xd9,
+	0xe2, 0x1f, 0x75, 0x68, 0xdb, 0x83, 0x71, 0x22, 0x40, 0xa0, 0x99, 0x04, 0xbf, 0xe5, 0xd6, 0x62,
+	0xf8, 0x5b, 0xfb, 0xf0, 0xe9, 0x85, 0xe2, 0x49, 0x36, 0x5d, 0xc1, 0x85, 0x7e, 0x58, 0xd9, 0xc6,
+	0x53, 0xb7, 0x6b, 0xfe, 0xc9, 0x28, 0xb1, 0x0f, 0x95, 0x49, 0xb0, 0x7e, 0xd3, 0x58, 0xd0, 0x67,
+	0xa9, 0x8a, 0x53, 0xa5, 0x51, 0x8d, 0x14, 0x53, 0x70, 0x8d, 0x5b, 0x78, 0x43, 0x8a, 0x48, 0xe3,
+	0x9a, 0x96, 0x65, 0x0a, 0xae, 0x1d, 0x5b, 0x84, 0x3e, 0x4f, 0xd4, 0xc1, 0xc0, 0x74, 0xba, 0x4d,
+	0x37, 0x07, 0xe8, 0x47, 0x24, 0xf3, 0xfd, 0x40, 0x23, 0x27, 0x4f, 0xb9, 0x7c, 0x1c, 0x44, 0xa9,
+	0xe2, 0x38, 0x06, 0x70, 0xdc, 0x19, 0x3b, 0x1a, 0x9f, 0x9f, 0x07, 0xde, 0x04, 0xfe, 0x9a, 0xc1,
+	0x9f, 0xde, 0xa1, 0x21, 0x5c, 0x79, 0xc0, 0x8d, 0x0b, 0x3e, 0x90, 0x22, 0x8d, 0xb1, 0x12, 0x8f,
+	0x2b, 0x85, 0x73, 0xf9, 0x4a, 0xa1, 0x98, 0x1c, 0x70, 0xd5, 0xd7, 0xda, 0x37, 0x8a, 0x2e, 0x40,
+	0xe8, 0x1f, 0xb3, 0x79, 0x21, 0x9e, 0x35, 0xd9, 0x8f, 0x3b, 0xd3, 0xfd, 0xb8, 0x9e, 0x4c, 0x45,
+	0x7e, 0xa9, 0x5f, 0xcf, 0x01, 0x63, 0x33, 0x37, 0x0a, 0x66, 0xee, 0x41, 0x4b, 0x8d, 0x8e, 0x44,
+	0x1a, 0x65, 0x3e, 0x92, 0x2d, 0xf5, 0x8e, 0x26, 0xd5, 0xf9, 0x63, 0x05, 0xbd, 0x30, 0x5b, 0xd2,
+	0x9f, 0xc3, 0x66, 0x2e, 0x15, 0x36, 0x8d, 0x9f, 0xc0, 0xea, 0x40, 0x2f, 0xb2, 0xa0, 0x99, 0xab,
+	0x02, 0x24, 0x73, 0x2d, 0xc1, 0xde, 0xef, 0x1d, 0xd8, 0x28, 0xcd, 0xb8, 0xc8, 0x16, 0xb4, 0x23,
+	0xa1, 0xcc, 0x9a, 0xfb, 0xdd, 0x1a, 0xd9, 0x80, 0xf5, 0x21, 0x0b, 0xf5, 0x04, 0x96, 0xfb, 0x5d,
+	0x87, 0x74, 0x60, 0x4d, 0x09, 0x71, 0xac, 0xd5, 0xd4, 0xad, 0x93, 0x36, 0xb4, 0x30, 0x21, 0x71,
+	0xbf, 0xdb, 0x20, 0x5d, 0x9b, 0xb1, 0xb4, 0x23, 0x0a, 0x11, 0x75, 0x9b, 0xa4, 0x07, 0xd7, 0x5e,
+	0x4b, 0x11, 0x0d, 0x8e, 0xca, 0xa3, 0xa2, 0xee, 0x0a, 0x21, 0xb0, 0x29, 0xed, 0x19, 0x87, 0x17,
+	0x4f, 0x84, 0xcf, 0xbb, 0xab, 0x7b, 0xef, 0x43, 0x77, 0xb2, 0x09, 0xd2, 0x07, 0x58, 0x87, 0xed,
+	0xd6, 0xf4, 0xc2, 0x7a, 0x64, 0xd7, 0xd9, 0x7b, 0x02, 0xdd, 0xc9, 0xb4, 0xac, 0x85, 0x8b, 0x84,
+	0xfa, 0x54, 0xa4, 0x91, 0x95, 0x3c, 0x88, 0x6c, 0xa4, 0x75, 0x1d, 0xb2, 0x0e, 0x2b, 0xc3, 0x20,
+	0xe2, 0x7e, 0xb7, 0xae, 0x4f, 0xb7, 0x62, 0x3f, 0x8b, 0x0c, 0xac, 0xb1, 0xf7, 0x33, 0xe8, 0x4e,
+	0xa6, 0x03, 0x7d, 0xa3, 0xa1, 0x81, 0x1d, 0xf8, 0x3e, 0x6a, 0x23, 0x87, 0x3c, 0x46, 0x3a, 0x47,
+	0xf3, 0x1a, 0x66, 0x74, 0x01, 0xea, 0xac, 0x7e, 0xf7, 0x7f, 0xd7, 0xe1, 0xca, 0x38, 0x19, 0xf4,
+	0x95, 0xe4, 0x6c, 0xc8, 0x25, 0xf9, 0x02, 0xde, 0x78, 0xc0, 0xd5, 0x71, 0xa0, 0xf8, 0x2f, 0xd1,
+	0x30, 0x05, 0xe7, 0x5a, 0x90, 0xe7, 0xb6, 0x17, 0xec, 0xd3, 0x1a, 0xf1, 0x60, 0xb3, 0x1c, 0x1b,
+	0x64, 0xb7, 0xfa, 0xd5, 0x50, 0x0e, 0xa1, 0xed, 0xb7, 0x17, 0x3a, 0x8c, 0xf6, 0x33, 0x5a, 0x23,
+	0x27, 0x78, 0xc8, 0x31, 0x8e, 0x83, 0x70, 0x93, 0x54, 0xa6, 0xc2, 0x6c, 0x10, 0xba, 0x84, 0xe8,
+	0x9f, 0xc3, 0x5a, 0x26, 0xd3, 0x42, 0x45, 0x2c, 0x53, 0x9e, 0x68, 0x8d, 0x7c, 0x01, 0x1b, 0x19,
+	0x4b, 0x33, 0xed, 0x5f, 0x9c, 0x16, 0x96, 0x64, 0x7d, 0xc7, 0x21, 0xbf, 0x86, 0xad, 0x8c, 0xb9,
+	0x79, 0x68, 0x24, 0xcb, 0xb0, 0xa7, 0xf3, 0x50, 0x0c, 0x1f, 0xe4, 0xee, 0xc3, 0x96, 0x2d, 0xd2,
+	0xa7, 0x1c, 0xf7, 0x92, 0x85, 0x4a, 0x99, 0xfb, 0x49, 0x61, 0xaa, 0xe2, 0xdb, 0x53, 0xa0, 0x7f,
+	0x11, 0x79, 0xf6, 0x80, 0x5b, 0xf3, 0x18, 0xd8, 0xda, 0xfc, 0x4d, 0x4e, 0xf9, 0x02, 0x3a, 0xba,
+	0x3d, 0x75, 0x5d, 0x17, 0xdf, 0x7d, 0x95, 0xe7, 0x14, 0xdf, 0x97, 0xdb, 0xb7, 0xe7, 0x23, 0x99,
+	0xa7, 0x23, 0xda, 0xf8, 0xea, 0x03, 0xae, 0x1f, 0xa9, 0x38, 0x72, 0x1b, 0x9f, 0xf1, 0x66, 0x05,
+	0x39, 0x4e, 0xe3, 0x97, 0x66, 0xfe, 0x1c, 0x3d, 0xbd, 0xf8, 0xd1, 0xe2, 0xdb, 0x55, 0xbd, 0xa1,
+	0xfd, 0x8e, 0xb2, 0xbd, 0x5c, 0xaf, 0x4a, 0x6b, 0x84, 0xa3, 0xff, 0x14, 0x60, 0xc9, 0x62, 0xe6,
+	0x73, 0x46, 0x00, 0x53, 0xed, 0x36, 0xad, 0xed, 0x3a, 0x77, 0x1c, 0xe2, 0x4d, 0x36, 0xe3, 0xb6,
+	0xe7, 0x5d, 0x78, 0xd6, 0xee, 0x72, 0x5d, 0x70, 0x9a, 0xd0, 0x1a, 0x79, 0x01, 0x5b, 0xba, 0x7e,
+	0x14, 0x15, 0xb5, 0x9c, 0x1e, 0x2a, 0xc3, 0xad, 0xf8, 0xb1, 0x87, 0xd6, 0x48, 0x02, 0x5d, 0x7d,
+	0x0b, 0xfb, 0x32, 0x3c, 0x19, 0x05, 0x7e, 0x42, 0x3e, 0x9a, 0x27, 0x60, 0xd5, 0x08, 0x76, 0x69,
+	0xfb, 0xdc, 0x71, 0xc8, 0x73, 0x20, 0x85, 0x43, 0xb3, 0x69, 0x25, 0x9d, 0xff, 0x82, 0xd2, 0x39,
+	0xb2, 0x3a, 0xdb, 0x19, 0x1e, 0xb4, 0x46, 0x7e, 0x03, 0xbd, 0x69, 0xde, 0xa6, 0x46, 0x90, 0x9b,
+	0xf3, 0x4f, 0x58, 0xcc, 0x7d, 0xd7, 0x21, 0x5f, 0x61, 0x93, 0x54, 0x7e, 0xd5, 0x91, 0xc5, 0x13,
+	0xa4, 0xfc, 0x65, 0xbe, 0xfd, 0xc1, 0xb2, 0xc8, 0xd6, 0xdb, 0xc8, 0x09, 0x86, 0xb9, 0xad, 0x9e,
+	0x27, 0xa3, 0xca, 0x6b, 0xd8, 0x79, 0xee, 0xf6, 0xc2, 0xfe, 0xd9, 0x26, 0x8f, 0x6e, 0xce, 0xd5,
+	0x2a, 0x68, 0x7e, 0x70, 0x5f, 0xc2, 0xc2, 0xbf, 0x2a, 0x32, 0xc7, 0x84, 0x95, 0x2c, 0x60, 0x7e,
+	0x6b, 0x89, 0x37, 0x04, 0xb2, 0x36, 0x45, 0xb2, 0xd8, 0xe1, 0xcf, 0x67, 0x4c, 0xe7, 0x33, 0xd6,
+	0x1c, 0x68, 0x8d, 0xb8, 0xa8, 0xe3, 0x7c, 0xe2, 0xbd, 0xa8, 0x26, 0xec, 0x54, 0xc6, 0x88, 0xe5,
+	0x40, 0x6b, 0xe4, 0x17, 0x40, 0xc6, 0xe5, 0x3c, 0xe7, 0x3c, 0x5f, 0xda, 0x65, 0xf8, 0xfa, 0x98,
+	0xe0, 0x8a, 0x23, 0x33, 0xf2, 0x6e, 0xb5, 0x4f, 0x4d, 0x8c, 0xd6, 0x2a, 0xf5, 0x51, 0xc0, 0x43,
+	0x3d, 0x0b, 0xd8, 0xca, 0x3d, 0xd2, 0x4c, 0x3b, 0xdf, 0x5d, 0x6e, 0x50, 0xaa, 0x4f, 0xf9, 0xf0,
+	0x12, 0x33, 0x55, 0xdb, 0xfd, 0x24, 0x70, 0x7d, 0x62, 0xd7, 0x7a, 0xe5, 0x25, 0x8e, 0xbd, 0xcc,
+	0x28, 0x17, 0x6f, 0xe9, 0x62, 0x27, 0x53, 0xf8, 0x80, 0x3c, 0xdf, 0x3c, 0x55, 0x8d, 0x48, 0xce,
+	0x80, 0xd6, 0xc8, 0x13, 0x68, 0xea, 0xaf, 0x41, 0x95, 0x95, 0x20, 0xfb, 0xac, 0x54, 0xe9, 0xf3,
+	0xc5, 0x6f, 0x49, 0xb4, 0x76, 0xf8, 0xad, 0xe7, 0x37, 0x42, 0xcd, 0xdf, 0x60, 0xf9, 0x1f, 0x9a,
+	0xbf, 0x32, 0xf6, 0xfe, 0x59, 0xaf, 0x9d, 0xae, 0xe2, 0x3f, 0x6d, 0x7c, 0xef, 0xff, 0x03, 0x00,
+	0xd8, 0x5b, 0x5b, 0xfe, 0xf3, 0x21, 0x00, 0x00,
 }
@@ -23,23 +23,48 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type CompactTxStreamerClient interface {
 	GetLiteWalletBlockGroup(ctx context.Context, in *BlockID, opts ...grpc.CallOption) (*BlockID, error)
+	// Return the boundaries of the groups of blocks (of about the requested
+	// size) in the given range, so downloads can be planned in parallel
+	GetBlockGroups(ctx context.Context, in *GetBlockGroupsArg, opts ...grpc.CallOption) (*BlockGroupList, error)
 	// Return the height of the tip of the best chain
 	GetLatestBlock(ctx context.Context, in *ChainSpec, opts ...grpc.CallOption) (*BlockID, error)
 	// Return the compact block corresponding to the given block identifier
 	GetBlock(ctx context.Context, in *BlockID, opts ...grpc.CallOption) (*CompactBlock, error)
 	// Return a list of consecutive compact blocks
 	GetBlockRange(ctx context.Context, in *BlockRange, opts ...grpc.CallOption) (CompactTxStreamer_GetBlockRangeClient, error)
+	// Return the full headers of a range of consecutive blocks
+	GetBlockHeaders(ctx context.Context, in *BlockRange, opts ...grpc.CallOption) (CompactTxStreamer_GetBlockHeadersClient, error)
+	// Return the compact blocks from the given height (only the height is
+	// used) to the tip, then each new block as it arrives, with a rollback
+	// whenever a reorg removes blocks that were already sent
+	SubscribeBlocks(ctx context.Context, in *BlockID, opts ...grpc.CallOption) (CompactTxStreamer_SubscribeBlocksClient, error)
+	// Find the highest of the locator's blocks that's still in the best
+	// chain (the fork point); return a rollback to it if it's below the
+	// locator's highest block, then the compact blocks from there to the
+	// tip (with a rollback if a reorg happens meanwhile). The server may
+	// limit the number of blocks per call; if it stops short of the tip, it
+	// returns a BlockRangeContinuation in the "continuation-bin" trailer,
+	// and the next call's locator should start with its previous block.
+	SyncBlocks(ctx context.Context, in *BlockLocator, opts ...grpc.CallOption) (CompactTxStreamer_SyncBlocksClient, error)
 	// Get the historical and current prices
 	GetARRRPrice(ctx context.Context, in *PriceRequest, opts ...grpc.CallOption) (*PriceResponse, error)
 	GetCurrentARRRPrice(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*PriceResponse, error)
 	// Return the requested full (not compact) transaction (as from pirated)
 	GetTransaction(ctx context.Context, in *TxFilter, opts ...grpc.CallOption) (*RawTransaction, error)
+	// Return the requested full transactions, looked up concurrently; an
+	// error looking up one transaction doesn't end the stream
+	GetTransactions(ctx context.Context, opts ...grpc.CallOption) (CompactTxStreamer_GetTransactionsClient, error)
+	// Return whether the given transaction (by txid) is mined, in the mempool, or expired
+	GetTransactionStatus(ctx context.Context, in *TxFilter, opts ...grpc.CallOption) (*TransactionStatus, error)
 	// Submit the given transaction to the Zcash network
 	SendTransaction(ctx context.Context, in *RawTransaction, opts ...grpc.CallOption) (*SendResponse, error)
 	// Return the txids corresponding to the given t-address within the given block range
 	GetTaddressTxids(ctx context.Context, in *TransparentAddressBlockFilter, opts ...grpc.CallOption) (CompactTxStreamer_GetTaddressTxidsClient, error)
 	GetTaddressBalance(ctx context.Context, in *AddressList, opts ...grpc.CallOption) (*Balance, error)
 	GetTaddressBalanceStream(ctx context.Context, opts ...grpc.CallOption) (CompactTxStreamer_GetTaddressBalanceStreamClient, error)
+	// Return a page of the transaction history of the given t-addresses,
+	// including mempool transactions
+	GetAddressHistory(ctx context.Context, in *GetAddressHistoryArg, opts ...grpc.CallOption) (*GetAddressHistoryReply, error)
 	// Return the compact transactions currently in the mempool; the results
 	// can be a few seconds out of date. If the Exclude list is empty, return
 	// all transactions; otherwise return all *except* those in the Exclude list
@@ -53,11 +78,22 @@ type CompactTxStreamerClient interface {
 	// Return a stream of current Mempool transactions. This will keep the output stream open while
 	// there are mempool transactions. It will close the returned stream when a new block is mined.
 	GetMempoolStream(ctx context.Context, in *Empty, opts ...grpc.CallOption) (CompactTxStreamer_GetMempoolStreamClient, error)
+	// Return a stream of events for shielded mempool transactions: each
+	// transaction (in compact form) as it enters the mempool, then when it's
+	// mined or evicted. Unlike GetMempoolStream, this continues across blocks.
+	GetMempoolEvents(ctx context.Context, in *Empty, opts ...grpc.CallOption) (CompactTxStreamer_GetMempoolEventsClient, error)
+	// Return statistics about the mempool
+	GetMempoolInfo(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*MempoolInfo, error)
 	// GetTreeState returns the note commitment tree state corresponding to the given block.
 	// See section 3.7 of the Zcash protocol specification. It returns several other useful
 	// values also (even though they can be obtained using GetBlock).
 	// The block can be specified by either height or hash.
 	GetTreeState(ctx context.Context, in *BlockID, opts ...grpc.CallOption) (*TreeState, error)
+	// GetLatestTreeState returns the note commitment tree state of the latest block.
+	GetLatestTreeState(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*TreeState, error)
+	// Returns a stream of information about roots of subtrees of the note
+	// commitment tree for the specified shielded protocol (Sapling or Orchard).
+	GetSubtreeRoots(ctx context.Context, in *GetSubtreeRootsArg, opts ...grpc.CallOption) (CompactTxStreamer_GetSubtreeRootsClient, error)
 	GetAddressUtxos(ctx context.Context, in *GetAddressUtxosArg, opts ...grpc.CallOption) (*GetAddressUtxosReplyList, error)
 	GetAddressUtxosStream(ctx context.Context, in *GetAddressUtxosArg, opts ...grpc.CallOption) (CompactTxStreamer_GetAddressUtxosStreamClient, error)
 	// Return information about this lightwalletd instance and the blockchain
@@ -83,6 +119,15 @@ func (c *compactTxStreamerClient) GetLiteWalletBlockGroup(ctx context.Context, i
 	return out, nil
 }
 
+func (c *compactTxStreamerClient) GetBlockGroups(ctx context.Context, in *GetBlockGroupsArg, opts ...grpc.CallOption) (*BlockGroupList, error) {
+	out := new(BlockGroupList)
+	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetBlockGroups", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *compactTxStreamerClient) GetLatestBlock(ctx context.Context, in *ChainSpec, opts ...grpc.CallOption) (*BlockID, error) {
 	out := new(BlockID)
 	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetLatestBlock", in, out, opts...)
@@ -133,6 +178,102 @@ func (x *compactTxStreamerGetBlockRangeClient) Recv() (*CompactBlock, error) {
 	return m, nil
 }
 
+func (c *compactTxStreamerClient) GetBlockHeaders(ctx context.Context, in *BlockRange, opts ...grpc.CallOption) (CompactTxStreamer_GetBlockHeadersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[1], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetBlockHeaders", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &compactTxStreamerGetBlockHeadersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CompactTxStreamer_GetBlockHeadersClient interface {
+	Recv() (*BlockHeader, error)
+	grpc.ClientStream
+}
+
+type compactTxStreamerGetBlockHeadersClient struct {
+	grpc.ClientStream
+}
+
+func (x *compactTxStreamerGetBlockHeadersClient) Recv() (*BlockHeader, error) {
+	m := new(BlockHeader)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *compactTxStreamerClient) SubscribeBlocks(ctx context.Context, in *BlockID, opts ...grpc.CallOption) (CompactTxStreamer_SubscribeBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[2], "/pirate.wallet.sdk.rpc.CompactTxStreamer/SubscribeBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &compactTxStreamerSubscribeBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CompactTxStreamer_SubscribeBlocksClient interface {
+	Recv() (*BlockSubscriptionEvent, error)
+	grpc.ClientStream
+}
+
+type compactTxStreamerSubscribeBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *compactTxStreamerSubscribeBlocksClient) Recv() (*BlockSubscriptionEvent, error) {
+	m := new(BlockSubscriptionEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *compactTxStreamerClient) SyncBlocks(ctx context.Context, in *BlockLocator, opts ...grpc.CallOption) (CompactTxStreamer_SyncBlocksClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[3], "/pirate.wallet.sdk.rpc.CompactTxStreamer/SyncBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &compactTxStreamerSyncBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CompactTxStreamer_SyncBlocksClient interface {
+	Recv() (*BlockSubscriptionEvent, error)
+	grpc.ClientStream
+}
+
+type compactTxStreamerSyncBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *compactTxStreamerSyncBlocksClient) Recv() (*BlockSubscriptionEvent, error) {
+	m := new(BlockSubscriptionEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *compactTxStreamerClient) GetARRRPrice(ctx context.Context, in *PriceRequest, opts ...grpc.CallOption) (*PriceResponse, error) {
 	out := new(PriceResponse)
 	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetARRRPrice", in, out, opts...)
@@ -160,6 +301,46 @@ func (c *compactTxStreamerClient) GetTransaction(ctx context.Context, in *TxFilt
 	return out, nil
 }
 
+func (c *compactTxStreamerClient) GetTransactions(ctx context.Context, opts ...grpc.CallOption) (CompactTxStreamer_GetTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[4], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTransactions", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &compactTxStreamerGetTransactionsClient{stream}
+	return x, nil
+}
+
+type CompactTxStreamer_GetTransactionsClient interface {
+	Send(*TxFilter) error
+	Recv() (*GetTransactionsReply, error)
+	grpc.ClientStream
+}
+
+type compactTxStreamerGetTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *compactTxStreamerGetTransactionsClient) Send(m *TxFilter) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *compactTxStreamerGetTransactionsClient) Recv() (*GetTransactionsReply, error) {
+	m := new(GetTransactionsReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *compactTxStreamerClient) GetTransactionStatus(ctx context.Context, in *TxFilter, opts ...grpc.CallOption) (*TransactionStatus, error) {
+	out := new(TransactionStatus)
+	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTransactionStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *compactTxStreamerClient) SendTransaction(ctx context.Context, in *RawTransaction, opts ...grpc.CallOption) (*SendResponse, error) {
 	out := new(SendResponse)
 	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/SendTransaction", in, out, opts...)
@@ -170,7 +351,7 @@ func (c *compactTxStreamerClient) SendTransaction(ctx context.Context, in *RawTr
 }
 
 func (c *compactTxStreamerClient) GetTaddressTxids(ctx context.Context, in *TransparentAddressBlockFilter, opts ...grpc.CallOption) (CompactTxStreamer_GetTaddressTxidsClient, error) {
-	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[1], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTaddressTxids", opts...)
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[5], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTaddressTxids", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -211,7 +392,7 @@ func (c *compactTxStreamerClient) GetTaddressBalance(ctx context.Context, in *Ad
 }
 
 func (c *compactTxStreamerClient) GetTaddressBalanceStream(ctx context.Context, opts ...grpc.CallOption) (CompactTxStreamer_GetTaddressBalanceStreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[2], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTaddressBalanceStream", opts...)
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[6], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTaddressBalanceStream", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -244,8 +425,17 @@ func (x *compactTxStreamerGetTaddressBalanceStreamClient) CloseAndRecv() (*Balan
 	return m, nil
 }
 
+func (c *compactTxStreamerClient) GetAddressHistory(ctx context.Context, in *GetAddressHistoryArg, opts ...grpc.CallOption) (*GetAddressHistoryReply, error) {
+	out := new(GetAddressHistoryReply)
+	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetAddressHistory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *compactTxStreamerClient) GetMempoolTx(ctx context.Context, in *Exclude, opts ...grpc.CallOption) (CompactTxStreamer_GetMempoolTxClient, error) {
-	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[3], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolTx", opts...)
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[7], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolTx", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -277,7 +467,7 @@ func (x *compactTxStreamerGetMempoolTxClient) Recv() (*CompactTx, error) {
 }
 
 func (c *compactTxStreamerClient) GetMempoolStream(ctx context.Context, in *Empty, opts ...grpc.CallOption) (CompactTxStreamer_GetMempoolStreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[4], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolStream", opts...)
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[8], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolStream", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -308,6 +498,47 @@ func (x *compactTxStreamerGetMempoolStreamClient) Recv() (*RawTransaction, error
 	return m, nil
 }
 
+func (c *compactTxStreamerClient) GetMempoolEvents(ctx context.Context, in *Empty, opts ...grpc.CallOption) (CompactTxStreamer_GetMempoolEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[9], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &compactTxStreamerGetMempoolEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CompactTxStreamer_GetMempoolEventsClient interface {
+	Recv() (*MempoolEvent, error)
+	grpc.ClientStream
+}
+
+type compactTxStreamerGetMempoolEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *compactTxStreamerGetMempoolEventsClient) Recv() (*MempoolEvent, error) {
+	m := new(MempoolEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *compactTxStreamerClient) GetMempoolInfo(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*MempoolInfo, error) {
+	out := new(MempoolInfo)
+	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolInfo", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *compactTxStreamerClient) GetTreeState(ctx context.Context, in *BlockID, opts ...grpc.CallOption) (*TreeState, error) {
 	out := new(TreeState)
 	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTreeState", in, out, opts...)
@@ -317,6 +548,47 @@ func (c *compactTxStreamerClient) GetTreeState(ctx context.Context, in *BlockID,
 	return out, nil
 }
 
+func (c *compactTxStreamerClient) GetLatestTreeState(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*TreeState, error) {
+	out := new(TreeState)
+	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetLatestTreeState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *compactTxStreamerClient) GetSubtreeRoots(ctx context.Context, in *GetSubtreeRootsArg, opts ...grpc.CallOption) (CompactTxStreamer_GetSubtreeRootsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[10], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetSubtreeRoots", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &compactTxStreamerGetSubtreeRootsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CompactTxStreamer_GetSubtreeRootsClient interface {
+	Recv() (*SubtreeRoot, error)
+	grpc.ClientStream
+}
+
+type compactTxStreamerGetSubtreeRootsClient struct {
+	grpc.ClientStream
+}
+
+func (x *compactTxStreamerGetSubtreeRootsClient) Recv() (*SubtreeRoot, error) {
+	m := new(SubtreeRoot)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *compactTxStreamerClient) GetAddressUtxos(ctx context.Context, in *GetAddressUtxosArg, opts ...grpc.CallOption) (*GetAddressUtxosReplyList, error) {
 	out := new(GetAddressUtxosReplyList)
 	err := c.cc.Invoke(ctx, "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetAddressUtxos", in, out, opts...)
@@ -327,7 +599,7 @@ func (c *compactTxStreamerClient) GetAddressUtxos(ctx context.Context, in *GetAd
 }
 
 func (c *compactTxStreamerClient) GetAddressUtxosStream(ctx context.Context, in *GetAddressUtxosArg, opts ...grpc.CallOption) (CompactTxStreamer_GetAddressUtxosStreamClient, error) {
-	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[5], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetAddressUtxosStream", opts...)
+	stream, err := c.cc.NewStream(ctx, &CompactTxStreamer_ServiceDesc.Streams[11], "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetAddressUtxosStream", opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -381,23 +653,48 @@ func (c *compactTxStreamerClient) Ping(ctx context.Context, in *Duration, opts .
 // for forward compatibility
 type CompactTxStreamerServer interface {
 	GetLiteWalletBlockGroup(context.Context, *BlockID) (*BlockID, error)
+	// Return the boundaries of the groups of blocks (of about the requested
+	// size) in the given range, so downloads can be planned in parallel
+	GetBlockGroups(context.Context, *GetBlockGroupsArg) (*BlockGroupList, error)
 	// Return the height of the tip of the best chain
 	GetLatestBlock(context.Context, *ChainSpec) (*BlockID, error)
 	// Return the compact block corresponding to the given block identifier
 	GetBlock(context.Context, *BlockID) (*CompactBlock, error)
 	// Return a list of consecutive compact blocks
 	GetBlockRange(*BlockRange, CompactTxStreamer_GetBlockRangeServer) error
+	// Return the full headers of a range of consecutive blocks
+	GetBlockHeaders(*BlockRange, CompactTxStreamer_GetBlockHeadersServer) error
+	// Return the compact blocks from the given height (only the height is
+	// used) to the tip, then each new block as it arrives, with a rollback
+	// whenever a reorg removes blocks that were already sent
+	SubscribeBlocks(*BlockID, CompactTxStreamer_SubscribeBlocksServer) error
+	// Find the highest of the locator's blocks that's still in the best
+	// chain (the fork point); return a rollback to it if it's below the
+	// locator's highest block, then the compact blocks from there to the
+	// tip (with a rollback if a reorg happens meanwhile). The server may
+	// limit the number of blocks per call; if it stops short of the tip, it
+	// returns a BlockRangeContinuation in the "continuation-bin" trailer,
+	// and the next call's locator should start with its previous block.
+	SyncBlocks(*BlockLocator, CompactTxStreamer_SyncBlocksServer) error
 	// Get the historical and current prices
 	GetARRRPrice(context.Context, *PriceRequest) (*PriceResponse, error)
 	GetCurrentARRRPrice(context.Context, *Empty) (*PriceResponse, error)
 	// Return the requested full (not compact) transaction (as from pirated)
 	GetTransaction(context.Context, *TxFilter) (*RawTransaction, error)
+	// Return the requested full transactions, looked up concurrently; an
+	// error looking up one transaction doesn't end the stream
+	GetTransactions(CompactTxStreamer_GetTransactionsServer) error
+	// Return whether the given transaction (by txid) is mined, in the mempool, or expired
+	GetTransactionStatus(context.Context, *TxFilter) (*TransactionStatus, error)
 	// Submit the given transaction to the Zcash network
 	SendTransaction(context.Context, *RawTransaction) (*SendResponse, error)
 	// Return the txids corresponding to the given t-address within the given block range
 	GetTaddressTxids(*TransparentAddressBlockFilter, CompactTxStreamer_GetTaddressTxidsServer) error
 	GetTaddressBalance(context.Context, *AddressList) (*Balance, error)
 	GetTaddressBalanceStream(CompactTxStreamer_GetTaddressBalanceStreamServer) error
+	// Return a page of the transaction history of the given t-addresses,
+	// including mempool transactions
+	GetAddressHistory(context.Context, *GetAddressHistoryArg) (*GetAddressHistoryReply, error)
 	// Return the compact transactions currently in the mempool; the results
 	// can be a few seconds out of date. If the Exclude list is empty, return
 	// all transactions; otherwise return all *except* those in the Exclude list
@@ -411,11 +708,22 @@ type CompactTxStreamerServer interface {
 	// Return a stream of current Mempool transactions. This will keep the output stream open while
 	// there are mempool transactions. It will close the returned stream when a new block is mined.
 	GetMempoolStream(*Empty, CompactTxStreamer_GetMempoolStreamServer) error
+	// Return a stream of events for shielded mempool transactions: each
+	// transaction (in compact form) as it enters the mempool, then when it's
+	// mined or evicted. Unlike GetMempoolStream, this continues across blocks.
+	GetMempoolEvents(*Empty, CompactTxStreamer_GetMempoolEventsServer) error
+	// Return statistics about the mempool
+	GetMempoolInfo(context.Context, *Empty) (*MempoolInfo, error)
 	// GetTreeState returns the note commitment tree state corresponding to the given block.
 	// See section 3.7 of the Zcash protocol specification. It returns several other useful
 	// values also (even though they can be obtained using GetBlock).
 	// The block can be specified by either height or hash.
 	GetTreeState(context.Context, *BlockID) (*TreeState, error)
+	// GetLatestTreeState returns the note commitment tree state of the latest block.
+	GetLatestTreeState(context.Context, *Empty) (*TreeState, error)
+	// Returns a stream of information about roots of subtrees of the note
+	// commitment tree for the specified shielded protocol (Sapling or Orchard).
+	GetSubtreeRoots(*GetSubtreeRootsArg, CompactTxStreamer_GetSubtreeRootsServer) error
 	GetAddressUtxos(context.Context, *GetAddressUtxosArg) (*GetAddressUtxosReplyList, error)
 	GetAddressUtxosStream(*GetAddressUtxosArg, CompactTxStreamer_GetAddressUtxosStreamServer) error
 	// Return information about this lightwalletd instance and the blockchain
@@ -432,6 +740,9 @@ type UnimplementedCompactTxStreamerServer struct {
 func (UnimplementedCompactTxStreamerServer) GetLiteWalletBlockGroup(context.Context, *BlockID) (*BlockID, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLiteWalletBlockGroup not implemented")
 }
+func (UnimplementedCompactTxStreamerServer) GetBlockGroups(context.Context, *GetBlockGroupsArg) (*BlockGroupList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBlockGroups not implemented")
+}
 func (UnimplementedCompactTxStreamerServer) GetLatestBlock(context.Context, *ChainSpec) (*BlockID, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetLatestBlock not implemented")
 }
@@ -441,6 +752,15 @@ func (UnimplementedCompactTxStreamerServer) GetBlock(context.Context, *BlockID)
 func (UnimplementedCompactTxStreamerServer) GetBlockRange(*BlockRange, CompactTxStreamer_GetBlockRangeServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetBlockRange not implemented")
 }
+func (UnimplementedCompactTxStreamerServer) GetBlockHeaders(*BlockRange, CompactTxStreamer_GetBlockHeadersServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetBlockHeaders not implemented")
+}
+func (UnimplementedCompactTxStreamerServer) SubscribeBlocks(*BlockID, CompactTxStreamer_SubscribeBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeBlocks not implemented")
+}
+func (UnimplementedCompactTxStreamerServer) SyncBlocks(*BlockLocator, CompactTxStreamer_SyncBlocksServer) error {
+	return status.Errorf(codes.Unimplemented, "method SyncBlocks not implemented")
+}
 func (UnimplementedCompactTxStreamerServer) GetARRRPrice(context.Context, *PriceRequest) (*PriceResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetARRRPrice not implemented")
 }
@@ -450,6 +770,12 @@ func (UnimplementedCompactTxStreamerServer) GetCurrentARRRPrice(context.Context,
 func (UnimplementedCompactTxStreamerServer) GetTransaction(context.Context, *TxFilter) (*RawTransaction, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTransaction not implemented")
 }
+func (UnimplementedCompactTxStreamerServer) GetTransactions(CompactTxStreamer_GetTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetTransactions not implemented")
+}
+func (UnimplementedCompactTxStreamerServer) GetTransactionStatus(context.Context, *TxFilter) (*TransactionStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTransactionStatus not implemented")
+}
 func (UnimplementedCompactTxStreamerServer) SendTransaction(context.Context, *RawTransaction) (*SendResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SendTransaction not implemented")
 }
@@ -462,15 +788,30 @@ func (UnimplementedCompactTxStreamerServer) GetTaddressBalance(context.Context,
 func (UnimplementedCompactTxStreamerServer) GetTaddressBalanceStream(CompactTxStreamer_GetTaddressBalanceStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetTaddressBalanceStream not implemented")
 }
+func (UnimplementedCompactTxStreamerServer) GetAddressHistory(context.Context, *GetAddressHistoryArg) (*GetAddressHistoryReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAddressHistory not implemented")
+}
 func (UnimplementedCompactTxStreamerServer) GetMempoolTx(*Exclude, CompactTxStreamer_GetMempoolTxServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetMempoolTx not implemented")
 }
 func (UnimplementedCompactTxStreamerServer) GetMempoolStream(*Empty, CompactTxStreamer_GetMempoolStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method GetMempoolStream not implemented")
 }
+func (UnimplementedCompactTxStreamerServer) GetMempoolEvents(*Empty, CompactTxStreamer_GetMempoolEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetMempoolEvents not implemented")
+}
+func (UnimplementedCompactTxStreamerServer) GetMempoolInfo(context.Context, *Empty) (*MempoolInfo, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMempoolInfo not implemented")
+}
 func (UnimplementedCompactTxStreamerServer) GetTreeState(context.Context, *BlockID) (*TreeState, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetTreeState not implemented")
 }
+func (UnimplementedCompactTxStreamerServer) GetLatestTreeState(context.Context, *Empty) (*TreeState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLatestTreeState not implemented")
+}
+func (UnimplementedCompactTxStreamerServer) GetSubtreeRoots(*GetSubtreeRootsArg, CompactTxStreamer_GetSubtreeRootsServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetSubtreeRoots not implemented")
+}
 func (UnimplementedCompactTxStreamerServer) GetAddressUtxos(context.Context, *GetAddressUtxosArg) (*GetAddressUtxosReplyList, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetAddressUtxos not implemented")
 }
@@ -514,6 +855,24 @@ func _CompactTxStreamer_GetLiteWalletBlockGroup_Handler(srv interface{}, ctx con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CompactTxStreamer_GetBlockGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBlockGroupsArg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompactTxStreamerServer).GetBlockGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetBlockGroups",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompactTxStreamerServer).GetBlockGroups(ctx, req.(*GetBlockGroupsArg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _CompactTxStreamer_GetLatestBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ChainSpec)
 	if err := dec(in); err != nil {
@@ -571,6 +930,69 @@ func (x *compactTxStreamerGetBlockRangeServer) Send(m *CompactBlock) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _CompactTxStreamer_GetBlockHeaders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlockRange)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CompactTxStreamerServer).GetBlockHeaders(m, &compactTxStreamerGetBlockHeadersServer{stream})
+}
+
+type CompactTxStreamer_GetBlockHeadersServer interface {
+	Send(*BlockHeader) error
+	grpc.ServerStream
+}
+
+type compactTxStreamerGetBlockHeadersServer struct {
+	grpc.ServerStream
+}
+
+func (x *compactTxStreamerGetBlockHeadersServer) Send(m *BlockHeader) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CompactTxStreamer_SubscribeBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlockID)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CompactTxStreamerServer).SubscribeBlocks(m, &compactTxStreamerSubscribeBlocksServer{stream})
+}
+
+type CompactTxStreamer_SubscribeBlocksServer interface {
+	Send(*BlockSubscriptionEvent) error
+	grpc.ServerStream
+}
+
+type compactTxStreamerSubscribeBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *compactTxStreamerSubscribeBlocksServer) Send(m *BlockSubscriptionEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CompactTxStreamer_SyncBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlockLocator)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CompactTxStreamerServer).SyncBlocks(m, &compactTxStreamerSyncBlocksServer{stream})
+}
+
+type CompactTxStreamer_SyncBlocksServer interface {
+	Send(*BlockSubscriptionEvent) error
+	grpc.ServerStream
+}
+
+type compactTxStreamerSyncBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *compactTxStreamerSyncBlocksServer) Send(m *BlockSubscriptionEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _CompactTxStreamer_GetARRRPrice_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(PriceRequest)
 	if err := dec(in); err != nil {
@@ -625,6 +1047,50 @@ func _CompactTxStreamer_GetTransaction_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CompactTxStreamer_GetTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CompactTxStreamerServer).GetTransactions(&compactTxStreamerGetTransactionsServer{stream})
+}
+
+type CompactTxStreamer_GetTransactionsServer interface {
+	Send(*GetTransactionsReply) error
+	Recv() (*TxFilter, error)
+	grpc.ServerStream
+}
+
+type compactTxStreamerGetTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *compactTxStreamerGetTransactionsServer) Send(m *GetTransactionsReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *compactTxStreamerGetTransactionsServer) Recv() (*TxFilter, error) {
+	m := new(TxFilter)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _CompactTxStreamer_GetTransactionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxFilter)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompactTxStreamerServer).GetTransactionStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetTransactionStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompactTxStreamerServer).GetTransactionStatus(ctx, req.(*TxFilter))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _CompactTxStreamer_SendTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RawTransaction)
 	if err := dec(in); err != nil {
@@ -708,6 +1174,24 @@ func (x *compactTxStreamerGetTaddressBalanceStreamServer) Recv() (*Address, erro
 	return m, nil
 }
 
+func _CompactTxStreamer_GetAddressHistory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAddressHistoryArg)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompactTxStreamerServer).GetAddressHistory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetAddressHistory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompactTxStreamerServer).GetAddressHistory(ctx, req.(*GetAddressHistoryArg))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _CompactTxStreamer_GetMempoolTx_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(Exclude)
 	if err := stream.RecvMsg(m); err != nil {
@@ -750,6 +1234,45 @@ func (x *compactTxStreamerGetMempoolStreamServer) Send(m *RawTransaction) error
 	return x.ServerStream.SendMsg(m)
 }
 
+func _CompactTxStreamer_GetMempoolEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Empty)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CompactTxStreamerServer).GetMempoolEvents(m, &compactTxStreamerGetMempoolEventsServer{stream})
+}
+
+type CompactTxStreamer_GetMempoolEventsServer interface {
+	Send(*MempoolEvent) error
+	grpc.ServerStream
+}
+
+type compactTxStreamerGetMempoolEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *compactTxStreamerGetMempoolEventsServer) Send(m *MempoolEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CompactTxStreamer_GetMempoolInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompactTxStreamerServer).GetMempoolInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetMempoolInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompactTxStreamerServer).GetMempoolInfo(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _CompactTxStreamer_GetTreeState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(BlockID)
 	if err := dec(in); err != nil {
@@ -768,6 +1291,45 @@ func _CompactTxStreamer_GetTreeState_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CompactTxStreamer_GetLatestTreeState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CompactTxStreamerServer).GetLatestTreeState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pirate.wallet.sdk.rpc.CompactTxStreamer/GetLatestTreeState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CompactTxStreamerServer).GetLatestTreeState(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CompactTxStreamer_GetSubtreeRoots_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetSubtreeRootsArg)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CompactTxStreamerServer).GetSubtreeRoots(m, &compactTxStreamerGetSubtreeRootsServer{stream})
+}
+
+type CompactTxStreamer_GetSubtreeRootsServer interface {
+	Send(*SubtreeRoot) error
+	grpc.ServerStream
+}
+
+type compactTxStreamerGetSubtreeRootsServer struct {
+	grpc.ServerStream
+}
+
+func (x *compactTxStreamerGetSubtreeRootsServer) Send(m *SubtreeRoot) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _CompactTxStreamer_GetAddressUtxos_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetAddressUtxosArg)
 	if err := dec(in); err != nil {
@@ -854,6 +1416,10 @@ var CompactTxStreamer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetLiteWalletBlockGroup",
 			Handler:    _CompactTxStreamer_GetLiteWalletBlockGroup_Handler,
 		},
+		{
+			MethodName: "GetBlockGroups",
+			Handler:    _CompactTxStreamer_GetBlockGroups_Handler,
+		},
 		{
 			MethodName: "GetLatestBlock",
 			Handler:    _CompactTxStreamer_GetLatestBlock_Handler,
@@ -874,6 +1440,10 @@ var CompactTxStreamer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTransaction",
 			Handler:    _CompactTxStreamer_GetTransaction_Handler,
 		},
+		{
+			MethodName: "GetTransactionStatus",
+			Handler:    _CompactTxStreamer_GetTransactionStatus_Handler,
+		},
 		{
 			MethodName: "SendTransaction",
 			Handler:    _CompactTxStreamer_SendTransaction_Handler,
@@ -882,10 +1452,22 @@ var CompactTxStreamer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetTaddressBalance",
 			Handler:    _CompactTxStreamer_GetTaddressBalance_Handler,
 		},
+		{
+			MethodName: "GetAddressHistory",
+			Handler:    _CompactTxStreamer_GetAddressHistory_Handler,
+		},
+		{
+			MethodName: "GetMempoolInfo",
+			Handler:    _CompactTxStreamer_GetMempoolInfo_Handler,
+		},
 		{
 			MethodName: "GetTreeState",
 			Handler:    _CompactTxStreamer_GetTreeState_Handler,
 		},
+		{
+			MethodName: "GetLatestTreeState",
+			Handler:    _CompactTxStreamer_GetLatestTreeState_Handler,
+		},
 		{
 			MethodName: "GetAddressUtxos",
 			Handler:    _CompactTxStreamer_GetAddressUtxos_Handler,
@@ -905,6 +1487,27 @@ var CompactTxStreamer_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _CompactTxStreamer_GetBlockRange_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetBlockHeaders",
+			Handler:       _CompactTxStreamer_GetBlockHeaders_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeBlocks",
+			Handler:       _CompactTxStreamer_SubscribeBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SyncBlocks",
+			Handler:       _CompactTxStreamer_SyncBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetTransactions",
+			Handler:       _CompactTxStreamer_GetTransactions_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "GetTaddressTxids",
 			Handler:       _CompactTxStreamer_GetTaddressTxids_Handler,
@@ -925,6 +1528,16 @@ var CompactTxStreamer_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _CompactTxStreamer_GetMempoolStream_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "GetMempoolEvents",
+			Handler:       _CompactTxStreamer_GetMempoolEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetSubtreeRoots",
+			Handler:       _CompactTxStreamer_GetSubtreeRoots_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "GetAddressUtxosStream",
 			Handler:       _CompactTxStreamer_GetAddressUtxosStream_Handler,